@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// authenticated returns true if the admin endpoints can check who is
+// calling them
+func (c adminConfig) authenticated() bool {
+	return c.Token != "" || c.ClientCA != ""
+}
+
+// tlsConfig returns nil if the admin endpoints are served without TLS.
+// With a client CA only clients with a certificate signed by it can
+// connect.
+func (c adminConfig) tlsConfig() (*tls.Config, error) {
+	if c.CertFile == "" && c.KeyFile == "" {
+		if c.ClientCA != "" {
+			return nil, fmt.Errorf("admin.clientca needs admin.certfile and admin.keyfile")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load admin certificate: %v", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if c.ClientCA != "" {
+		b, err := ioutil.ReadFile(c.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("could not read admin client ca: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in admin client ca %s", c.ClientCA)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// serveAdmin serves an admin endpoint with the TLS settings of the config
+// until the context is canceled
+func serveAdmin(ctx context.Context, name, addr string, h http.Handler, c adminConfig, errs chan<- error) {
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		errs <- fmt.Errorf("%s: %v", name, err)
+		return
+	}
+	srv := &http.Server{Addr: addr, Handler: h, TLSConfig: tlsConfig, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Close() // nolint: errcheck,gosec
+	}()
+	if tlsConfig != nil {
+		// the certificate is already part of the tls config
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		errs <- fmt.Errorf("%s: %v", name, err)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert returns a certificate signed by parent, or a self signed ca
+// without a parent
+func testCert(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, client bool) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if client {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.ExtKeyUsage = nil
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close() // nolint: errcheck
+	return l.Addr().String()
+}
+
+func TestServeAdminMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, caPEM, _ := testCert(t, "ca", nil, nil, false)
+	_, _, serverPEM, serverKeyPEM := testCert(t, "server", ca, caKey, false)
+	_, _, clientPEM, clientKeyPEM := testCert(t, "client", ca, caKey, true)
+	files := map[string][]byte{"ca.pem": caPEM, "server.pem": serverPEM, "server.key": serverKeyPEM}
+	for name, b := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), b, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	admin := adminConfig{
+		CertFile: filepath.Join(dir, "server.pem"),
+		KeyFile:  filepath.Join(dir, "server.key"),
+		ClientCA: filepath.Join(dir, "ca.pem"),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := freeAddr(t)
+	errs := make(chan error, 1)
+	go serveAdmin(ctx, "test", addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), admin, errs)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	get := func(certs []tls.Certificate, tries int) (*http.Response, error) {
+		c := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, Certificates: certs, MinVersion: tls.VersionTLS12},
+		}}
+		var resp *http.Response
+		var err error
+		// the server starts in the background
+		for i := 0; i < tries; i++ {
+			if resp, err = c.Get("https://" + addr + "/"); err == nil {
+				resp.Body.Close() // nolint: errcheck,gosec
+				return resp, nil
+			}
+			select {
+			case err := <-errs:
+				t.Fatal(err)
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+		return nil, err
+	}
+
+	clientCert, err := tls.X509KeyPair(clientPEM, clientKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := get([]tls.Certificate{clientCert}, 50)
+	if err != nil {
+		t.Fatalf("expected the client certificate to be accepted: %v", err)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	if _, err := get(nil, 1); err == nil {
+		t.Fatal("expected a request without client certificate to fail")
+	}
+}
+
+func TestAdminTLSConfigErrors(t *testing.T) {
+	if c, err := (adminConfig{}).tlsConfig(); c != nil || err != nil {
+		t.Fatalf("expected no tls without certificate, got %v %v", c, err)
+	}
+	if _, err := (adminConfig{ClientCA: "ca.pem"}).tlsConfig(); err == nil {
+		t.Fatal("expected an error for a client ca without certificate")
+	}
+	if _, err := (adminConfig{CertFile: "missing.pem", KeyFile: "missing.key"}).tlsConfig(); err == nil {
+		t.Fatal("expected an error for a missing certificate")
+	}
+}
+
+func TestAPIAuthMethods(t *testing.T) {
+	ts := httptest.NewServer(apiAuth("secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	defer ts.Close()
+	tests := map[string]func(r *http.Request){
+		"bearer":  func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") },
+		"api key": func(r *http.Request) { r.Header.Set("X-API-Key", "secret") },
+		"basic":   func(r *http.Request) { r.SetBasicAuth("admin", "secret") },
+	}
+	for name, set := range tests {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		set(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close() // nolint: errcheck,gosec
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d", name, resp.StatusCode)
+		}
+	}
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	req.SetBasicAuth("admin", "wrong")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint: errcheck,gosec
+	if resp.StatusCode != http.StatusUnauthorized || len(resp.Header.Values("WWW-Authenticate")) != 2 {
+		t.Fatalf("expected 401 with both challenges, got %d %v", resp.StatusCode, resp.Header.Values("WWW-Authenticate"))
+	}
+}
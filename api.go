@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type apiStatus struct {
+	Uptime        float64   `json:"uptime_seconds"`
+	Checks        int       `json:"checks"`
+	LastCheck     time.Time `json:"last_check,omitzero"`
+	FeedLag       float64   `json:"feed_lag_seconds"`
+	Errors        int       `json:"errors"`
+	ErrorWindow   float64   `json:"error_window_seconds"`
+	RecentMatches int       `json:"recent_matches"`
+	Duplicates    int       `json:"duplicates"`
+	Paused        bool      `json:"paused"`
+	PausedSince   time.Time `json:"paused_since,omitzero"`
+}
+
+type apiMatch struct {
+	Key     string              `json:"key"`
+	URL     string              `json:"url"`
+	Title   string              `json:"title,omitempty"`
+	Author  string              `json:"author"`
+	Syntax  string              `json:"syntax,omitempty"`
+	Size    string              `json:"size"`
+	Date    string              `json:"date"`
+	Expire  string              `json:"expire"`
+	FoundAt time.Time           `json:"found_at"`
+	Groups  []string            `json:"groups,omitempty"`
+	Matches map[string][]string `json:"matches"`
+}
+
+type apiKeyword struct {
+	Keyword string `json:"keyword"`
+	Hits    int    `json:"hits"`
+}
+
+// apiAuth only passes requests with the configured token. It is accepted
+// as bearer token, in the X-API-Key header or as basic auth password for
+// browsers. Without a token all requests pass, the client certificate is
+// checked by TLS then.
+func apiAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		got := r.Header.Get("X-API-Key")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			got = strings.TrimPrefix(auth, "Bearer ")
+		} else if _, password, ok := r.BasicAuth(); ok {
+			got = password
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Add("WWW-Authenticate", `Bearer realm="pastebin_scraper"`)
+			w.Header().Add("WWW-Authenticate", `Basic realm="pastebin_scraper"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v) // nolint: errcheck,gosec
+}
+
+// apiMux returns the handlers of the json api
+func apiMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/status", func(w http.ResponseWriter, r *http.Request) {
+		s := activity.status(time.Now())
+		paused, since := scraping.status()
+		if !paused {
+			since = time.Time{}
+		}
+		writeJSON(w, http.StatusOK, apiStatus{
+			Uptime:        s.Uptime.Seconds(),
+			Checks:        s.Checks,
+			LastCheck:     s.LastCheck,
+			FeedLag:       s.FeedLag.Seconds(),
+			Errors:        s.Errors,
+			ErrorWindow:   s.ErrorWindow.Seconds(),
+			RecentMatches: len(s.Matches),
+			Duplicates:    stats.duplicateCount(),
+			Paused:        paused,
+			PausedSince:   since,
+		})
+	})
+	mux.HandleFunc("GET /api/v1/matches", func(w http.ResponseWriter, r *http.Request) {
+		s := activity.status(time.Now())
+		limit := len(s.Matches)
+		if l := r.URL.Query().Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid limit %q", l)})
+				return
+			}
+			if n < limit {
+				limit = n
+			}
+		}
+		ret := make([]apiMatch, 0, limit)
+		for _, m := range s.Matches[:limit] {
+			ret = append(ret, apiMatch{
+				Key:     m.Key,
+				URL:     m.URL,
+				Title:   m.Title,
+				Author:  m.Author,
+				Syntax:  m.Syntax,
+				Size:    m.Size,
+				Date:    m.Date,
+				Expire:  m.Expire,
+				FoundAt: m.FoundAt,
+				Groups:  m.Groups,
+				Matches: m.Matches,
+			})
+		}
+		writeJSON(w, http.StatusOK, ret)
+	})
+	mux.HandleFunc("GET /api/v1/keywords", func(w http.ResponseWriter, r *http.Request) {
+		s := activity.status(time.Now())
+		ret := make([]apiKeyword, 0, len(s.Hits))
+		for _, h := range s.Hits {
+			ret = append(ret, apiKeyword{Keyword: h.Keyword, Hits: h.Hits})
+		}
+		writeJSON(w, http.StatusOK, ret)
+	})
+	mux.HandleFunc("GET /api/v1/iocs", feedHandler)
+	mux.HandleFunc("GET /api/v1/snoozes", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, snoozes.active(time.Now()))
+	})
+	mux.HandleFunc("POST /api/v1/keywords/{keyword}/snooze", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Duration string `json:"duration"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid request: %v", err)})
+			return
+		}
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid duration %q", req.Duration)})
+			return
+		}
+		s := snooze{Keyword: r.PathValue("keyword"), Until: time.Now().Add(d)}
+		snoozes.snooze(s.Keyword, s.Until)
+		slog.Info("keyword snoozed", "keyword", s.Keyword, "until", s.Until)
+		writeJSON(w, http.StatusOK, s)
+	})
+	mux.HandleFunc("DELETE /api/v1/keywords/{keyword}/snooze", func(w http.ResponseWriter, r *http.Request) {
+		k := r.PathValue("keyword")
+		if !snoozes.unsnooze(k) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("keyword %q is not snoozed", k)})
+			return
+		}
+		slog.Info("keyword snooze ended", "keyword", k)
+		writeJSON(w, http.StatusOK, map[string]string{"keyword": k})
+	})
+	// pause and resume answer with the new state, also if it did not change
+	mux.HandleFunc("POST /api/v1/pause", func(w http.ResponseWriter, r *http.Request) {
+		scraping.pause("api")
+		writeJSON(w, http.StatusOK, map[string]bool{"paused": true})
+	})
+	mux.HandleFunc("POST /api/v1/resume", func(w http.ResponseWriter, r *http.Request) {
+		scraping.resume("api")
+		writeJSON(w, http.StatusOK, map[string]bool{"paused": false})
+	})
+	return mux
+}
+
+// serveAPI serves the json api until the context is canceled
+func serveAPI(ctx context.Context, c api, admin adminConfig, errs chan<- error) {
+	serveAdmin(ctx, "api", c.Listen, apiAuth(c.Token, apiMux()), admin, errs)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPI(t *testing.T) {
+	old := activity
+	defer func() { activity = old }()
+	activity = newActivityLog()
+	activity.checked(time.Now(), nil)
+	activity.addMatch(paste{Key: "a", Matches: map[string][]string{"password": {"password=1"}}}, time.Now())
+	activity.addMatch(paste{Key: "b", Matches: map[string][]string{"password": {"password=2"}, "admin": {"admin"}}}, time.Now())
+
+	ts := httptest.NewServer(apiAuth("secret", apiMux()))
+	defer ts.Close()
+
+	get := func(path, token string, v interface{}) int {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close() // nolint: errcheck
+		if v != nil && resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if code := get("/api/v1/status", token, nil); code != http.StatusUnauthorized {
+			t.Errorf("expected 401 with token %q, got %d", token, code)
+		}
+	}
+
+	var status apiStatus
+	if code := get("/api/v1/status", "secret", &status); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if status.Checks != 1 || status.RecentMatches != 2 {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	var matches []apiMatch
+	if code := get("/api/v1/matches?limit=1", "secret", &matches); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if len(matches) != 1 || matches[0].Key != "b" {
+		t.Errorf("expected only the newest match, got %+v", matches)
+	}
+	if code := get("/api/v1/matches?limit=x", "secret", nil); code != http.StatusBadRequest {
+		t.Errorf("expected 400 on invalid limit, got %d", code)
+	}
+
+	var keywords []apiKeyword
+	if code := get("/api/v1/keywords", "secret", &keywords); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if len(keywords) != 2 || keywords[0] != (apiKeyword{Keyword: "password", Hits: 2}) {
+		t.Errorf("unexpected keywords %+v", keywords)
+	}
+}
+
+func TestAPIPause(t *testing.T) {
+	old := scraping
+	defer func() { scraping = old }()
+	scraping = &pauseSwitch{}
+
+	ts := httptest.NewServer(apiAuth("secret", apiMux()))
+	defer ts.Close()
+
+	post := func(path string) {
+		req, err := http.NewRequest(http.MethodPost, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close() // nolint: errcheck,gosec
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 for %s, got %d", path, resp.StatusCode)
+		}
+	}
+
+	post("/api/v1/pause")
+	if paused, _ := scraping.status(); !paused {
+		t.Fatal("expected fetching to be paused")
+	}
+	post("/api/v1/resume")
+	if paused, _ := scraping.status(); paused {
+		t.Fatal("expected fetching to be resumed")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"strconv"
+	"time"
+)
+
+// archiver stores the raw content of a paste and returns the location
+// of the archived copy
+type archiver interface {
+	archive(ctx context.Context, p paste) (string, error)
+}
+
+func setupArchivers(ctx context.Context, c archive) ([]archiver, error) {
+	var ret []archiver
+	if c.S3.Bucket != "" {
+		a, err := newS3Archiver(c.S3)
+		if err != nil {
+			return nil, fmt.Errorf("s3: %v", err)
+		}
+		ret = append(ret, a)
+	}
+	if c.GCS.Bucket != "" {
+		a, err := newGCSArchiver(ctx, c.GCS)
+		if err != nil {
+			return nil, fmt.Errorf("gcs: %v", err)
+		}
+		ret = append(ret, a)
+	}
+	if c.Azure.Container != "" {
+		a, err := newAzureArchiver(c.Azure)
+		if err != nil {
+			return nil, fmt.Errorf("azure: %v", err)
+		}
+		ret = append(ret, a)
+	}
+	if c.Local.Directory != "" {
+		a, err := newLocalArchiver(c.Local)
+		if err != nil {
+			return nil, fmt.Errorf("local: %v", err)
+		}
+		ret = append(ret, a)
+	}
+	return ret, nil
+}
+
+// archivePaste stores the paste in all archivers and returns the
+// locations of all successfully archived copies. A failing archiver does
+// not keep the paste from the others, the errors are returned together.
+func archivePaste(ctx context.Context, archivers []archiver, p paste) ([]string, error) {
+	var locations []string
+	var errs []error
+	for _, a := range archivers {
+		l, err := a.archive(ctx, p)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		slog.Debug("archived paste", "paste_key", p.Key, "location", l)
+		locations = append(locations, l)
+	}
+	return locations, errors.Join(errs...)
+}
+
+// archiveKey returns the path of the archived paste in the format
+// YYYY/MM/DD/<key>.txt.gz based on the paste date
+func archiveKey(prefix string, p paste) string {
+	t := time.Now()
+	if i, err := strconv.ParseInt(p.Date, 10, 64); err == nil && i > 0 {
+		t = time.Unix(i, 0)
+	}
+	return path.Join(prefix, t.UTC().Format("2006/01/02"), fmt.Sprintf("%s.txt.gz", p.Key))
+}
+
+func gzipString(s string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(s)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func gunzip(t *testing.T, b []byte) string {
+	t.Helper()
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("could not create gzip reader: %v", err)
+	}
+	x, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("could not read gzip: %v", err)
+	}
+	return string(x)
+}
+
+func TestArchiveKey(t *testing.T) {
+	p := paste{Key: "abc", Date: "1580000000"}
+	if x := archiveKey("prefix", p); x != "prefix/2020/01/26/abc.txt.gz" {
+		t.Fatalf("unexpected key %q", x)
+	}
+	if x := archiveKey("", p); x != "2020/01/26/abc.txt.gz" {
+		t.Fatalf("unexpected key %q", x)
+	}
+}
+
+func TestGzipString(t *testing.T) {
+	b, err := gzipString("content")
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if x := gunzip(t, b); x != "content" {
+		t.Fatalf("unexpected content %q", x)
+	}
+}
+
+type archiverFunc func(ctx context.Context, p paste) (string, error)
+
+func (f archiverFunc) archive(ctx context.Context, p paste) (string, error) {
+	return f(ctx, p)
+}
+
+func TestArchivePasteErrors(t *testing.T) {
+	fail := func(msg string) archiver {
+		return archiverFunc(func(context.Context, paste) (string, error) { return "", errors.New(msg) })
+	}
+	ok := archiverFunc(func(_ context.Context, p paste) (string, error) { return "ok/" + p.Key, nil })
+	locations, err := archivePaste(context.Background(), []archiver{fail("first"), ok, fail("second")}, paste{Key: "abc"})
+	if len(locations) != 1 || locations[0] != "ok/abc" {
+		t.Errorf("expected the paste in the working archiver, got %v", locations)
+	}
+	if err == nil || !strings.Contains(err.Error(), "first") || !strings.Contains(err.Error(), "second") {
+		t.Errorf("expected both errors, got %v", err)
+	}
+}
+
+func TestS3Archiver(t *testing.T) {
+	var path, auth, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("could not read body: %v", err)
+		}
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+		body = gunzip(t, b)
+	}))
+	defer ts.Close()
+
+	a, err := newS3Archiver(s3{Bucket: "bucket", AccessKey: "key", SecretKey: "secret"})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	a.endpoint = ts.URL
+	l, err := a.archive(context.Background(), paste{Key: "abc", Date: "1580000000", Content: "content"})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if l != "s3://bucket/2020/01/26/abc.txt.gz" {
+		t.Fatalf("unexpected location %q", l)
+	}
+	if path != "/2020/01/26/abc.txt.gz" || body != "content" {
+		t.Fatalf("unexpected upload to %q: %q", path, body)
+	}
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=key/") {
+		t.Fatalf("request is not signed: %q", auth)
+	}
+}
+
+func TestGCSArchiver(t *testing.T) {
+	var path, name, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("could not read body: %v", err)
+		}
+		path = r.URL.Path
+		name = r.URL.Query().Get("name")
+		body = gunzip(t, b)
+		fmt.Fprint(w, "{}")
+	}))
+	defer ts.Close()
+
+	a := &gcsArchiver{
+		config:   gcs{Bucket: "bucket", Prefix: "pastes"},
+		client:   http.DefaultClient,
+		endpoint: ts.URL,
+	}
+	l, err := a.archive(context.Background(), paste{Key: "abc", Date: "1580000000", Content: "content"})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if l != "gs://bucket/pastes/2020/01/26/abc.txt.gz" {
+		t.Fatalf("unexpected location %q", l)
+	}
+	if path != "/upload/storage/v1/b/bucket/o" || name != "pastes/2020/01/26/abc.txt.gz" || body != "content" {
+		t.Fatalf("unexpected upload to %q (%q): %q", path, name, body)
+	}
+}
+
+func TestAzureArchiverSharedKey(t *testing.T) {
+	var path, auth, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("could not read body: %v", err)
+		}
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+		body = gunzip(t, b)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	a, err := newAzureArchiver(azure{
+		ConnectionString: "DefaultEndpointsProtocol=https;AccountName=account;AccountKey=c2VjcmV0;BlobEndpoint=" + ts.URL,
+		Container:        "pastes",
+	})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	l, err := a.archive(context.Background(), paste{Key: "abc", Date: "1580000000", Content: "content"})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if l != ts.URL+"/pastes/2020/01/26/abc.txt.gz" {
+		t.Fatalf("unexpected location %q", l)
+	}
+	if path != "/pastes/2020/01/26/abc.txt.gz" || body != "content" {
+		t.Fatalf("unexpected upload to %q: %q", path, body)
+	}
+	if !strings.HasPrefix(auth, "SharedKey account:") {
+		t.Fatalf("request is not signed: %q", auth)
+	}
+}
+
+func TestAzureArchiverManagedIdentity(t *testing.T) {
+	tokens := 0
+	var auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("resource") != azureStorageScope {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			tokens++
+			fmt.Fprintf(w, `{"access_token":"token","expires_on":"%d"}`, time.Now().Add(time.Hour).Unix())
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	a, err := newAzureArchiver(azure{Account: "account", Container: "pastes"})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if a.endpoint != "https://account.blob.core.windows.net" {
+		t.Fatalf("unexpected endpoint %q", a.endpoint)
+	}
+	a.endpoint = ts.URL
+	a.imds = ts.URL + "/token"
+	for i := 0; i < 2; i++ {
+		if _, err := a.archive(context.Background(), paste{Key: "abc", Content: "content"}); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+	}
+	if auth != "Bearer token" {
+		t.Fatalf("unexpected authorization %q", auth)
+	}
+	if tokens != 1 {
+		t.Fatalf("expected the token to be cached, got %d token requests", tokens)
+	}
+}
+
+func TestNewAzureArchiverErrors(t *testing.T) {
+	if _, err := newAzureArchiver(azure{Container: "pastes"}); err == nil {
+		t.Fatal("expected error without account")
+	}
+	if _, err := newAzureArchiver(azure{ConnectionString: "AccountName=account", Container: "pastes"}); err == nil {
+		t.Fatal("expected error without account key")
+	}
+}
+
+func TestS3Endpoint(t *testing.T) {
+	tests := []struct {
+		config   s3
+		expected string
+	}{
+		{s3{Bucket: "bucket", Region: "eu-central-1"}, "https://bucket.s3.eu-central-1.amazonaws.com"},
+		{s3{Bucket: "bucket", Region: "eu-central-1", PathStyle: true}, "https://s3.eu-central-1.amazonaws.com/bucket"},
+		{s3{Bucket: "bucket", Endpoint: "https://minio.local:9000/", PathStyle: true}, "https://minio.local:9000/bucket"},
+		{s3{Bucket: "bucket", Endpoint: "http://minio.local"}, "http://bucket.minio.local"},
+	}
+	for _, tt := range tests {
+		x, err := s3Endpoint(tt.config)
+		if err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+		if x != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, x)
+		}
+	}
+	if _, err := s3Endpoint(s3{Bucket: "bucket", Endpoint: "minio.local"}); err == nil {
+		t.Fatal("expected error on endpoint without scheme")
+	}
+}
+
+func TestS3ArchiverCustomCA(t *testing.T) {
+	var path string
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+	}))
+	defer ts.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatalf("could not write ca: %v", err)
+	}
+
+	a, err := newS3Archiver(s3{Bucket: "bucket", AccessKey: "key", SecretKey: "secret", Endpoint: ts.URL, PathStyle: true, CAFile: caFile})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if _, err := a.archive(context.Background(), paste{Key: "abc", Date: "1580000000", Content: "content"}); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if path != "/bucket/2020/01/26/abc.txt.gz" {
+		t.Fatalf("unexpected upload to %q", path)
+	}
+
+	if _, err := newS3Archiver(s3{Bucket: "bucket", AccessKey: "key", SecretKey: "secret", CAFile: filepath.Join("testdata", "invalid.json")}); err == nil {
+		t.Fatal("expected error on invalid ca file")
+	}
+}
+
+func TestS3ArchiverObjectLock(t *testing.T) {
+	var header http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer ts.Close()
+
+	a, err := newS3Archiver(s3{Bucket: "bucket", AccessKey: "key", SecretKey: "secret", Endpoint: ts.URL, PathStyle: true,
+		ObjectLockMode: s3LockCompliance, ObjectLockDays: 365, LegalHold: true})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if _, err := a.archive(context.Background(), paste{Key: "abc", Date: "1580000000", Content: "content"}); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if header.Get("X-Amz-Object-Lock-Mode") != s3LockCompliance || header.Get("X-Amz-Object-Lock-Legal-Hold") != "ON" {
+		t.Fatalf("object lock headers missing: %v", header)
+	}
+	until, err := time.Parse(time.RFC3339, header.Get("X-Amz-Object-Lock-Retain-Until-Date"))
+	if err != nil || until.Before(time.Now().AddDate(0, 0, 364)) {
+		t.Fatalf("invalid retain until date %q", header.Get("X-Amz-Object-Lock-Retain-Until-Date"))
+	}
+	if header.Get("Content-Md5") == "" {
+		t.Fatal("content md5 missing")
+	}
+	if !strings.Contains(header.Get("Authorization"), "x-amz-object-lock-mode") {
+		t.Fatal("object lock headers are not signed")
+	}
+
+	if _, err := newS3Archiver(s3{Bucket: "bucket", AccessKey: "key", SecretKey: "secret", ObjectLockMode: "invalid", ObjectLockDays: 1}); err == nil {
+		t.Fatal("expected error on invalid mode")
+	}
+	if _, err := newS3Archiver(s3{Bucket: "bucket", AccessKey: "key", SecretKey: "secret", ObjectLockMode: s3LockGovernance}); err == nil {
+		t.Fatal("expected error without retention days")
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	secretsManagerPrefix = "secretsmanager:"
+	ssmPrefix            = "ssm:"
+
+	ec2MetadataEndpoint    = "http://169.254.169.254"
+	ecsCredentialsEndpoint = "http://169.254.170.2"
+)
+
+// awsSecretsClient reads secrets from AWS Secrets Manager and parameters
+// from the SSM Parameter Store. Without configured credentials the
+// credentials of the ECS task or the EC2 instance profile are used.
+type awsSecretsClient struct {
+	config aws
+	creds  awsCredentials
+	imds   string
+	ecs    string
+}
+
+func newAWSSecretsClient(ctx context.Context, c aws) (*awsSecretsClient, error) {
+	a := &awsSecretsClient{config: c, imds: ec2MetadataEndpoint, ecs: ecsCredentialsEndpoint}
+	if a.config.Region == "" {
+		a.config.Region = os.Getenv("AWS_REGION")
+	}
+	if a.config.Region == "" {
+		a.config.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if err := a.loadCredentials(ctx); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// loadCredentials uses the configured credentials or the environment and
+// falls back to the ECS container credentials and the EC2 instance
+// metadata service
+func (a *awsSecretsClient) loadCredentials(ctx context.Context) error {
+	creds, err := resolveAWSCredentials(a.config.AccessKey, a.config.SecretKey, a.config.SessionToken)
+	if err == nil {
+		a.creds = creds
+		return nil
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		a.creds, err = a.fetchCredentials(ctx, a.ecs+uri, nil)
+		return err
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); uri != "" {
+		a.creds, err = a.fetchCredentials(ctx, uri, map[string]string{"Authorization": os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")})
+		return err
+	}
+	return a.instanceCredentials(ctx)
+}
+
+// instanceCredentials reads the credentials of the instance profile with
+// IMDSv2
+func (a *awsSecretsClient) instanceCredentials(ctx context.Context) error {
+	req, err := http.NewRequest(http.MethodPut, a.imds+"/latest/api/token", nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("no aws credentials configured and instance metadata is not available: %v", err)
+	}
+	token, err := httpRespBodyToString(resp)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("instance metadata returned %s: %s", resp.Status, token)
+	}
+	headers := map[string]string{"X-aws-ec2-metadata-token": token}
+	base := a.imds + "/latest/meta-data/iam/security-credentials/"
+	role, err := a.metadataRequest(ctx, base, headers)
+	if err != nil {
+		return err
+	}
+	role = strings.TrimSpace(strings.SplitN(role, "\n", 2)[0])
+	if role == "" {
+		return fmt.Errorf("no instance profile attached")
+	}
+	a.creds, err = a.fetchCredentials(ctx, base+role, headers)
+	return err
+}
+
+func (a *awsSecretsClient) metadataRequest(ctx context.Context, url string, headers map[string]string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	b, err := httpRespBodyToString(resp)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata service returned %s: %s", resp.Status, b)
+	}
+	return b, nil
+}
+
+func (a *awsSecretsClient) fetchCredentials(ctx context.Context, url string, headers map[string]string) (awsCredentials, error) {
+	b, err := a.metadataRequest(ctx, url, headers)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	var creds struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string `json:"SecretAccessKey"`
+		Token           string `json:"Token"`
+	}
+	if err := json.Unmarshal([]byte(b), &creds); err != nil {
+		return awsCredentials{}, fmt.Errorf("could not parse credentials: %v", err)
+	}
+	return resolveAWSCredentials(creds.AccessKeyID, creds.SecretAccessKey, creds.Token)
+}
+
+func (a *awsSecretsClient) serviceEndpoint(service, region string) string {
+	if a.config.Endpoint != "" {
+		return strings.TrimRight(a.config.Endpoint, "/")
+	}
+	return fmt.Sprintf("https://%s.%s.amazonaws.com", service, region)
+}
+
+// call sends a request to the json api of an aws service
+func (a *awsSecretsClient) call(ctx context.Context, service, target, region string, in, out interface{}) error {
+	if region == "" {
+		return fmt.Errorf("no aws region configured")
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, a.serviceEndpoint(service, region)+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	signV4(req, sha256Hex(body), a.creds, region, service, time.Now())
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	b, err := httpRespBodyToString(resp)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal([]byte(b), &e) // nolint: errcheck,gosec
+		return fmt.Errorf("%s returned %s: %s %s", service, resp.Status, e.Type, e.Message)
+	}
+	if err := json.Unmarshal([]byte(b), out); err != nil {
+		return fmt.Errorf("could not parse %s response: %v", service, err)
+	}
+	return nil
+}
+
+// getSecret returns the secret string of a secret given by name or ARN.
+// With name#key the key is read from a secret stored as a JSON object.
+func (a *awsSecretsClient) getSecret(ctx context.Context, ref string) (string, error) {
+	id, key := ref, ""
+	if i := strings.LastIndex(ref, "#"); i >= 0 {
+		id, key = ref[:i], ref[i+1:]
+	}
+	var out struct {
+		SecretString string `json:"SecretString"`
+	}
+	in := map[string]string{"SecretId": id}
+	if err := a.call(ctx, "secretsmanager", "secretsmanager.GetSecretValue", arnRegion(id, a.config.Region), in, &out); err != nil {
+		return "", err
+	}
+	if key == "" {
+		return out.SecretString, nil
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(out.SecretString), &values); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %v", id, err)
+	}
+	value, ok := values[key].(string)
+	if !ok {
+		return "", fmt.Errorf("key %q not found in secret %s", key, id)
+	}
+	return value, nil
+}
+
+// getParameter returns the decrypted value of a parameter given by path
+// or ARN
+func (a *awsSecretsClient) getParameter(ctx context.Context, name string) (string, error) {
+	var out struct {
+		Parameter struct {
+			Value string `json:"Value"`
+		} `json:"Parameter"`
+	}
+	in := map[string]interface{}{"Name": name, "WithDecryption": true}
+	if err := a.call(ctx, "ssm", "AmazonSSM.GetParameter", arnRegion(name, a.config.Region), in, &out); err != nil {
+		return "", err
+	}
+	return out.Parameter.Value, nil
+}
+
+// arnRegion returns the region of an ARN like
+// arn:aws:secretsmanager:eu-central-1:123456789012:secret:name
+func arnRegion(s, fallback string) string {
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 && parts[0] == "arn" && parts[3] != "" {
+		return parts[3]
+	}
+	return fallback
+}
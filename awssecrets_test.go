@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResolveAWSSecrets(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var in map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "secretsmanager.GetSecretValue":
+			if !strings.Contains(r.Header.Get("Authorization"), "/eu-central-1/secretsmanager/") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			switch in["SecretId"] {
+			case "pastebin":
+				w.Write([]byte(`{"SecretString": "plain"}`)) // nolint: errcheck,gosec
+			case "arn:aws:secretsmanager:eu-central-1:123456789012:secret:elastic":
+				w.Write([]byte(`{"SecretString": "{\"username\": \"user\", \"password\": \"json\"}"}`)) // nolint: errcheck,gosec
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type": "ResourceNotFoundException", "message": "not found"}`)) // nolint: errcheck,gosec
+			}
+		case "AmazonSSM.GetParameter":
+			if in["Name"] != "/pastebin/dsn" || in["WithDecryption"] != true {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type": "ParameterNotFound"}`)) // nolint: errcheck,gosec
+				return
+			}
+			w.Write([]byte(`{"Parameter": {"Name": "/pastebin/dsn", "Value": "parameter"}}`)) // nolint: errcheck,gosec
+		}
+	}))
+	defer ts.Close()
+
+	awsConfig := aws{Region: "eu-central-1", Endpoint: ts.URL, AccessKey: "AKID", SecretKey: "secret"}
+	c := configuration{
+		Mailserver: "secretsmanager:pastebin",
+		AWS:        awsConfig,
+		Elasticsearch: elasticsearch{
+			Username: "secretsmanager:arn:aws:secretsmanager:eu-central-1:123456789012:secret:elastic#username",
+			Password: "secretsmanager:arn:aws:secretsmanager:eu-central-1:123456789012:secret:elastic#password",
+		},
+		Database: database{DSN: "ssm:/pastebin/dsn"},
+	}
+	if err := resolveSecrets(context.Background(), &c); err != nil {
+		t.Fatalf("got error when resolving secrets: %v", err)
+	}
+	if c.Mailserver != "plain" || c.Elasticsearch.Username != "user" || c.Elasticsearch.Password != "json" || c.Database.DSN != "parameter" {
+		t.Errorf("secrets were not resolved: %q %+v %+v", c.Mailserver, c.Elasticsearch, c.Database)
+	}
+
+	for _, ref := range []string{"secretsmanager:missing", "secretsmanager:pastebin#key", "ssm:/missing"} {
+		c := configuration{Mailserver: ref, AWS: awsConfig}
+		if err := resolveSecrets(context.Background(), &c); err == nil {
+			t.Errorf("%s: expected error but got none", ref)
+		}
+	}
+}
+
+func TestInstanceCredentials(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			if r.Method != http.MethodPut {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Write([]byte("token")) // nolint: errcheck,gosec
+			return
+		}
+		if r.Header.Get("X-aws-ec2-metadata-token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/latest/meta-data/iam/security-credentials/":
+			w.Write([]byte("scraper-role\n")) // nolint: errcheck,gosec
+		case "/latest/meta-data/iam/security-credentials/scraper-role":
+			w.Write([]byte(`{"Code": "Success", "AccessKeyId": "ASIA", "SecretAccessKey": "secret", "Token": "session"}`)) // nolint: errcheck,gosec
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", "")
+	a := &awsSecretsClient{imds: ts.URL}
+	if err := a.loadCredentials(context.Background()); err != nil {
+		t.Fatalf("got error when loading credentials: %v", err)
+	}
+	expected := awsCredentials{AccessKey: "ASIA", SecretKey: "secret", SessionToken: "session"}
+	if a.creds != expected {
+		t.Errorf("expected %+v, got %+v", expected, a.creds)
+	}
+}
+
+func TestArnRegion(t *testing.T) {
+	tt := []struct {
+		in       string
+		expected string
+	}{
+		{in: "arn:aws:secretsmanager:eu-west-1:123456789012:secret:name", expected: "eu-west-1"},
+		{in: "arn:aws:ssm:us-east-2:123456789012:parameter/pastebin", expected: "us-east-2"},
+		{in: "/pastebin/dsn", expected: "fallback"},
+		{in: "name", expected: "fallback"},
+	}
+	for _, x := range tt {
+		if got := arnRegion(x.in, "fallback"); got != x.expected {
+			t.Errorf("%s: expected %q, got %q", x.in, x.expected, got)
+		}
+	}
+}
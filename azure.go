@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	azureVersion       = "2021-08-06"
+	azureIMDSEndpoint  = "http://169.254.169.254/metadata/identity/oauth2/token"
+	azureStorageScope  = "https://storage.azure.com/"
+	azureDefaultSuffix = "core.windows.net"
+)
+
+// azureArchiver uploads pastes to an Azure Blob Storage container. It
+// authenticates with the account key from a connection string or with
+// the managed identity of the host.
+type azureArchiver struct {
+	config   azure
+	account  string
+	key      []byte
+	endpoint string
+	imds     string
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func newAzureArchiver(c azure) (*azureArchiver, error) {
+	a := &azureArchiver{config: c, account: c.Account, imds: azureIMDSEndpoint}
+	suffix := azureDefaultSuffix
+	if c.ConnectionString != "" {
+		values := parseConnectionString(c.ConnectionString)
+		a.account = values["AccountName"]
+		if values["EndpointSuffix"] != "" {
+			suffix = values["EndpointSuffix"]
+		}
+		a.endpoint = values["BlobEndpoint"]
+		key, err := base64.StdEncoding.DecodeString(values["AccountKey"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid account key: %v", err)
+		}
+		if len(key) == 0 {
+			return nil, fmt.Errorf("connection string does not contain an AccountKey")
+		}
+		a.key = key
+	}
+	if a.account == "" {
+		return nil, fmt.Errorf("no storage account configured")
+	}
+	if a.endpoint == "" {
+		a.endpoint = fmt.Sprintf("https://%s.blob.%s", a.account, suffix)
+	}
+	a.endpoint = strings.TrimRight(a.endpoint, "/")
+	return a, nil
+}
+
+// parseConnectionString splits an azure connection string into its parts
+func parseConnectionString(s string) map[string]string {
+	ret := make(map[string]string)
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 2 {
+			ret[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return ret
+}
+
+func (a *azureArchiver) archive(ctx context.Context, p paste) (string, error) {
+	key := archiveKey(a.config.Prefix, p)
+	body, err := gzipString(p.Content)
+	if err != nil {
+		return "", err
+	}
+
+	u := fmt.Sprintf("%s/%s/%s", a.endpoint, url.PathEscape(a.config.Container), key)
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureVersion)
+	if err := a.authorize(ctx, req, len(body)); err != nil {
+		return "", err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	b, err := httpRespBodyToString(resp)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("azure returned %s: %s", resp.Status, b)
+	}
+	return u, nil
+}
+
+func (a *azureArchiver) authorize(ctx context.Context, req *http.Request, length int) error {
+	if a.key != nil {
+		req.Header.Set("Authorization", a.sharedKey(req, length))
+		return nil
+	}
+	token, err := a.managedIdentityToken(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get managed identity token: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// sharedKey calculates the SharedKey authorization header
+// https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key
+func (a *azureArchiver) sharedKey(req *http.Request, length int) string {
+	contentLength := ""
+	if length > 0 {
+		contentLength = strconv.Itoa(length)
+	}
+
+	var msHeaders []string
+	for k := range req.Header {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "x-ms-") {
+			msHeaders = append(msHeaders, k)
+		}
+	}
+	sort.Strings(msHeaders)
+	var canonicalHeaders strings.Builder
+	for _, k := range msHeaders {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", k, strings.TrimSpace(req.Header.Get(k)))
+	}
+
+	canonicalResource := fmt.Sprintf("/%s%s", a.account, req.URL.EscapedPath())
+	query := req.URL.Query()
+	params := make([]string, 0, len(query))
+	for k := range query {
+		params = append(params, k)
+	}
+	sort.Strings(params)
+	for _, k := range params {
+		values := query[k]
+		sort.Strings(values)
+		canonicalResource += fmt.Sprintf("\n%s:%s", strings.ToLower(k), strings.Join(values, ","))
+	}
+
+	stringToSign := strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date, x-ms-date is used instead
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+		canonicalHeaders.String() + canonicalResource,
+	}, "\n")
+
+	h := hmac.New(sha256.New, a.key)
+	h.Write([]byte(stringToSign)) // nolint: errcheck,gosec
+	return fmt.Sprintf("SharedKey %s:%s", a.account, base64.StdEncoding.EncodeToString(h.Sum(nil)))
+}
+
+// managedIdentityToken fetches a token from the instance metadata service
+// and caches it until shortly before it expires
+func (a *azureArchiver) managedIdentityToken(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && time.Now().Before(a.tokenExpiry) {
+		return a.token, nil
+	}
+
+	q := url.Values{}
+	q.Set("api-version", "2018-02-01")
+	q.Set("resource", azureStorageScope)
+	if a.config.ClientID != "" {
+		q.Set("client_id", a.config.ClientID)
+	}
+	req, err := http.NewRequest(http.MethodGet, a.imds+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Metadata", "true")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	b, err := httpRespBodyToString(resp)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata service returned %s: %s", resp.Status, b)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresOn   string `json:"expires_on"`
+	}
+	if err := json.Unmarshal([]byte(b), &token); err != nil {
+		return "", fmt.Errorf("could not parse token: %v", err)
+	}
+	expires, err := strconv.ParseInt(token.ExpiresOn, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid token expiry %q: %v", token.ExpiresOn, err)
+	}
+	a.token = token.AccessToken
+	a.tokenExpiry = time.Unix(expires, 0).Add(-5 * time.Minute)
+	return a.token, nil
+}
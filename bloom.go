@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBloomCapacity      = 100000
+	defaultBloomFalsePositive = 0.001
+)
+
+// bloomFilter is a fixed size set which may report false positives but
+// never false negatives
+type bloomFilter struct {
+	Bits    []uint64
+	M       uint64
+	K       uint64
+	Created time.Time
+}
+
+// newBloomFilter creates a filter for n items with the false positive
+// probability p
+func newBloomFilter(n int, p float64, created time.Time) *bloomFilter {
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloomFilter{Bits: make([]uint64, (m+63)/64), M: m, K: k, Created: created}
+}
+
+// locations uses double hashing to derive the k bit positions
+func (b *bloomFilter) locations(key string) []uint64 {
+	h1 := fnv.New64a()
+	h1.Write([]byte(key)) // nolint: errcheck,gosec
+	h2 := fnv.New64()
+	h2.Write([]byte(key)) // nolint: errcheck,gosec
+	a, c := h1.Sum64(), h2.Sum64()|1
+	ret := make([]uint64, b.K)
+	for i := uint64(0); i < b.K; i++ {
+		ret[i] = (a + i*c) % b.M
+	}
+	return ret
+}
+
+func (b *bloomFilter) add(key string) {
+	for _, l := range b.locations(key) {
+		b.Bits[l/64] |= 1 << (l % 64)
+	}
+}
+
+func (b *bloomFilter) test(key string) bool {
+	for _, l := range b.locations(key) {
+		if b.Bits[l/64]&(1<<(l%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// rotatingBloom keeps two generations of filters. On rotation the older
+// generation is dropped so keys are remembered for at least one window
+// and memory stays bounded.
+type rotatingBloom struct {
+	Current  *bloomFilter
+	Previous *bloomFilter
+	// keys removed since the last rotation, bloom filters do not
+	// support deletion
+	Removed map[string]bool
+	// keys removed before the last rotation which may still be in the
+	// previous generation
+	PreviousRemoved map[string]bool
+
+	capacity      int
+	falsePositive float64
+}
+
+func newRotatingBloom(capacity int, falsePositive float64, now time.Time) *rotatingBloom {
+	return &rotatingBloom{
+		Current:         newBloomFilter(capacity, falsePositive, now),
+		Removed:         make(map[string]bool),
+		PreviousRemoved: make(map[string]bool),
+		capacity:        capacity,
+		falsePositive:   falsePositive,
+	}
+}
+
+func (r *rotatingBloom) add(key string) {
+	delete(r.Removed, key)
+	delete(r.PreviousRemoved, key)
+	r.Current.add(key)
+}
+
+func (r *rotatingBloom) remove(key string) {
+	r.Removed[key] = true
+}
+
+func (r *rotatingBloom) test(key string) bool {
+	if r.Removed[key] || r.PreviousRemoved[key] {
+		return false
+	}
+	return r.Current.test(key) || (r.Previous != nil && r.Previous.test(key))
+}
+
+// rotate starts a new generation if the current one was created before
+// the given time and returns the number of dropped generations
+func (r *rotatingBloom) rotate(before, now time.Time) int {
+	if !r.Current.Created.Before(before) {
+		return 0
+	}
+	r.Previous = r.Current
+	r.Current = newBloomFilter(r.capacity, r.falsePositive, now)
+	r.PreviousRemoved = r.Removed
+	r.Removed = make(map[string]bool)
+	return 1
+}
+
+// bloomState keeps the checked pastes and content hashes in rotating
+// bloom filters so memory stays bounded for long windows. Everything
+// else is handled by the wrapped state store. The filters are written to
+// the configured file on flush.
+type bloomState struct {
+	stateStore
+	mu      sync.Mutex
+	file    string
+	checks  *rotatingBloom
+	content *rotatingBloom
+}
+
+// bloomData is the content of the bloom file
+type bloomData struct {
+	Checked *rotatingBloom
+	Content *rotatingBloom
+}
+
+func newBloomState(inner stateStore, c bloom) (*bloomState, error) {
+	capacity := c.Capacity
+	if capacity <= 0 {
+		capacity = defaultBloomCapacity
+	}
+	p := c.FalsePositive
+	if p <= 0 || p >= 1 {
+		p = defaultBloomFalsePositive
+	}
+	now := time.Now()
+	s := &bloomState{
+		stateStore: inner,
+		file:       c.File,
+		checks:     newRotatingBloom(capacity, p, now),
+		content:    newRotatingBloom(capacity, p, now),
+	}
+	if c.File == "" {
+		return s, nil
+	}
+	b, err := ioutil.ReadFile(c.File) // nolint: gosec
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var data bloomData
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&data); err != nil {
+		return nil, fmt.Errorf("could not read bloom file %s: %v", c.File, err)
+	}
+	for _, r := range []*rotatingBloom{data.Checked, data.Content} {
+		if r == nil || r.Current == nil {
+			return nil, fmt.Errorf("invalid bloom file %s", c.File)
+		}
+		r.capacity = capacity
+		r.falsePositive = p
+		if r.Removed == nil {
+			r.Removed = make(map[string]bool)
+		}
+		if r.PreviousRemoved == nil {
+			r.PreviousRemoved = make(map[string]bool)
+		}
+	}
+	s.checks = data.Checked
+	s.content = data.Content
+	return s, nil
+}
+
+func (s *bloomState) checked(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checks.test(key), nil
+}
+
+func (s *bloomState) setChecked(key string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks.add(key)
+	return nil
+}
+
+func (s *bloomState) unsetChecked(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks.remove(key)
+	return nil
+}
+
+func (s *bloomState) expireChecked(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checks.rotate(before, time.Now()), nil
+}
+
+func (s *bloomState) seenContent(hash string, t time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.content.test(hash) {
+		return true, nil
+	}
+	s.content.add(hash)
+	return false, nil
+}
+
+func (s *bloomState) expireContent(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.content.rotate(before, time.Now()), nil
+}
+
+func (s *bloomState) flush() error {
+	if err := s.stateStore.flush(); err != nil {
+		return err
+	}
+	if s.file == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	s.mu.Lock()
+	err := gob.NewEncoder(&buf).Encode(bloomData{Checked: s.checks, Content: s.content})
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.file, buf.Bytes(), 0600)
+}
+
+func (s *bloomState) close() error {
+	if err := s.flush(); err != nil {
+		s.stateStore.close() // nolint: errcheck,gosec
+		return err
+	}
+	return s.stateStore.close()
+}
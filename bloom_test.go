@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBloomFilter(t *testing.T) {
+	b := newBloomFilter(1000, 0.01, time.Now())
+	for i := 0; i < 1000; i++ {
+		b.add(fmt.Sprintf("key%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		if !b.test(fmt.Sprintf("key%d", i)) {
+			t.Fatalf("false negative for key%d", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if b.test(fmt.Sprintf("other%d", i)) {
+			falsePositives++
+		}
+	}
+	// allow some margin above the configured rate
+	if falsePositives > 300 {
+		t.Fatalf("too many false positives: %d", falsePositives)
+	}
+}
+
+func TestRotatingBloom(t *testing.T) {
+	now := time.Now()
+	r := newRotatingBloom(100, 0.001, now.Add(-20*time.Minute))
+	r.add("old")
+	r.add("removed")
+	r.remove("removed")
+	if r.test("removed") {
+		t.Fatal("removed key should not be found")
+	}
+
+	if n := r.rotate(now.Add(-30*time.Minute), now); n != 0 {
+		t.Fatalf("expected no rotation, got %d", n)
+	}
+	if n := r.rotate(now.Add(-10*time.Minute), now); n != 1 {
+		t.Fatalf("expected a rotation, got %d", n)
+	}
+	r.add("new")
+	if !r.test("old") || !r.test("new") {
+		t.Fatal("keys of the previous generation should still be found")
+	}
+	if r.test("removed") {
+		t.Fatal("removed key should not be found in the previous generation")
+	}
+
+	r.rotate(now.Add(1*time.Minute), now)
+	if r.test("old") {
+		t.Fatal("keys older than two generations should be dropped")
+	}
+	if !r.test("new") {
+		t.Fatal("new key should still be found")
+	}
+	r.add("removed")
+	if !r.test("removed") {
+		t.Fatal("key added again should be found")
+	}
+}
+
+func TestBloomState(t *testing.T) {
+	dir := t.TempDir()
+	c := bloom{Enabled: true, Capacity: 100, File: filepath.Join(dir, "bloom.gob")}
+	inner, err := loadFileState(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatalf("could not load state: %v", err)
+	}
+	s, err := newBloomState(inner, c)
+	if err != nil {
+		t.Fatalf("could not create bloom state: %v", err)
+	}
+	if err := s.setChecked("abc", time.Now()); err != nil {
+		t.Fatalf("could not set checked: %v", err)
+	}
+	if seen, _ := s.seenContent("hash", time.Now()); seen {
+		t.Fatal("content should not be seen on first call")
+	}
+	if seen, _ := s.seenContent("hash", time.Now()); !seen {
+		t.Fatal("content should be seen on second call")
+	}
+	if err := s.setLastCheck(time.Unix(1580000000, 0)); err != nil {
+		t.Fatalf("could not set last check: %v", err)
+	}
+	if err := s.close(); err != nil {
+		t.Fatalf("could not close state: %v", err)
+	}
+
+	inner, err = loadFileState(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatalf("could not load state: %v", err)
+	}
+	s, err = newBloomState(inner, c)
+	if err != nil {
+		t.Fatalf("could not load bloom state: %v", err)
+	}
+	if found, _ := s.checked("abc"); !found {
+		t.Fatal("checked key was not restored")
+	}
+	if found, _ := s.checked("xyz"); found {
+		t.Fatal("unknown key should not be found")
+	}
+	if last, _ := s.lastCheck(); last.Unix() != 1580000000 {
+		t.Fatalf("last check was not passed to the state file, got %v", last)
+	}
+	// the wrapped state does not keep the checked keys
+	if len(inner.data.Checked) != 0 {
+		t.Fatalf("expected no checked keys in the state file, got %d", len(inner.data.Checked))
+	}
+}
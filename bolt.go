@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketChecked  = []byte("checked")
+	bucketNotified = []byte("notified")
+	bucketMeta     = []byte("meta")
+	bucketContent  = []byte("content")
+	bucketPending  = []byte("pending")
+	keyLastCheck   = []byte("lastcheck")
+	keyKeywords    = []byte("keywords")
+)
+
+// boltState keeps the state in an embedded bolt database so every change
+// is durable without external dependencies
+type boltState struct {
+	db *bolt.DB
+}
+
+func openBoltState(f string) (*boltState, error) {
+	db, err := bolt.Open(f, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("could not open state database %s: %v", f, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{bucketChecked, bucketNotified, bucketMeta, bucketContent, bucketPending} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close() // nolint: errcheck,gosec
+		return nil, err
+	}
+	return &boltState{db: db}, nil
+}
+
+func (s *boltState) checked(key string) (bool, error) {
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket(bucketChecked).Get([]byte(key)) != nil
+		return nil
+	})
+	return found, err
+}
+
+func (s *boltState) setChecked(key string, t time.Time) error {
+	v, err := t.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketChecked).Put([]byte(key), v)
+	})
+}
+
+func (s *boltState) unsetChecked(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketChecked).Delete([]byte(key))
+	})
+}
+
+func (s *boltState) expireChecked(before time.Time) (int, error) {
+	n := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		checked := tx.Bucket(bucketChecked)
+		notified := tx.Bucket(bucketNotified)
+		var expired [][]byte
+		err := checked.ForEach(func(k, v []byte) error {
+			var t time.Time
+			if err := t.UnmarshalBinary(v); err != nil || t.Before(before) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// deleting while iterating with ForEach is not supported
+		for _, k := range expired {
+			if err := checked.Delete(k); err != nil {
+				return err
+			}
+			if err := notified.Delete(k); err != nil {
+				return err
+			}
+		}
+		n = len(expired)
+		return nil
+	})
+	return n, err
+}
+
+func (s *boltState) seenContent(hash string, t time.Time) (bool, error) {
+	v, err := t.MarshalBinary()
+	if err != nil {
+		return false, err
+	}
+	found := false
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketContent)
+		if b.Get([]byte(hash)) != nil {
+			found = true
+			return nil
+		}
+		return b.Put([]byte(hash), v)
+	})
+	return found, err
+}
+
+func (s *boltState) expireContent(before time.Time) (int, error) {
+	n := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketContent)
+		var expired [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var t time.Time
+			if err := t.UnmarshalBinary(v); err != nil || t.Before(before) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		n = len(expired)
+		return nil
+	})
+	return n, err
+}
+
+func (s *boltState) lastCheck() (time.Time, error) {
+	var t time.Time
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketMeta).Get(keyLastCheck)
+		if v == nil {
+			return nil
+		}
+		return t.UnmarshalBinary(v)
+	})
+	return t, err
+}
+
+func (s *boltState) setLastCheck(t time.Time) error {
+	v, err := t.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put(keyLastCheck, v)
+	})
+}
+
+func (s *boltState) setNotified(key string, err error) error {
+	n := notification{Time: time.Now()}
+	if err != nil {
+		n.Error = err.Error()
+	}
+	v, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketNotified).Put([]byte(key), v)
+	})
+}
+
+func (s *boltState) keywords() ([]string, error) {
+	var k []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketMeta).Get(keyKeywords)
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &k)
+	})
+	return k, err
+}
+
+func (s *boltState) setKeywords(k []string) error {
+	v, err := json.Marshal(k)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put(keyKeywords, v)
+	})
+}
+
+func (s *boltState) addPending(pastes []paste) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketPending)
+		for _, p := range pastes {
+			v, err := json.Marshal(p)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(p.Key), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltState) removePending(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketPending).Delete([]byte(key))
+	})
+}
+
+func (s *boltState) pending() ([]paste, error) {
+	var ret []paste
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketPending).ForEach(func(k, v []byte) error {
+			var p paste
+			if err := json.Unmarshal(v, &p); err != nil {
+				return fmt.Errorf("invalid pending paste %s: %v", k, err)
+			}
+			ret = append(ret, p)
+			return nil
+		})
+	})
+	return ret, err
+}
+
+// flush is a noop as every change is written immediately
+func (s *boltState) flush() error {
+	return nil
+}
+
+func (s *boltState) close() error {
+	return s.db.Close()
+}
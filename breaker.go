@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerThreshold = 10
+	defaultBreakerProbe     = 1 * time.Minute
+)
+
+// circuitBreaker pauses all requests to the api after too many failed
+// requests in a row. While open a single probe request is let through
+// every probe interval and the first successful request closes it again.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	probe     time.Duration
+	failures  int
+	open      bool
+	probing   bool
+	nextProbe time.Time
+}
+
+func newCircuitBreaker(c breakerConfig) (*circuitBreaker, error) {
+	b := &circuitBreaker{threshold: c.Threshold, probe: defaultBreakerProbe}
+	if b.threshold == 0 {
+		b.threshold = defaultBreakerThreshold
+	}
+	if c.Probe != "" {
+		var err error
+		if b.probe, err = time.ParseDuration(c.Probe); err != nil {
+			return nil, fmt.Errorf("invalid value for breaker.probe %q: %v", c.Probe, err)
+		}
+	}
+	return b, nil
+}
+
+// isOpen returns if requests are paused
+func (b *circuitBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open
+}
+
+// wait blocks while the breaker is open until this caller may send the
+// probe request or the breaker closed. It returns false if the context
+// was canceled.
+func (b *circuitBreaker) wait(ctx context.Context) bool {
+	for {
+		b.mu.Lock()
+		if !b.open {
+			b.mu.Unlock()
+			return true
+		}
+		now := time.Now()
+		if !b.probing && !now.Before(b.nextProbe) {
+			b.probing = true
+			b.mu.Unlock()
+			return true
+		}
+		d := time.Second
+		if !b.probing {
+			d = b.nextProbe.Sub(now)
+		}
+		b.mu.Unlock()
+		if !sleep(ctx, d) {
+			return false
+		}
+	}
+}
+
+// success records a successful request and returns true if it closed the
+// breaker
+func (b *circuitBreaker) success() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	if !b.open {
+		return false
+	}
+	b.open, b.probing = false, false
+	return true
+}
+
+// failure records a failed request and returns true if it opened the
+// breaker. A negative threshold disables the breaker.
+func (b *circuitBreaker) failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.open {
+		b.probing = false
+		b.nextProbe = time.Now().Add(b.probe)
+		return false
+	}
+	if b.threshold < 0 || b.failures < b.threshold {
+		return false
+	}
+	b.open = true
+	b.nextProbe = time.Now().Add(b.probe)
+	return true
+}
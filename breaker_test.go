@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b, err := newCircuitBreaker(breakerConfig{Threshold: 3, Probe: "20ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if b.failure() || b.failure() {
+		t.Fatal("expected the breaker to stay closed below the threshold")
+	}
+	// a success resets the failures
+	b.success()
+	b.failure()
+	b.failure()
+	if b.isOpen() {
+		t.Fatal("expected the failures to be reset")
+	}
+	if !b.failure() || !b.isOpen() {
+		t.Fatal("expected the breaker to open on the threshold")
+	}
+	if b.failure() {
+		t.Fatal("expected the source down to be reported only once")
+	}
+
+	// the probe is let through only after the probe interval
+	start := time.Now()
+	if !b.wait(ctx) {
+		t.Fatal("unexpected cancel")
+	}
+	if d := time.Since(start); d < 15*time.Millisecond {
+		t.Fatalf("expected to wait for the probe, waited %s", d)
+	}
+	// a second caller waits for the running probe
+	ctx2, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if b.wait(ctx2) {
+		t.Fatal("expected only one probe at a time")
+	}
+	if !b.success() || b.isOpen() {
+		t.Fatal("expected a successful probe to close the breaker")
+	}
+	if !b.wait(ctx) {
+		t.Fatal("expected a closed breaker to pass")
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b, err := newCircuitBreaker(breakerConfig{Threshold: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if b.failure() {
+			t.Fatal("expected a disabled breaker to never open")
+		}
+	}
+	if _, err := newCircuitBreaker(breakerConfig{Probe: "1"}); err == nil {
+		t.Fatal("expected error for an invalid probe interval")
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/FireFart/pastebin_scraper/scraper"
+)
+
+const (
+	// pastes are read in blocks of this size to check the budget
+	budgetBlock = 32 << 10
+	// spilled pastes are scanned in parts of about this size
+	scanChunk = 1 << 20
+)
+
+var (
+	// limits the paste bodies held in memory at once, nil if unlimited
+	contentBudget *memoryBudget
+
+	metricPastesSpilled = metrics.counter("pastes_spilled_total", "Number of pastes written to a temp file because the memory budget was used up.", "")
+	metricBudgetUsed    = metrics.gauge("memory_budget_used_bytes", "Bytes of paste bodies currently held in memory.", "")
+)
+
+// memoryBudget counts the bytes of the paste bodies in memory so
+// concurrent large pastes do not get the scraper killed on small hosts
+type memoryBudget struct {
+	mu   sync.Mutex
+	max  int64
+	used int64
+}
+
+func newMemoryBudget(max int64) *memoryBudget {
+	return &memoryBudget{max: max}
+}
+
+// reserve returns false if n more bytes would exceed the budget
+func (b *memoryBudget) reserve(n int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used+n > b.max {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+func (b *memoryBudget) release(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= n
+}
+
+func (b *memoryBudget) inUse() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// readResponse reads the body of the paste within the memory budget
+func (p *paste) readResponse(resp *http.Response, max int64) (err error) {
+	defer func() {
+		if rerr := resp.Body.Close(); rerr != nil && err == nil {
+			p.releaseContent()
+			err = rerr
+		}
+	}()
+	if max > 0 && resp.ContentLength > max {
+		return errBodyTooLarge
+	}
+	return p.readBody(resp.Body, max, contentBudget)
+}
+
+// readBody reads the content of the paste while the budget allows it and
+// writes it to a temp file as soon as the budget is used up. At most max
+// bytes are read (0 means no limit). The hash is calculated on the fly.
+func (p *paste) readBody(r io.Reader, max int64, b *memoryBudget) (err error) {
+	if max > 0 {
+		r = io.LimitReader(r, max+1)
+	}
+	h := sha256.New()
+	var buf []byte
+	var f *os.File
+	defer func() {
+		if err == nil || f == nil {
+			return
+		}
+		f.Close()           // nolint: errcheck,gosec
+		os.Remove(f.Name()) // nolint: errcheck,gosec
+	}()
+	var size int64
+	block := make([]byte, budgetBlock)
+	for {
+		n, rerr := r.Read(block)
+		if n > 0 {
+			size += int64(n)
+			if max > 0 && size > max {
+				b.release(p.reserved)
+				p.reserved = 0
+				return errBodyTooLarge
+			}
+			h.Write(block[:n]) // nolint: errcheck,gosec
+			switch {
+			case f != nil:
+				if _, err := f.Write(block[:n]); err != nil {
+					return err
+				}
+			case b.reserve(int64(n)):
+				p.reserved += int64(n)
+				buf = append(buf, block[:n]...)
+			default:
+				// move everything read so far to disk and give back the budget
+				if f, err = ioutil.TempFile("", "pastebin_scraper-"); err != nil {
+					return fmt.Errorf("could not create temp file: %v", err)
+				}
+				if _, err := f.Write(append(buf, block[:n]...)); err != nil {
+					return err
+				}
+				buf = nil
+				b.release(p.reserved)
+				p.reserved = 0
+				metricPastesSpilled.inc("")
+				logger(componentFetcher).Debug("memory budget used up, spilling paste to disk", "paste_key", p.Key, "file", f.Name())
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			b.release(p.reserved)
+			p.reserved = 0
+			return rerr
+		}
+	}
+	p.Hash = hex.EncodeToString(h.Sum(nil))
+	p.contentSize = size
+	if f != nil {
+		p.contentFile = f.Name()
+		return f.Close()
+	}
+	p.Content = string(buf)
+	return nil
+}
+
+// length returns the size of the content in memory or on disk
+func (p *paste) length() int64 {
+	if p.contentFile != "" {
+		return p.contentSize
+	}
+	return int64(len(p.Content))
+}
+
+// loadContent reads a spilled content back into memory, for example to
+// attach it to an alert
+func (p *paste) loadContent() error {
+	if p.contentFile == "" || p.Content != "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(p.contentFile)
+	if err != nil {
+		return fmt.Errorf("could not read spilled paste %s: %v", p.contentFile, err)
+	}
+	p.Content = string(b)
+	return nil
+}
+
+// releaseContent gives back the budget and removes the spilled content
+func (p *paste) releaseContent() {
+	if contentBudget != nil && p.reserved > 0 {
+		contentBudget.release(p.reserved)
+		p.reserved = 0
+	}
+	if p.contentFile != "" {
+		os.Remove(p.contentFile) // nolint: errcheck,gosec
+	}
+}
+
+// scanFile matches a spilled paste in parts split at line breaks so it is
+// never fully in memory. The threshold is applied to the matches of all
+// parts together, exceptions in the body only apply to their part.
+func (p *paste) scanFile(m *matcher) error {
+	f, err := os.Open(p.contentFile)
+	if err != nil {
+		return fmt.Errorf("could not open spilled paste %s: %v", p.contentFile, err)
+	}
+	defer f.Close() // nolint: errcheck
+	r := bufio.NewReaderSize(f, scanChunk)
+	keywords := make(map[string][]string)
+	other := make(map[string][]string)
+	merge := func(dst, src map[string][]string) {
+		for k := range src {
+			if _, ok := dst[k]; !ok {
+				dst[k] = nil
+			}
+		}
+		scraper.MergeMatches(dst, src)
+	}
+	chunk := make([]byte, 0, scanChunk)
+	for {
+		line, err := r.ReadSlice('\n')
+		chunk = append(chunk, line...)
+		if len(chunk) >= scanChunk || (err != nil && len(chunk) > 0) {
+			blocked, k, o := m.ScanRules(string(chunk))
+			if blocked {
+				return nil
+			}
+			merge(keywords, k)
+			merge(other, o)
+			chunk = chunk[:0]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return err
+		}
+	}
+	if found, key := m.Combine(keywords, other); found {
+		p.Matches = key
+		p.Groups = m.Groups(key)
+	}
+	return nil
+}
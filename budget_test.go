@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadBodyBudget(t *testing.T) {
+	b := newMemoryBudget(100)
+	small := paste{Key: "small"}
+	if err := small.readBody(strings.NewReader("password=1"), 0, b); err != nil {
+		t.Fatal(err)
+	}
+	if small.Content != "password=1" || small.contentFile != "" || b.inUse() != 10 {
+		t.Fatalf("expected the paste in memory, got %+v with %d bytes used", small, b.inUse())
+	}
+	if small.Hash != sha256Hex([]byte("password=1")) {
+		t.Fatalf("unexpected hash %s", small.Hash)
+	}
+
+	body := strings.Repeat("a", 200) + "\npassword=2\n"
+	large := paste{Key: "large"}
+	if err := large.readBody(strings.NewReader(body), 0, b); err != nil {
+		t.Fatal(err)
+	}
+	if large.Content != "" || large.contentFile == "" || large.length() != int64(len(body)) {
+		t.Fatalf("expected the paste to be spilled, got %+v", large)
+	}
+	if b.inUse() != 10 {
+		t.Fatalf("expected the spilled paste to give back the budget, %d bytes used", b.inUse())
+	}
+	if large.Hash != sha256Hex([]byte(body)) {
+		t.Fatalf("unexpected hash %s", large.Hash)
+	}
+	if err := large.loadContent(); err != nil || large.Content != body {
+		t.Fatalf("could not load the spilled content: %v", err)
+	}
+
+	old := contentBudget
+	contentBudget = b
+	defer func() { contentBudget = old }()
+	small.releaseContent()
+	large.releaseContent()
+	if b.inUse() != 0 {
+		t.Fatalf("expected the budget to be released, %d bytes used", b.inUse())
+	}
+	if _, err := os.Stat(large.contentFile); !os.IsNotExist(err) {
+		t.Fatalf("expected the spilled content to be removed, got %v", err)
+	}
+
+	tooLarge := paste{Key: "toolarge"}
+	if err := tooLarge.readBody(strings.NewReader(body), 50, b); err != errBodyTooLarge {
+		t.Fatalf("expected errBodyTooLarge, got %v", err)
+	}
+	if b.inUse() != 0 {
+		t.Fatalf("expected no budget used after a too large paste, %d bytes used", b.inUse())
+	}
+}
+
+func TestScanFile(t *testing.T) {
+	m, err := newMatcher(configuration{
+		Keywords:  []keyword{{Keyword: "password", Score: intPointer(2)}, {Keyword: "secret", Score: intPointer(2)}},
+		Threshold: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the keywords are in different parts of the paste and only reach the
+	// threshold together
+	body := "password=1\n" + strings.Repeat("x\n", scanChunk) + "secret=2\n"
+	p := paste{Key: "spilled"}
+	if err := p.readBody(strings.NewReader(body), 0, newMemoryBudget(0)); err != nil {
+		t.Fatal(err)
+	}
+	defer p.releaseContent()
+	p.scan(m)
+	if len(p.Matches) != 2 || len(p.Matches["password"]) != 1 || len(p.Matches["secret"]) != 1 {
+		t.Fatalf("unexpected matches %v", p.Matches)
+	}
+}
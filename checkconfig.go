@@ -0,0 +1,279 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/mail"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/FireFart/pastebin_scraper/scraper"
+	"github.com/FireFart/pastebin_scraper/store"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// validateConfig checks the whole config and returns all errors instead
+// of stopping at the first one
+func validateConfig(c configuration) []error {
+	var errs []error
+	add := func(format string, a ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	// every keyword on its own so all broken ones are reported
+	for i, k := range c.Keywords {
+		if err := scraper.CheckKeyword(k); err != nil {
+			add("keyword %d (%q): %v", i+1, k.Keyword, err)
+		}
+	}
+	if err := scraper.CheckCIDRs(c.CIDRs); err != nil {
+		add("cidrs: %v", err)
+	}
+	if _, err := parseDetectors(c.Detectors); err != nil {
+		add("detectors: %v", err)
+	}
+
+	if c.Mailserver == "" {
+		add("mailserver: not set")
+	}
+	if c.Mailport <= 0 || c.Mailport > 65535 {
+		add("mailport: invalid port %d", c.Mailport)
+	}
+	addresses := map[string]string{"mailfrom": c.Mailfrom, "mailto": c.Mailto}
+	if c.Mailonerror {
+		addresses["mailtoerror"] = c.Mailtoerror
+	}
+	for name, g := range c.Groups {
+		if g.Mailto != "" {
+			addresses[fmt.Sprintf("groups.%s.mailto", name)] = g.Mailto
+		}
+	}
+	for name, t := range c.Tenants {
+		addresses[fmt.Sprintf("tenants.%s.mailto", name)] = t.Mailto
+		for g, x := range t.Groups {
+			if x.Mailto != "" {
+				addresses[fmt.Sprintf("tenants.%s.groups.%s.mailto", name, g)] = x.Mailto
+			}
+		}
+		for i, k := range t.Keywords {
+			if err := scraper.CheckKeyword(k); err != nil {
+				add("tenants.%s keyword %d (%q): %v", name, i+1, k.Keyword, err)
+			}
+		}
+	}
+	names := make([]string, 0, len(addresses))
+	for k := range addresses {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := mail.ParseAddress(addresses[name]); err != nil {
+			add("%s: invalid address %q: %v", name, addresses[name], err)
+		}
+	}
+
+	durations := map[string]string{
+		"plugins.timeout":                c.Plugins.Timeout,
+		"nearduplicates.window":          c.Nearduplicates.Window,
+		"plugins.interval":               c.Plugins.Interval,
+		"timeout":                        c.Timeout,
+		"interval":                       c.Interval,
+		"shutdowntimeout":                c.Shutdowntimeout,
+		"delay":                          c.Delay,
+		"maxdelay":                       c.Maxdelay,
+		"dedupwindow":                    c.Dedupwindow,
+		"retention.interval":             c.Retention.Interval,
+		"retention.matches":              c.Retention.Matches,
+		"archive.local.maxage":           c.Archive.Local.MaxAge,
+		"archive.local.snapshot.timeout": c.Archive.Local.Snapshot.Timeout,
+		"elasticsearch.flushinterval":    c.Elasticsearch.FlushInterval,
+		"log.maxage":                     c.Log.MaxAge,
+		"redis.lease":                    c.Redis.Lease,
+		"tor.rotate":                     c.Tor.Rotate,
+		"statsd.interval":                c.Statsd.Interval,
+		"summary.interval":               c.Summary.Interval,
+		"api.feedmaxage":                 c.API.Feedmaxage,
+		"enrichment.timeout":             c.Enrichment.Timeout,
+		"enrichment.rdap.newdomainage":   c.Enrichment.RDAP.Newdomainage,
+		"http.dialtimeout":               c.HTTP.DialTimeout,
+		"http.keepalive":                 c.HTTP.KeepAlive,
+		"http.tlshandshaketimeout":       c.HTTP.TLSHandshakeTimeout,
+		"http.responseheadertimeout":     c.HTTP.ResponseHeaderTimeout,
+		"http.idleconntimeout":           c.HTTP.IdleConnTimeout,
+	}
+	names = names[:0]
+	for k := range durations {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if durations[name] == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(durations[name]); err != nil {
+			add("%s: invalid duration %q", name, durations[name])
+		}
+	}
+
+	if _, err := buildQuietSchedules(c); err != nil {
+		add("%v", err)
+	}
+	if c.Memorybudget < 0 {
+		add("memorybudget: must not be negative, got %d", c.Memorybudget)
+	}
+	if c.Jitter < 0 || c.Jitter >= 1 {
+		add("jitter: must be between 0 and 1, got %v", c.Jitter)
+	}
+	if c.Proxy != "" {
+		if _, err := parseProxy(c.Proxy); err != nil {
+			add("proxy: %v", err)
+		}
+	}
+	if c.Maxsize > 0 && c.Minsize > c.Maxsize {
+		add("minsize: larger than maxsize")
+	}
+	if c.Tor.Enabled && c.Proxy != "" {
+		add("tor: can not be used together with proxy")
+	}
+	if _, err := newRetryPolicy(c.Retry, c.Jitter); err != nil {
+		add("%v", err)
+	}
+	if _, err := newCircuitBreaker(c.Breaker); err != nil {
+		add("%v", err)
+	}
+	if c.Database.Driver != "" {
+		if !store.Supported(c.Database.Driver) {
+			add("database.driver: unsupported driver %q", c.Database.Driver)
+		}
+	}
+	if _, err := newLogHandler(ioutil.Discard, c.Log); err != nil {
+		add("log: %v", err)
+	}
+	if c.SIEM.Format != "" && c.SIEM.Format != formatCEF && c.SIEM.Format != formatLEEF {
+		add("siem.format: unsupported format %q", c.SIEM.Format)
+	}
+	if c.Archive.S3.Bucket != "" {
+		if _, err := s3Endpoint(c.Archive.S3); err != nil {
+			add("archive.s3.endpoint: %v", err)
+		}
+	}
+	if c.Archive.Local.Evidence && (c.Archive.Local.MaxAge != "" || c.Archive.Local.MaxSize > 0) {
+		add("archive.local: maxage and maxsize can not be used in evidence mode")
+	}
+	if f := c.Archive.Local.Snapshot.Format; f != "" && f != snapshotPDF && f != snapshotPNG {
+		add("archive.local.snapshot.format: must be pdf or png, got %q", f)
+	}
+	if c.Archive.Local.Snapshot.enabled() && c.Archive.Local.Directory == "" {
+		add("archive.local.snapshot: needs archive.local.directory")
+	}
+	if c.API.Listen != "" && c.API.Token == "" {
+		add("api.token: required if the api is enabled")
+	}
+	if c.Dashboard != "" && !c.Admin.authenticated() {
+		add("dashboard: needs admin.token or admin.clientca")
+	}
+	if c.Admin.Metricsauth && !c.Admin.authenticated() {
+		add("admin.metricsauth: needs admin.token or admin.clientca")
+	}
+	if (c.Admin.CertFile == "") != (c.Admin.KeyFile == "") {
+		add("admin: certfile and keyfile must be set together")
+	}
+	if c.Admin.ClientCA != "" && c.Admin.CertFile == "" {
+		add("admin.clientca: needs admin.certfile and admin.keyfile")
+	}
+	if c.Mailstyle != "" && styles.Registry[c.Mailstyle] == nil {
+		add("mailstyle: unknown style %q", c.Mailstyle)
+	}
+	if !validFormat(c.Mailformat) {
+		add("mailformat: must be one of %s, got %q", strings.Join(formatProfiles, ", "), c.Mailformat)
+	}
+	if c.Script.File != "" {
+		if _, err := loadMatchScript(c.Script); err != nil {
+			add("script: %v", err)
+		}
+	}
+	for i, h := range c.Exec.Hooks {
+		if len(h.Command) == 0 || h.Command[0] == "" {
+			add("exec.hooks[%d]: command is missing", i)
+		}
+		if h.Timeout != "" {
+			if _, err := time.ParseDuration(h.Timeout); err != nil {
+				add("exec.hooks[%d].timeout: invalid duration %q", i, h.Timeout)
+			}
+		}
+		if !validFormat(h.Format) {
+			add("exec.hooks[%d].format: must be one of %s, got %q", i, strings.Join(formatProfiles, ", "), h.Format)
+		}
+	}
+	if l := c.Stream.Listen; l != "" && !strings.HasPrefix(l, streamUnixPrefix) {
+		if err := checkLoopback(l); err != nil {
+			add("stream.listen: %v", err)
+		}
+	}
+	if m := c.Nearduplicates.Mode; m != "" && m != nearDuplicateSuppress && m != nearDuplicateGroup {
+		add("nearduplicates.mode: unsupported mode %q", m)
+	}
+	if d := c.Nearduplicates.Distance; d < 0 || d > 32 {
+		add("nearduplicates.distance: must be between 0 and 32, got %d", d)
+	}
+	if f := c.Report.Format; f != "" && f != "markdown" && f != "html" {
+		add("report.format: unsupported format %q", f)
+	}
+	if _, err := reportLocation(c.Report); err != nil {
+		add("%v", err)
+	}
+	if f := c.Attachment.Format; f != "" && f != "zip" && f != "text" && f != "none" {
+		add("attachment.format: unsupported format %q", f)
+	}
+	if c.Enrichment.Virustotal.APIKey != "" && !c.Extractiocs {
+		add("enrichment.virustotal: needs extractiocs")
+	}
+	if c.Enrichment.HIBP.APIKey != "" && !c.Extractiocs {
+		add("enrichment.hibp: needs extractiocs")
+	}
+	if (c.Enrichment.GeoIP.Database != "" || c.Enrichment.GeoIP.Asndatabase != "") && !c.Extractiocs {
+		add("enrichment.geoip: needs extractiocs")
+	}
+	if c.Enrichment.RDAP.Enabled && !c.Extractiocs {
+		add("enrichment.rdap: needs extractiocs")
+	}
+	if len(c.Enrichment.GeoIP.Watchasns) > 0 && c.Enrichment.GeoIP.Asndatabase == "" {
+		add("enrichment.geoip.watchasns: needs asndatabase")
+	}
+	return errs
+}
+
+func printConfigErrors(w io.Writer, errs []error) error {
+	for _, err := range errs {
+		if _, err := fmt.Fprintf(w, "ERROR: %v\n", err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runCheckConfig implements the check-config subcommand
+func runCheckConfig(args []string) error {
+	fs := flag.NewFlagSet("check-config", flag.ExitOnError)
+	configFile := fs.String("config", "", "Config File to use")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	c, err := getConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("could not read config file %s: %v", *configFile, err)
+	}
+	errs := validateConfig(*c)
+	if len(errs) > 0 {
+		if err := printConfigErrors(os.Stderr, errs); err != nil {
+			return err
+		}
+		return fmt.Errorf("config %s has %d errors", *configFile, len(errs))
+	}
+	fmt.Printf("config %s is valid\n", *configFile)
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	c, err := getConfig(path.Join("testdata", "test.json"))
+	if err != nil {
+		t.Fatalf("got error when reading config file: %v", err)
+	}
+	if errs := validateConfig(*c); len(errs) != 0 {
+		t.Fatalf("expected valid config, got %v", errs)
+	}
+}
+
+func TestValidateConfigErrors(t *testing.T) {
+	c := configuration{
+		Mailserver: "localhost",
+		Mailport:   70000,
+		Mailfrom:   "Pastebin Alert <alert@example.com>",
+		Mailto:     "invalid",
+		Timeout:    "10",
+		Jitter:     1.5,
+		Keywords: []keyword{
+			{Keyword: "valid"},
+			{Keyword: "fuzzy", Fuzzy: 5},
+			{Keyword: "boundary", Boundary: "invalid"},
+		},
+		CIDRs:     []string{"10.0.0.0/33"},
+		Detectors: []string{"unknown"},
+		Database:  database{Driver: "oracle"},
+		Log:       logConfig{Level: "verbose"},
+	}
+	errs := validateConfig(c)
+	expected := []string{
+		`keyword 2 ("fuzzy")`,
+		`keyword 3 ("boundary")`,
+		"cidrs:",
+		"detectors:",
+		"mailport: invalid port 70000",
+		`mailto: invalid address "invalid"`,
+		`timeout: invalid duration "10"`,
+		"jitter: must be between 0 and 1",
+		`database.driver: unsupported driver "oracle"`,
+		"log:",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, e := range expected {
+		if !strings.HasPrefix(errs[i].Error(), e) {
+			t.Errorf("expected error %d to start with %q, got %q", i, e, errs[i])
+		}
+	}
+}
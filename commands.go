@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	runtimedebug "runtime/debug"
+	"strings"
+)
+
+var (
+	// set on build with -ldflags "-X main.version=1.2.3"
+	version = ""
+)
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+// subcommands returns all subcommands of the binary. run is the default
+// if no subcommand is given.
+func subcommands() []command {
+	return []command{
+		{name: "run", description: "scrape pastebin and alert on matches (default)", run: runScraper},
+		{name: "test", description: "run the rules against local files", run: runTest},
+		{name: "replay", description: "run the rules against the local archive", run: runReplay},
+		{name: "search", description: "search the archive and the database", run: runSearch},
+		{name: "export", description: "export the matches from the database", run: runExport},
+		{name: "verify", description: "verify the hash chain of the evidence archive", run: runVerify},
+		{name: "snooze", description: "snooze a keyword of the running scraper", run: runSnooze},
+		{name: "check-config", description: "validate the config file", run: runCheckConfig},
+		{name: "version", description: "print the version", run: runVersion},
+		{name: "help", description: "print this help", run: runHelp},
+	}
+}
+
+// parseCommand returns the subcommand and its arguments. Flags without
+// a subcommand are passed to run for backward compatibility.
+func parseCommand(args []string) (command, []string, error) {
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	for _, c := range subcommands() {
+		if c.name == name {
+			return c, args, nil
+		}
+	}
+	return command{}, nil, fmt.Errorf("unknown command %q", name)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range subcommands() {
+		fmt.Fprintf(w, "  %-14s %s\n", c.name, c.description)
+	}
+	fmt.Fprintf(w, "\nRun %s <command> -h for the flags of a command.\n", os.Args[0])
+}
+
+func runHelp(_ []string) error {
+	printUsage(os.Stdout)
+	return nil
+}
+
+// buildVersion returns the version set on build or the module version
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := runtimedebug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+func runVersion(_ []string) error {
+	fmt.Printf("pastebin_scraper %s\n", buildVersion())
+	return nil
+}
+
+func main() {
+	c, args, err := parseCommand(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n", err)
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+	if err := c.run(args); err != nil {
+		fatal("command failed", "command", c.name, "error", err)
+	}
+}
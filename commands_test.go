@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tt := []struct {
+		args     []string
+		name     string
+		expected []string
+	}{
+		{args: nil, name: "run", expected: nil},
+		{args: []string{"-config", "config.json"}, name: "run", expected: []string{"-config", "config.json"}},
+		{args: []string{"run", "-config", "config.json"}, name: "run", expected: []string{"-config", "config.json"}},
+		{args: []string{"check-config", "-config", "config.json"}, name: "check-config", expected: []string{"-config", "config.json"}},
+		{args: []string{"version"}, name: "version", expected: []string{}},
+	}
+	for _, x := range tt {
+		c, args, err := parseCommand(x.args)
+		if err != nil {
+			t.Fatalf("%v: got error: %v", x.args, err)
+		}
+		if c.name != x.name || !reflect.DeepEqual(args, x.expected) {
+			t.Errorf("%v: expected %s %v, got %s %v", x.args, x.name, x.expected, c.name, args)
+		}
+	}
+	if _, _, err := parseCommand([]string{"invalid"}); err == nil {
+		t.Fatal("expected error on unknown command")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// days of the report history used for the keyword rarity
+	confidenceRarityDays = 7
+	// the rarity is only known after this many scanned pastes
+	confidenceMinScanned = 1000
+	// keywords matching at most this share of the pastes are rare, at
+	// least the upper share common
+	confidenceRareShare   = 0.0001
+	confidenceCommonShare = 0.05
+	// matched lines counting as full evidence
+	confidenceFullCount = 20
+)
+
+// frequency returns the share of the scanned pastes of the last days
+// matching the keyword, false if too few pastes were scanned
+func (h *reportHistory) frequency(keyword string, now time.Time, days int) (float64, bool) {
+	scanned, hits := 0, 0
+	for i := 0; i < days; i++ {
+		d := h.get(now.AddDate(0, 0, -i))
+		scanned += d.Scanned
+		hits += d.Hits[keyword]
+	}
+	if scanned < confidenceMinScanned {
+		return 0, false
+	}
+	return float64(hits) / float64(scanned), true
+}
+
+// rarity maps the share of matching pastes from 1 for rare keywords to
+// 0.1 for common ones on a log scale, unknown keywords get 0.5
+func rarity(share float64, known bool) float64 {
+	if !known {
+		return 0.5
+	}
+	if share <= confidenceRareShare {
+		return 1
+	}
+	if share >= confidenceCommonShare {
+		return 0.1
+	}
+	pos := (math.Log10(share) - math.Log10(confidenceRareShare)) / (math.Log10(confidenceCommonShare) - math.Log10(confidenceRareShare))
+	return 1 - 0.9*pos
+}
+
+// confidence estimates from 0 to 100 how likely the alert is relevant.
+// Rare keywords and detectors weigh most, then the number of matched
+// lines and finally the paste size as tiny pastes are mostly noise.
+func (m *matcher) confidence(p *paste, now time.Time) int {
+	kind, lines := 0.0, 0
+	for k, v := range p.Matches {
+		lines += len(v)
+		signal := 0.7 // cidrs
+		if m.IsKeyword(k) {
+			signal = rarity(reports.frequency(k, now, confidenceRarityDays))
+		} else {
+			for _, d := range m.Detectors() {
+				if d.Name == k {
+					signal = 0.9
+				}
+			}
+		}
+		kind = math.Max(kind, signal)
+	}
+	if lines < len(p.Matches) {
+		lines = len(p.Matches)
+	}
+	count := math.Min(1, 0.3+0.7*math.Log10(float64(lines))/math.Log10(confidenceFullCount))
+	size := 1.0
+	switch n := p.length(); {
+	case n < 64:
+		size = 0.2
+	case n < 256:
+		size = 0.5
+	case n > 5*1024*1024:
+		// a single keyword in a huge paste is often incidental
+		size = 0.6
+	}
+	return int(math.Round(100 * (0.5*kind + 0.3*count + 0.2*size)))
+}
+
+func confidenceToString(c int) string {
+	if c <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d%%", c)
+}
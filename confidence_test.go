@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRarity(t *testing.T) {
+	for _, c := range []struct {
+		share float64
+		known bool
+		want  float64
+	}{
+		{0, false, 0.5},
+		{0.00001, true, 1},
+		{0.1, true, 0.1},
+		{math.Sqrt(confidenceRareShare * confidenceCommonShare), true, 0.55},
+	} {
+		if got := rarity(c.share, c.known); math.Abs(got-c.want) > 0.001 {
+			t.Errorf("rarity(%v, %v) = %v, expected %v", c.share, c.known, got, c.want)
+		}
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	now := time.Now()
+	old := reports
+	defer func() { reports = old }()
+	reports = newReportHistory(time.UTC)
+	for i := 0; i < 10000; i++ {
+		reports.addScanned(now)
+	}
+	for i := 0; i < 100; i++ {
+		reports.addMatch(paste{Matches: map[string][]string{"admin": {}}}, 1, now)
+	}
+	reports.addMatch(paste{Matches: map[string][]string{"acme": {}}}, 1, now)
+
+	m, err := newMatcher(configuration{Keywords: []keyword{{Keyword: "admin"}, {Keyword: "acme"}}, Detectors: []string{"sqldump"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := strings.Repeat("x", 1000)
+	common := m.confidence(&paste{Content: body, Matches: map[string][]string{"admin": {"admin"}}}, now)
+	rare := m.confidence(&paste{Content: body, Matches: map[string][]string{"acme": {"acme"}}}, now)
+	if common >= rare {
+		t.Errorf("expected the rare keyword to have a higher confidence, got %d and %d", common, rare)
+	}
+	tiny := m.confidence(&paste{Content: "acme", Matches: map[string][]string{"acme": {"acme"}}}, now)
+	if tiny >= rare {
+		t.Errorf("expected a tiny paste to have a lower confidence, got %d and %d", tiny, rare)
+	}
+	lines := make([]string, confidenceFullCount)
+	many := m.confidence(&paste{Content: body, Matches: map[string][]string{"acme": lines}}, now)
+	if many != 100 {
+		t.Errorf("expected full confidence for many rare matches, got %d", many)
+	}
+	detector := m.confidence(&paste{Content: body, Matches: map[string][]string{"database dump": {"x"}}}, now)
+	if detector != 74 {
+		t.Errorf("unexpected detector confidence %d", detector)
+	}
+}
+
+func TestRecipientsMinConfidence(t *testing.T) {
+	c := configuration{
+		Mailto:        "all@example.com",
+		Minconfidence: 50,
+		Groups:        map[string]group{"soc": {Mailto: "soc@example.com"}},
+	}
+	p := paste{Groups: []string{"soc"}, Confidence: 40}
+	if to := p.recipients(c); !reflect.DeepEqual(to, []string{"soc@example.com"}) {
+		t.Errorf("unexpected recipients %v", to)
+	}
+	p.Confidence = 60
+	if to := p.recipients(c); !reflect.DeepEqual(to, []string{"all@example.com", "soc@example.com"}) {
+		t.Errorf("unexpected recipients %v", to)
+	}
+	if (execHook{Minconfidence: 70}).wants(p) {
+		t.Error("expected the hook to be skipped below its confidence")
+	}
+}
@@ -1,30 +1,553 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/FireFart/pastebin_scraper/scraper"
+	"github.com/FireFart/pastebin_scraper/store"
 )
 
 type configuration struct {
-	Mailserver  string    `json:"mailserver"`
-	Mailport    int       `json:"mailport"`
-	Mailfrom    string    `json:"mailfrom"`
-	Mailonerror bool      `json:"mailonerror"`
-	Mailtoerror string    `json:"mailtoerror"`
-	Mailto      string    `json:"mailto"`
-	Mailsubject string    `json:"mailsubject"`
-	Timeout     string    `json:"timeout"`
-	Keywords    []keyword `json:"keywords"`
-	CIDRs       []string  `json:"cidrs"`
+	Mailserver      string              `json:"mailserver"`
+	Mailport        int                 `json:"mailport"`
+	Mailfrom        string              `json:"mailfrom"`
+	Mailonerror     bool                `json:"mailonerror"`
+	Mailtoerror     string              `json:"mailtoerror"`
+	Mailto          string              `json:"mailto"`
+	Quiethours      quietHours          `json:"quiethours"`
+	Mailsubject     string              `json:"mailsubject"`
+	Mailhtml        bool                `json:"mailhtml"`
+	Mailstyle       string              `json:"mailstyle"`
+	Mailformat      string              `json:"mailformat"`
+	Attachment      attachmentConfig    `json:"attachment"`
+	Timeout         string              `json:"timeout"`
+	Shutdowntimeout string              `json:"shutdowntimeout"`
+	HTTP            httpConfig          `json:"http"`
+	Maxpastesize    int64               `json:"maxpastesize"`
+	Memorybudget    int64               `json:"memorybudget"`
+	Minsize         int64               `json:"minsize"`
+	Maxsize         int64               `json:"maxsize"`
+	Proxy           string              `json:"proxy"`
+	Tor             torConfig           `json:"tor"`
+	Headers         map[string]string   `json:"headers"`
+	Useragents      []string            `json:"useragents"`
+	Interval        string              `json:"interval"`
+	Delay           string              `json:"delay"`
+	Maxdelay        string              `json:"maxdelay"`
+	Jitter          float64             `json:"jitter"`
+	Workers         int                 `json:"workers"`
+	Retry           retryConfig         `json:"retry"`
+	Breaker         breakerConfig       `json:"breaker"`
+	Threshold       int                 `json:"threshold"`
+	Minconfidence   int                 `json:"minconfidence"`
+	Keywords        []keyword           `json:"keywords"`
+	Include         []string            `json:"include"`
+	CIDRs           []string            `json:"cidrs"`
+	Blocklist       []string            `json:"blocklist"`
+	Normalize       bool                `json:"normalize"`
+	FoldHomoglyphs  bool                `json:"foldhomoglyphs"`
+	Groups          map[string]group    `json:"groups"`
+	Tenants         map[string]tenant   `json:"tenants"`
+	Detectors       []string            `json:"detectors"`
+	Script          scriptConfig        `json:"script"`
+	Extractiocs     bool                `json:"extractiocs"`
+	Defang          bool                `json:"defang"`
+	Enrichment      enrichmentConfig    `json:"enrichment"`
+	Statefile       string              `json:"statefile"`
+	Statedb         string              `json:"statedb"`
+	Pidfile         string              `json:"pidfile"`
+	Dedupwindow     string              `json:"dedupwindow"`
+	Nearduplicates  nearDuplicateConfig `json:"nearduplicates"`
+	Follow          followConfig        `json:"follow"`
+	Bloom           bloom               `json:"bloom"`
+	Jsonlfile       string              `json:"jsonlfile"`
+	SIEM            siem                `json:"siem"`
+	STIX            stixConfig          `json:"stix"`
+	Exec            execConfig          `json:"exec"`
+	Plugins         pluginConfig        `json:"plugins"`
+	Metrics         string              `json:"metrics"`
+	Statsd          statsdConfig        `json:"statsd"`
+	Tracing         tracingConfig       `json:"tracing"`
+	Dashboard       string              `json:"dashboard"`
+	API             api                 `json:"api"`
+	Admin           adminConfig         `json:"admin"`
+	GRPC            grpcConfig          `json:"grpc"`
+	Stream          streamConfig        `json:"stream"`
+	Redis           redisConfig         `json:"redis"`
+	Queue           queueConfig         `json:"queue"`
+	Summary         summaryConfig       `json:"summary"`
+	Report          reportConfig        `json:"report"`
+	Log             logConfig           `json:"log"`
+	Database        database            `json:"database"`
+	Elasticsearch   elasticsearch       `json:"elasticsearch"`
+	Archive         archive             `json:"archive"`
+	Retention       retention           `json:"retention"`
+	Retroscan       retroscan           `json:"retroscan"`
+	Vault           vault               `json:"vault"`
+	AWS             aws                 `json:"aws"`
+
+	// shortest lease of the resolved vault secrets
+	secretsLease time.Duration
+}
+
+type aws struct {
+	// defaults to the AWS_REGION environment variable or the region of
+	// the ARN
+	Region string `json:"region"`
+	// custom endpoint for VPC endpoints or testing
+	Endpoint string `json:"endpoint"`
+	// defaults to the environment, the ECS task role and the EC2
+	// instance profile
+	AccessKey    string `json:"accesskey"`
+	SecretKey    string `json:"secretkey"`
+	SessionToken string `json:"sessiontoken"`
+}
+
+type vault struct {
+	// defaults to the VAULT_ADDR and VAULT_TOKEN environment variables
+	Address   string `json:"address"`
+	Token     string `json:"token"`
+	TokenFile string `json:"tokenfile"`
+	Namespace string `json:"namespace"`
+}
+
+type retryConfig struct {
+	// number of tries per request including the first one
+	Attempts int `json:"attempts"`
+	// wait before the first retry, doubles on every further retry
+	Backoff    string `json:"backoff"`
+	MaxBackoff string `json:"maxbackoff"`
+	// drop, requeue or error
+	GiveUp string `json:"giveup"`
+}
+
+type statsdConfig struct {
+	// host:port of the statsd agent
+	Address string `json:"address"`
+	// defaults to pastebin_scraper.
+	Prefix string `json:"prefix"`
+	// send labels and tags in the dogstatsd format
+	Dogstatsd bool     `json:"dogstatsd"`
+	Tags      []string `json:"tags"`
+	// how often the gauges are sent
+	Interval string `json:"interval"`
+}
+
+type tracingConfig struct {
+	// url of the otlp http endpoint, e.g. http://localhost:4318/v1/traces
+	Endpoint string            `json:"endpoint"`
+	Headers  map[string]string `json:"headers"`
+	// defaults to pastebin_scraper
+	Service string `json:"service"`
+	// fraction of the traces to sample, all if unset
+	SampleRatio float64 `json:"sampleratio"`
+}
+
+type httpConfig struct {
+	DialTimeout           string `json:"dialtimeout"`
+	KeepAlive             string `json:"keepalive"`
+	TLSHandshakeTimeout   string `json:"tlshandshaketimeout"`
+	ResponseHeaderTimeout string `json:"responseheadertimeout"`
+	IdleConnTimeout       string `json:"idleconntimeout"`
+	MaxIdleConns          int    `json:"maxidleconns"`
+	MaxIdleConnsPerHost   int    `json:"maxidleconnsperhost"`
+	MaxConnsPerHost       int    `json:"maxconnsperhost"`
+	DisableKeepAlives     bool   `json:"disablekeepalives"`
+}
+
+type torConfig struct {
+	// send the requests to the source through tor
+	Enabled bool `json:"enabled"`
+	// host:port of the socks proxy, defaults to 127.0.0.1:9050
+	Socks string `json:"socks"`
+	// host:port of the control port, defaults to 127.0.0.1:9051
+	Control    string `json:"control"`
+	Password   string `json:"password"`
+	CookieFile string `json:"cookiefile"`
+	// request new circuits in this interval, disabled if empty
+	Rotate string `json:"rotate"`
+}
+
+type breakerConfig struct {
+	// failed requests in a row until fetching is paused, -1 disables it
+	Threshold int `json:"threshold"`
+	// how often a request is tried while paused
+	Probe string `json:"probe"`
+}
+
+// summaryConfig enables the periodic activity summaries
+type summaryConfig struct {
+	// like 1h or 24h, unset disables the summaries
+	Interval string `json:"interval"`
+	// also mail the summary to mailtoerror
+	Mail bool `json:"mail"`
+}
+
+type reportConfig struct {
+	// send a report of the previous day after midnight
+	Daily bool `json:"daily"`
+	// send the trends of the previous week on mondays
+	Weekly bool `json:"weekly"`
+	// markdown or html
+	Format string `json:"format"`
+	// mail the reports to mailto, defaults to the global mailto
+	Mail   bool   `json:"mail"`
+	Mailto string `json:"mailto"`
+	// also write the reports to this directory
+	Directory string `json:"directory"`
+	// the days start at midnight in this timezone, defaults to local time
+	Timezone string `json:"timezone"`
+	// keeps the counters of the last days across restarts
+	Historyfile string `json:"historyfile"`
+}
+
+// followConfig fetches the pastes linked from matched pastes
+type followConfig struct {
+	Enabled bool `json:"enabled"`
+	// links followed per paste, defaults to 5
+	Maxlinks int `json:"maxlinks"`
+	// url shorteners resolved before the target is checked
+	Shorteners []string `json:"shorteners"`
+	// hosts whose links are fetched as is, pastebin links always
+	Hosts []string `json:"hosts"`
+}
+
+// queueConfig limits the alerts and errors waiting for the notifier
+type queueConfig struct {
+	Alerts int `json:"alerts"`
+	Errors int `json:"errors"`
+	// directory for the alerts which do not fit into the queue, they are
+	// dropped if unset
+	Spool string `json:"spool"`
+}
+
+type redisConfig struct {
+	// host:port of the redis server, enables the distributed mode
+	Address  string `json:"address"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+	TLS      bool   `json:"tls"`
+	// prefix of all keys, defaults to pastebin_scraper:
+	Prefix string `json:"prefix"`
+	// run active/passive instead of distributing the pastes, only the
+	// instance holding the lease fetches
+	HA    bool   `json:"ha"`
+	Lease string `json:"lease"`
+}
+
+type grpcConfig struct {
+	Listen string `json:"listen"`
+	// optional bearer token in the authorization metadata
+	Token    string `json:"token"`
+	CertFile string `json:"certfile"`
+	KeyFile  string `json:"keyfile"`
+}
+
+// nearDuplicateConfig relates alerts of pastes similar to recent ones
+type nearDuplicateConfig struct {
+	// suppress or group, disabled if empty
+	Mode string `json:"mode"`
+	// differing bits of the fingerprints, defaults to 3
+	Distance int `json:"distance"`
+	// how long alerts are remembered, defaults to 24h
+	Window string `json:"window"`
+}
+
+// scriptConfig post-processes the matches with a starlark script
+type scriptConfig struct {
+	File string `json:"file"`
+	// starlark steps per paste, defaults to one million
+	Maxsteps int `json:"maxsteps"`
+}
+
+// execConfig runs commands for every match
+type execConfig struct {
+	// hooks running at the same time, defaults to 4
+	Concurrency int        `json:"concurrency"`
+	Hooks       []execHook `json:"hooks"`
+}
+
+type execHook struct {
+	// program and arguments, no shell is involved
+	Command []string `json:"command"`
+	// only run for matches of these keywords, all matches if empty
+	Keywords []string `json:"keywords"`
+	// the command is killed after this time, defaults to 30s
+	Timeout string `json:"timeout"`
+	// only run for alerts with at least this confidence
+	Minconfidence int `json:"minconfidence"`
+	// formatting profile of the message, plain, markdown or minimal
+	Format string `json:"format"`
+}
+
+// pluginConfig starts the plugins in the directory
+type pluginConfig struct {
+	Directory string `json:"directory"`
+	// time to wait for a response, defaults to 30s
+	Timeout string `json:"timeout"`
+	// sources are polled in this interval, defaults to 1m
+	Interval string `json:"interval"`
+}
+
+// streamConfig serves the matches as json lines to local clients
+type streamConfig struct {
+	// unix:/path/to/socket or a loopback address like 127.0.0.1:9091
+	Listen string `json:"listen"`
+}
+
+// adminConfig secures the metrics, dashboard and api endpoints
+type adminConfig struct {
+	CertFile string `json:"certfile"`
+	KeyFile  string `json:"keyfile"`
+	// require client certificates signed by this ca
+	ClientCA string `json:"clientca"`
+	// api key for the dashboard, and the metrics with metricsauth
+	Token       string `json:"token"`
+	Metricsauth bool   `json:"metricsauth"`
+}
+
+type api struct {
+	Listen string `json:"listen"`
+	// bearer token required for all requests
+	Token string `json:"token"`
+	// indicators not seen again within this time drop out of the feed,
+	// defaults to 720h
+	Feedmaxage string `json:"feedmaxage"`
+}
+
+type logConfig struct {
+	// text or json
+	Format string `json:"format"`
+	// error, warn, info, debug or trace
+	Level string `json:"level"`
+	// level overrides for the fetcher, matcher and notifier
+	Components map[string]string `json:"components"`
+	// log to this file instead of stderr
+	File       string `json:"file"`
+	MaxSize    int64  `json:"maxsize"` // megabytes
+	MaxAge     string `json:"maxage"`
+	MaxBackups int    `json:"maxbackups"`
+	Compress   bool   `json:"compress"`
+}
+
+type enrichmentConfig struct {
+	// upper bound of all lookups for one paste, defaults to 1m
+	Timeout    string           `json:"timeout"`
+	Virustotal virusTotalConfig `json:"virustotal"`
+	HIBP       hibpConfig       `json:"hibp"`
+	GeoIP      geoIPConfig      `json:"geoip"`
+	RDAP       rdapConfig       `json:"rdap"`
+}
+
+type virusTotalConfig struct {
+	APIKey string `json:"apikey"`
+	// defaults to the 4 requests of the public api
+	Requestsperminute int `json:"requestsperminute"`
+	// uncached lookups per paste, defaults to 4
+	Maxlookups int `json:"maxlookups"`
+}
+
+type hibpConfig struct {
+	APIKey string `json:"apikey"`
+	// only addresses of these domains and their subdomains are looked
+	// up, all addresses if unset
+	Domains []string `json:"domains"`
+	// defaults to the 10 requests of the smallest subscription
+	Requestsperminute int `json:"requestsperminute"`
+	// uncached lookups per paste, defaults to 10
+	Maxlookups int `json:"maxlookups"`
+}
+
+type geoIPConfig struct {
+	// GeoLite2 country or city database
+	Database string `json:"database"`
+	// GeoLite2 ASN database
+	Asndatabase string `json:"asndatabase"`
+	// IPs of these networks put the paste into the watchgroup
+	Watchasns []uint `json:"watchasns"`
+	// defaults to asn watchlist
+	Watchgroup string `json:"watchgroup"`
+}
+
+type rdapConfig struct {
+	Enabled bool `json:"enabled"`
+	// defaults to https://rdap.org which redirects to the registry
+	Endpoint          string `json:"endpoint"`
+	Requestsperminute int    `json:"requestsperminute"`
+	// uncached lookups per paste, defaults to 10
+	Maxlookups int `json:"maxlookups"`
+	// domains registered within this time are flagged, defaults to 720h
+	Newdomainage string `json:"newdomainage"`
+}
+
+type attachmentConfig struct {
+	// zip, text or none
+	Format string `json:"format"`
+	// text attachments above this size in bytes are gzipped, defaults
+	// to 1 MB, negative to never compress
+	Gzipsize int64 `json:"gzipsize"`
+}
+
+type stixConfig struct {
+	// writes a bundle per match to this directory
+	Directory string      `json:"directory"`
+	TAXII     taxiiConfig `json:"taxii"`
+}
+
+type taxiiConfig struct {
+	// objects endpoint of the collection, e.g.
+	// https://taxii.example.com/api1/collections/<id>/objects/
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Token    string `json:"token"`
+}
+
+type siem struct {
+	// cef or leef
+	Format string `json:"format"`
+	File   string `json:"file"`
+	// udp://host:514 or tcp://host:514
+	Syslog   string `json:"syslog"`
+	Severity int    `json:"severity"`
+}
+
+type bloom struct {
+	Enabled bool `json:"enabled"`
+	// expected number of keys per window
+	Capacity      int     `json:"capacity"`
+	FalsePositive float64 `json:"falsepositive"`
+	File          string  `json:"file"`
+}
+
+type retroscan struct {
+	// number of days of the local archive checked for added keywords
+	Days int `json:"days"`
+}
+
+type retention struct {
+	// how often the retention job runs
+	Interval string `json:"interval"`
+	// maximum age of matches in the database
+	Matches string `json:"matches"`
+}
+
+type archive struct {
+	// archive every fetched paste instead of only matches
+	All   bool  `json:"all"`
+	S3    s3    `json:"s3"`
+	GCS   gcs   `json:"gcs"`
+	Azure azure `json:"azure"`
+	Local local `json:"local"`
+}
+
+type local struct {
+	Directory string `json:"directory"`
+	MaxAge    string `json:"maxage"`
+	MaxSize   int64  `json:"maxsize"` // megabytes
+	// write once and record every file in a hash chain
+	Evidence bool `json:"evidence"`
+	// render a visual copy of matched pastes next to the content
+	Snapshot snapshotConfig `json:"snapshot"`
+}
+
+// snapshotConfig renders matched pastes as pdf or png, either as text or
+// with an external renderer like a headless browser
+type snapshotConfig struct {
+	Enabled bool     `json:"enabled"`
+	Format  string   `json:"format"`
+	Command []string `json:"command"`
+	Timeout string   `json:"timeout"`
+}
+
+type azure struct {
+	// either a connection string with an account key or the account
+	// name when using a managed identity
+	ConnectionString string `json:"connectionstring"`
+	Account          string `json:"account"`
+	ClientID         string `json:"clientid"`
+	Container        string `json:"container"`
+	Prefix           string `json:"prefix"`
+}
+
+type gcs struct {
+	Bucket          string `json:"bucket"`
+	Prefix          string `json:"prefix"`
+	CredentialsFile string `json:"credentialsfile"`
+}
+
+type s3 struct {
+	Bucket       string `json:"bucket"`
+	Region       string `json:"region"`
+	Prefix       string `json:"prefix"`
+	AccessKey    string `json:"accesskey"`
+	SecretKey    string `json:"secretkey"`
+	SessionToken string `json:"sessiontoken"`
+	// custom endpoint for S3 compatible storage like MinIO
+	Endpoint  string `json:"endpoint"`
+	PathStyle bool   `json:"pathstyle"`
+	// additional CA certificates in PEM format to trust
+	CAFile string `json:"cafile"`
+	// object lock for buckets with object lock enabled
+	ObjectLockMode string `json:"objectlockmode"`
+	ObjectLockDays int    `json:"objectlockdays"`
+	LegalHold      bool   `json:"legalhold"`
+}
+
+type elasticsearch struct {
+	URL           string `json:"url"`
+	Index         string `json:"index"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	Content       bool   `json:"content"`
+	BulkSize      int    `json:"bulksize"`
+	FlushInterval string `json:"flushinterval"`
+}
+
+type database struct {
+	Driver string `json:"driver"`
+	DSN    string `json:"dsn"`
+}
+
+// tenant has its own keywords and recipients, its matches are only sent
+// to them
+type tenant struct {
+	Keywords      []keyword        `json:"keywords"`
+	Threshold     int              `json:"threshold"`
+	Mailto        string           `json:"mailto"`
+	Minconfidence int              `json:"minconfidence"`
+	Quiethours    quietHours       `json:"quiethours"`
+	Groups        map[string]group `json:"groups"`
+}
+
+type group struct {
+	Mailto     string     `json:"mailto"`
+	Quiethours quietHours `json:"quiethours"`
+	// only alerts with at least this confidence are mailed
+	Minconfidence int `json:"minconfidence"`
 }
 
-type keyword struct {
-	Keyword    string   `json:"keyword"`
-	Exceptions []string `json:"exceptions"`
+// quietHours hold back the alerts of a recipient and send them as a
+// digest afterwards
+type quietHours struct {
+	// daily quiet hours like 22:00 to 07:00
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Timezone string `json:"timezone"`
+	// whole days like saturday and sunday
+	Days []string `json:"days"`
+	// alerts with at least this keyword score are sent anyway
+	Minscore int `json:"minscore"`
 }
 
+// keyword is the config of a keyword, it is compiled by the scraper package
+type keyword = scraper.Keyword
+
 func getConfig(f string) (*configuration, error) {
 	if f == "" {
 		return nil, fmt.Errorf("please provide a valid config file")
@@ -34,12 +557,83 @@ func getConfig(f string) (*configuration, error) {
 	if err != nil {
 		return nil, err
 	}
-	reader := bytes.NewReader(b)
-
-	decoder := json.NewDecoder(reader)
 	c := configuration{}
-	if err = decoder.Decode(&c); err != nil {
+	if err := decodeConfig(f, b, &c); err != nil {
+		return nil, err
+	}
+	if err := loadIncludes(&c, filepath.Dir(f)); err != nil {
+		return nil, err
+	}
+	if err := applyEnvOverrides(&c); err != nil {
+		return nil, err
+	}
+	if err := resolveSecrets(context.Background(), &c); err != nil {
 		return nil, err
 	}
+	setDefaults(&c)
 	return &c, nil
 }
+
+// setDefaults fills in all optional values which are not set
+func setDefaults(c *configuration) {
+	if c.Mailport == 0 {
+		c.Mailport = defaultMailport
+	}
+	if c.Mailtoerror == "" {
+		c.Mailtoerror = c.Mailto
+	}
+	if c.Timeout == "" {
+		c.Timeout = defaultTimeout.String()
+	}
+	if c.Shutdowntimeout == "" {
+		c.Shutdowntimeout = defaultShutdownTimeout.String()
+	}
+	if c.Interval == "" {
+		c.Interval = defaultInterval.String()
+	}
+	if c.Delay == "" {
+		c.Delay = defaultDelay.String()
+	}
+	if c.Maxdelay == "" {
+		c.Maxdelay = defaultMaxDelay.String()
+	}
+	if c.Maxpastesize == 0 {
+		c.Maxpastesize = defaultMaxPasteSize
+	}
+	if c.Queue.Alerts <= 0 {
+		c.Queue.Alerts = defaultAlertQueue
+	}
+	if c.Queue.Errors <= 0 {
+		c.Queue.Errors = defaultErrorQueue
+	}
+	if c.Workers <= 0 {
+		c.Workers = defaultWorkers
+	}
+	if c.Dedupwindow == "" {
+		c.Dedupwindow = defaultDedupWindow.String()
+	}
+	if c.Retention.Interval == "" {
+		c.Retention.Interval = defaultRetentionInterval.String()
+	}
+	if c.Bloom.Capacity <= 0 {
+		c.Bloom.Capacity = defaultBloomCapacity
+	}
+	if c.Bloom.FalsePositive <= 0 {
+		c.Bloom.FalsePositive = defaultBloomFalsePositive
+	}
+	if c.SIEM.Severity <= 0 {
+		c.SIEM.Severity = defaultSIEMSeverity
+	}
+	if c.Elasticsearch.Index == "" {
+		c.Elasticsearch.Index = defaultElasticIndex
+	}
+	if c.Elasticsearch.FlushInterval == "" {
+		c.Elasticsearch.FlushInterval = defaultFlushInterval.String()
+	}
+	if c.Database.Driver == "" && c.Database.DSN != "" {
+		c.Database.Driver = store.SQLite
+	}
+	if c.Log.Format == "" {
+		c.Log.Format = logFormatText
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"io/ioutil"
 	"path"
+	"strings"
 	"testing"
+
+	"github.com/FireFart/pastebin_scraper/store"
 )
 
 func TestGetConfig(t *testing.T) {
@@ -32,3 +36,46 @@ func TestGetConfigInvalid(t *testing.T) {
 		t.Fatal("expected error when reading config file but got none")
 	}
 }
+
+func TestGetConfigUnknownField(t *testing.T) {
+	f := path.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(f, []byte(`{"mailserver": "localhost", "mailsrever": "localhost"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := getConfig(f)
+	if err == nil {
+		t.Fatal("expected error on unknown field but got none")
+	}
+	if !strings.Contains(err.Error(), `unknown option "mailsrever"`) {
+		t.Fatalf("expected the unknown field in the error, got %v", err)
+	}
+}
+
+func TestGetConfigDefaults(t *testing.T) {
+	f := path.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(f, []byte(`{"mailto": "test@example.com", "timeout": "5s", "database": {"dsn": "matches.db"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := getConfig(f)
+	if err != nil {
+		t.Fatalf("got error when reading config file: %v", err)
+	}
+	if c.Mailport != defaultMailport {
+		t.Errorf("expected default mailport %d, got %d", defaultMailport, c.Mailport)
+	}
+	if c.Mailtoerror != "test@example.com" {
+		t.Errorf("expected mailtoerror to default to mailto, got %q", c.Mailtoerror)
+	}
+	if c.Timeout != "5s" {
+		t.Errorf("expected configured timeout to be kept, got %q", c.Timeout)
+	}
+	if c.Interval != defaultInterval.String() || c.Delay != defaultDelay.String() {
+		t.Errorf("expected default interval and delay, got %q %q", c.Interval, c.Delay)
+	}
+	if c.Database.Driver != store.SQLite {
+		t.Errorf("expected default driver %q, got %q", store.SQLite, c.Database.Driver)
+	}
+	if c.Elasticsearch.Index != defaultElasticIndex {
+		t.Errorf("expected default index %q, got %q", defaultElasticIndex, c.Elasticsearch.Index)
+	}
+}
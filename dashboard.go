@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// number of matches shown on the dashboard
+	dashboardRecentMatches = 50
+	// errors are counted over this window for the error rate
+	dashboardErrorWindow = 1 * time.Hour
+)
+
+var (
+	activity = newActivityLog()
+)
+
+// activityLog records the recent activity of the scraper for the dashboard
+type activityLog struct {
+	mu      sync.Mutex
+	started time.Time
+	checks  int
+	// time of the last successful fetch of the paste list
+	lastCheck time.Time
+	// date of the newest paste in the last list
+	newestPaste time.Time
+	errors      []time.Time
+	matches     []recentMatch
+	hits        map[string]int
+}
+
+type recentMatch struct {
+	Key     string
+	URL     string
+	Title   string
+	Author  string
+	Syntax  string
+	Size    string
+	Date    string
+	Expire  string
+	FoundAt time.Time
+	Groups  []string
+	Matches map[string][]string
+}
+
+type keywordHits struct {
+	Keyword string
+	Hits    int
+	// relative to the keyword with the most hits for the chart
+	Percent int
+}
+
+// dashboardStatus is a snapshot of the activity
+type dashboardStatus struct {
+	Now         time.Time
+	Uptime      time.Duration
+	Checks      int
+	LastCheck   time.Time
+	FeedLag     time.Duration
+	Errors      int
+	ErrorWindow time.Duration
+	Matches     []recentMatch
+	Hits        []keywordHits
+}
+
+func newActivityLog() *activityLog {
+	return &activityLog{started: time.Now(), hits: make(map[string]int)}
+}
+
+// checked records a successful fetch of the paste list
+func (a *activityLog) checked(t time.Time, pastes []paste) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.checks++
+	a.lastCheck = t
+	for _, p := range pastes {
+		if d := unixToTime(p.Date); d.Valid && d.Time.After(a.newestPaste) {
+			a.newestPaste = d.Time
+		}
+	}
+}
+
+func (a *activityLog) addError(t time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.errors = append(a.errors, t)
+	a.expireErrors(t)
+}
+
+func (a *activityLog) expireErrors(now time.Time) {
+	i := 0
+	for i < len(a.errors) && now.Sub(a.errors[i]) > dashboardErrorWindow {
+		i++
+	}
+	a.errors = a.errors[i:]
+}
+
+func (a *activityLog) addMatch(p paste, t time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for k := range p.Matches {
+		a.hits[k]++
+	}
+	m := recentMatch{
+		Key:     p.Key,
+		URL:     p.FullURL,
+		Title:   p.Title,
+		Author:  authorToString(p.User),
+		Syntax:  p.Syntax,
+		Size:    sizeToString(p.Size),
+		Date:    dateToString(p.Date),
+		Expire:  expireToString(p.Expire),
+		FoundAt: t,
+		Groups:  p.Groups,
+		Matches: p.Matches,
+	}
+	a.matches = append([]recentMatch{m}, a.matches...)
+	if len(a.matches) > dashboardRecentMatches {
+		a.matches = a.matches[:dashboardRecentMatches]
+	}
+}
+
+func (a *activityLog) status(now time.Time) dashboardStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expireErrors(now)
+	s := dashboardStatus{
+		Now:         now,
+		Uptime:      now.Sub(a.started).Round(time.Second),
+		Checks:      a.checks,
+		LastCheck:   a.lastCheck,
+		Errors:      len(a.errors),
+		ErrorWindow: dashboardErrorWindow,
+		Matches:     append([]recentMatch(nil), a.matches...),
+	}
+	if !a.newestPaste.IsZero() {
+		s.FeedLag = now.Sub(a.newestPaste).Round(time.Second)
+	}
+	most := 0
+	for k, v := range a.hits {
+		s.Hits = append(s.Hits, keywordHits{Keyword: k, Hits: v})
+		if v > most {
+			most = v
+		}
+	}
+	sort.Slice(s.Hits, func(i, j int) bool {
+		if s.Hits[i].Hits != s.Hits[j].Hits {
+			return s.Hits[i].Hits > s.Hits[j].Hits
+		}
+		return s.Hits[i].Keyword < s.Hits[j].Keyword
+	})
+	for i := range s.Hits {
+		s.Hits[i].Percent = s.Hits[i].Hits * 100 / most
+	}
+	return s
+}
+
+// highlight escapes the line and marks all occurrences of the keyword
+func highlight(line, keyword string) template.HTML {
+	lower := strings.ToLower(line)
+	k := strings.ToLower(keyword)
+	if k == "" || len(lower) != len(line) {
+		return template.HTML(template.HTMLEscapeString(line)) // nolint: gosec
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(lower, k)
+		if i < 0 {
+			break
+		}
+		b.WriteString(template.HTMLEscapeString(line[:i]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(line[i : i+len(k)]))
+		b.WriteString("</mark>")
+		line, lower = line[i+len(k):], lower[i+len(k):]
+	}
+	b.WriteString(template.HTMLEscapeString(line))
+	return template.HTML(b.String()) // nolint: gosec
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"highlight": highlight,
+	"since": func(now, t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return now.Sub(t).Round(time.Second).String() + " ago"
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="30">
+<title>Pastebin Scraper</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+td, th { padding: 0.3em 0.8em; text-align: left; vertical-align: top; }
+.status td:first-child { font-weight: bold; }
+.bar { background: #4a90d9; height: 1em; }
+.line { font-family: monospace; white-space: pre-wrap; word-break: break-all; }
+mark { background: #ffe066; }
+.matches tr { border-top: 1px solid #ddd; }
+</style>
+</head>
+<body>
+<h1>Pastebin Scraper</h1>
+<h2>Status</h2>
+<table class="status">
+<tr><td>Uptime</td><td>{{.Uptime}}</td></tr>
+<tr><td>Last check</td><td>{{since .Now .LastCheck}}</td></tr>
+<tr><td>Checks</td><td>{{.Checks}}</td></tr>
+<tr><td>Feed lag</td><td>{{if .FeedLag}}{{.FeedLag}}{{else}}unknown{{end}}</td></tr>
+<tr><td>Errors</td><td>{{.Errors}} in the last {{.ErrorWindow}}</td></tr>
+</table>
+<h2>Hits per keyword</h2>
+{{if .Hits}}<table>
+{{range .Hits}}<tr><td>{{.Keyword}}</td><td>{{.Hits}}</td><td style="width: 20em"><div class="bar" style="width: {{.Percent}}%"></div></td></tr>
+{{end}}</table>{{else}}<p>No matches yet.</p>{{end}}
+<h2>Recent matches</h2>
+{{if .Matches}}<table class="matches">
+<tr><th>Found</th><th>Paste</th><th>Groups</th><th>Matches</th></tr>
+{{range .Matches}}<tr>
+<td>{{since $.Now .FoundAt}}</td>
+<td><a href="{{.URL}}">{{.Key}}</a>{{if .Title}}<br>{{.Title}}{{end}}<br>by {{.Author}}, {{.Date}}<br>{{.Size}}{{if .Syntax}}, {{.Syntax}}{{end}}, expires {{.Expire}}</td>
+<td>{{range .Groups}}{{.}} {{end}}</td>
+<td>{{range $k, $lines := .Matches}}<b>{{$k}}</b>{{range $lines}}<div class="line">{{highlight . $k}}</div>{{end}}{{end}}</td>
+</tr>
+{{end}}</table>{{else}}<p>No matches yet.</p>{{end}}
+</body>
+</html>
+`))
+
+func dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, activity.status(time.Now())); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// serveDashboard serves the web dashboard until the context is canceled
+func serveDashboard(ctx context.Context, addr string, admin adminConfig, errs chan<- error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", dashboardHandler)
+	serveAdmin(ctx, "dashboard", addr, apiAuth(admin.Token, mux), admin, errs)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActivityLogStatus(t *testing.T) {
+	a := newActivityLog()
+	now := time.Unix(time.Now().Unix(), 0)
+	a.checked(now, []paste{
+		{Key: "a", Date: strconv.FormatInt(now.Add(-2*time.Minute).Unix(), 10)},
+		{Key: "b", Date: strconv.FormatInt(now.Add(-1*time.Minute).Unix(), 10)},
+	})
+	a.addError(now.Add(-2 * dashboardErrorWindow))
+	a.addError(now)
+	a.addMatch(paste{Key: "a", Matches: map[string][]string{"password": {"password=1"}, "admin": {"admin"}}}, now)
+	a.addMatch(paste{Key: "b", Matches: map[string][]string{"password": {"password=2"}}}, now)
+
+	s := a.status(now)
+	if s.Checks != 1 || s.Errors != 1 {
+		t.Fatalf("unexpected status: %+v", s)
+	}
+	if s.FeedLag != time.Minute {
+		t.Fatalf("expected feed lag of 1m, got %v", s.FeedLag)
+	}
+	if len(s.Matches) != 2 || s.Matches[0].Key != "b" {
+		t.Fatalf("expected the newest match first, got %+v", s.Matches)
+	}
+	expected := []keywordHits{{Keyword: "password", Hits: 2, Percent: 100}, {Keyword: "admin", Hits: 1, Percent: 50}}
+	if len(s.Hits) != 2 || s.Hits[0] != expected[0] || s.Hits[1] != expected[1] {
+		t.Fatalf("expected hits %v, got %v", expected, s.Hits)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tt := []struct {
+		line     string
+		keyword  string
+		expected string
+	}{
+		{line: "the Password is <b>", keyword: "password", expected: "the <mark>Password</mark> is &lt;b&gt;"},
+		{line: "pass pass", keyword: "pass", expected: "<mark>pass</mark> <mark>pass</mark>"},
+		{line: "10.0.0.1", keyword: "10.0.0.0/8", expected: "10.0.0.1"},
+	}
+	for _, x := range tt {
+		if got := string(highlight(x.line, x.keyword)); got != x.expected {
+			t.Errorf("expected %q, got %q", x.expected, got)
+		}
+	}
+}
+
+func TestDashboardHandler(t *testing.T) {
+	old := activity
+	defer func() { activity = old }()
+	activity = newActivityLog()
+	activity.addMatch(paste{Key: "abc", Title: "<script>", Matches: map[string][]string{"password": {"password=1"}}}, time.Now())
+
+	w := httptest.NewRecorder()
+	dashboardHandler(w, httptest.NewRequest("GET", "/", nil))
+	body := w.Body.String()
+	if w.Code != 200 || !strings.Contains(body, "<mark>password</mark>=1") {
+		t.Fatalf("unexpected response %d: %s", w.Code, body)
+	}
+	if strings.Contains(body, "<script>") {
+		t.Fatalf("expected the title to be escaped: %s", body)
+	}
+	w = httptest.NewRecorder()
+	dashboardHandler(w, httptest.NewRequest("GET", "/other", nil))
+	if w.Code != 404 {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+}
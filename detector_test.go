@@ -0,0 +1,14 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseDetectors(t *testing.T) {
+	if _, err := parseDetectors([]string{"sqldump"}); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if _, err := parseDetectors([]string{"invalid"}); err == nil {
+		t.Fatal("expected error on unknown detector")
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// elasticMapping is used when creating the index
+const elasticMapping = `{
+  "mappings": {
+    "properties": {
+      "key":       { "type": "keyword" },
+      "url":       { "type": "keyword" },
+      "title":     { "type": "text" },
+      "user":      { "type": "keyword" },
+      "syntax":    { "type": "keyword" },
+      "size":      { "type": "long" },
+      "date":      { "type": "date", "format": "epoch_second" },
+      "expire":    { "type": "date", "format": "epoch_second" },
+      "hash":      { "type": "keyword" },
+      "groups":    { "type": "keyword" },
+      "keywords":  { "type": "keyword" },
+      "matches": {
+        "type": "nested",
+        "properties": {
+          "keyword": { "type": "keyword" },
+          "line":    { "type": "text" }
+        }
+      },
+      "content":   { "type": "text" },
+      "hostname":  { "type": "keyword" },
+      "found_at":  { "type": "date" }
+    }
+  }
+}`
+
+type elasticMatch struct {
+	Keyword string `json:"keyword"`
+	Line    string `json:"line"`
+}
+
+type elasticDocument struct {
+	Key      string         `json:"key"`
+	URL      string         `json:"url"`
+	Title    string         `json:"title,omitempty"`
+	User     string         `json:"user,omitempty"`
+	Syntax   string         `json:"syntax,omitempty"`
+	Size     string         `json:"size,omitempty"`
+	Date     string         `json:"date,omitempty"`
+	Expire   string         `json:"expire,omitempty"`
+	Hash     string         `json:"hash,omitempty"`
+	Groups   []string       `json:"groups,omitempty"`
+	Keywords []string       `json:"keywords"`
+	Matches  []elasticMatch `json:"matches"`
+	Content  string         `json:"content,omitempty"`
+	Hostname string         `json:"hostname"`
+	FoundAt  time.Time      `json:"found_at"`
+}
+
+const (
+	defaultFlushInterval = 5 * time.Second
+	defaultElasticIndex  = "pastebin"
+	// maximum number of retries of a bulk request on backpressure
+	maxBulkRetries = 5
+	maxBulkBackoff = 30 * time.Second
+)
+
+// elasticSink indexes every matched paste into elasticsearch or
+// opensearch. In bulk mode the documents are queued and sent in batches
+// using the bulk api.
+type elasticSink struct {
+	config        elasticsearch
+	hostname      string
+	flushInterval time.Duration
+	// initial backoff when the cluster rejects documents
+	backoff time.Duration
+	queue   chan elasticDocument
+	done    chan struct{}
+}
+
+func newElasticSink(ctx context.Context, c elasticsearch) (*elasticSink, error) {
+	if c.Index == "" {
+		c.Index = defaultElasticIndex
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("could not get hostname: %v", err)
+	}
+	e := &elasticSink{config: c, hostname: hostname, flushInterval: defaultFlushInterval, backoff: 1 * time.Second}
+	if c.FlushInterval != "" {
+		e.flushInterval, err = time.ParseDuration(c.FlushInterval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid flush interval %q: %v", c.FlushInterval, err)
+		}
+	}
+	if err := e.ensureIndex(ctx); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// start runs the bulk indexer in the background if enabled. Errors
+// during flushing are sent to errs.
+func (e *elasticSink) start(ctx context.Context, errs chan<- error) {
+	if e.config.BulkSize <= 0 {
+		return
+	}
+	// the queue is bounded so a slow cluster blocks the senders
+	e.queue = make(chan elasticDocument, e.config.BulkSize*4)
+	e.done = make(chan struct{})
+	go e.run(ctx, errs)
+}
+
+func (e *elasticSink) run(ctx context.Context, errs chan<- error) {
+	defer close(e.done)
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	var batch []elasticDocument
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.bulk(ctx, batch); err != nil {
+			errs <- fmt.Errorf("bulk index: %v", err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case doc := <-e.queue:
+			batch = append(batch, doc)
+			if len(batch) >= e.config.BulkSize {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		case <-ctx.Done():
+			// send out everything still queued
+		drain:
+			for {
+				select {
+				case doc := <-e.queue:
+					batch = append(batch, doc)
+				default:
+					break drain
+				}
+			}
+			ctx2, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			flush(ctx2)
+			cancel()
+			return
+		}
+	}
+}
+
+// close waits for the bulk indexer to flush all queued documents
+func (e *elasticSink) close() {
+	if e.done != nil {
+		<-e.done
+	}
+}
+
+func (e *elasticSink) request(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	u := strings.TrimRight(e.config.URL, "/") + path
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if e.config.Username != "" {
+		req.SetBasicAuth(e.config.Username, e.config.Password)
+	}
+	return client.Do(req)
+}
+
+// ensureIndex creates the index with the mapping if it does not exist
+func (e *elasticSink) ensureIndex(ctx context.Context) error {
+	index := "/" + url.PathEscape(e.config.Index)
+	resp, err := e.request(ctx, http.MethodHead, index, nil)
+	if err != nil {
+		return fmt.Errorf("could not check index: %v", err)
+	}
+	if err := drainBody(resp); err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("unexpected status %s when checking index", resp.Status)
+	}
+
+	slog.Debug("creating elasticsearch index", "index", e.config.Index)
+	resp, err = e.request(ctx, http.MethodPut, index, []byte(elasticMapping))
+	if err != nil {
+		return fmt.Errorf("could not create index: %v", err)
+	}
+	return checkElasticResponse(resp)
+}
+
+func (e *elasticSink) document(p paste) elasticDocument {
+	doc := elasticDocument{
+		Key:      p.Key,
+		URL:      p.FullURL,
+		Title:    p.Title,
+		User:     p.User,
+		Syntax:   p.Syntax,
+		Size:     p.Size,
+		Date:     p.Date,
+		Expire:   p.Expire,
+		Hash:     p.Hash,
+		Groups:   p.Groups,
+		Keywords: getKeysFromMap(p.Matches),
+		Hostname: e.hostname,
+		FoundAt:  time.Now(),
+	}
+	// the api returns 0 for pastes without expiration
+	if doc.Expire == "0" {
+		doc.Expire = ""
+	}
+	sort.Strings(doc.Keywords)
+	for _, k := range doc.Keywords {
+		for _, line := range p.Matches[k] {
+			doc.Matches = append(doc.Matches, elasticMatch{Keyword: k, Line: line})
+		}
+	}
+	if e.config.Content {
+		doc.Content = p.Content
+	}
+	return doc
+}
+
+// indexPaste indexes the paste using its key as the document id
+func (e *elasticSink) indexPaste(ctx context.Context, p paste) error {
+	doc := e.document(p)
+	if e.queue != nil {
+		select {
+		case e.queue <- doc:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/%s/_doc/%s", url.PathEscape(e.config.Index), url.PathEscape(p.Key))
+	resp, err := e.request(ctx, http.MethodPut, path, b)
+	if err != nil {
+		return err
+	}
+	return checkElasticResponse(resp)
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// bulk sends the documents using the bulk api. Documents rejected with
+// 429 Too Many Requests or a server error are retried with an exponential
+// backoff, documents which failed for good are returned as error.
+func (e *elasticSink) bulk(ctx context.Context, docs []elasticDocument) error {
+	backoff := e.backoff
+	var failed []error
+	for i := 0; ; i++ {
+		retry, err := e.bulkRequest(ctx, docs)
+		if err != nil {
+			failed = append(failed, err)
+		}
+		if len(retry) == 0 {
+			return errors.Join(failed...)
+		}
+		if i >= maxBulkRetries {
+			return errors.Join(append(failed, fmt.Errorf("giving up on %d documents after %d retries", len(retry), i))...)
+		}
+		slog.Debug("elasticsearch rejected documents", "count", len(retry), "retry_in", backoff)
+		if !sleep(ctx, backoff) {
+			return errors.Join(append(failed, ctx.Err())...)
+		}
+		backoff *= 2
+		if backoff > maxBulkBackoff {
+			backoff = maxBulkBackoff
+		}
+		docs = retry
+	}
+}
+
+// bulkRequest sends a single bulk request and returns the documents
+// which should be retried. The error lists the documents which failed for
+// good, the retryable documents of the batch are returned along with it.
+func (e *elasticSink) bulkRequest(ctx context.Context, docs []elasticDocument) ([]elasticDocument, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, d := range docs {
+		action := map[string]map[string]string{
+			"index": {"_index": e.config.Index, "_id": d.Key},
+		}
+		if err := enc.Encode(action); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(d); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := e.request(ctx, http.MethodPost, "/_bulk", buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	body, err := httpRespBodyToString(resp)
+	if err != nil {
+		return nil, err
+	}
+	if transientStatus(resp.StatusCode) {
+		return docs, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("bulk request returned %s: %s", resp.Status, body)
+	}
+
+	var r bulkResponse
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		return nil, fmt.Errorf("could not parse bulk response: %v", err)
+	}
+	if !r.Errors {
+		return nil, nil
+	}
+	var retry []elasticDocument
+	var failed []string
+	for i, item := range r.Items {
+		for _, x := range item {
+			switch {
+			case transientStatus(x.Status) && i < len(docs):
+				retry = append(retry, docs[i])
+			case x.Status < 200 || x.Status > 299:
+				failed = append(failed, string(x.Error))
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return retry, fmt.Errorf("%d documents failed: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return retry, nil
+}
+
+func checkElasticResponse(resp *http.Response) error {
+	body, err := httpRespBodyToString(resp)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("elasticsearch returned %s: %s", resp.Status, body)
+	}
+	return nil
+}
+
+// drainBody reads and closes the body so the connection can be reused
+func drainBody(resp *http.Response) error {
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		resp.Body.Close() // nolint: errcheck,gosec
+		return err
+	}
+	return resp.Body.Close()
+}
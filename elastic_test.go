@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type elasticServer struct {
+	mu       sync.Mutex
+	indexes  map[string]bool
+	requests []string
+	docs     map[string]elasticDocument
+}
+
+func newElasticServer(t *testing.T) (*elasticServer, *httptest.Server) {
+	t.Helper()
+	e := &elasticServer{
+		indexes: make(map[string]bool),
+		docs:    make(map[string]elasticDocument),
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.requests = append(e.requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodHead:
+			if !e.indexes[r.URL.Path] {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == http.MethodPut && r.URL.Path == "/pastebin":
+			e.indexes[r.URL.Path] = true
+		case r.Method == http.MethodPut:
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Errorf("could not read body: %v", err)
+			}
+			var doc elasticDocument
+			if err := json.Unmarshal(b, &doc); err != nil {
+				t.Errorf("invalid document: %v", err)
+			}
+			e.docs[r.URL.Path] = doc
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	return e, ts
+}
+
+func TestElasticSink(t *testing.T) {
+	e, ts := newElasticServer(t)
+	defer ts.Close()
+
+	ctx := context.Background()
+	sink, err := newElasticSink(ctx, elasticsearch{URL: ts.URL, Content: true})
+	if err != nil {
+		t.Fatalf("could not create sink: %v", err)
+	}
+	// the index exists now and should not be created again
+	if _, err := newElasticSink(ctx, elasticsearch{URL: ts.URL}); err != nil {
+		t.Fatalf("could not create sink: %v", err)
+	}
+
+	p := paste{
+		Key:     "abc",
+		Expire:  "0",
+		Content: "content",
+		Matches: map[string][]string{"b": {"line b"}, "a": {"line a1", "line a2"}},
+	}
+	if err := sink.indexPaste(ctx, p); err != nil {
+		t.Fatalf("could not index paste: %v", err)
+	}
+
+	expected := []string{"HEAD /pastebin", "PUT /pastebin", "HEAD /pastebin", "PUT /pastebin/_doc/abc"}
+	if len(e.requests) != len(expected) {
+		t.Fatalf("unexpected requests: %v", e.requests)
+	}
+	for i := range expected {
+		if e.requests[i] != expected[i] {
+			t.Fatalf("unexpected request %q, expected %q", e.requests[i], expected[i])
+		}
+	}
+
+	doc := e.docs["/pastebin/_doc/abc"]
+	if doc.Content != "content" || doc.Expire != "" || len(doc.Matches) != 3 || doc.Matches[0].Keyword != "a" {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+}
+
+func TestElasticSinkError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+	if _, err := newElasticSink(context.Background(), elasticsearch{URL: ts.URL}); err == nil {
+		t.Fatal("expected error on unauthorized response")
+	}
+}
+
+func TestElasticSinkBulk(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	var ids []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		if r.URL.Path != "/_bulk" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		dec := json.NewDecoder(r.Body)
+		var n int
+		for dec.More() {
+			var action map[string]map[string]string
+			if err := dec.Decode(&action); err != nil {
+				t.Errorf("invalid action: %v", err)
+				return
+			}
+			var doc elasticDocument
+			if err := dec.Decode(&doc); err != nil {
+				t.Errorf("invalid document: %v", err)
+				return
+			}
+			ids = append(ids, action["index"]["_id"])
+			n++
+		}
+		switch calls {
+		case 1:
+			// reject the whole request
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			// reject the second document
+			fmt.Fprint(w, `{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":429,"error":{"type":"es_rejected_execution_exception"}}}]}`)
+		default:
+			fmt.Fprint(w, `{"errors":false,"items":[]}`)
+		}
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	sink, err := newElasticSink(ctx, elasticsearch{URL: ts.URL})
+	if err != nil {
+		t.Fatalf("could not create sink: %v", err)
+	}
+	sink.backoff = time.Millisecond
+	docs := []elasticDocument{{Key: "a"}, {Key: "b"}}
+	if err := sink.bulk(ctx, docs); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	expected := []string{"a", "b", "a", "b", "b"}
+	if calls != 3 || len(ids) != len(expected) {
+		t.Fatalf("unexpected bulk requests: %d calls with ids %v", calls, ids)
+	}
+	for i := range expected {
+		if ids[i] != expected[i] {
+			t.Fatalf("unexpected ids %v, expected %v", ids, expected)
+		}
+	}
+}
+
+func TestElasticSinkBulkPartialFailure(t *testing.T) {
+	var mu sync.Mutex
+	var ids [][]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		var batch []string
+		dec := json.NewDecoder(r.Body)
+		for dec.More() {
+			var action map[string]map[string]string
+			var doc elasticDocument
+			if err := dec.Decode(&action); err != nil || dec.Decode(&doc) != nil {
+				t.Errorf("invalid bulk body: %v", err)
+				return
+			}
+			batch = append(batch, action["index"]["_id"])
+		}
+		ids = append(ids, batch)
+		if len(ids) == 1 {
+			// one document is broken, the others hit a busy or failing shard
+			fmt.Fprint(w, `{"errors":true,"items":[{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}},{"index":{"status":429}},{"index":{"status":503}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"errors":false,"items":[]}`)
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	sink, err := newElasticSink(ctx, elasticsearch{URL: ts.URL})
+	if err != nil {
+		t.Fatalf("could not create sink: %v", err)
+	}
+	sink.backoff = time.Millisecond
+	err = sink.bulk(ctx, []elasticDocument{{Key: "a"}, {Key: "b"}, {Key: "c"}})
+	if err == nil || !strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Fatalf("expected the failed document in the error, got %v", err)
+	}
+	if len(ids) != 2 || !reflect.DeepEqual(ids[1], []string{"b", "c"}) {
+		t.Fatalf("expected the retryable documents to be retried, got %v", ids)
+	}
+}
+
+func TestElasticSinkBulkQueue(t *testing.T) {
+	var mu sync.Mutex
+	var docs int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		dec := json.NewDecoder(r.Body)
+		for dec.More() {
+			var x json.RawMessage
+			if err := dec.Decode(&x); err != nil {
+				t.Errorf("invalid body: %v", err)
+				return
+			}
+			docs++
+		}
+		fmt.Fprint(w, `{"errors":false,"items":[]}`)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sink, err := newElasticSink(ctx, elasticsearch{URL: ts.URL, BulkSize: 10, FlushInterval: "1h"})
+	if err != nil {
+		t.Fatalf("could not create sink: %v", err)
+	}
+	errs := make(chan error, 10)
+	sink.start(ctx, errs)
+	for i := 0; i < 3; i++ {
+		if err := sink.indexPaste(ctx, paste{Key: fmt.Sprintf("%d", i)}); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+	}
+	// shutting down flushes the queue
+	cancel()
+	sink.close()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("got error: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// every document consists of an action and the document itself
+	if docs != 6 {
+		t.Fatalf("expected 3 documents to be sent, got %d lines", docs)
+	}
+}
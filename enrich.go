@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// upper bound of the lookups for one paste, slow services delay the
+	// alert at most this long
+	defaultEnrichTimeout = time.Minute
+	// how long the result of a lookup is reused for other pastes
+	defaultEnrichCacheTTL = 24 * time.Hour
+	// cached lookups per service before expired entries are dropped
+	enrichCacheSize = 10000
+)
+
+var (
+	// the lookups are not sent through the proxy or tor of the scraper
+	enrichClient = &http.Client{}
+
+	metricEnrichLookups = metrics.counter("enrichment_lookups_total", "Number of lookups sent to enrichment services.", "service")
+	metricEnrichErrors  = metrics.counter("enrichment_errors_total", "Number of failed lookups of enrichment services.", "service")
+)
+
+// enrichment holds the results of the external lookups for the
+// indicators of a paste
+type enrichment struct {
+	VirusTotal []virusTotalResult `json:"virustotal,omitempty"`
+	HIBP       []hibpResult       `json:"hibp,omitempty"`
+	GeoIP      []geoIPResult      `json:"geoip,omitempty"`
+	RDAP       []rdapResult       `json:"rdap,omitempty"`
+}
+
+func (e *enrichment) empty() bool {
+	return len(e.VirusTotal) == 0 && len(e.HIBP) == 0 && len(e.GeoIP) == 0 && len(e.RDAP) == 0
+}
+
+// write appends the results to the alert text
+func (e *enrichment) write(w io.Writer) error {
+	if e == nil || e.empty() {
+		return nil
+	}
+	sections := []struct {
+		name    string
+		results []fmt.Stringer
+	}{
+		{"GeoIP", stringers(e.GeoIP)},
+		{"VirusTotal", stringers(e.VirusTotal)},
+		{"Have I Been Pwned", stringers(e.HIBP)},
+		{"Domain registrations", stringers(e.RDAP)},
+	}
+	for _, x := range sections {
+		if len(x.results) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "\n%s:\n", x.name); err != nil {
+			return err
+		}
+		for _, r := range x.results {
+			if _, err := fmt.Fprintf(w, "  %s\n", r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func stringers[T fmt.Stringer](in []T) []fmt.Stringer {
+	ret := make([]fmt.Stringer, 0, len(in))
+	for _, x := range in {
+		ret = append(ret, x)
+	}
+	return ret
+}
+
+// enrichPaste looks up the extracted indicators of a matched paste at the
+// configured services. Failed lookups are logged and left out of the
+// alert, they never hold back the alert itself.
+func enrichPaste(ctx context.Context, c enrichmentConfig, p *paste) {
+	if p.IOCs == nil || p.IOCs.empty() {
+		return
+	}
+	timeout := defaultEnrichTimeout
+	if c.Timeout != "" {
+		if d, err := time.ParseDuration(c.Timeout); err == nil {
+			timeout = d
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	e := &enrichment{}
+	if c.GeoIP.Database != "" || c.GeoIP.Asndatabase != "" {
+		results, watched, err := geoIP.lookup(c.GeoIP, p.IOCs.IPs)
+		if err != nil {
+			metricEnrichErrors.inc("geoip")
+			logger(componentNotifier).Warn("geoip lookup failed", "paste_key", p.Key, "error", err)
+		}
+		e.GeoIP = results
+		if watched {
+			group := c.GeoIP.Watchgroup
+			if group == "" {
+				group = defaultGeoIPWatchGroup
+			}
+			if !stringInSlice(group, p.Groups) {
+				p.Groups = append(p.Groups, group)
+			}
+		}
+	}
+	if c.Virustotal.APIKey != "" {
+		e.VirusTotal = virusTotal.lookup(ctx, c.Virustotal, p.IOCs)
+	}
+	if c.HIBP.APIKey != "" {
+		e.HIBP = hibp.lookup(ctx, c.HIBP, p.IOCs)
+	}
+	if c.RDAP.Enabled {
+		e.RDAP = rdap.lookup(ctx, c.RDAP, p.IOCs)
+	}
+	if !e.empty() {
+		p.Enrichment = e
+	}
+}
+
+// lookupCache keeps the results of a service so indicators found in
+// several pastes are only looked up once
+type lookupCache[T any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry[T]
+}
+
+type cacheEntry[T any] struct {
+	value   T
+	expires time.Time
+}
+
+func newLookupCache[T any](ttl time.Duration) *lookupCache[T] {
+	return &lookupCache[T]{ttl: ttl, entries: make(map[string]cacheEntry[T])}
+}
+
+func (c *lookupCache[T]) get(key string, now time.Time) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || now.After(e.expires) {
+		var zero T
+		return zero, false
+	}
+	return e.value, true
+}
+
+func (c *lookupCache[T]) put(key string, v T, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= enrichCacheSize {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= enrichCacheSize {
+			c.entries = make(map[string]cacheEntry[T])
+		}
+	}
+	c.entries[key] = cacheEntry[T]{value: v, expires: now.Add(c.ttl)}
+}
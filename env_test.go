@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestApplyEnvOverrides(t *testing.T) {
+	t.Setenv("PASTEBIN_SCRAPER_MAILSERVER", "smtp.example.com")
+	t.Setenv("PASTEBIN_SCRAPER_MAILPORT", "587")
+	t.Setenv("PASTEBIN_SCRAPER_NORMALIZE", "true")
+	t.Setenv("PASTEBIN_SCRAPER_BLOCKLIST", "a, b,")
+	t.Setenv("PASTEBIN_SCRAPER_DATABASE_DSN", "postgres://secret@db/scraper")
+	t.Setenv("PASTEBIN_SCRAPER_ARCHIVE_S3_SECRETKEY", "secret")
+	t.Setenv("PASTEBIN_SCRAPER_BLOOM_FALSEPOSITIVE", "0.01")
+
+	var c configuration
+	if err := applyEnvOverrides(&c); err != nil {
+		t.Fatalf("could not apply overrides: %v", err)
+	}
+	if c.Mailserver != "smtp.example.com" || c.Mailport != 587 || !c.Normalize {
+		t.Fatalf("top level values were not set: %+v", c)
+	}
+	if !reflect.DeepEqual(c.Blocklist, []string{"a", "b"}) {
+		t.Fatalf("unexpected list %v", c.Blocklist)
+	}
+	if c.Database.DSN != "postgres://secret@db/scraper" || c.Archive.S3.SecretKey != "secret" || c.Bloom.FalsePositive != 0.01 {
+		t.Fatal("nested values were not set")
+	}
+}
+
+func TestApplyEnvOverridesInvalid(t *testing.T) {
+	for k, v := range map[string]string{
+		"PASTEBIN_SCRAPER_MAILPORT": "smtp",
+		"PASTEBIN_SCRAPER_KEYWORDS": "password",
+	} {
+		t.Run(k, func(t *testing.T) {
+			t.Setenv(k, v)
+			var c configuration
+			if err := applyEnvOverrides(&c); err == nil {
+				t.Fatalf("expected error for %s", k)
+			}
+		})
+	}
+}
+
+func TestGetConfigEnvOverride(t *testing.T) {
+	t.Setenv("PASTEBIN_SCRAPER_MAILTO", "env@example.com")
+	c, err := getConfig(path.Join("testdata", "test.json"))
+	if err != nil {
+		t.Fatalf("got error when reading config file: %v", err)
+	}
+	if c.Mailto != "env@example.com" {
+		t.Fatalf("config file value was not overridden: %q", c.Mailto)
+	}
+}
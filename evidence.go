@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	chainFile = "chain.log"
+	// previous hash of the first entry
+	chainGenesis = "0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+// hashChain is an append-only log of all files written in evidence mode.
+// Every entry contains the hash of the file and a hash over the entry
+// and the previous hash, so removing or changing entries or files can
+// be detected.
+type hashChain struct {
+	mu   sync.Mutex
+	file string
+	last string
+}
+
+type chainEntry struct {
+	time     string
+	path     string
+	fileHash string
+	hash     string
+}
+
+func (e chainEntry) chainHash(previous string) string {
+	return sha256Hex([]byte(strings.Join([]string{previous, e.time, e.path, e.fileHash}, " ")))
+}
+
+// openHashChain verifies the existing chain in dir and continues it
+func openHashChain(dir string) (*hashChain, error) {
+	f := filepath.Join(dir, chainFile)
+	last, _, err := verifyChain(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	return &hashChain{file: f, last: last}, nil
+}
+
+// add appends an entry for the file relative to the archive directory
+func (c *hashChain) add(path, fileHash string, t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := chainEntry{time: t.UTC().Format(time.RFC3339Nano), path: filepath.ToSlash(path), fileHash: fileHash}
+	e.hash = e.chainHash(c.last)
+	f, err := os.OpenFile(c.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640) // nolint: gosec
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%s %s %s %s\n", e.time, e.path, e.fileHash, e.hash); err != nil {
+		f.Close() // nolint: errcheck,gosec
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close() // nolint: errcheck,gosec
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	c.last = e.hash
+	return nil
+}
+
+// verifyChain checks all entries of the chain in dir and, if files is set,
+// the hashes of the archived files. It returns the last hash and the
+// number of entries.
+func verifyChain(dir string, files bool) (string, int, error) {
+	f, err := os.Open(filepath.Join(dir, chainFile)) // nolint: gosec
+	if os.IsNotExist(err) {
+		return chainGenesis, 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close() // nolint: errcheck
+
+	last := chainGenesis
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		n++
+		parts := strings.Fields(scanner.Text())
+		if len(parts) != 4 {
+			return "", n, fmt.Errorf("invalid chain entry on line %d", n)
+		}
+		e := chainEntry{time: parts[0], path: parts[1], fileHash: parts[2], hash: parts[3]}
+		if e.chainHash(last) != e.hash {
+			return "", n, fmt.Errorf("chain is broken on line %d", n)
+		}
+		if files {
+			b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(e.path)))
+			if err != nil {
+				return "", n, fmt.Errorf("could not read %s: %v", e.path, err)
+			}
+			if sha256Hex(b) != e.fileHash {
+				return "", n, fmt.Errorf("hash of %s does not match", e.path)
+			}
+		}
+		last = e.hash
+	}
+	return last, n, scanner.Err()
+}
+
+// writeFileOnce writes the file only if it does not exist yet. The
+// content is written to a temporary file first and hard linked so the
+// file is never overwritten. It returns false if the file already existed.
+func writeFileOnce(f string, b []byte, perm os.FileMode) (bool, error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(f), "."+filepath.Base(f))
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name()) // nolint: errcheck
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close() // nolint: errcheck,gosec
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return false, err
+	}
+	if err := os.Link(tmp.Name(), f); err != nil {
+		if os.IsExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// runVerify implements the verify subcommand. It checks the hash chain
+// and all files of the local evidence archive.
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	configFile := fs.String("config", "", "Config File to use")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := getConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("could not read config file %s: %v", *configFile, err)
+	}
+	if config.Archive.Local.Directory == "" {
+		return fmt.Errorf("no local archive configured")
+	}
+	last, n, err := verifyChain(config.Archive.Local.Directory, true)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("verified %d files, last hash %s\n", n, last)
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEvidenceArchive(t *testing.T) {
+	dir := t.TempDir()
+	l, err := newLocalArchiver(local{Directory: dir, Evidence: true})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	ctx := context.Background()
+	p := paste{Key: "abc", Date: "1590000000", Content: "original"}
+	f, err := l.archive(ctx, p)
+	if err != nil {
+		t.Fatalf("could not archive: %v", err)
+	}
+	p.Content = "changed"
+	if _, err := l.archive(ctx, p); err != nil {
+		t.Fatalf("could not archive again: %v", err)
+	}
+	if _, err := l.archive(ctx, paste{Key: "def", Date: "1590000000", Content: "other"}); err != nil {
+		t.Fatalf("could not archive: %v", err)
+	}
+
+	archived, err := readArchivedPaste(f)
+	if err != nil {
+		t.Fatalf("could not read archived paste: %v", err)
+	}
+	if archived.Content != "original" {
+		t.Fatalf("archived file was overwritten: %q", archived.Content)
+	}
+	info, err := os.Stat(f)
+	if err != nil {
+		t.Fatalf("could not stat: %v", err)
+	}
+	if info.Mode().Perm()&0222 != 0 {
+		t.Fatalf("archived file is writable: %v", info.Mode())
+	}
+
+	// content and metadata of two pastes
+	last, n, err := verifyChain(dir, true)
+	if err != nil {
+		t.Fatalf("chain is invalid: %v", err)
+	}
+	if n != 4 {
+		t.Fatalf("expected 4 chain entries, got %d", n)
+	}
+
+	// the chain is continued after a restart
+	l2, err := newLocalArchiver(local{Directory: dir, Evidence: true})
+	if err != nil {
+		t.Fatalf("could not reopen: %v", err)
+	}
+	if l2.chain.last != last {
+		t.Fatal("chain was not continued")
+	}
+}
+
+func TestVerifyChainTampered(t *testing.T) {
+	dir := t.TempDir()
+	l, err := newLocalArchiver(local{Directory: dir, Evidence: true})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	ctx := context.Background()
+	for _, k := range []string{"abc", "def"} {
+		if _, err := l.archive(ctx, paste{Key: k, Date: "1590000000", Content: k}); err != nil {
+			t.Fatalf("could not archive: %v", err)
+		}
+	}
+
+	chain := filepath.Join(dir, chainFile)
+	b, err := ioutil.ReadFile(chain)
+	if err != nil {
+		t.Fatalf("could not read chain: %v", err)
+	}
+	// remove the first entry
+	lines := strings.SplitAfter(string(b), "\n")
+	if err := ioutil.WriteFile(chain, []byte(strings.Join(lines[1:], "")), 0600); err != nil {
+		t.Fatalf("could not write chain: %v", err)
+	}
+	if _, _, err := verifyChain(dir, false); err == nil {
+		t.Fatal("expected error on removed entry")
+	}
+	if _, err := newLocalArchiver(local{Directory: dir, Evidence: true}); err == nil {
+		t.Fatal("expected error on broken chain")
+	}
+
+	// restore the chain and change a file
+	if err := ioutil.WriteFile(chain, b, 0600); err != nil {
+		t.Fatalf("could not write chain: %v", err)
+	}
+	f := filepath.Join(dir, "2020", "05", "20", "abc.json")
+	if err := os.Chmod(f, 0600); err != nil {
+		t.Fatalf("could not chmod: %v", err)
+	}
+	if err := ioutil.WriteFile(f, []byte("{}"), 0600); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+	if _, _, err := verifyChain(dir, false); err != nil {
+		t.Fatalf("chain itself should still be valid: %v", err)
+	}
+	if _, _, err := verifyChain(dir, true); err == nil {
+		t.Fatal("expected error on changed file")
+	}
+}
+
+func TestEvidenceNoPruning(t *testing.T) {
+	if _, err := newLocalArchiver(local{Directory: t.TempDir(), Evidence: true, MaxAge: "1h"}); err == nil {
+		t.Fatal("expected error on max age in evidence mode")
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/FireFart/pastebin_scraper/store"
+)
+
+const (
+	formatCSV   = "csv"
+	formatJSONL = "jsonl"
+)
+
+// matchRecord is a single matched line. The field names are stable so
+// exports can be loaded into spreadsheets and data pipelines.
+type matchRecord struct {
+	Hostname string `json:"hostname"`
+	Key      string `json:"key"`
+	URL      string `json:"url"`
+	Date     string `json:"date"`
+	Hash     string `json:"hash"`
+	Keyword  string `json:"keyword"`
+	Line     string `json:"line"`
+	FoundAt  string `json:"found_at"`
+	// only known for new matches, not part of the csv export
+	Archived []string `json:"archived,omitempty"`
+}
+
+var matchRecordHeader = []string{"hostname", "key", "url", "date", "hash", "keyword", "line", "found_at"}
+
+func (r matchRecord) csv() []string {
+	return []string{r.Hostname, r.Key, r.URL, r.Date, r.Hash, r.Keyword, r.Line, r.FoundAt}
+}
+
+func formatRecordTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// pasteRecords returns a record for every matched line sorted by keyword
+func pasteRecords(p paste, hostname string, foundAt time.Time) []matchRecord {
+	keywords := getKeysFromMap(p.Matches)
+	sort.Strings(keywords)
+	var ret []matchRecord
+	for _, k := range keywords {
+		for _, line := range p.Matches[k] {
+			ret = append(ret, matchRecord{
+				Hostname: hostname,
+				Key:      p.Key,
+				URL:      p.FullURL,
+				Date:     formatRecordTime(unixToTime(p.Date).Time),
+				Hash:     p.Hash,
+				Keyword:  k,
+				Line:     line,
+				FoundAt:  formatRecordTime(foundAt),
+				Archived: p.Archived,
+			})
+		}
+	}
+	return ret
+}
+
+// jsonlSink appends every match as a json line to a file
+type jsonlSink struct {
+	mu       sync.Mutex
+	f        *os.File
+	hostname string
+}
+
+func newJSONLSink(f string) (*jsonlSink, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("could not get hostname: %v", err)
+	}
+	file, err := os.OpenFile(f, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640) // nolint: gosec
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %v", f, err)
+	}
+	return &jsonlSink{f: file, hostname: hostname}, nil
+}
+
+func (j *jsonlSink) write(p paste) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	enc := json.NewEncoder(j.f)
+	for _, r := range pasteRecords(p, j.hostname, time.Now()) {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *jsonlSink) close() error {
+	return j.f.Close()
+}
+
+// pasteEvent is the json line of a match written to stdout and the event
+// stream
+type pasteEvent struct {
+	paste
+	FoundAt string `json:"found_at"`
+}
+
+func newPasteEvent(p paste, foundAt time.Time) pasteEvent {
+	return pasteEvent{p, formatRecordTime(foundAt)}
+}
+
+// pasteLineSink writes every matched paste as one json line, used for
+// -output json to pipe the matches into jq
+type pasteLineSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newPasteLineSink(w io.Writer) *pasteLineSink {
+	return &pasteLineSink{enc: json.NewEncoder(w)}
+}
+
+func (s *pasteLineSink) write(p paste, foundAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(newPasteEvent(p, foundAt))
+}
+
+// storedRecord returns the record of a match stored in the database
+func storedRecord(m store.Match) matchRecord {
+	return matchRecord{
+		Hostname: m.Hostname,
+		Key:      m.Key,
+		URL:      m.URL,
+		Date:     formatRecordTime(m.Date),
+		Hash:     m.Hash,
+		Keyword:  m.Keyword,
+		Line:     m.Line,
+		FoundAt:  formatRecordTime(m.FoundAt),
+	}
+}
+
+// writeExport writes all stored matches in the given format
+func writeExport(ctx context.Context, w io.Writer, s *store.SQL, format string) error {
+	switch format {
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(matchRecordHeader); err != nil {
+			return err
+		}
+		if err := s.Export(ctx, func(m store.Match) error {
+			return cw.Write(storedRecord(m).csv())
+		}); err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	case formatJSONL:
+		enc := json.NewEncoder(w)
+		return s.Export(ctx, func(m store.Match) error {
+			return enc.Encode(storedRecord(m))
+		})
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// runExport implements the export subcommand. It exports all matches
+// from the database of the config file.
+func runExport(args []string) (err error) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	configFile := fs.String("config", "", "Config File to use")
+	format := fs.String("format", formatCSV, "output format: csv or jsonl")
+	output := fs.String("output", "", "output file, defaults to stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := getConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("could not read config file %s: %v", *configFile, err)
+	}
+	if config.Database.Driver == "" {
+		return fmt.Errorf("no database configured")
+	}
+	db, err := store.Open(config.Database.Driver, config.Database.DSN)
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer db.Close() // nolint: errcheck
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}()
+		w = f
+	}
+	return writeExport(context.Background(), w, db, *format)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FireFart/pastebin_scraper/store"
+)
+
+func TestPasteRecords(t *testing.T) {
+	p := paste{
+		Key:     "abc",
+		FullURL: "https://pastebin.com/abc",
+		Date:    "1590000000",
+		Hash:    "hash",
+		Matches: map[string][]string{"b": {"line 2"}, "a": {"line 1"}},
+	}
+	records := pasteRecords(p, "host", time.Unix(1590000100, 0))
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	expected := matchRecord{
+		Hostname: "host",
+		Key:      "abc",
+		URL:      "https://pastebin.com/abc",
+		Date:     "2020-05-20T18:40:00Z",
+		Hash:     "hash",
+		Keyword:  "a",
+		Line:     "line 1",
+		FoundAt:  "2020-05-20T18:41:40Z",
+	}
+	if !reflect.DeepEqual(records[0], expected) {
+		t.Fatalf("unexpected record %+v", records[0])
+	}
+	if len(records[0].csv()) != len(matchRecordHeader) {
+		t.Fatal("csv columns do not match the header")
+	}
+}
+
+func TestJSONLSink(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "matches.jsonl")
+	j, err := newJSONLSink(f)
+	if err != nil {
+		t.Fatalf("could not open sink: %v", err)
+	}
+	p := paste{Key: "abc", Matches: map[string][]string{"a": {"line 1", "line 2"}}}
+	if err := j.write(p); err != nil {
+		t.Fatalf("could not write: %v", err)
+	}
+	if err := j.close(); err != nil {
+		t.Fatalf("could not close: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		t.Fatalf("could not read file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	var r matchRecord
+	if err := json.Unmarshal([]byte(lines[1]), &r); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if r.Key != "abc" || r.Line != "line 2" {
+		t.Fatalf("unexpected record %+v", r)
+	}
+}
+
+func TestWriteExport(t *testing.T) {
+	s, err := store.Open(store.SQLite, filepath.Join(t.TempDir(), "matches.db"))
+	if err != nil {
+		t.Fatalf("could not open store: %v", err)
+	}
+	defer s.Close() // nolint: errcheck
+
+	ctx := context.Background()
+	p := paste{Key: "abc", Date: "1590000000", Hash: "hash", Matches: map[string][]string{"a": {"line, with comma"}}}
+	if err := s.SaveMatches(ctx, p.alert(), time.Now()); err != nil {
+		t.Fatalf("could not save matches: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeExport(ctx, &buf, s, formatCSV); err != nil {
+		t.Fatalf("could not export: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[0] != strings.Join(matchRecordHeader, ",") {
+		t.Fatalf("unexpected csv: %s", buf.String())
+	}
+	if !strings.Contains(lines[1], `,abc,,2020-05-20T18:40:00Z,hash,a,"line, with comma",`) {
+		t.Fatalf("unexpected csv row: %s", lines[1])
+	}
+
+	buf.Reset()
+	if err := writeExport(ctx, &buf, s, formatJSONL); err != nil {
+		t.Fatalf("could not export: %v", err)
+	}
+	var r matchRecord
+	if err := json.Unmarshal(buf.Bytes(), &r); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if r.Hash != "hash" || r.Line != "line, with comma" {
+		t.Fatalf("unexpected record %+v", r)
+	}
+
+	if err := writeExport(ctx, &buf, s, "xml"); err == nil {
+		t.Fatal("expected error on unsupported format")
+	}
+}
+
+func TestPasteLineSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := newPasteLineSink(&buf)
+	p := paste{Key: "abc", FullURL: "https://pastebin.com/abc", Content: "secret", Matches: map[string][]string{"password": {"password=1"}}}
+	if err := s.write(p, time.Unix(1600000000, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.write(paste{Key: "def"}, time.Unix(1600000000, 0)); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected two lines, got %q", buf.String())
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["key"] != "abc" || got["found_at"] != "2020-09-13T12:26:40Z" || got["matches"] == nil {
+		t.Errorf("unexpected line %s", lines[0])
+	}
+	if strings.Contains(lines[0], "secret") {
+		t.Errorf("the content must not be written: %s", lines[0])
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// indicators not seen again within this time drop out of the feed
+	defaultFeedMaxAge = 30 * 24 * time.Hour
+	// upper bound of the indicators in the feed, the oldest are dropped
+	maxFeedEntries = 100000
+	// paste keys kept per indicator
+	maxFeedPastes = 10
+)
+
+var (
+	// indicators extracted from the matched pastes for the feed endpoint
+	iocFeed = newIndicatorFeed(defaultFeedMaxAge)
+)
+
+type feedEntry struct {
+	Type      string    `json:"type"`
+	Value     string    `json:"value"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// number of pastes the indicator was found in
+	Count    int      `json:"count"`
+	Pastes   []string `json:"pastes"`
+	Keywords []string `json:"keywords"`
+}
+
+// indicatorFeed collects the deduplicated indicators of all matches
+type indicatorFeed struct {
+	mu      sync.Mutex
+	maxAge  time.Duration
+	entries map[string]*feedEntry
+}
+
+func newIndicatorFeed(maxAge time.Duration) *indicatorFeed {
+	return &indicatorFeed{maxAge: maxAge, entries: make(map[string]*feedEntry)}
+}
+
+func (f *indicatorFeed) setMaxAge(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.maxAge = d
+}
+
+// add records the indicators of a matched paste
+func (f *indicatorFeed) add(p paste, now time.Time) {
+	if p.IOCs == nil || p.IOCs.empty() {
+		return
+	}
+	keywords := getKeysFromMap(p.Matches)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	add := func(kind string, values []string) {
+		for _, v := range values {
+			e, ok := f.entries[kind+":"+v]
+			if !ok {
+				e = &feedEntry{Type: kind, Value: v, FirstSeen: now}
+				f.entries[kind+":"+v] = e
+			}
+			e.LastSeen = now
+			if !stringInSlice(p.Key, e.Pastes) {
+				e.Count++
+				e.Pastes = append(e.Pastes, p.Key)
+				if len(e.Pastes) > maxFeedPastes {
+					e.Pastes = e.Pastes[len(e.Pastes)-maxFeedPastes:]
+				}
+			}
+			for _, k := range keywords {
+				if !stringInSlice(k, e.Keywords) {
+					e.Keywords = append(e.Keywords, k)
+				}
+			}
+		}
+	}
+	add("ip", p.IOCs.IPs)
+	add("domain", p.IOCs.Domains)
+	add("url", p.IOCs.URLs)
+	add("email", p.IOCs.Emails)
+	add("hash", p.IOCs.Hashes)
+	f.expire(now)
+}
+
+// expire drops the indicators older than the max age and the oldest ones
+// above the size limit
+func (f *indicatorFeed) expire(now time.Time) {
+	for k, e := range f.entries {
+		if f.maxAge > 0 && now.Sub(e.LastSeen) > f.maxAge {
+			delete(f.entries, k)
+		}
+	}
+	if len(f.entries) <= maxFeedEntries {
+		return
+	}
+	keys := make([]string, 0, len(f.entries))
+	for k := range f.entries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return f.entries[keys[i]].LastSeen.Before(f.entries[keys[j]].LastSeen) })
+	for _, k := range keys[:len(keys)-maxFeedEntries] {
+		delete(f.entries, k)
+	}
+}
+
+// list returns copies of the indicators of the given type seen since the
+// given time, newest first
+func (f *indicatorFeed) list(kind string, since, now time.Time) []feedEntry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expire(now)
+	ret := make([]feedEntry, 0, len(f.entries))
+	for _, e := range f.entries {
+		if (kind != "" && e.Type != kind) || e.LastSeen.Before(since) {
+			continue
+		}
+		c := *e
+		c.Pastes = append([]string(nil), e.Pastes...)
+		c.Keywords = append([]string(nil), e.Keywords...)
+		sort.Strings(c.Keywords)
+		ret = append(ret, c)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if !ret[i].LastSeen.Equal(ret[j].LastSeen) {
+			return ret[i].LastSeen.After(ret[j].LastSeen)
+		}
+		return ret[i].Type+ret[i].Value < ret[j].Type+ret[j].Value
+	})
+	return ret
+}
+
+// feedHandler serves the indicators as json, csv or as plain list with
+// one value per line for firewall blocklists
+func feedHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	kind := q.Get("type")
+	if kind != "" && kind != "ip" && kind != "domain" && kind != "url" && kind != "email" && kind != "hash" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid type %q", kind)})
+		return
+	}
+	var since time.Time
+	if s := q.Get("since"); s != "" {
+		if d, err := time.ParseDuration(s); err == nil {
+			since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, s); err == nil {
+			since = t
+		} else if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			since = time.Unix(i, 0)
+		} else {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid since %q", s)})
+			return
+		}
+	}
+	entries := iocFeed.list(kind, since, time.Now())
+	switch q.Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, entries)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"type", "value", "first_seen", "last_seen", "count", "pastes", "keywords"}) // nolint: errcheck,gosec
+		for _, e := range entries {
+			cw.Write([]string{ // nolint: errcheck,gosec
+				e.Type, e.Value,
+				e.FirstSeen.UTC().Format(time.RFC3339), e.LastSeen.UTC().Format(time.RFC3339),
+				strconv.Itoa(e.Count), strings.Join(e.Pastes, " "), strings.Join(e.Keywords, " "),
+			})
+		}
+		cw.Flush()
+	case "plain":
+		w.Header().Set("Content-Type", "text/plain")
+		for _, e := range entries {
+			fmt.Fprintln(w, e.Value) // nolint: errcheck,gosec
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid format %q", q.Get("format"))})
+	}
+}
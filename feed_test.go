@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIndicatorFeed(t *testing.T) {
+	f := newIndicatorFeed(time.Hour)
+	now := time.Now()
+	f.add(paste{Key: "a", Matches: map[string][]string{"k1": nil}, IOCs: &iocSummary{IPs: []string{"203.0.113.7"}, Domains: []string{"evil.example.com"}}}, now.Add(-2*time.Hour))
+	f.add(paste{Key: "b", Matches: map[string][]string{"k1": nil}, IOCs: &iocSummary{IPs: []string{"198.51.100.1"}}}, now.Add(-30*time.Minute))
+	f.add(paste{Key: "c", Matches: map[string][]string{"k2": nil}, IOCs: &iocSummary{IPs: []string{"198.51.100.1"}}}, now)
+	f.add(paste{Key: "c", Matches: map[string][]string{"k2": nil}, IOCs: &iocSummary{IPs: []string{"198.51.100.1"}}}, now)
+
+	entries := f.list("", time.Time{}, now)
+	if len(entries) != 1 {
+		t.Fatalf("expected the old indicators to expire, got %+v", entries)
+	}
+	e := entries[0]
+	if e.Value != "198.51.100.1" || e.Count != 2 || strings.Join(e.Pastes, ",") != "b,c" || strings.Join(e.Keywords, ",") != "k1,k2" || !e.FirstSeen.Equal(now.Add(-30*time.Minute)) {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if got := f.list("domain", time.Time{}, now); len(got) != 0 {
+		t.Errorf("expected no domains, got %+v", got)
+	}
+	if got := f.list("", now.Add(time.Minute), now); len(got) != 0 {
+		t.Errorf("expected nothing newer, got %+v", got)
+	}
+}
+
+func TestFeedHandler(t *testing.T) {
+	old := iocFeed
+	iocFeed = newIndicatorFeed(time.Hour)
+	defer func() { iocFeed = old }()
+	iocFeed.add(paste{Key: "a", Matches: map[string][]string{"k": nil}, IOCs: &iocSummary{IPs: []string{"203.0.113.7"}, Hashes: []string{"d41d8cd98f00b204e9800998ecf8427e"}}}, time.Now())
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		feedHandler(w, httptest.NewRequest(http.MethodGet, "/api/v1/iocs"+query, nil))
+		return w
+	}
+	var entries []feedEntry
+	if err := json.Unmarshal(get("").Body.Bytes(), &entries); err != nil || len(entries) != 2 {
+		t.Errorf("expected all indicators as json, got %+v: %v", entries, err)
+	}
+	if body := get("?format=plain&type=ip").Body.String(); body != "203.0.113.7\n" {
+		t.Errorf("unexpected plain feed %q", body)
+	}
+	records, err := csv.NewReader(get("?format=csv&since=1h").Body).ReadAll()
+	if err != nil || len(records) != 3 || records[0][0] != "type" {
+		t.Errorf("unexpected csv feed %v: %v", records, err)
+	}
+	for _, q := range []string{"?format=xml", "?type=mac", "?since=yesterday"} {
+		if code := get(q).Code; code != http.StatusBadRequest {
+			t.Errorf("expected bad request for %s, got %d", q, code)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	apiItemEndpoint = "https://scrape.pastebin.com/api_scrape_item.php"
+	// links followed per matched paste
+	defaultFollowMaxLinks = 5
+)
+
+var (
+	regexPastebinLink = regexp.MustCompile(`^/(?:raw/|dl/)?([A-Za-z0-9]{8})/?$`)
+
+	defaultFollowShorteners = []string{
+		"bit.ly", "cutt.ly", "goo.gl", "is.gd", "ow.ly", "rebrand.ly", "shorturl.at", "t.co", "tiny.cc", "tinyurl.com",
+	}
+
+	metricFollowedLinks = metrics.counter("followed_links_total", "Number of links of matched pastes fetched by result.", "result")
+)
+
+// linkedPaste is a paste or raw file referenced by a matched paste
+type linkedPaste struct {
+	// key of the paste on pastebin, fetched through the scraping api
+	key string
+	url string
+}
+
+func (c followConfig) shorteners() []string {
+	if len(c.Shorteners) > 0 {
+		return c.Shorteners
+	}
+	return defaultFollowShorteners
+}
+
+func hostIn(u *url.URL, hosts []string) bool {
+	h := strings.ToLower(u.Hostname())
+	for _, x := range hosts {
+		x = strings.ToLower(x)
+		if h == x || strings.HasSuffix(h, "."+x) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyLink returns the paste behind a pastebin link or a link to one
+// of the raw hosts, shortened reports if it has to be resolved first
+func classifyLink(c followConfig, raw string) (l linkedPaste, shortened, ok bool) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return l, false, false
+	}
+	if hostIn(u, []string{"pastebin.com"}) {
+		if m := regexPastebinLink.FindStringSubmatch(u.Path); m != nil {
+			return linkedPaste{key: m[1], url: "https://pastebin.com/" + m[1]}, false, true
+		}
+		return l, false, false
+	}
+	if hostIn(u, c.shorteners()) {
+		return linkedPaste{url: raw}, true, false
+	}
+	if hostIn(u, c.Hosts) {
+		return linkedPaste{url: raw}, false, true
+	}
+	return l, false, false
+}
+
+// followableLinks returns the links of the body worth fetching, at most
+// maxlinks and never the paste itself
+func followableLinks(c followConfig, self, body string) []string {
+	max := c.Maxlinks
+	if max <= 0 {
+		max = defaultFollowMaxLinks
+	}
+	var ret []string
+	for _, u := range regexIOCURL.FindAllString(body, -1) {
+		u = strings.TrimRight(u, ".,;:!?)]}")
+		if len(ret) >= max {
+			break
+		}
+		l, shortened, ok := classifyLink(c, u)
+		if (!ok && !shortened) || (l.key != "" && l.key == self) || stringInSlice(u, ret) {
+			continue
+		}
+		ret = append(ret, u)
+	}
+	return ret
+}
+
+// followRequest sends a request to a linked site. It is paced like the
+// requests to the scraping api but the configured headers are not sent as
+// they may authenticate against it. Redirects are never followed, they
+// could lead to internal addresses or hosts outside of the allowlist.
+func followRequest(ctx context.Context, method, u string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", userAgent)
+	if !scraping.wait(ctx) || !pace.wait(ctx) {
+		return nil, ctx.Err()
+	}
+	c := *client
+	c.Transport = sourceTransport
+	c.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	return c.Do(req)
+}
+
+// resolveLink returns the target of a shortened link without visiting it
+func resolveLink(ctx context.Context, u string) (string, error) {
+	resp, err := followRequest(ctx, http.MethodHead, u)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close() // nolint: errcheck,gosec
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return "", fmt.Errorf("no redirect but %s", resp.Status)
+	}
+	loc, err := resp.Location()
+	if err != nil {
+		return "", err
+	}
+	return loc.String(), nil
+}
+
+// resolve returns the paste behind the link, shortened links are resolved
+// once so only pastes directly referenced are fetched
+func (c followConfig) resolve(ctx context.Context, u string) (linkedPaste, bool, error) {
+	l, shortened, ok := classifyLink(c, u)
+	if !shortened {
+		return l, ok, nil
+	}
+	target, err := resolveLink(ctx, u)
+	if err != nil {
+		return l, false, fmt.Errorf("could not resolve %s: %v", u, err)
+	}
+	l, shortened, ok = classifyLink(c, target)
+	return l, ok && !shortened, nil
+}
+
+// linkKey returns a key for pastes of other sites usable as file name
+func linkKey(u string) string {
+	h := sha256.Sum256([]byte(u))
+	return "link-" + hex.EncodeToString(h[:8])
+}
+
+// paste returns the paste to fetch for the link of the parent
+func (l linkedPaste) paste(parent paste) paste {
+	p := paste{Key: l.key, FullURL: l.url, ScrapeURL: fmt.Sprintf("%s?i=%s", apiItemEndpoint, l.key), LinkedFrom: parent.FullURL}
+	if l.key == "" {
+		p.Key, p.ScrapeURL = linkKey(l.url), l.url
+	}
+	return p
+}
+
+// fetchRaw downloads a file of another site as paste
+func (p paste) fetchRaw(ctx context.Context) (*paste, error) {
+	resp, err := followRequest(ctx, http.MethodGet, p.ScrapeURL)
+	if err != nil {
+		return nil, err
+	}
+	b, err := httpRespBodyToStringLimit(resp, atomic.LoadInt64(&maxPasteSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", p.FullURL, resp.Status)
+	}
+	p.Content = b
+	p.Hash = sha256Hex([]byte(b))
+	p.Size = fmt.Sprintf("%d", len(b))
+	return &p, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestClassifyLink(t *testing.T) {
+	c := followConfig{Hosts: []string{"paste.ee"}}
+	tests := []struct {
+		url       string
+		key       string
+		shortened bool
+		ok        bool
+	}{
+		{"https://pastebin.com/AbCd1234", "AbCd1234", false, true},
+		{"https://pastebin.com/raw/AbCd1234", "AbCd1234", false, true},
+		{"http://www.pastebin.com/dl/AbCd1234/", "AbCd1234", false, true},
+		{"https://pastebin.com/u/someone", "", false, false},
+		{"https://bit.ly/3xyz", "", true, false},
+		{"https://paste.ee/r/abc", "", false, true},
+		{"https://example.com/file.txt", "", false, false},
+		{"ftp://pastebin.com/AbCd1234", "", false, false},
+	}
+	for _, tt := range tests {
+		l, shortened, ok := classifyLink(c, tt.url)
+		if l.key != tt.key || shortened != tt.shortened || ok != tt.ok {
+			t.Errorf("%s: got key %q, shortened %v, ok %v", tt.url, l.key, shortened, ok)
+		}
+	}
+}
+
+func TestFollowableLinks(t *testing.T) {
+	body := `full version: https://pastebin.com/raw/AbCd1234. mirror (https://pastebin.com/AbCd1234)
+self https://pastebin.com/Self1234 https://example.com/x https://tinyurl.com/abc https://pastebin.com/AbCd1234.`
+	links := followableLinks(followConfig{}, "Self1234", body)
+	want := []string{"https://pastebin.com/raw/AbCd1234", "https://pastebin.com/AbCd1234", "https://tinyurl.com/abc"}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("got %q, want %q", links, want)
+	}
+	if links := followableLinks(followConfig{Maxlinks: 1}, "", body); len(links) != 1 {
+		t.Errorf("maxlinks not applied: %q", links)
+	}
+}
+
+func TestFollowResolve(t *testing.T) {
+	unpaced(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/short":
+			http.Redirect(w, r, "https://pastebin.com/AbCd1234", http.StatusMovedPermanently)
+		case "/chain":
+			http.Redirect(w, r, "https://bit.ly/other", http.StatusFound)
+		case "/moved":
+			http.Redirect(w, r, "/raw", http.StatusFound)
+		case "/raw":
+			if r.Header.Get("X-Api-Key") != "" {
+				t.Error("configured headers sent to a linked site")
+			}
+			fmt.Fprint(w, "the full dump")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	sourceHeaders.set(map[string]string{"X-Api-Key": "secret"}, nil)
+	defer sourceHeaders.set(nil, nil)
+
+	c := followConfig{Shorteners: []string{"127.0.0.1"}}
+	l, ok, err := c.resolve(context.Background(), ts.URL+"/short")
+	if err != nil || !ok || l.key != "AbCd1234" {
+		t.Fatalf("got %+v, %v, %v", l, ok, err)
+	}
+	if _, ok, err := c.resolve(context.Background(), ts.URL+"/chain"); err != nil || ok {
+		t.Errorf("shortened links must only be resolved once, got %v, %v", ok, err)
+	}
+	if _, _, err := c.resolve(context.Background(), ts.URL+"/missing"); err == nil {
+		t.Error("expected an error without a redirect")
+	}
+
+	c = followConfig{Hosts: []string{"127.0.0.1"}}
+	l, ok, err = c.resolve(context.Background(), ts.URL+"/raw")
+	if err != nil || !ok {
+		t.Fatalf("got %+v, %v, %v", l, ok, err)
+	}
+	p := l.paste(paste{Key: "parent", FullURL: "https://pastebin.com/parent"})
+	if p.Key != linkKey(ts.URL+"/raw") || p.LinkedFrom != "https://pastebin.com/parent" {
+		t.Errorf("unexpected linked paste %+v", p)
+	}
+	p2, err := p.fetchRaw(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p2.Content != "the full dump" || p2.Hash == "" {
+		t.Errorf("unexpected content %q", p2.Content)
+	}
+	moved := linkedPaste{url: ts.URL + "/moved"}.paste(paste{})
+	if _, err := moved.fetchRaw(context.Background()); err == nil {
+		t.Error("redirects of raw links must not be followed")
+	}
+	if p := (linkedPaste{key: "AbCd1234", url: "https://pastebin.com/AbCd1234"}).paste(paste{}); p.ScrapeURL != apiItemEndpoint+"?i=AbCd1234" {
+		t.Errorf("unexpected scrape url %q", p.ScrapeURL)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	formatPlain    = "plain"
+	formatMarkdown = "markdown"
+	formatMinimal  = "minimal"
+	// length of a single SMS, the minimal format is cut to fit
+	minimalFormatLength = 160
+)
+
+var formatProfiles = []string{formatPlain, formatMarkdown, formatMinimal}
+
+// validFormat reports if the formatting profile is known, empty means
+// plain
+func validFormat(f string) bool {
+	return f == "" || stringInSlice(f, formatProfiles)
+}
+
+// format renders the alert for a channel: plain text for mail, markdown
+// for chats or a single short line for SMS and pagers
+func (p *paste) format(profile string, defanged bool) string {
+	switch profile {
+	case formatMarkdown:
+		return p.alertMarkdown(defanged)
+	case formatMinimal:
+		return p.alertMinimal(defanged)
+	default:
+		return p.alertText(defanged)
+	}
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`)
+
+// markdownCode fences the text so nothing taken from the paste is
+// rendered as markdown
+func markdownCode(w *bytes.Buffer, s string) {
+	fence := "```"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(w, "%s\n%s\n%s\n", fence, strings.TrimRight(s, "\n"), fence)
+}
+
+// alertMarkdown formats the alert with the metadata as list and the
+// matches as code blocks
+func (p *paste) alertMarkdown(defanged bool) string {
+	clean := func(s string) string { return s }
+	if defanged {
+		clean = defang
+	}
+	keywords := getKeysFromMap(p.Matches)
+	sort.Strings(keywords)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "**Pastebin Alert for Keywords %s**\n\n", markdownEscaper.Replace(strings.Join(keywords, ", ")))
+	for _, f := range p.alertFields(clean) {
+		v := markdownEscaper.Replace(f.Value)
+		if f.Link != "" {
+			v = fmt.Sprintf("[%s](%s)", v, f.Link)
+		}
+		fmt.Fprintf(&b, "- **%s:** %s\n", f.Name, v)
+	}
+	for _, k := range keywords {
+		fmt.Fprintf(&b, "\n**Matches for %s:**\n", markdownEscaper.Replace(k))
+		markdownCode(&b, clean(strings.Join(p.Matches[k], "\n")))
+	}
+	var details bytes.Buffer
+	if err := p.IOCs.write(&details); err != nil {
+		return fmt.Sprintf("error on tostring: %v", err)
+	}
+	if err := p.Enrichment.write(&details); err != nil {
+		return fmt.Sprintf("error on tostring: %v", err)
+	}
+	if d := strings.TrimSpace(details.String()); d != "" {
+		b.WriteString("\n")
+		markdownCode(&b, clean(d))
+	}
+	return b.String()
+}
+
+// alertMinimal formats the alert as one line ending with the paste URL.
+// Keywords are left out at the end if the line gets too long.
+func (p *paste) alertMinimal(defanged bool) string {
+	prefix := "Pastebin alert"
+	if p.Severity != "" {
+		prefix = fmt.Sprintf("[%s] %s", strings.ToUpper(p.Severity), prefix)
+	}
+	if p.Confidence > 0 {
+		prefix = fmt.Sprintf("%s (%s)", prefix, confidenceToString(p.Confidence))
+	}
+	keywords := getKeysFromMap(p.Matches)
+	sort.Strings(keywords)
+	if defanged {
+		for i, k := range keywords {
+			keywords[i] = defang(k)
+		}
+	}
+	suffix := " " + p.FullURL
+	for n := len(keywords); n > 0; n-- {
+		k := strings.Join(keywords[:n], ", ")
+		if n < len(keywords) {
+			k += fmt.Sprintf(" +%d", len(keywords)-n)
+		}
+		if s := fmt.Sprintf("%s: %s%s", prefix, k, suffix); len(s) <= minimalFormatLength || n == 1 {
+			return s
+		}
+	}
+	return prefix + suffix
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAlertMarkdown(t *testing.T) {
+	p := paste{Key: "abc", Title: "dump_*2*", FullURL: "https://pastebin.com/abc",
+		Matches: map[string][]string{"password": {"password=```x```"}, "admin": {"admin"}}}
+	s := p.format(formatMarkdown, false)
+	for _, want := range []string{
+		"**Pastebin Alert for Keywords admin, password**\n",
+		`- **Title:** dump\_\*2\*`,
+		"- **URL:** [https://pastebin.com/abc](https://pastebin.com/abc)",
+		"**Matches for password:**\n````\npassword=```x```\n````\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("expected %q in\n%s", want, s)
+		}
+	}
+	if i, j := strings.Index(s, "admin:"), strings.Index(s, "password:"); i < 0 || i > j {
+		t.Errorf("matches not sorted:\n%s", s)
+	}
+}
+
+func TestAlertMinimal(t *testing.T) {
+	p := paste{FullURL: "https://pastebin.com/abc", Severity: "high", Confidence: 80, Matches: map[string][]string{"password": {}, "admin": {}}}
+	if s := p.format(formatMinimal, false); s != "[HIGH] Pastebin alert (80%): admin, password https://pastebin.com/abc" {
+		t.Errorf("unexpected message %q", s)
+	}
+	p = paste{FullURL: "https://pastebin.com/abc", Matches: map[string][]string{}}
+	for _, k := range []string{"aaaaaaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbbbbbb", "cccccccccccccccccccc", "dddddddddddddddddddd", "eeeeeeeeeeeeeeeeeeee", "ffffffffffffffffffff"} {
+		p.Matches[k] = nil
+	}
+	s := p.format(formatMinimal, false)
+	if len(s) > minimalFormatLength || !strings.Contains(s, " +") || !strings.HasSuffix(s, " https://pastebin.com/abc") {
+		t.Errorf("message not shortened %q", s)
+	}
+}
+
+func TestFormatPlain(t *testing.T) {
+	p := paste{Key: "abc", FullURL: "https://pastebin.com/abc", Matches: map[string][]string{"password": {"x"}}}
+	if p.format("", false) != p.alertText(false) || p.format(formatPlain, false) != p.alertText(false) {
+		t.Error("plain is not the default alert text")
+	}
+	if validFormat("html") || !validFormat("") || !validFormat(formatMarkdown) {
+		t.Error("unexpected format validation")
+	}
+}
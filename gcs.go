@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	gcsEndpoint = "https://storage.googleapis.com"
+	gcsScope    = "https://www.googleapis.com/auth/devstorage.read_write"
+)
+
+// gcsArchiver uploads pastes to a Google Cloud Storage bucket
+type gcsArchiver struct {
+	config   gcs
+	client   *http.Client
+	endpoint string
+}
+
+// newGCSArchiver uses the configured service account key file or the
+// application default credentials (GOOGLE_APPLICATION_CREDENTIALS or the
+// metadata server when running on GCP)
+func newGCSArchiver(ctx context.Context, c gcs) (*gcsArchiver, error) {
+	var creds *google.Credentials
+	var err error
+	if c.CredentialsFile != "" {
+		b, err := ioutil.ReadFile(c.CredentialsFile) // nolint: gosec
+		if err != nil {
+			return nil, err
+		}
+		creds, err = google.CredentialsFromJSON(ctx, b, gcsScope)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse credentials: %v", err)
+		}
+	} else {
+		creds, err = google.FindDefaultCredentials(ctx, gcsScope)
+		if err != nil {
+			return nil, fmt.Errorf("could not find default credentials: %v", err)
+		}
+	}
+	// the token source should not be bound to the lifetime of the context
+	hc := oauth2.NewClient(context.Background(), creds.TokenSource)
+	hc.Timeout = client.Timeout
+	return &gcsArchiver{config: c, client: hc, endpoint: gcsEndpoint}, nil
+}
+
+func (g *gcsArchiver) archive(ctx context.Context, p paste) (string, error) {
+	key := archiveKey(g.config.Prefix, p)
+	body, err := gzipString(p.Content)
+	if err != nil {
+		return "", err
+	}
+
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s",
+		strings.TrimRight(g.endpoint, "/"), url.PathEscape(g.config.Bucket), url.QueryEscape(key))
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", "application/gzip")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	b, err := httpRespBodyToString(resp)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("gcs returned %s: %s", resp.Status, b)
+	}
+	return fmt.Sprintf("gs://%s/%s", g.config.Bucket, key), nil
+}
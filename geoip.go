@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+const (
+	defaultGeoIPWatchGroup = "asn watchlist"
+)
+
+var (
+	geoIP = &geoIPDatabases{}
+)
+
+// geoIPResult is the location and network of an extracted IP
+type geoIPResult struct {
+	IP           string `json:"ip"`
+	Country      string `json:"country,omitempty"`
+	ASN          uint   `json:"asn,omitempty"`
+	Organization string `json:"organization,omitempty"`
+	// the ASN is on the watchlist
+	Watched bool `json:"watched,omitempty"`
+}
+
+func (r geoIPResult) String() string {
+	s := r.IP + ":"
+	if r.Country != "" {
+		s += " " + r.Country
+	}
+	if r.ASN != 0 {
+		if r.Country != "" {
+			s += ","
+		}
+		s += fmt.Sprintf(" AS%d %s", r.ASN, r.Organization)
+	}
+	if r.Watched {
+		s += " [watchlist]"
+	}
+	return s
+}
+
+// geoIPSource resolves the country and the network of an IP
+type geoIPSource interface {
+	country(ip netip.Addr) (string, error)
+	asn(ip netip.Addr) (uint, string, error)
+}
+
+// lookupGeoIP resolves all IPs which are found in the databases. The
+// second value is true if one of the IPs is in a watched ASN.
+func lookupGeoIP(src geoIPSource, c geoIPConfig, ips []string) ([]geoIPResult, bool) {
+	var ret []geoIPResult
+	watched := false
+	for _, s := range ips {
+		ip, err := netip.ParseAddr(s)
+		if err != nil || ip.IsPrivate() || ip.IsLinkLocalUnicast() {
+			continue
+		}
+		r := geoIPResult{IP: s}
+		if r.Country, err = src.country(ip); err != nil {
+			logger(componentNotifier).Warn("geoip country lookup failed", "ip", s, "error", err)
+		}
+		if r.ASN, r.Organization, err = src.asn(ip); err != nil {
+			logger(componentNotifier).Warn("geoip asn lookup failed", "ip", s, "error", err)
+		}
+		if r.Country == "" && r.ASN == 0 {
+			continue
+		}
+		for _, w := range c.Watchasns {
+			if r.ASN != 0 && r.ASN == w {
+				r.Watched = true
+				watched = true
+			}
+		}
+		ret = append(ret, r)
+	}
+	return ret, watched
+}
+
+// geoIPDatabases keeps the MaxMind databases open and reopens them when
+// the files are replaced by geoipupdate or the paths change
+type geoIPDatabases struct {
+	mu        sync.Mutex
+	countryDB mmdbFile
+	asnDB     mmdbFile
+}
+
+type mmdbFile struct {
+	path    string
+	modTime time.Time
+	reader  *maxminddb.Reader
+}
+
+// refresh opens the file if it changed since the last call
+func (f *mmdbFile) refresh(path string) error {
+	if path == "" {
+		f.close()
+		return nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("could not stat geoip database %s: %v", path, err)
+	}
+	if f.reader != nil && f.path == path && fi.ModTime().Equal(f.modTime) {
+		return nil
+	}
+	r, err := maxminddb.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open geoip database %s: %v", path, err)
+	}
+	f.close()
+	f.path, f.modTime, f.reader = path, fi.ModTime(), r
+	return nil
+}
+
+func (f *mmdbFile) close() {
+	if f.reader != nil {
+		f.reader.Close() // nolint: errcheck,gosec
+		f.reader = nil
+	}
+}
+
+// lookup resolves the IPs with the configured databases. The lock is
+// held during the lookups so a reopened database is never closed while
+// it is still read.
+func (g *geoIPDatabases) lookup(c geoIPConfig, ips []string) ([]geoIPResult, bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.countryDB.refresh(c.Database); err != nil {
+		return nil, false, err
+	}
+	if err := g.asnDB.refresh(c.Asndatabase); err != nil {
+		return nil, false, err
+	}
+	metricEnrichLookups.add(float64(len(ips)), "geoip")
+	results, watched := lookupGeoIP(g, c, ips)
+	return results, watched, nil
+}
+
+func (g *geoIPDatabases) country(ip netip.Addr) (string, error) {
+	if g.countryDB.reader == nil {
+		return "", nil
+	}
+	// the country and city databases both contain the country
+	var rec struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := g.countryDB.reader.Lookup(ip).Decode(&rec); err != nil {
+		return "", err
+	}
+	return rec.Country.ISOCode, nil
+}
+
+func (g *geoIPDatabases) asn(ip netip.Addr) (uint, string, error) {
+	if g.asnDB.reader == nil {
+		return 0, "", nil
+	}
+	var rec struct {
+		ASN          uint   `maxminddb:"autonomous_system_number"`
+		Organization string `maxminddb:"autonomous_system_organization"`
+	}
+	if err := g.asnDB.reader.Lookup(ip).Decode(&rec); err != nil {
+		return 0, "", err
+	}
+	return rec.ASN, rec.Organization, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type fakeGeoIP map[string]geoIPResult
+
+func (f fakeGeoIP) country(ip netip.Addr) (string, error) {
+	if ip.String() == "192.0.2.1" {
+		return "", fmt.Errorf("broken record")
+	}
+	return f[ip.String()].Country, nil
+}
+
+func (f fakeGeoIP) asn(ip netip.Addr) (uint, string, error) {
+	r := f[ip.String()]
+	return r.ASN, r.Organization, nil
+}
+
+func TestLookupGeoIP(t *testing.T) {
+	src := fakeGeoIP{
+		"203.0.113.7":  {Country: "DE", ASN: 64496, Organization: "Example Net"},
+		"198.51.100.1": {Country: "US"},
+		"192.0.2.1":    {ASN: 64497, Organization: "Other Net"},
+	}
+	ips := []string{"203.0.113.7", "198.51.100.1", "192.0.2.1", "10.0.0.1", "2001:db8::1"}
+	got, watched := lookupGeoIP(src, geoIPConfig{}, ips)
+	expected := []geoIPResult{
+		{IP: "203.0.113.7", Country: "DE", ASN: 64496, Organization: "Example Net"},
+		{IP: "198.51.100.1", Country: "US"},
+		{IP: "192.0.2.1", ASN: 64497, Organization: "Other Net"},
+	}
+	if watched || !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected results %v:\n%+v\nexpected\n%+v", watched, got, expected)
+	}
+
+	got, watched = lookupGeoIP(src, geoIPConfig{Watchasns: []uint{64497}}, ips)
+	if !watched || got[0].Watched || !got[2].Watched {
+		t.Errorf("expected only the watched network to be flagged, got %+v", got)
+	}
+	if s := got[2].String(); s != "192.0.2.1: AS64497 Other Net [watchlist]" {
+		t.Errorf("unexpected formatting %q", s)
+	}
+	if s := got[0].String(); s != "203.0.113.7: DE, AS64496 Example Net" {
+		t.Errorf("unexpected formatting %q", s)
+	}
+}
+
+func TestGeoIPMissingDatabase(t *testing.T) {
+	g := &geoIPDatabases{}
+	c := geoIPConfig{Database: filepath.Join(t.TempDir(), "missing.mmdb")}
+	if _, _, err := g.lookup(c, []string{"203.0.113.7"}); err == nil {
+		t.Error("expected an error for a missing database")
+	}
+}
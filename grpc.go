@@ -0,0 +1,193 @@
+package main
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative matchpb/matches.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FireFart/pastebin_scraper/matchpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// matches buffered per subscriber before they are dropped
+	grpcSubscriberBuffer = 100
+)
+
+// matchBroker fans out the matches to all subscribers. Slow subscribers
+// lose matches instead of blocking the scraper.
+type matchBroker struct {
+	mu          sync.Mutex
+	hostname    string
+	subscribers map[chan *matchpb.Match]struct{}
+}
+
+func newMatchBroker(hostname string) *matchBroker {
+	return &matchBroker{hostname: hostname, subscribers: make(map[chan *matchpb.Match]struct{})}
+}
+
+func (b *matchBroker) subscribe() chan *matchpb.Match {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := make(chan *matchpb.Match, grpcSubscriberBuffer)
+	b.subscribers[c] = struct{}{}
+	return c
+}
+
+func (b *matchBroker) unsubscribe(c chan *matchpb.Match) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, c)
+}
+
+func (b *matchBroker) publish(p paste, foundAt time.Time) {
+	m := pasteToProto(p, b.hostname, foundAt)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.subscribers {
+		select {
+		case c <- m:
+		default:
+			slog.Warn("dropping match for slow grpc subscriber", "paste_key", m.Key)
+		}
+	}
+}
+
+// pasteToProto converts a matched paste to the protobuf message
+func pasteToProto(p paste, hostname string, foundAt time.Time) *matchpb.Match {
+	m := &matchpb.Match{
+		Key:      p.Key,
+		Url:      p.FullURL,
+		Title:    p.Title,
+		User:     p.User,
+		Syntax:   p.Syntax,
+		Hash:     p.Hash,
+		Groups:   p.Groups,
+		FoundAt:  timestamppb.New(foundAt),
+		Hostname: hostname,
+	}
+	if d := unixToTime(p.Date); d.Valid {
+		m.Date = timestamppb.New(d.Time)
+	}
+	keys := getKeysFromMap(p.Matches)
+	sort.Strings(keys)
+	for _, k := range keys {
+		m.Matches = append(m.Matches, &matchpb.KeywordMatch{Keyword: k, Lines: p.Matches[k]})
+	}
+	return m
+}
+
+// wantMatch checks the filters of the subscription
+func wantMatch(req *matchpb.SubscribeMatchesRequest, m *matchpb.Match) bool {
+	if len(req.Keywords) > 0 {
+		found := false
+		for _, k := range m.Matches {
+			if stringInSlice(k.Keyword, req.Keywords) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(req.Groups) > 0 {
+		for _, g := range m.Groups {
+			if stringInSlice(g, req.Groups) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+type grpcServer struct {
+	matchpb.UnimplementedMatchesServer
+	broker *matchBroker
+}
+
+func (s *grpcServer) SubscribeMatches(req *matchpb.SubscribeMatchesRequest, stream grpc.ServerStreamingServer[matchpb.Match]) error {
+	c := s.broker.subscribe()
+	defer s.broker.unsubscribe(c)
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case m := <-c:
+			if !wantMatch(req, m) {
+				continue
+			}
+			if err := stream.Send(m); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// grpcTokenAuth checks the bearer token in the authorization metadata of
+// every stream
+func grpcTokenAuth(token string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		var got string
+		if v := md.Get("authorization"); len(v) > 0 {
+			got = strings.TrimPrefix(v[0], "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return status.Error(codes.Unauthenticated, "invalid token")
+		}
+		return handler(srv, ss)
+	}
+}
+
+func newGRPCServer(c grpcConfig, broker *matchBroker) (*grpc.Server, error) {
+	var opts []grpc.ServerOption
+	if c.CertFile != "" || c.KeyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load certificate: %v", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	if c.Token != "" {
+		opts = append(opts, grpc.StreamInterceptor(grpcTokenAuth(c.Token)))
+	}
+	srv := grpc.NewServer(opts...)
+	matchpb.RegisterMatchesServer(srv, &grpcServer{broker: broker})
+	return srv, nil
+}
+
+// serveGRPC serves the streaming api until the context is canceled
+func serveGRPC(ctx context.Context, c grpcConfig, broker *matchBroker, errs chan<- error) {
+	srv, err := newGRPCServer(c, broker)
+	if err != nil {
+		errs <- fmt.Errorf("grpc: %v", err)
+		return
+	}
+	l, err := net.Listen("tcp", c.Listen)
+	if err != nil {
+		errs <- fmt.Errorf("grpc: %v", err)
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Stop()
+	}()
+	if err := srv.Serve(l); err != nil && err != grpc.ErrServerStopped {
+		errs <- fmt.Errorf("grpc: %v", err)
+	}
+}
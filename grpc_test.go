@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/FireFart/pastebin_scraper/matchpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPCSubscribeMatches(t *testing.T) {
+	broker := newMatchBroker("host")
+	srv, err := newGRPCServer(grpcConfig{Token: "secret"}, broker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := bufconn.Listen(1024 * 1024)
+	go srv.Serve(l) // nolint: errcheck
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close() // nolint: errcheck
+	client := matchpb.NewMatchesClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.SubscribeMatches(ctx, &matchpb.SubscribeMatchesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected unauthenticated error, got %v", err)
+	}
+
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+	stream, err = client.SubscribeMatches(authCtx, &matchpb.SubscribeMatchesRequest{Groups: []string{"credentials"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// wait for the subscription before publishing
+	for {
+		broker.mu.Lock()
+		n := len(broker.subscribers)
+		broker.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	broker.publish(paste{Key: "other", Matches: map[string][]string{"brand": {"brand"}}, Groups: []string{"brand"}}, time.Now())
+	broker.publish(paste{Key: "abc", Date: "1590000000", Matches: map[string][]string{"password": {"password=1"}}, Groups: []string{"credentials"}}, time.Now())
+
+	m, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Key != "abc" || m.Hostname != "host" || m.Date.AsTime().Unix() != 1590000000 {
+		t.Fatalf("unexpected match %v", m)
+	}
+	if len(m.Matches) != 1 || m.Matches[0].Keyword != "password" || m.Matches[0].Lines[0] != "password=1" {
+		t.Fatalf("unexpected keyword matches %v", m.Matches)
+	}
+}
+
+func TestWantMatch(t *testing.T) {
+	m := pasteToProto(paste{Matches: map[string][]string{"password": nil}, Groups: []string{"credentials"}}, "", time.Now())
+	tt := []struct {
+		req      *matchpb.SubscribeMatchesRequest
+		expected bool
+	}{
+		{req: &matchpb.SubscribeMatchesRequest{}, expected: true},
+		{req: &matchpb.SubscribeMatchesRequest{Keywords: []string{"password"}}, expected: true},
+		{req: &matchpb.SubscribeMatchesRequest{Keywords: []string{"other"}}, expected: false},
+		{req: &matchpb.SubscribeMatchesRequest{Groups: []string{"credentials"}}, expected: true},
+		{req: &matchpb.SubscribeMatchesRequest{Keywords: []string{"password"}, Groups: []string{"brand"}}, expected: false},
+	}
+	for i, x := range tt {
+		if got := wantMatch(x.req, m); got != x.expected {
+			t.Errorf("%d: expected %v, got %v", i, x.expected, got)
+		}
+	}
+}
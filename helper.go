@@ -3,7 +3,9 @@ package main
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"fmt"
+	"math/rand"
 	"strconv"
 	"time"
 )
@@ -41,6 +43,15 @@ func getKeysFromMap(in map[string][]string) []string {
 	return keys
 }
 
+func stringInSlice(s string, list []string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
 func dateToString(in string) string {
 	i, err := strconv.ParseInt(in, 10, 64)
 	if err != nil {
@@ -51,3 +62,61 @@ func dateToString(in string) string {
 	}
 	return time.Unix(i, 0).Local().Format(time.ANSIC)
 }
+
+// expireToString formats the expiry of a paste, pastes without expiry
+// are kept forever
+func expireToString(in string) string {
+	if in == "0" || in == "" {
+		return "Never"
+	}
+	return dateToString(in)
+}
+
+// sizeToString formats the size in bytes reported by the api in a human
+// readable way
+func sizeToString(in string) string {
+	i, err := strconv.ParseInt(in, 10, 64)
+	if err != nil {
+		return in
+	}
+	const unit = 1024
+	if i < unit {
+		return fmt.Sprintf("%d B", i)
+	}
+	div, exp := int64(unit), 0
+	for n := i / unit; n >= unit && exp < 3; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB (%d bytes)", float64(i)/float64(div), "KMGT"[exp], i)
+}
+
+// authorToString returns the user who created the paste, pastes by
+// anonymous users have no user
+func authorToString(in string) string {
+	if in == "" {
+		return "Guest"
+	}
+	return in
+}
+
+// jitter randomly shortens or extends d by up to the given fraction
+func jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+	return d + time.Duration((rand.Float64()*2-1)*fraction*float64(d)) // nolint: gosec
+}
+
+// sleep waits for the duration and returns false if the context was
+// canceled before
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJitter(t *testing.T) {
+	if d := jitter(time.Minute, 0); d != time.Minute {
+		t.Errorf("expected no jitter, got %s", d)
+	}
+	for i := 0; i < 100; i++ {
+		d := jitter(time.Minute, 0.1)
+		if d < 54*time.Second || d > 66*time.Second {
+			t.Fatalf("expected jitter of at most 10%%, got %s", d)
+		}
+	}
+}
+
+func TestSizeToString(t *testing.T) {
+	tests := map[string]string{
+		"0":        "0 B",
+		"1023":     "1023 B",
+		"1536":     "1.5 KB (1536 bytes)",
+		"10485760": "10.0 MB (10485760 bytes)",
+		"x":        "x",
+	}
+	for in, expected := range tests {
+		if got := sizeToString(in); got != expected {
+			t.Errorf("sizeToString(%q) = %q, expected %q", in, got, expected)
+		}
+	}
+}
+
+func TestExpireToString(t *testing.T) {
+	if got := expireToString("0"); got != "Never" {
+		t.Errorf("expected Never for pastes without expiry, got %q", got)
+	}
+	if got := expireToString("1500000000"); got != dateToString("1500000000") {
+		t.Errorf("expected the date of the expiry, got %q", got)
+	}
+}
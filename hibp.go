@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// the smallest subscription allows 10 requests per minute
+	defaultHIBPRate = 10
+	// one minute of the smallest subscription, so a leaked list of
+	// addresses does not hold up the alert for long
+	defaultHIBPLookups = 10
+)
+
+var (
+	hibpEndpoint = "https://haveibeenpwned.com/api/v3"
+
+	hibp = &hibpClient{
+		pace:  newPacer(time.Minute/defaultHIBPRate, time.Minute/defaultHIBPRate, 0),
+		cache: newLookupCache[hibpResult](defaultEnrichCacheTTL),
+	}
+)
+
+// hibpResult lists the known breaches of an email address
+type hibpResult struct {
+	Email    string   `json:"email"`
+	Breaches []string `json:"breaches,omitempty"`
+}
+
+func (r hibpResult) String() string {
+	if len(r.Breaches) == 0 {
+		return fmt.Sprintf("%s: not in any known breach", r.Email)
+	}
+	return fmt.Sprintf("%s: %d breaches (%s)", r.Email, len(r.Breaches), strings.Join(r.Breaches, ", "))
+}
+
+// hibpClient looks up email addresses at Have I Been Pwned spacing the
+// requests to the rate limit of the subscription
+type hibpClient struct {
+	pace  *pacer
+	cache *lookupCache[hibpResult]
+}
+
+// corporateEmails returns the addresses of the configured domains and
+// their subdomains, all addresses without domains
+func corporateEmails(emails, domains []string) []string {
+	if len(domains) == 0 {
+		return emails
+	}
+	var ret []string
+	for _, e := range emails {
+		d := e[strings.LastIndexByte(e, '@')+1:]
+		for _, x := range domains {
+			x = strings.ToLower(x)
+			if d == x || strings.HasSuffix(d, "."+x) {
+				ret = append(ret, e)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// lookup returns the breaches of the corporate addresses. Cached results
+// are always returned, at most maxlookups are sent to the api.
+func (h *hibpClient) lookup(ctx context.Context, c hibpConfig, iocs *iocSummary) []hibpResult {
+	rate := c.Requestsperminute
+	if rate <= 0 {
+		rate = defaultHIBPRate
+	}
+	h.pace.configure(time.Minute/time.Duration(rate), time.Minute/time.Duration(rate), 0)
+	maxLookups := c.Maxlookups
+	if maxLookups <= 0 {
+		maxLookups = defaultHIBPLookups
+	}
+	var ret []hibpResult
+	lookups := 0
+	for _, e := range corporateEmails(iocs.Emails, c.Domains) {
+		if r, ok := h.cache.get(e, time.Now()); ok {
+			ret = append(ret, r)
+			continue
+		}
+		if lookups >= maxLookups || !h.pace.wait(ctx) {
+			continue
+		}
+		lookups++
+		metricEnrichLookups.inc("hibp")
+		r, err := h.get(ctx, c.APIKey, e)
+		if err != nil {
+			metricEnrichErrors.inc("hibp")
+			logger(componentNotifier).Warn("hibp lookup failed", "email", e, "error", err)
+			continue
+		}
+		h.cache.put(e, r, time.Now())
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+func (h *hibpClient) get(ctx context.Context, apiKey, email string) (hibpResult, error) {
+	r := hibpResult{Email: email}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hibpEndpoint+"/breachedaccount/"+url.PathEscape(email)+"?truncateResponse=true", nil)
+	if err != nil {
+		return r, fmt.Errorf("could not create request: %v", err)
+	}
+	req.Header.Set("hibp-api-key", apiKey)
+	// the api rejects requests without a user agent
+	req.Header.Set("User-Agent", "pastebin_scraper/"+buildVersion())
+	resp, err := enrichClient.Do(req)
+	if err != nil {
+		return r, fmt.Errorf("could not query hibp: %v", err)
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		io.Copy(ioutil.Discard, resp.Body) // nolint: errcheck,gosec
+		return r, nil
+	default:
+		return r, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var breaches []struct {
+		Name string `json:"Name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&breaches); err != nil {
+		return r, fmt.Errorf("could not decode response: %v", err)
+	}
+	for _, b := range breaches {
+		r.Breaches = append(r.Breaches, b.Name)
+	}
+	return r, nil
+}
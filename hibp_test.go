@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCorporateEmails(t *testing.T) {
+	emails := []string{"a@example.com", "b@mail.example.com", "c@notexample.com", "d@other.org"}
+	if got := corporateEmails(emails, nil); !reflect.DeepEqual(got, emails) {
+		t.Errorf("expected all addresses without domains, got %v", got)
+	}
+	expected := []string{"a@example.com", "b@mail.example.com"}
+	if got := corporateEmails(emails, []string{"Example.com"}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestHIBPLookup(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("hibp-api-key") != "key" || r.UserAgent() == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/breachedaccount/a@example.com" && r.URL.Query().Get("truncateResponse") == "true" {
+			w.Write([]byte(`[{"Name":"Adobe"},{"Name":"LinkedIn"}]`)) // nolint: errcheck
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+	old := hibpEndpoint
+	hibpEndpoint = ts.URL
+	defer func() { hibpEndpoint = old }()
+
+	h := &hibpClient{pace: newPacer(0, 0, 0), cache: newLookupCache[hibpResult](time.Hour)}
+	iocs := &iocSummary{Emails: []string{"a@example.com", "b@example.com", "c@other.org"}}
+	c := hibpConfig{APIKey: "key", Domains: []string{"example.com"}, Requestsperminute: 6000}
+	got := h.lookup(context.Background(), c, iocs)
+	expected := []hibpResult{{Email: "a@example.com", Breaches: []string{"Adobe", "LinkedIn"}}, {Email: "b@example.com"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected results:\n%+v\nexpected\n%+v", got, expected)
+	}
+	if s := got[0].String(); s != "a@example.com: 2 breaches (Adobe, LinkedIn)" {
+		t.Errorf("unexpected formatting %q", s)
+	}
+}
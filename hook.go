@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHookTimeout     = 30 * time.Second
+	defaultHookConcurrency = 4
+	// output of a failed hook kept for the log
+	maxHookOutput = 1024
+)
+
+var (
+	metricHookRuns = metrics.counter("exec_hooks_total", "Number of exec hooks run by result.", "result")
+)
+
+// hookPayload is written to the stdin of the hooks
+type hookPayload struct {
+	pasteEvent
+	Content string `json:"content"`
+	Score   int    `json:"score"`
+	// the alert in the formatting profile of the channel
+	Message string `json:"message"`
+}
+
+// hookRunner runs the exec hooks in the background, at most concurrency
+// at the same time. Further matches wait for a free slot.
+type hookRunner struct {
+	sem chan struct{}
+	wg  sync.WaitGroup
+}
+
+func newHookRunner(concurrency int) *hookRunner {
+	if concurrency <= 0 {
+		concurrency = defaultHookConcurrency
+	}
+	return &hookRunner{sem: make(chan struct{}, concurrency)}
+}
+
+// wants checks the confidence and if the hook applies to one of the
+// matched keywords, hooks without keywords apply to all matches
+func (h execHook) wants(p paste) bool {
+	if p.Confidence < h.Minconfidence {
+		return false
+	}
+	if len(h.Keywords) == 0 {
+		return true
+	}
+	for _, k := range h.Keywords {
+		if _, ok := p.Matches[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// run starts the hooks applying to the paste. Failures are logged and
+// counted, they are no reason to stop the scraper.
+func (r *hookRunner) run(ctx context.Context, hooks []execHook, p paste, score int, foundAt time.Time) {
+	for _, h := range hooks {
+		if !h.wants(p) {
+			continue
+		}
+		select {
+		case r.sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		r.wg.Add(1)
+		go func(h execHook) {
+			defer r.wg.Done()
+			defer func() { <-r.sem }()
+			log := logger(componentNotifier).With("hook", h.Command[0], "paste_key", p.Key)
+			if err := runHook(ctx, h, p, score, foundAt); err != nil {
+				log.Error("exec hook failed", "error", err)
+				return
+			}
+			log.Debug("exec hook finished")
+		}(h)
+	}
+}
+
+// wait blocks until the running hooks are finished
+func (r *hookRunner) wait() {
+	r.wg.Wait()
+}
+
+// hookEnv returns the variables describing the match for the hook
+func hookEnv(p paste, score int) []string {
+	keywords := getKeysFromMap(p.Matches)
+	sort.Strings(keywords)
+	return []string{
+		"PASTE_KEY=" + p.Key,
+		"PASTE_URL=" + p.FullURL,
+		"PASTE_TITLE=" + p.Title,
+		"PASTE_USER=" + p.User,
+		"PASTE_SYNTAX=" + p.Syntax,
+		"PASTE_KEYWORDS=" + strings.Join(keywords, ","),
+		"PASTE_GROUPS=" + strings.Join(p.Groups, ","),
+		"PASTE_SCORE=" + strconv.Itoa(score),
+		"PASTE_CONFIDENCE=" + strconv.Itoa(p.Confidence),
+	}
+}
+
+func runHook(ctx context.Context, h execHook, p paste, score int, foundAt time.Time) error {
+	timeout := defaultHookTimeout
+	if h.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(h.Timeout); err != nil {
+			return fmt.Errorf("invalid timeout %q: %v", h.Timeout, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	message := p.format(h.Format, false)
+	payload, err := json.Marshal(hookPayload{newPasteEvent(p, foundAt), p.Content, score, message})
+	if err != nil {
+		return fmt.Errorf("could not encode match: %v", err)
+	}
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...) // nolint: gosec
+	cmd.Env = append(os.Environ(), append(hookEnv(p, score), "PASTE_MESSAGE="+message)...)
+	cmd.Stdin = bytes.NewReader(append(payload, '\n'))
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	// children keeping the output open must not block the runner
+	cmd.WaitDelay = time.Second
+	err = cmd.Run()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		metricHookRuns.inc("timeout")
+		return fmt.Errorf("killed after %v", timeout)
+	case err != nil:
+		metricHookRuns.inc("error")
+		o := out.String()
+		if len(o) > maxHookOutput {
+			o = o[:maxHookOutput]
+		}
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(o))
+	}
+	metricHookRuns.inc("ok")
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunHook(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell available")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	p := paste{Key: "abc", FullURL: "https://pastebin.com/abc", Content: "password=1", Matches: map[string][]string{"password": {"password=1"}, "admin": {}}}
+	h := execHook{Command: []string{"sh", "-c", `cat > "$0"; echo "$PASTE_KEY $PASTE_KEYWORDS $PASTE_SCORE" >> "$0"`, out}, Format: formatMinimal}
+	if err := runHook(context.Background(), h, p, 5, time.Unix(1600000000, 0)); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 || lines[1] != "abc admin,password 5" {
+		t.Fatalf("unexpected hook output %q", b)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["key"] != "abc" || got["content"] != "password=1" || got["score"] != float64(5) || got["message"] != "Pastebin alert: admin, password https://pastebin.com/abc" {
+		t.Errorf("unexpected payload %s", lines[0])
+	}
+
+	h = execHook{Command: []string{"sh", "-c", "echo broken; exit 3"}}
+	if err := runHook(context.Background(), h, p, 0, time.Now()); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected the output in the error, got %v", err)
+	}
+	h = execHook{Command: []string{"sleep", "10"}, Timeout: "100ms"}
+	start := time.Now()
+	if err := runHook(context.Background(), h, p, 0, time.Now()); err == nil || !strings.Contains(err.Error(), "killed") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("the hook was not killed")
+	}
+}
+
+func TestHookWants(t *testing.T) {
+	p := paste{Matches: map[string][]string{"password": {}}}
+	if !(execHook{}).wants(p) {
+		t.Error("expected a hook without keywords to run for all matches")
+	}
+	if !(execHook{Keywords: []string{"admin", "password"}}).wants(p) {
+		t.Error("expected the hook to run for password")
+	}
+	if (execHook{Keywords: []string{"admin"}}).wants(p) {
+		t.Error("expected the hook to be skipped")
+	}
+}
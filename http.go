@@ -2,36 +2,176 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	userAgent = "Pastebin Scraper (https://firefart.at)"
 )
 
+var errBodyTooLarge = errors.New("response body too large")
+
 var (
 	client = &http.Client{
 		// default timeout
 		Timeout: 10 * time.Second,
 	}
+
+	// transport of the requests to the scraping api, other requests are
+	// not sent through the proxy
+	sourceTransport = http.DefaultTransport.(*http.Transport).Clone()
+
+	// headers of the requests to the scraping api
+	sourceHeaders = &requestHeaders{}
 )
 
+// requestHeaders sets the configured headers on the requests and rotates
+// through the user agents
+type requestHeaders struct {
+	mu         sync.Mutex
+	headers    map[string]string
+	userAgents []string
+	next       int
+}
+
+func (h *requestHeaders) set(headers map[string]string, userAgents []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.headers = headers
+	h.userAgents = userAgents
+	h.next = 0
+}
+
+func (h *requestHeaders) apply(req *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ua := userAgent
+	if len(h.userAgents) > 0 {
+		ua = h.userAgents[h.next%len(h.userAgents)]
+		h.next++
+	}
+	req.Header.Set("User-Agent", ua)
+	for k, v := range h.headers {
+		req.Header.Set(k, v)
+	}
+}
+
+// setupTransport applies the connection settings to the requests to the
+// scraping api. Unset values keep the defaults of net/http.
+func setupTransport(c httpConfig) error {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	durations := []struct {
+		name  string
+		value string
+		set   func(d time.Duration)
+	}{
+		{"http.dialtimeout", c.DialTimeout, func(d time.Duration) { dialer.Timeout = d }},
+		{"http.keepalive", c.KeepAlive, func(d time.Duration) { dialer.KeepAlive = d }},
+		{"http.tlshandshaketimeout", c.TLSHandshakeTimeout, func(d time.Duration) { sourceTransport.TLSHandshakeTimeout = d }},
+		{"http.responseheadertimeout", c.ResponseHeaderTimeout, func(d time.Duration) { sourceTransport.ResponseHeaderTimeout = d }},
+		{"http.idleconntimeout", c.IdleConnTimeout, func(d time.Duration) { sourceTransport.IdleConnTimeout = d }},
+	}
+	for _, x := range durations {
+		if x.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(x.value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s %q: %v", x.name, x.value, err)
+		}
+		x.set(d)
+	}
+	sourceTransport.DialContext = dialer.DialContext
+	if c.MaxIdleConns > 0 {
+		sourceTransport.MaxIdleConns = c.MaxIdleConns
+	}
+	if c.MaxIdleConnsPerHost > 0 {
+		sourceTransport.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	}
+	if c.MaxConnsPerHost > 0 {
+		sourceTransport.MaxConnsPerHost = c.MaxConnsPerHost
+	}
+	sourceTransport.DisableKeepAlives = c.DisableKeepAlives
+	return nil
+}
+
+// setupProxy sends the requests to the scraping api through the proxy.
+// http, https and socks5 proxies are supported, credentials are passed in
+// the url.
+func setupProxy(proxy string) error {
+	if proxy == "" {
+		sourceTransport.Proxy = http.ProxyFromEnvironment
+		return nil
+	}
+	u, err := parseProxy(proxy)
+	if err != nil {
+		return err
+	}
+	sourceTransport.Proxy = http.ProxyURL(u)
+	return nil
+}
+
+func parseProxy(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("missing proxy host in %q", u.Redacted())
+	}
+	return u, nil
+}
+
+// httpRequest sends a request to the scraping api at the current pace. It
+// blocks while fetching is paused.
 func httpRequest(ctx context.Context, url string) (*http.Response, error) {
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
 	req = req.WithContext(ctx)
-	req.Header.Set("User-Agent", userAgent)
+	sourceHeaders.apply(req)
 
-	resp, err := client.Do(req)
+	if !scraping.wait(ctx) || !pace.wait(ctx) {
+		return nil, ctx.Err()
+	}
+	_, span := tracer.Start(ctx, "http request", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("url.full", url)))
+	c := *client
+	c.Transport = sourceTransport
+	resp, err := c.Do(req)
+	if resp != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+	endSpan(span, err)
+	pace.observe(resp)
 	return resp, err
 }
 
 func httpRespBodyToString(resp *http.Response) (res string, err error) {
+	return httpRespBodyToStringLimit(resp, 0)
+}
+
+// httpRespBodyToStringLimit reads at most max bytes of the body, 0 means
+// no limit. Larger bodies are not read to the end and return
+// errBodyTooLarge.
+func httpRespBodyToStringLimit(resp *http.Response, max int64) (res string, err error) {
 	if resp == nil {
 		return "", fmt.Errorf("response is nil")
 	}
@@ -39,15 +179,25 @@ func httpRespBodyToString(resp *http.Response) (res string, err error) {
 	// catch errors when closing and return them
 	defer func() {
 		rerr := resp.Body.Close()
-		if rerr != nil {
+		if rerr != nil && err == nil {
 			err = rerr
 		}
 	}()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	var r io.Reader = resp.Body
+	if max > 0 {
+		if resp.ContentLength > max {
+			return "", errBodyTooLarge
+		}
+		r = io.LimitReader(resp.Body, max+1)
+	}
+	body, err := ioutil.ReadAll(r)
 	if err != nil {
 		return "", err
 	}
+	if max > 0 && int64(len(body)) > max {
+		return "", errBodyTooLarge
+	}
 
 	res = string(body)
 	return res, nil
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// decodeConfig decodes a config file or fragment into c rejecting unknown
+// options
+func decodeConfig(f string, b []byte, c *configuration) error {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	// typos in option names would otherwise be silently ignored
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		if field := strings.TrimPrefix(err.Error(), "json: unknown field "); field != err.Error() {
+			return fmt.Errorf("invalid config file %s: unknown option %s", f, field)
+		}
+		return fmt.Errorf("invalid config file %s: %v", f, err)
+	}
+	return nil
+}
+
+// includedFiles expands the include patterns relative to the directory of
+// the config file. Directories include their .json and .txt files.
+func includedFiles(patterns []string, base string) ([]string, error) {
+	var ret []string
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(base, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include %q: %v", pattern, err)
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			fi, err := os.Stat(m)
+			if err != nil {
+				return nil, err
+			}
+			if !fi.IsDir() {
+				ret = append(ret, m)
+				continue
+			}
+			files, err := ioutil.ReadDir(m)
+			if err != nil {
+				return nil, fmt.Errorf("could not read include directory %s: %v", m, err)
+			}
+			for _, f := range files {
+				if ext := filepath.Ext(f.Name()); !f.IsDir() && (ext == ".json" || ext == ".txt") {
+					ret = append(ret, filepath.Join(m, f.Name()))
+				}
+			}
+		}
+	}
+	return ret, nil
+}
+
+// loadIncludes merges the included config fragments and keyword files in
+// order. Fragments override the options they set like a second config
+// file, except that their keywords, cidrs and blocklist are added to the
+// ones already loaded. Keyword files contain one keyword per line.
+func loadIncludes(c *configuration, base string) error {
+	files, err := includedFiles(c.Include, base)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f) // nolint: gosec
+		if err != nil {
+			return err
+		}
+		if filepath.Ext(f) == ".txt" {
+			c.Keywords = append(c.Keywords, parseKeywordFile(b)...)
+			continue
+		}
+		keywords, cidrs, blocklist := c.Keywords, c.CIDRs, c.Blocklist
+		c.Keywords, c.CIDRs, c.Blocklist, c.Include = nil, nil, nil, nil
+		if err := decodeConfig(f, b, c); err != nil {
+			return err
+		}
+		if len(c.Include) > 0 {
+			return fmt.Errorf("invalid config file %s: include is only allowed in the main config file", f)
+		}
+		c.Keywords = append(keywords, c.Keywords...)
+		c.CIDRs = append(cidrs, c.CIDRs...)
+		c.Blocklist = append(blocklist, c.Blocklist...)
+	}
+	return nil
+}
+
+// parseKeywordFile returns the keywords of a file with one keyword per
+// line. Empty lines and lines starting with # are skipped.
+func parseKeywordFile(b []byte) []keyword {
+	var ret []keyword
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ret = append(ret, keyword{Keyword: line})
+	}
+	return ret
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		f := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(f), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(f, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConfigIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.json":         `{"mailto": "default@mail.com", "include": ["conf.d"], "keywords": [{"keyword": "main"}], "cidrs": ["10.0.0.0/8"]}`,
+		"conf.d/10-soc.json":  `{"keywords": [{"keyword": "password", "group": "credentials"}], "groups": {"credentials": {"mailto": "soc@mail.com"}}}`,
+		"conf.d/20-brand.txt": "# brand team\nacme\n\nacme corp\n",
+		"conf.d/30-net.json":  `{"cidrs": ["192.168.0.0/16"], "mailto": "override@mail.com"}`,
+		"conf.d/notes.md":     "not included",
+	})
+	c, err := getConfig(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, k := range c.Keywords {
+		names = append(names, k.Keyword)
+	}
+	if strings.Join(names, ",") != "main,password,acme,acme corp" {
+		t.Errorf("unexpected keywords %v", names)
+	}
+	if len(c.CIDRs) != 2 || c.Groups["credentials"].Mailto != "soc@mail.com" || c.Mailto != "override@mail.com" {
+		t.Errorf("unexpected merged config %+v", c)
+	}
+}
+
+func TestConfigIncludeErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown option": `{"keywordz": []}`,
+		"nested include": `{"include": ["other"]}`,
+	}
+	for name, fragment := range tests {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"config.json":        `{"include": ["conf.d/*.json"]}`,
+			"conf.d/team.json":   fragment,
+			"conf.d/ignored.txt": "not matched by the pattern",
+		})
+		_, err := getConfig(filepath.Join(dir, "config.json"))
+		if err == nil || !strings.Contains(err.Error(), "team.json") {
+			t.Errorf("%s: expected an error naming the fragment, got %v", name, err)
+		}
+	}
+}
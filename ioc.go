@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// maximum number of indicators reported per type
+	maxIOCsPerType = 50
+)
+
+var (
+	regexIOCURL    = regexp.MustCompile(`(?i)\b(?:https?|ftp)://[^\s<>"'` + "`" + `]+`)
+	regexIOCEmail  = regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,63}\b`)
+	regexIOCIPv4   = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	regexIOCIPv6   = regexp.MustCompile(`(?i)[0-9a-f]{0,4}(?::[0-9a-f]{0,4}){2,7}`)
+	regexIOCDomain = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}\b`)
+	regexIOCHash   = regexp.MustCompile(`(?i)\b(?:[0-9a-f]{64}|[0-9a-f]{40}|[0-9a-f]{32})\b`)
+
+	// file names look like domains, skip the common extensions
+	iocFileExtensions = map[string]bool{
+		"bat": true, "bin": true, "conf": true, "cpp": true, "css": true, "csv": true,
+		"dll": true, "doc": true, "docx": true, "exe": true, "gif": true, "go": true,
+		"gz": true, "htm": true, "html": true, "ini": true, "java": true, "jpg": true,
+		"js": true, "json": true, "log": true, "php": true, "pdf": true, "png": true,
+		"ps1": true, "py": true, "rar": true, "rb": true, "sh": true, "sql": true,
+		"tar": true, "tmp": true, "txt": true, "xls": true, "xlsx": true, "xml": true,
+		"yaml": true, "yml": true, "zip": true,
+	}
+)
+
+// iocSummary holds the deduplicated indicators of compromise found in
+// a paste
+type iocSummary struct {
+	IPs     []string `json:"ips,omitempty"`
+	Domains []string `json:"domains,omitempty"`
+	URLs    []string `json:"urls,omitempty"`
+	Emails  []string `json:"emails,omitempty"`
+	Hashes  []string `json:"hashes,omitempty"`
+}
+
+func (s *iocSummary) empty() bool {
+	return len(s.IPs) == 0 && len(s.Domains) == 0 && len(s.URLs) == 0 && len(s.Emails) == 0 && len(s.Hashes) == 0
+}
+
+func (s *iocSummary) count() int {
+	return len(s.IPs) + len(s.Domains) + len(s.URLs) + len(s.Emails) + len(s.Hashes)
+}
+
+// iocSet deduplicates the indicators of one type keeping the first
+// maxIOCsPerType
+type iocSet map[string]bool
+
+func (s iocSet) add(v string) {
+	if len(s) < maxIOCsPerType {
+		s[v] = true
+	}
+}
+
+func (s iocSet) sorted() []string {
+	if len(s) == 0 {
+		return nil
+	}
+	ret := make([]string, 0, len(s))
+	for k := range s {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// extractIOCs returns the IPs, domains, URLs, emails and hashes found in
+// the body. Domains and emails are lowercased, the hosts of the URLs and
+// the domains of the emails are reported as domains too.
+func extractIOCs(body string) *iocSummary {
+	ips, domains, urls, emails, hashes := iocSet{}, iocSet{}, iocSet{}, iocSet{}, iocSet{}
+	addDomain := func(d string) {
+		d = strings.ToLower(strings.TrimSuffix(d, "."))
+		if i := strings.LastIndexByte(d, '.'); i < 0 || iocFileExtensions[d[i+1:]] {
+			return
+		}
+		domains.add(d)
+	}
+	addIP := func(s string) {
+		if a, err := netip.ParseAddr(s); err == nil && !a.IsUnspecified() && !a.IsLoopback() {
+			ips.add(a.String())
+		}
+	}
+	for _, u := range regexIOCURL.FindAllString(body, -1) {
+		u = strings.TrimRight(u, ".,;:!?)]}")
+		parsed, err := url.Parse(u)
+		if err != nil || parsed.Hostname() == "" {
+			continue
+		}
+		urls.add(u)
+		if _, err := netip.ParseAddr(parsed.Hostname()); err == nil {
+			addIP(parsed.Hostname())
+		} else {
+			addDomain(parsed.Hostname())
+		}
+	}
+	for _, e := range regexIOCEmail.FindAllString(body, -1) {
+		e = strings.ToLower(e)
+		emails.add(e)
+		addDomain(e[strings.IndexByte(e, '@')+1:])
+	}
+	// remove the found urls and emails so their parts are not reported again
+	rest := regexIOCEmail.ReplaceAllString(regexIOCURL.ReplaceAllString(body, " "), " ")
+	for _, ip := range regexIOCIPv4.FindAllString(rest, -1) {
+		addIP(ip)
+	}
+	for _, loc := range regexIOCIPv6.FindAllStringIndex(rest, -1) {
+		// the regexp has no word boundaries for colons, skip matches
+		// within words like std::cout
+		if (loc[0] > 0 && isAlnum(rest[loc[0]-1])) || (loc[1] < len(rest) && isAlnum(rest[loc[1]])) {
+			continue
+		}
+		addIP(rest[loc[0]:loc[1]])
+	}
+	for _, d := range regexIOCDomain.FindAllString(rest, -1) {
+		addDomain(d)
+	}
+	for _, h := range regexIOCHash.FindAllString(rest, -1) {
+		hashes.add(strings.ToLower(h))
+	}
+	return &iocSummary{
+		IPs:     ips.sorted(),
+		Domains: domains.sorted(),
+		URLs:    urls.sorted(),
+		Emails:  emails.sorted(),
+		Hashes:  hashes.sorted(),
+	}
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// write appends the summary to the alert text
+func (s *iocSummary) write(w io.Writer) error {
+	if s == nil || s.empty() {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "\nIndicators of compromise:\n"); err != nil {
+		return err
+	}
+	sections := []struct {
+		name  string
+		items []string
+	}{
+		{"IPs", s.IPs},
+		{"Domains", s.Domains},
+		{"URLs", s.URLs},
+		{"Emails", s.Emails},
+		{"Hashes", s.Hashes},
+	}
+	for _, x := range sections {
+		if len(x.items) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s (%d):\n", x.name, len(x.items)); err != nil {
+			return err
+		}
+		for _, i := range x.items {
+			if _, err := fmt.Fprintf(w, "  %s\n", i); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// defang makes the urls, IPs, emails and domains in s unclickable, e.g.
+// hxxps://evil[.]example[.]com and admin[@]example[.]com
+func defang(s string) string {
+	dots := strings.NewReplacer(".", "[.]")
+	s = regexIOCURL.ReplaceAllStringFunc(s, func(u string) string {
+		scheme, rest, _ := strings.Cut(u, "://")
+		scheme = strings.NewReplacer("t", "x", "T", "X").Replace(scheme)
+		// only the host, the path is not clickable without it
+		host, path, _ := strings.Cut(rest, "/")
+		if path != "" || strings.HasSuffix(rest, "/") {
+			path = "/" + path
+		}
+		return scheme + "://" + dots.Replace(host) + path
+	})
+	s = regexIOCEmail.ReplaceAllStringFunc(s, func(e string) string {
+		return dots.Replace(strings.Replace(e, "@", "[@]", 1))
+	})
+	s = regexIOCIPv4.ReplaceAllStringFunc(s, dots.Replace)
+	return regexIOCDomain.ReplaceAllStringFunc(s, func(d string) string {
+		if i := strings.LastIndexByte(d, '.'); iocFileExtensions[strings.ToLower(d[i+1:])] {
+			return d
+		}
+		return dots.Replace(d)
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractIOCs(t *testing.T) {
+	body := `download from http://Evil.example.com/payload.exe, then
+connect to 203.0.113.7 and 2001:db8::1 or 127.0.0.1
+contact admin@Example.org for details
+std::cout << x; read config.json
+md5 d41d8cd98f00b204e9800998ecf8427e
+sha1 DA39A3EE5E6B4B0D3255BFEF95601890AFD80709
+again http://Evil.example.com/payload.exe`
+	s := extractIOCs(body)
+	expected := &iocSummary{
+		IPs:     []string{"2001:db8::1", "203.0.113.7"},
+		Domains: []string{"evil.example.com", "example.org"},
+		URLs:    []string{"http://Evil.example.com/payload.exe"},
+		Emails:  []string{"admin@example.org"},
+		Hashes:  []string{"d41d8cd98f00b204e9800998ecf8427e", "da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("unexpected indicators:\n%+v\nexpected\n%+v", s, expected)
+	}
+}
+
+func TestExtractIOCsLimit(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 2*maxIOCsPerType; i++ {
+		b.WriteString("host" + strings.Repeat("a", i+1) + ".example.com\n")
+	}
+	if s := extractIOCs(b.String()); len(s.Domains) != maxIOCsPerType {
+		t.Errorf("expected %d domains, got %d", maxIOCsPerType, len(s.Domains))
+	}
+	if s := extractIOCs("nothing to see"); !s.empty() {
+		t.Errorf("expected no indicators, got %+v", s)
+	}
+}
+
+func TestPasteStringIOCs(t *testing.T) {
+	p := paste{Matches: map[string][]string{"a": {"a"}}}
+	if strings.Contains(p.String(), "Indicators") {
+		t.Error("expected no summary without indicators")
+	}
+	p.IOCs = extractIOCs("see 198.51.100.1")
+	s := p.String()
+	if !strings.Contains(s, "Indicators of compromise:\nIPs (1):\n  198.51.100.1\n") {
+		t.Errorf("expected the summary in the alert, got:\n%s", s)
+	}
+}
+
+func TestDefang(t *testing.T) {
+	tests := map[string]string{
+		"get https://evil.example.com/a/b.php now": "get hxxps://evil[.]example[.]com/a/b.php now",
+		"ftp://203.0.113.7/":                       "fxp://203[.]0[.]113[.]7/",
+		"mail admin@example.org":                   "mail admin[@]example[.]org",
+		"connect to 198.51.100.1:22":               "connect to 198[.]51[.]100[.]1:22",
+		"see evil.example.com, not config.json.":   "see evil[.]example[.]com, not config.json.",
+		"nothing here. really":                     "nothing here. really",
+	}
+	for in, expected := range tests {
+		if got := defang(in); got != expected {
+			t.Errorf("defang(%q) = %q, expected %q", in, got, expected)
+		}
+	}
+}
+
+func TestAlertTextDefanged(t *testing.T) {
+	p := paste{
+		FullURL: "https://pastebin.com/abc",
+		Title:   "links to evil.example.com",
+		Matches: map[string][]string{"evil": {"http://evil.example.com/x"}},
+		IOCs:    &iocSummary{Domains: []string{"evil.example.com"}},
+	}
+	s := p.alertText(true)
+	if strings.Contains(s, "evil.example.com") || !strings.Contains(s, "hxxp://evil[.]example[.]com/x") {
+		t.Errorf("expected everything from the paste to be defanged, got:\n%s", s)
+	}
+	if !strings.Contains(s, "https://pastebin.com/abc") {
+		t.Errorf("expected the paste url to be kept, got:\n%s", s)
+	}
+	if !strings.Contains(p.String(), "http://evil.example.com/x") {
+		t.Error("expected no defanging by default")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// how long the leader holds the lease without renewing it
+	defaultLeaderLease = 5 * time.Second
+)
+
+// only touch the lease if it is still held by this instance
+var (
+	renewLeaseScript   = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`)
+	releaseLeaseScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+)
+
+// leaderElection elects a single active instance with a lease in redis.
+// The leader renews the lease three times per lease duration so the
+// standby instances take over within one lease after the leader died.
+type leaderElection struct {
+	client *redis.Client
+	key    string
+	id     string
+	lease  time.Duration
+	leader atomic.Bool
+}
+
+func newLeaderElection(client *redis.Client, prefix, id string, lease time.Duration) *leaderElection {
+	if prefix == "" {
+		prefix = defaultRedisPrefix
+	}
+	if lease <= 0 {
+		lease = defaultLeaderLease
+	}
+	return &leaderElection{client: client, key: prefix + "leader", id: id, lease: lease}
+}
+
+func (e *leaderElection) isLeader() bool {
+	return e.leader.Load()
+}
+
+// campaign acquires or renews the lease and returns if this instance is
+// the leader now
+func (e *leaderElection) campaign(ctx context.Context) (bool, error) {
+	if e.isLeader() {
+		n, err := renewLeaseScript.Run(ctx, e.client, []string{e.key}, e.id, e.lease.Milliseconds()).Int()
+		if err != nil {
+			return false, fmt.Errorf("could not renew lease: %v", err)
+		}
+		return n == 1, nil
+	}
+	ok, err := e.client.SetNX(ctx, e.key, e.id, e.lease).Result()
+	if err != nil {
+		return false, fmt.Errorf("could not acquire lease: %v", err)
+	}
+	return ok, nil
+}
+
+// release gives up the lease so a standby instance takes over immediately
+func (e *leaderElection) release(ctx context.Context) error {
+	if !e.leader.Swap(false) {
+		return nil
+	}
+	return releaseLeaseScript.Run(ctx, e.client, []string{e.key}, e.id).Err()
+}
+
+// run campaigns for the leadership until the context is canceled. On
+// errors the instance steps down as it can not be sure to still hold the
+// lease.
+func (e *leaderElection) run(ctx context.Context, errs chan<- error) {
+	ticker := time.NewTicker(e.lease / 3)
+	defer ticker.Stop()
+	for {
+		leader, err := e.campaign(ctx)
+		if err != nil && ctx.Err() == nil {
+			errs <- fmt.Errorf("leader election: %v", err)
+		}
+		if was := e.leader.Swap(leader); was != leader {
+			if leader {
+				slog.Info("became leader", "id", e.id)
+			} else {
+				slog.Warn("lost leadership", "id", e.id)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			if err := e.release(context.Background()); err != nil {
+				slog.Error("could not release lease", "error", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLeaderElection(t *testing.T) {
+	mr := newTestRedis(t)
+	client, err := openRedis(redisConfig{Address: mr.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close() // nolint: errcheck
+	ctx := context.Background()
+	e1 := newLeaderElection(client, "", "one", time.Second)
+	e2 := newLeaderElection(client, "", "two", time.Second)
+
+	campaign := func(e *leaderElection) bool {
+		t.Helper()
+		leader, err := e.campaign(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.leader.Store(leader)
+		return leader
+	}
+
+	if !campaign(e1) {
+		t.Fatal("expected the first instance to become leader")
+	}
+	if campaign(e2) {
+		t.Fatal("expected the second instance to stay standby")
+	}
+	// renewing keeps the lease alive
+	mr.FastForward(800 * time.Millisecond)
+	if !campaign(e1) {
+		t.Fatal("expected the leader to renew the lease")
+	}
+	mr.FastForward(800 * time.Millisecond)
+	if campaign(e2) {
+		t.Fatal("expected the renewed lease to be held")
+	}
+
+	// the leader died
+	mr.FastForward(time.Second)
+	if !campaign(e2) {
+		t.Fatal("expected the standby to take over the expired lease")
+	}
+	if campaign(e1) {
+		t.Fatal("expected the old leader to step down")
+	}
+
+	// releasing hands over immediately but not a lease held by someone else
+	if err := e1.release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if campaign(e1) {
+		t.Fatal("expected the lease of the other instance to be kept")
+	}
+	if err := e2.release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !campaign(e1) {
+		t.Fatal("expected the released lease to be acquired")
+	}
+}
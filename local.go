@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// localArchiver writes pastes to a local directory. Old files are pruned
+// by the retention job when they exceed the configured age or the
+// directory gets too big.
+type localArchiver struct {
+	config  local
+	maxAge  time.Duration
+	maxSize int64
+	chain   *hashChain
+
+	// only one prune at a time
+	mu sync.Mutex
+}
+
+func newLocalArchiver(c local) (*localArchiver, error) {
+	l := &localArchiver{config: c, maxSize: c.MaxSize * 1024 * 1024}
+	if c.MaxAge != "" {
+		d, err := time.ParseDuration(c.MaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max age %q: %v", c.MaxAge, err)
+		}
+		l.maxAge = d
+	}
+	if f := c.Snapshot.Format; f != "" && f != snapshotPDF && f != snapshotPNG {
+		return nil, fmt.Errorf("invalid snapshot format %q", f)
+	}
+	if err := os.MkdirAll(c.Directory, 0750); err != nil {
+		return nil, err
+	}
+	if c.Evidence {
+		if l.maxAge > 0 || l.maxSize > 0 {
+			return nil, fmt.Errorf("maxage and maxsize can not be used in evidence mode")
+		}
+		chain, err := openHashChain(c.Directory)
+		if err != nil {
+			return nil, fmt.Errorf("could not verify hash chain: %v", err)
+		}
+		l.chain = chain
+	}
+	return l, nil
+}
+
+func (l *localArchiver) archive(ctx context.Context, p paste) (string, error) {
+	body, err := gzipString(p.Content)
+	if err != nil {
+		return "", err
+	}
+	f := filepath.Join(l.config.Directory, filepath.FromSlash(archiveKey("", p)))
+	if err := os.MkdirAll(filepath.Dir(f), 0750); err != nil {
+		return "", err
+	}
+	// store the metadata next to the content so the archive can be
+	// used to match new keywords against old pastes
+	meta, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	files := []archiveFile{{f, body}, {metadataFile(f), meta}}
+	if l.config.Snapshot.enabled() && len(p.Matches) > 0 {
+		// a missing snapshot must not lose the archived content
+		if b, err := renderSnapshot(ctx, l.config.Snapshot, p, time.Now()); err != nil {
+			metricSnapshotErrors.inc("")
+			logger(componentNotifier).Error("could not render snapshot", "paste_key", p.Key, "error", err)
+		} else {
+			files = append(files, archiveFile{snapshotFile(f, l.config.Snapshot), b})
+		}
+	}
+	if l.chain != nil {
+		return f, l.archiveEvidence(files)
+	}
+	for _, x := range files {
+		if err := writeFileAtomic(x.file, x.b, 0640); err != nil {
+			return "", err
+		}
+	}
+	return f, nil
+}
+
+type archiveFile struct {
+	file string
+	b    []byte
+}
+
+// archiveEvidence writes read-only files which are never overwritten and
+// records them in the hash chain
+func (l *localArchiver) archiveEvidence(files []archiveFile) error {
+	for _, x := range files {
+		written, err := writeFileOnce(x.file, x.b, 0440)
+		if err != nil {
+			return err
+		}
+		if !written {
+			continue
+		}
+		rel, err := filepath.Rel(l.config.Directory, x.file)
+		if err != nil {
+			return err
+		}
+		if err := l.chain.add(rel, sha256Hex(x.b), time.Now()); err != nil {
+			return fmt.Errorf("could not add %s to hash chain: %v", rel, err)
+		}
+	}
+	return nil
+}
+
+func metadataFile(f string) string {
+	return strings.TrimSuffix(f, ".txt.gz") + ".json"
+}
+
+// walkLocalArchive calls fn for every paste in the local archive which
+// was archived on or after since. Pastes without a metadata file only
+// contain the key and the content.
+func walkLocalArchive(dir string, since time.Time, fn func(p paste) error) error {
+	day := since.UTC().Format("2006/01/02")
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || !strings.HasSuffix(path, ".txt.gz") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		// the directories are named after the date so compare them as strings
+		if !since.IsZero() && filepath.ToSlash(rel) < day {
+			return nil
+		}
+		p, err := readArchivedPaste(path)
+		if err != nil {
+			return fmt.Errorf("could not read %s: %v", path, err)
+		}
+		return fn(p)
+	})
+}
+
+func readArchivedPaste(f string) (paste, error) {
+	var p paste
+	meta, err := ioutil.ReadFile(metadataFile(f)) // nolint: gosec
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(meta, &p); err != nil {
+			return p, err
+		}
+	case os.IsNotExist(err):
+		p.Key = strings.TrimSuffix(filepath.Base(f), ".txt.gz")
+	default:
+		return p, err
+	}
+
+	b, err := ioutil.ReadFile(f) // nolint: gosec
+	if err != nil {
+		return p, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return p, err
+	}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return p, err
+	}
+	p.Content = string(content)
+	return p, nil
+}
+
+type archivedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// prune deletes all files older than the max age and the oldest files
+// as long as the directory exceeds the max size. It returns the number
+// of deleted files.
+func (l *localArchiver) prune(ctx context.Context, now time.Time) (int, error) {
+	if l.maxAge <= 0 && l.maxSize <= 0 {
+		return 0, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var files []archivedFile
+	var total int64
+	err := filepath.Walk(l.config.Directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, archivedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	deleted := 0
+	for _, f := range files {
+		if ctx.Err() != nil {
+			return deleted, ctx.Err()
+		}
+		expired := l.maxAge > 0 && now.Sub(f.modTime) > l.maxAge
+		tooBig := l.maxSize > 0 && total > l.maxSize
+		if !expired && !tooBig {
+			break
+		}
+		if err := os.Remove(f.path); err != nil {
+			return deleted, err
+		}
+		total -= f.size
+		deleted++
+		removeEmptyDirs(filepath.Dir(f.path), l.config.Directory)
+	}
+	return deleted, nil
+}
+
+// removeEmptyDirs removes dir and its parents up to root if they are empty
+func removeEmptyDirs(dir, root string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && len(dir) > len(root); dir = filepath.Dir(dir) {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			return
+		}
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
+
+// writeFileAtomic writes to a temporary file first and renames it so
+// readers never see partial files
+func writeFileAtomic(f string, b []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(f), "."+filepath.Base(f))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()           // nolint: errcheck,gosec
+		os.Remove(tmp.Name()) // nolint: errcheck,gosec
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name()) // nolint: errcheck,gosec
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		os.Remove(tmp.Name()) // nolint: errcheck,gosec
+		return err
+	}
+	return os.Rename(tmp.Name(), f)
+}
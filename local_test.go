@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLocalArchiver(t *testing.T) {
+	dir := t.TempDir()
+	a, err := newLocalArchiver(local{Directory: dir})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	l, err := a.archive(context.Background(), paste{Key: "abc", Date: "1580000000", Content: "content"})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if l != filepath.Join(dir, "2020", "01", "26", "abc.txt.gz") {
+		t.Fatalf("unexpected location %q", l)
+	}
+	b, err := ioutil.ReadFile(l)
+	if err != nil {
+		t.Fatalf("could not read archived file: %v", err)
+	}
+	if x := gunzip(t, b); x != "content" {
+		t.Fatalf("unexpected content %q", x)
+	}
+}
+
+func TestLocalArchiverPrune(t *testing.T) {
+	dir := t.TempDir()
+	a, err := newLocalArchiver(local{Directory: dir, MaxAge: "24h", MaxSize: 1})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	now := time.Now()
+	files := []struct {
+		name string
+		size int
+		age  time.Duration
+	}{
+		{"2020/01/01/expired.txt.gz", 10, 48 * time.Hour},
+		{"2020/01/02/old.txt.gz", 512 * 1024, 3 * time.Hour},
+		{"2020/01/02/older.txt.gz", 512 * 1024, 4 * time.Hour},
+		{"2020/01/03/new.txt.gz", 512 * 1024, 1 * time.Hour},
+	}
+	for _, f := range files {
+		p := filepath.Join(dir, filepath.FromSlash(f.name))
+		if err := os.MkdirAll(filepath.Dir(p), 0750); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+		if err := ioutil.WriteFile(p, make([]byte, f.size), 0600); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+		ts := now.Add(-f.age)
+		if err := os.Chtimes(p, ts, ts); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+	}
+
+	n, err := a.prune(context.Background(), now)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	// the expired file and the oldest file to get below 1MB
+	if n != 2 {
+		t.Fatalf("expected 2 deleted files, got %d", n)
+	}
+	for name, exists := range map[string]bool{
+		"2020/01/01":              false,
+		"2020/01/02/older.txt.gz": false,
+		"2020/01/02/old.txt.gz":   true,
+		"2020/01/03/new.txt.gz":   true,
+	} {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if exists != (err == nil) {
+			t.Errorf("%s: expected exists to be %t, got error %v", name, exists, err)
+		}
+	}
+}
+
+func TestWalkLocalArchive(t *testing.T) {
+	dir := t.TempDir()
+	a, err := newLocalArchiver(local{Directory: dir})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	ctx := context.Background()
+	for _, p := range []paste{
+		{Key: "old", Date: "1580000000", Title: "old paste", Content: "old content"},
+		{Key: "new", Date: "1590000000", Title: "new paste", Content: "new content", Matches: map[string][]string{"k": {"line"}}},
+	} {
+		if _, err := a.archive(ctx, p); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+	}
+	// a paste without metadata
+	f := filepath.Join(dir, "2020", "05", "20", "bare.txt.gz")
+	b, err := gzipString("bare content")
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if err := ioutil.WriteFile(f, b, 0600); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	found := make(map[string]paste)
+	err = walkLocalArchive(dir, time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC), func(p paste) error {
+		found[p.Key] = p
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("expected 2 pastes, got %v", found)
+	}
+	if p := found["new"]; p.Title != "new paste" || p.Content != "new content" || len(p.Matches["k"]) != 1 {
+		t.Fatalf("unexpected paste %+v", p)
+	}
+	if p := found["bare"]; p.Content != "bare content" {
+		t.Fatalf("unexpected paste %+v", p)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// instanceLock is a pid file holding an exclusive lock while the scraper
+// runs so a second instance on the same state fails instead of sending
+// duplicate alerts
+type instanceLock struct {
+	f *os.File
+}
+
+// lockFile returns the configured pid file or one next to the state
+func lockFile(c configuration) string {
+	switch {
+	case c.Pidfile != "":
+		return c.Pidfile
+	case c.Statedb != "":
+		return c.Statedb + ".lock"
+	case c.Statefile != "":
+		return c.Statefile + ".lock"
+	}
+	return ""
+}
+
+func acquireLock(file string) (*instanceLock, error) {
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0600) // nolint: gosec
+	if err != nil {
+		return nil, fmt.Errorf("could not open lock file %s: %v", file, err)
+	}
+	if err := lockExclusive(f); err != nil {
+		b, _ := ioutil.ReadAll(f)
+		f.Close() // nolint: errcheck,gosec
+		if pid := strings.TrimSpace(string(b)); pid != "" {
+			return nil, fmt.Errorf("another instance with pid %s is already running (lock file %s)", pid, file)
+		}
+		return nil, fmt.Errorf("another instance is already running (lock file %s): %v", file, err)
+	}
+	if err := f.Truncate(0); err != nil {
+		f.Close() // nolint: errcheck,gosec
+		return nil, err
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		f.Close() // nolint: errcheck,gosec
+		return nil, err
+	}
+	return &instanceLock{f: f}, nil
+}
+
+// release empties the pid file and unlocks it. The file is kept as
+// removing it while locked lets two instances lock different files, the
+// next instance takes the lock on the same file.
+func (l *instanceLock) release() error {
+	if err := l.f.Truncate(0); err != nil {
+		l.f.Close() // nolint: errcheck,gosec
+		return err
+	}
+	if err := unlockFile(l.f); err != nil {
+		l.f.Close() // nolint: errcheck,gosec
+		return err
+	}
+	return l.f.Close()
+}
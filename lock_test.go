@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAcquireLock(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "state.json.lock")
+	l, err := acquireLock(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = acquireLock(f)
+	if err == nil || !strings.Contains(err.Error(), "pid "+strconv.Itoa(os.Getpid())) {
+		t.Fatalf("expected the second lock to fail naming the pid, got %v", err)
+	}
+	if err := l.release(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(f); err != nil || len(b) != 0 {
+		t.Fatalf("expected an empty pid file, got %q, %v", b, err)
+	}
+	l, err = acquireLock(f)
+	if err != nil {
+		t.Fatalf("expected the lock to be free again, got %v", err)
+	}
+	l.release() // nolint: errcheck,gosec
+}
+
+func TestLockFile(t *testing.T) {
+	tests := []struct {
+		c        configuration
+		expected string
+	}{
+		{configuration{}, ""},
+		{configuration{Statefile: "state.json"}, "state.json.lock"},
+		{configuration{Statedb: "state.db", Statefile: "state.json"}, "state.db.lock"},
+		{configuration{Pidfile: "/run/scraper.pid", Statedb: "state.db"}, "/run/scraper.pid"},
+	}
+	for _, x := range tests {
+		if f := lockFile(x.c); f != x.expected {
+			t.Errorf("expected %q, got %q", x.expected, f)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rotateTimeFormat = "20060102T150405.000"
+
+// rotatingFile is a log file which is rotated when it exceeds the max
+// size or age. Rotated files get a timestamp suffix, are optionally
+// compressed and the oldest ones are deleted.
+type rotatingFile struct {
+	mu         sync.Mutex
+	file       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+
+	f      *os.File
+	size   int64
+	opened time.Time
+	// overridden in tests
+	now func() time.Time
+}
+
+func newRotatingFile(c logConfig) (*rotatingFile, error) {
+	r := &rotatingFile{
+		file:       c.File,
+		maxSize:    c.MaxSize * 1024 * 1024,
+		maxBackups: c.MaxBackups,
+		compress:   c.Compress,
+		now:        time.Now,
+	}
+	if c.MaxAge != "" {
+		d, err := time.ParseDuration(c.MaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log max age %q: %v", c.MaxAge, err)
+		}
+		r.maxAge = d
+	}
+	if err := os.MkdirAll(filepath.Dir(c.File), 0750); err != nil {
+		return nil, err
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640) // nolint: gosec
+	if err != nil {
+		return fmt.Errorf("could not open log file: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close() // nolint: errcheck,gosec
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	// appending to an existing file continues its age
+	r.opened = info.ModTime()
+	if r.size == 0 {
+		r.opened = r.now()
+	}
+	return nil
+}
+
+func (r *rotatingFile) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	expired := r.maxAge > 0 && r.now().Sub(r.opened) >= r.maxAge
+	full := r.maxSize > 0 && r.size+int64(len(b)) > r.maxSize
+	if r.size > 0 && (expired || full) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file and opens a new one
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	ext := filepath.Ext(r.file)
+	backup := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(r.file, ext), r.now().UTC().Format(rotateTimeFormat), ext)
+	if err := os.Rename(r.file, backup); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	if r.compress {
+		if err := gzipFile(backup); err != nil {
+			return err
+		}
+	}
+	return r.removeBackups()
+}
+
+// backups returns all rotated files sorted from oldest to newest
+func (r *rotatingFile) backups() ([]string, error) {
+	ext := filepath.Ext(r.file)
+	matches, err := filepath.Glob(strings.TrimSuffix(r.file, ext) + "-*" + ext + "*")
+	if err != nil {
+		return nil, err
+	}
+	// the timestamp suffix sorts chronologically
+	sort.Strings(matches)
+	return matches, nil
+}
+
+func (r *rotatingFile) removeBackups() error {
+	if r.maxBackups <= 0 {
+		return nil
+	}
+	files, err := r.backups()
+	if err != nil {
+		return err
+	}
+	for len(files) > r.maxBackups {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// gzipFile compresses the file to <file>.gz and removes the original
+func gzipFile(f string) (err error) {
+	in, err := os.Open(f) // nolint: gosec
+	if err != nil {
+		return err
+	}
+	defer in.Close() // nolint: errcheck
+
+	out, err := os.OpenFile(f+".gz", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640) // nolint: gosec
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(out.Name()) // nolint: errcheck,gosec
+		}
+	}()
+	w := gzip.NewWriter(out)
+	if _, err := io.Copy(w, in); err != nil {
+		out.Close() // nolint: errcheck,gosec
+		return err
+	}
+	if err := w.Close(); err != nil {
+		out.Close() // nolint: errcheck,gosec
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(f)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileSize(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "scraper.log")
+	r, err := newRotatingFile(logConfig{File: f, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatalf("could not open log file: %v", err)
+	}
+	defer r.Close() // nolint: errcheck
+	r.maxSize = 10
+
+	now := time.Date(2020, 5, 20, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("could not write: %v", err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		t.Fatalf("could not read log file: %v", err)
+	}
+	if string(b) != "line 4\n" {
+		t.Fatalf("unexpected content %q", b)
+	}
+	backups, err := r.backups()
+	if err != nil {
+		t.Fatalf("could not list backups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v", backups)
+	}
+	if !strings.HasSuffix(backups[1], ".log.gz") {
+		t.Fatalf("backup was not compressed: %s", backups[1])
+	}
+	c, err := ioutil.ReadFile(backups[1])
+	if err != nil {
+		t.Fatalf("could not read backup: %v", err)
+	}
+	if x := gunzip(t, c); x != "line 3\n" {
+		t.Fatalf("unexpected backup content %q", x)
+	}
+}
+
+func TestRotatingFileAge(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "scraper.log")
+	r, err := newRotatingFile(logConfig{File: f, MaxAge: "24h"})
+	if err != nil {
+		t.Fatalf("could not open log file: %v", err)
+	}
+	defer r.Close() // nolint: errcheck
+
+	if _, err := r.Write([]byte("old\n")); err != nil {
+		t.Fatalf("could not write: %v", err)
+	}
+	r.now = func() time.Time { return time.Now().Add(25 * time.Hour) }
+	if _, err := r.Write([]byte("new\n")); err != nil {
+		t.Fatalf("could not write: %v", err)
+	}
+	backups, err := r.backups()
+	if err != nil {
+		t.Fatalf("could not list backups: %v", err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("expected a backup, got %v", backups)
+	}
+
+	if _, err := newRotatingFile(logConfig{File: f, MaxAge: "1 day"}); err == nil {
+		t.Fatal("expected error on invalid max age")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+
+	// levelTrace is more verbose than debug, for example every checked line
+	levelTrace = slog.Level(-8)
+
+	componentFetcher  = "fetcher"
+	componentMatcher  = "matcher"
+	componentNotifier = "notifier"
+)
+
+var logLevels = map[string]slog.Level{
+	"error": slog.LevelError,
+	"warn":  slog.LevelWarn,
+	"info":  slog.LevelInfo,
+	"debug": slog.LevelDebug,
+	"trace": levelTrace,
+}
+
+var logComponents = []string{componentFetcher, componentMatcher, componentNotifier}
+
+func parseLogLevel(s string) (slog.Level, error) {
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	l, ok := logLevels[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("invalid log level %q", s)
+	}
+	return l, nil
+}
+
+// componentHandler filters the records by the level of the component
+// set with the "component" attribute
+type componentHandler struct {
+	inner      slog.Handler
+	level      slog.Level
+	components map[string]slog.Level
+	component  string
+}
+
+func (h *componentHandler) minLevel() slog.Level {
+	if l, ok := h.components[h.component]; ok {
+		return l
+	}
+	return h.level
+}
+
+func (h *componentHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.minLevel()
+}
+
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	x := *h
+	x.inner = h.inner.WithAttrs(attrs)
+	for _, a := range attrs {
+		if a.Key == "component" {
+			x.component = a.Value.String()
+		}
+	}
+	return &x
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	x := *h
+	x.inner = h.inner.WithGroup(name)
+	return &x
+}
+
+// replaceLevel prints the custom trace level by name
+func replaceLevel(_ []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey {
+		if l, ok := a.Value.Any().(slog.Level); ok && l == levelTrace {
+			a.Value = slog.StringValue("TRACE")
+		}
+	}
+	return a
+}
+
+// newLogHandler returns a slog handler writing text or json lines
+func newLogHandler(w io.Writer, c logConfig) (slog.Handler, error) {
+	level, err := parseLogLevel(c.Level)
+	if err != nil {
+		return nil, err
+	}
+	components := make(map[string]slog.Level)
+	for k, v := range c.Components {
+		if !stringInSlice(k, logComponents) {
+			return nil, fmt.Errorf("unknown log component %q", k)
+		}
+		if components[k], err = parseLogLevel(v); err != nil {
+			return nil, err
+		}
+	}
+	// the inner handler logs everything, filtering is done per component
+	opts := &slog.HandlerOptions{Level: levelTrace, ReplaceAttr: replaceLevel}
+	var inner slog.Handler
+	switch c.Format {
+	case "", logFormatText:
+		inner = slog.NewTextHandler(w, opts)
+	case logFormatJSON:
+		inner = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unsupported log format %q", c.Format)
+	}
+	return &componentHandler{inner: inner, level: level, components: components}, nil
+}
+
+// setupLogging replaces the default logger. Output of the standard log
+// package is also passed to the handler.
+func setupLogging(w io.Writer, c logConfig) error {
+	h, err := newLogHandler(w, c)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// logFlags applies the command line flags to the log config. -debug is
+// kept as a shortcut for the debug level. The flags take precedence over
+// the config, info is used if neither sets a level.
+func logFlags(c logConfig) logConfig {
+	if *logFormat != "" {
+		c.Format = *logFormat
+	}
+	switch {
+	case *logLevel != "":
+		c.Level = *logLevel
+	case *debug:
+		c.Level = "debug"
+	case c.Level == "":
+		c.Level = "info"
+	}
+	return c
+}
+
+// logger returns the default logger for the component
+func logger(component string) *slog.Logger {
+	return slog.Default().With("component", component)
+}
+
+// fatal logs the error and exits
+func fatal(msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
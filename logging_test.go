@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetupLogging(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	var buf bytes.Buffer
+	if err := setupLogging(&buf, logConfig{Format: logFormatJSON}); err != nil {
+		t.Fatalf("could not setup logging: %v", err)
+	}
+	slog.Debug("hidden")
+	slog.Info("found paste", "paste_key", "abc", "keyword", "secret")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single json line, got %q: %v", buf.String(), err)
+	}
+	if entry["msg"] != "found paste" || entry["paste_key"] != "abc" || entry["keyword"] != "secret" {
+		t.Fatalf("unexpected log entry %v", entry)
+	}
+
+	for _, c := range []logConfig{
+		{Format: "xml"},
+		{Level: "verbose"},
+		{Components: map[string]string{"unknown": "debug"}},
+		{Components: map[string]string{componentFetcher: "verbose"}},
+	} {
+		if err := setupLogging(&buf, c); err == nil {
+			t.Errorf("expected error for %+v", c)
+		}
+	}
+}
+
+func TestComponentLevels(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	var buf bytes.Buffer
+	c := logConfig{Level: "warn", Components: map[string]string{componentMatcher: "trace"}}
+	if err := setupLogging(&buf, c); err != nil {
+		t.Fatalf("could not setup logging: %v", err)
+	}
+	slog.Info("hidden default")
+	logger(componentFetcher).Debug("hidden fetcher")
+	logger(componentMatcher).Log(context.Background(), levelTrace, "visible matcher")
+	logger(componentFetcher).Error("visible fetcher")
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if !strings.Contains(out, "level=TRACE msg=\"visible matcher\" component=matcher") {
+		t.Fatalf("trace message missing: %q", out)
+	}
+	if !strings.Contains(out, "visible fetcher") {
+		t.Fatalf("error message missing: %q", out)
+	}
+}
+
+func TestLogFlags(t *testing.T) {
+	defer func(d bool, l string) { *debug, *logLevel = d, l }(*debug, *logLevel)
+
+	*debug, *logLevel = false, ""
+	if c := logFlags(logConfig{}); c.Level != "info" {
+		t.Errorf("expected the info default, got %q", c.Level)
+	}
+	if c := logFlags(logConfig{Level: "warn"}); c.Level != "warn" {
+		t.Errorf("expected the config level, got %q", c.Level)
+	}
+	*debug = true
+	for _, c := range []logConfig{{}, {Level: "warn"}} {
+		if c := logFlags(c); c.Level != "debug" {
+			t.Errorf("-debug ignored, got %q", c.Level)
+		}
+	}
+	// the config defaults must not hide -debug
+	var c configuration
+	setDefaults(&c)
+	if l := logFlags(c.Log).Level; l != "debug" {
+		t.Errorf("-debug ignored with the config defaults, got %q", l)
+	}
+	*logLevel = "trace"
+	if c := logFlags(logConfig{}); c.Level != "trace" {
+		t.Errorf("expected -loglevel to win, got %q", c.Level)
+	}
+}
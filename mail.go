@@ -2,37 +2,62 @@ package main
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"fmt"
-	"log"
+	"time"
 
+	"github.com/FireFart/pastebin_scraper/notify"
 	gomail "gopkg.in/gomail.v2"
 )
 
-func sendEmail(config configuration, m *gomail.Message) error {
-	debugOutput("sending mail")
+// sendEmail delivers the mail within the configured timeout. Canceling the
+// context aborts the smtp conversation.
+func sendEmail(ctx context.Context, config configuration, m *gomail.Message) error {
+	logger(componentNotifier).Debug("sending mail")
+	n, err := mailNotifier(config)
+	if err != nil {
+		return err
+	}
+	return n.Send(ctx, m)
+}
+
+// mailNotifier returns the notifier of the mail server in the config. In
+// test mode the mails are only logged.
+func mailNotifier(config configuration) (notify.Notifier, error) {
 	if *test {
-		text, err := messageToString(m)
-		if err != nil {
-			return fmt.Errorf("could not print mail: %v", err)
+		return printNotifier{}, nil
+	}
+	var timeout time.Duration
+	if config.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(config.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid value for timeout %q: %v", config.Timeout, err)
 		}
-		log.Printf("[MAIL] %s", text)
-		return nil
 	}
-	d := gomail.Dialer{Host: config.Mailserver, Port: config.Mailport}
-	d.TLSConfig = &tls.Config{InsecureSkipVerify: true} // nolint: gosec
-	return d.DialAndSend(m)
+	return notify.SMTP{Host: config.Mailserver, Port: config.Mailport, Timeout: timeout}, nil
+}
+
+// printNotifier logs the mails instead of sending them
+type printNotifier struct{}
+
+func (printNotifier) Send(_ context.Context, m *gomail.Message) error {
+	text, err := messageToString(m)
+	if err != nil {
+		return fmt.Errorf("could not print mail: %v", err)
+	}
+	logger(componentNotifier).Info("test mode, not sending mail", "mail", text)
+	return nil
 }
 
-func sendErrorMessage(config configuration, errorMessage error) error {
-	debugOutput("sending error mail")
+func sendErrorMessage(ctx context.Context, config configuration, errorMessage error) error {
+	logger(componentNotifier).Debug("sending error mail")
 	m := gomail.NewMessage()
 	m.SetHeader("From", config.Mailfrom)
 	m.SetHeader("To", config.Mailtoerror)
 	m.SetHeader("Subject", "ERROR in pastebin_scraper")
 	m.SetBody("text/plain", fmt.Sprintf("%v", errorMessage))
 
-	err := sendEmail(config, m)
+	err := sendEmail(ctx, config, m)
 	return err
 }
 
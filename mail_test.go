@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/FireFart/pastebin_scraper/notify"
 	"gopkg.in/gomail.v2"
 )
 
@@ -15,12 +18,27 @@ func init() {
 func TestSendEmail(t *testing.T) {
 	config := configuration{}
 	m := gomail.NewMessage()
-	err := sendEmail(config, m)
+	err := sendEmail(context.Background(), config, m)
 	if err != nil {
 		t.Fatalf("error returned: %v", err)
 	}
 }
 
+func TestMailNotifier(t *testing.T) {
+	*test = false
+	defer func() { *test = true }()
+	n, err := mailNotifier(configuration{Mailserver: "localhost", Mailport: 25, Timeout: "5s"})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if n != (notify.SMTP{Host: "localhost", Port: 25, Timeout: 5 * time.Second}) {
+		t.Fatalf("unexpected notifier %#v", n)
+	}
+	if _, err := mailNotifier(configuration{Timeout: "invalid"}); err == nil {
+		t.Fatal("expected error on invalid timeout")
+	}
+}
+
 func TestSendErrorMessage(t *testing.T) {
 	config := configuration{
 		Mailfrom:    "from@mail.com",
@@ -28,8 +46,32 @@ func TestSendErrorMessage(t *testing.T) {
 		Mailtoerror: "to@mail.com",
 	}
 	e := errors.New("test")
-	err := sendErrorMessage(config, e)
+	err := sendErrorMessage(context.Background(), config, e)
 	if err != nil {
 		t.Fatalf("error returned: %v", err)
 	}
 }
+
+func TestPasteRecipients(t *testing.T) {
+	config := configuration{
+		Mailto: "default@mail.com",
+		Groups: map[string]group{
+			"credentials": {Mailto: "soc@mail.com"},
+			"brand":       {Mailto: "default@mail.com"},
+		},
+	}
+	p := paste{Groups: []string{"brand", "credentials", "unknown"}}
+	to := p.recipients(config)
+	if len(to) != 2 || to[0] != "default@mail.com" || to[1] != "soc@mail.com" {
+		t.Fatalf("unexpected recipients: %v", to)
+	}
+	// only routed through the groups
+	config.Mailto = ""
+	delete(config.Groups, "brand")
+	if to := p.recipients(config); len(to) != 1 || to[0] != "soc@mail.com" {
+		t.Fatalf("unexpected recipients without mailto: %q", to)
+	}
+	if to := (&paste{}).recipients(config); to != nil {
+		t.Fatalf("expected no recipients, got %q", to)
+	}
+}
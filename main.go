@@ -4,225 +4,1057 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"math/rand"
-	"net"
-	"regexp"
-	"strings"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+
+	"github.com/FireFart/pastebin_scraper/scraper"
+	"github.com/FireFart/pastebin_scraper/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
-	debug = flag.Bool("debug", false, "Print debug output")
-	test  = flag.Bool("test", false, "do not send mails, print them instead")
+	debug     = flag.Bool("debug", false, "Print debug output")
+	logFormat = flag.String("logformat", "", "log format: text or json")
+	logLevel  = flag.String("loglevel", "", "log level: error, warn, info, debug or trace")
+	test      = flag.Bool("test", false, "do not send mails, print them instead")
+	pprofAddr = flag.String("pprof", "", "expose net/http/pprof on this localhost address, e.g. localhost:6060")
 
 	r = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
 
-	// capture IPs (only v4)
-	// https://www.regular-expressions.info/ip.html
-	regexIP = regexp.MustCompile(`(\b(?:\d{1,3}\.){3}\d{1,3}\b)`)
+const (
+	// how long the content hashes are kept to skip reposts
+	defaultDedupWindow = 24 * time.Hour
+	defaultTimeout     = 10 * time.Second
+	// how often the paste list is fetched and how long to wait between
+	// fetching the pastes
+	defaultInterval = 1 * time.Minute
+	defaultDelay    = 1 * time.Second
+	defaultMailport = 25
 )
 
-type keywordType struct {
-	regexp     *regexp.Regexp
-	exceptions []string
+// matcher bundles all compiled rules a paste is checked against
+type matcher struct {
+	*scraper.Matcher
+	// rules of the tenants by name
+	tenants map[string]*matcher
 }
 
-type cidrType struct {
-	ipNet *net.IPNet
-}
+// runScraper implements the run subcommand which continuously scrapes
+// the pastes. The flags are parsed from the default flag set.
+// nolint: gocyclo
+func runScraper(args []string) error {
+	configFile := flag.String("config", "", "Config File to use")
+	output := flag.String("output", "", "write every match as json line to stdout: json")
 
-func debugOutput(s string, a ...interface{}) {
-	if *debug {
-		log.Printf("[DEBUG] %s", fmt.Sprintf(s, a...))
+	// chanError is never closed as goroutines not waited for may report
+	// errors until the process exits
+	chanError := make(chan error)
+	chanOutput := make(chan paste)
+	// background tracks the reload loop, the retro-scans and the retention
+	// job so they are done before chanOutput is closed
+	var background sync.WaitGroup
+
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return err
 	}
-}
 
-func checkKeywords(body string, keywords *map[string]keywordType) (bool, map[string][]string) {
-	found := make(map[string][]string)
-	status := false
-	for k, v := range *keywords {
-		var x []string
-		s := v.regexp.FindAllString(body, -1)
-		// we have a match
-		if len(s) > 0 {
-			// check for exceptions
-			for _, m := range s {
-				match := strings.TrimSpace(m)
-				if !checkExceptions(match, v.exceptions) {
-					x = append(x, match)
-					status = true
-				}
+	if *output != "" && *output != "json" {
+		return fmt.Errorf("unsupported output %q", *output)
+	}
+	// only errors are logged to stderr with -output json unless a level was
+	// requested on the command line
+	quiet := func(c logConfig) logConfig {
+		if *output == "json" && c.File == "" && *logLevel == "" && !*debug {
+			c.Level = "error"
+			c.Components = nil
+		}
+		return c
+	}
+	if err := setupLogging(os.Stderr, quiet(logFlags(logConfig{}))); err != nil {
+		fatal("could not setup logging", "error", err)
+	}
+	slog.Info("Starting Pastebin Scraper")
+	config, err := getConfig(*configFile)
+	if err != nil {
+		fatal("could not read config file", "file", *configFile, "error", err)
+	}
+	var logOutput io.Writer = os.Stderr
+	if config.Log.File != "" {
+		f, err := newRotatingFile(config.Log)
+		if err != nil {
+			fatal("could not open log file", "error", err)
+		}
+		defer f.Close() // nolint: errcheck
+		logOutput = f
+	}
+	if err := setupLogging(logOutput, quiet(logFlags(config.Log))); err != nil {
+		fatal("could not setup logging", "error", err)
+	}
+
+	var plugins []*plugin
+	if config.Plugins.Directory != "" {
+		if plugins, err = discoverPlugins(config.Plugins); err != nil {
+			fatal("could not setup plugins", "error", err)
+		}
+		defer closePlugins(plugins)
+		for _, p := range plugins {
+			if p.is(pluginDetector) {
+				pluginDetectors = append(pluginDetectors, p.detector())
 			}
 		}
-		// append result if not in exceptions
-		if len(x) > 0 {
-			found[k] = x
+	}
+
+	live, err := newLiveConfig(*configFile, *config)
+	if err != nil {
+		fatal("could not parse config", "error", err)
+	}
+	timeout, err := time.ParseDuration(config.Timeout)
+	if err != nil {
+		fatal("invalid value for timeout", "timeout", config.Timeout, "error", err)
+	}
+	client.Timeout = timeout
+	sourceHeaders.set(config.Headers, config.Useragents)
+	atomic.StoreInt64(&maxPasteSize, config.Maxpastesize)
+	if err := setupTransport(config.HTTP); err != nil {
+		fatal("invalid http settings", "error", err)
+	}
+	if err := configureQuietHours(*config); err != nil {
+		fatal("invalid quiet hours", "error", err)
+	}
+	if err := setupProxy(config.Proxy); err != nil {
+		fatal("invalid proxy", "error", err)
+	}
+	var torRotate time.Duration
+	if config.Tor.Enabled {
+		if config.Proxy != "" {
+			fatal("tor can not be used together with a proxy")
+		}
+		if err := setupTor(config.Tor); err != nil {
+			fatal("could not setup tor", "error", err)
+		}
+		if config.Tor.Rotate != "" {
+			if torRotate, err = time.ParseDuration(config.Tor.Rotate); err != nil {
+				fatal("invalid tor rotate interval", "rotate", config.Tor.Rotate, "error", err)
+			}
 		}
 	}
-	return status, found
-}
+	interval, err := time.ParseDuration(config.Interval)
+	if err != nil {
+		fatal("invalid value for interval", "interval", config.Interval, "error", err)
+	}
+	if err := configurePace(*config); err != nil {
+		fatal("invalid pace", "error", err)
+	}
+	retries, err := newRetryPolicy(config.Retry, config.Jitter)
+	if err != nil {
+		fatal("invalid retry settings", "error", err)
+	}
+	breaker, err := newCircuitBreaker(config.Breaker)
+	if err != nil {
+		fatal("invalid circuit breaker settings", "error", err)
+	}
 
-func checkCIDRs(body string, cidrs *[]cidrType) (bool, map[string][]string) {
-	found := make(map[string][]string)
-	status := false
-	for _, cidr := range *cidrs {
-		var x []string
-		s := regexIP.FindAllString(body, -1)
-		// we have a match
-		if len(s) > 0 {
-			for _, m := range s {
-				match := strings.TrimSpace(m)
-				ip := net.ParseIP(match)
-				// invalid IP matched
-				if ip == nil {
-					debugOutput("%q is not a valid ip", match)
-					continue
-				}
-				if cidr.ipNet.Contains(ip) {
-					debugOutput("%v contains %s", cidr.ipNet, ip)
-					x = append(x, match)
-					status = true
+	dedupWindow := defaultDedupWindow
+	if config.Dedupwindow != "" {
+		dedupWindow, err = time.ParseDuration(config.Dedupwindow)
+		if err != nil {
+			fatal("invalid dedup window", "dedupwindow", config.Dedupwindow, "error", err)
+		}
+	}
+	if f := lockFile(*config); f != "" {
+		lock, err := acquireLock(f)
+		if err != nil {
+			fatal("could not lock the state", "error", err)
+		}
+		defer lock.release() // nolint: errcheck
+	}
+	st, err := openStateStore(*config)
+	if err != nil {
+		fatal("could not open state", "error", err)
+	}
+	var queue *redisQueue
+	var election *leaderElection
+	if config.Redis.Address != "" {
+		rc, err := openRedis(config.Redis)
+		if err != nil {
+			fatal("could not open redis", "error", err)
+		}
+		hostname, err := os.Hostname()
+		if err != nil {
+			fatal("could not get hostname", "error", err)
+		}
+		id := fmt.Sprintf("%s:%d", hostname, os.Getpid())
+		st = newRedisState(st, rc, config.Redis.Prefix, dedupWindow)
+		if config.Redis.HA {
+			lease := defaultLeaderLease
+			if config.Redis.Lease != "" {
+				lease, err = time.ParseDuration(config.Redis.Lease)
+				if err != nil {
+					fatal("invalid lease", "lease", config.Redis.Lease, "error", err)
 				}
 			}
-		}
-		if len(x) > 0 {
-			found[cidr.ipNet.String()] = x
+			election = newLeaderElection(rc, config.Redis.Prefix, id, lease)
+			slog.Info("running in high availability mode", "redis", config.Redis.Address, "lease", lease)
+		} else {
+			queue = newRedisQueue(rc, config.Redis.Prefix, id)
+			slog.Info("running in distributed mode", "redis", config.Redis.Address)
 		}
 	}
-	return status, found
-}
+	defer st.close() // nolint: errcheck
+	lastCheck, err := st.lastCheck()
+	if err != nil {
+		fatal("could not read state", "error", err)
+	}
 
-func checkExceptions(s string, exceptions []string) bool {
-	for _, x := range exceptions {
-		if strings.Contains(s, x) {
-			debugOutput("String %q contains exception %q", s, x)
-			return true
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	chanSignal := make(chan os.Signal, 1)
+	signal.Notify(chanSignal, os.Interrupt, syscall.SIGTERM)
+	go handleShutdown(chanSignal, cancel)
+
+	if len(pauseSignals) > 0 {
+		chanPause := make(chan os.Signal, 1)
+		for sig := range pauseSignals {
+			signal.Notify(chanPause, sig)
 		}
+		go func() {
+			for sig := range chanPause {
+				if pauseSignals[sig] {
+					scraping.pause("signal")
+				} else {
+					scraping.resume("signal")
+				}
+			}
+		}()
 	}
-	return false
-}
 
-func parseKeywords(k []keyword) *map[string]keywordType {
-	keywords := make(map[string]keywordType)
-	for _, k := range k {
-		// use a boundary for keyword searching
-		r := fmt.Sprintf(`(?im)^(.*\b%s.*)$`, regexp.QuoteMeta(k.Keyword))
-		keywords[k.Keyword] = keywordType{
-			regexp:     regexp.MustCompile(r),
-			exceptions: k.Exceptions,
+	chanReload := make(chan os.Signal, 1)
+	signal.Notify(chanReload, syscall.SIGHUP)
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-chanReload:
+			}
+			slog.Info("reloading config", "file", *configFile)
+			c, err := live.reload()
+			if err != nil {
+				chanError <- fmt.Errorf("reload: %v", err)
+				continue
+			}
+			slog.Info("config reloaded", "keywords", len(c.Keywords))
+			_, m := live.get()
+			if err := checkAddedKeywords(ctx, &background, c, m, st, chanOutput, chanError); err != nil {
+				chanError <- fmt.Errorf("reload: %v", err)
+			}
 		}
+	}()
+
+	shutdownTracing, err := setupTracing(ctx, config.Tracing)
+	if err != nil {
+		fatal("could not setup tracing", "error", err)
 	}
-	return &keywords
-}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("could not flush traces", "error", err)
+		}
+	}()
 
-func parseCIDRs(cidrs []string) (*[]cidrType, error) {
-	var ret []cidrType
-	for _, c := range cidrs {
-		_, n, err := net.ParseCIDR(c)
+	go refreshSecrets(ctx, live, chanError)
+	if config.Summary.Interval != "" {
+		go runSummaries(ctx, config.Summary, live, chanError)
+	}
+	if config.Report.Daily || config.Report.Weekly || config.Report.Historyfile != "" {
+		loc, err := reportLocation(config.Report)
 		if err != nil {
-			return nil, fmt.Errorf("could not parse cidr %s: %v", c, err)
+			fatal("could not setup reports", "error", err)
+		}
+		reports = newReportHistory(loc)
+		if config.Report.Historyfile != "" {
+			if err := reports.load(config.Report.Historyfile); err != nil {
+				fatal("could not setup reports", "error", err)
+			}
 		}
-		ret = append(ret, cidrType{ipNet: n})
+		reportsDone := make(chan struct{})
+		defer func() { <-reportsDone }()
+		go func() {
+			defer close(reportsDone)
+			runReports(ctx, config.Report, live, chanError)
+		}()
+	}
+	// wait for the pending digests on shutdown
+	digestsDone := make(chan struct{})
+	defer func() {
+		cancel()
+		<-digestsDone
+	}()
+	go func() {
+		defer close(digestsDone)
+		runDigests(ctx, live, chanError)
+	}()
+	if torRotate > 0 {
+		go rotateTorCircuits(ctx, config.Tor, torRotate, chanError)
+	}
+	if election != nil {
+		go election.run(ctx, chanError)
 	}
-	return &ret, nil
-}
-
-// nolint: gocyclo
-func main() {
-	configFile := flag.String("config", "", "Config File to use")
-	var lastCheck time.Time
 
-	chanError := make(chan error)
-	chanOutput := make(chan paste)
-	// we run in an endless loop so no need for a waitgroup here
-	defer close(chanOutput)
-	defer close(chanError)
+	var db store.Store
+	if config.Database.Driver != "" {
+		db, err = store.Open(config.Database.Driver, config.Database.DSN)
+		if err != nil {
+			fatal("could not open database", "error", err)
+		}
+		defer db.Close() // nolint: errcheck
+	}
 
-	alredyChecked := make(map[string]time.Time)
+	var duplicates *nearDuplicates
+	if config.Nearduplicates.Mode != "" {
+		var window time.Duration
+		if config.Nearduplicates.Window != "" {
+			if window, err = time.ParseDuration(config.Nearduplicates.Window); err != nil {
+				fatal("invalid value for nearduplicates.window", "window", config.Nearduplicates.Window, "error", err)
+			}
+		}
+		duplicates = newNearDuplicates(config.Nearduplicates.Distance, window)
+	}
 
-	flag.Parse()
+	var script *matchScript
+	if config.Script.File != "" {
+		if script, err = loadMatchScript(config.Script); err != nil {
+			fatal("could not setup script", "error", err)
+		}
+	}
 
-	log.Println("Starting Pastebin Scraper")
-	config, err := getConfig(*configFile)
+	archivers, err := setupArchivers(ctx, config.Archive)
 	if err != nil {
-		log.Fatalf("could not read config file %s: %v", *configFile, err)
+		fatal("could not setup archive", "error", err)
 	}
 
-	keywords := parseKeywords(config.Keywords)
-	cidrs, err := parseCIDRs(config.CIDRs)
+	policies, err := setupRetention(config.Retention, db, archivers)
 	if err != nil {
-		log.Fatalf("could not parse cidrs: %v", err)
+		fatal("could not setup retention", "error", err)
 	}
-	timeout, err := time.ParseDuration(config.Timeout)
-	if err != nil {
-		log.Fatalf("invalid value for timeout: %q - %v", config.Timeout, err)
+	retentionInterval := defaultRetentionInterval
+	if config.Retention.Interval != "" {
+		retentionInterval, err = time.ParseDuration(config.Retention.Interval)
+		if err != nil {
+			fatal("invalid retention interval", "interval", config.Retention.Interval, "error", err)
+		}
 	}
-	client.Timeout = timeout
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		retentionJob(ctx, retentionInterval, policies, chanError)
+	}()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	var es *elasticSink
+	if config.Elasticsearch.URL != "" {
+		es, err = newElasticSink(ctx, config.Elasticsearch)
+		if err != nil {
+			fatal("could not setup elasticsearch", "error", err)
+		}
+		es.start(ctx, chanError)
+		defer es.close()
+	}
+
+	// a slow notifier must not stall the fetch loop
+	alerts := newBoundedQueue(config.Queue.Alerts, func(p paste) {
+		metricQueueDropped.inc("output")
+		logger(componentNotifier).Warn("alert queue full, dropping alert", "paste_key", p.Key, "keywords", getKeysFromMap(p.Matches))
+	})
+	var refill func(func(paste) bool)
+	if config.Queue.Spool != "" {
+		spool, err := newAlertSpool(config.Queue.Spool)
+		if err != nil {
+			fatal("could not setup alert spool", "error", err)
+		}
+		alerts.overflow = func(p paste) {
+			if err := spool.write(p); err != nil {
+				metricQueueDropped.inc("output")
+				logger(componentNotifier).Error("could not spool alert, dropping it", "paste_key", p.Key, "error", err)
+				return
+			}
+			metricQueueSpooled.inc("")
+		}
+		refill = func(put func(paste) bool) {
+			if err := spool.refill(put); err != nil {
+				logger(componentNotifier).Error("could not refill alerts from spool", "error", err)
+			}
+		}
+	}
+	go alerts.forward(chanOutput, refill)
+	errorQueue := newBoundedQueue(config.Queue.Errors, func(err error) {
+		metricQueueDropped.inc("error")
+		slog.Error("error queue full, not notifying", "error", err)
+	})
+	go errorQueue.forward(chanError, nil)
+	metricQueueDepth.set("output", func() float64 { return float64(len(alerts.out)) })
+	metricQueueDepth.set("error", func() float64 { return float64(len(errorQueue.out)) })
+	metricAPIDelay.set("", func() float64 { return pace.delay().Seconds() })
+	if config.Memorybudget > 0 {
+		contentBudget = newMemoryBudget(config.Memorybudget)
+		metricBudgetUsed.set("", func() float64 { return float64(contentBudget.inUse()) })
+	}
+	metricPaused.set("", func() float64 {
+		if paused, _ := scraping.status(); paused {
+			return 1
+		}
+		return 0
+	})
+	if es != nil {
+		metricQueueDepth.set("elasticsearch", func() float64 { return float64(len(es.queue)) })
+	}
+	if config.Metrics != "" {
+		go serveMetrics(ctx, config.Metrics, config.Admin, chanError)
+	}
+	if config.Statsd.Address != "" {
+		go pushStatsd(ctx, config.Statsd, chanError)
+	}
+	if config.Dashboard != "" {
+		if !config.Admin.authenticated() {
+			fatal("the dashboard needs admin.token or admin.clientca")
+		}
+		go serveDashboard(ctx, config.Dashboard, config.Admin, chanError)
+	}
+	if config.API.Listen != "" {
+		if config.API.Token == "" {
+			fatal("the api needs a token")
+		}
+		if config.API.Feedmaxage != "" {
+			d, err := time.ParseDuration(config.API.Feedmaxage)
+			if err != nil {
+				fatal("invalid value for api.feedmaxage", "feedmaxage", config.API.Feedmaxage, "error", err)
+			}
+			iocFeed.setMaxAge(d)
+		}
+		go serveAPI(ctx, config.API, config.Admin, chanError)
+	}
+	var broker *matchBroker
+	if config.GRPC.Listen != "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			fatal("could not get hostname", "error", err)
+		}
+		broker = newMatchBroker(hostname)
+		go serveGRPC(ctx, config.GRPC, broker, chanError)
+	}
+	var events *eventStream
+	if config.Stream.Listen != "" {
+		l, err := listenStream(config.Stream.Listen)
+		if err != nil {
+			fatal("could not listen for stream clients", "listen", config.Stream.Listen, "error", err)
+		}
+		events = newEventStream()
+		go events.serve(ctx, l, chanError)
+	}
+	if *pprofAddr != "" {
+		if err := checkLoopback(*pprofAddr); err != nil {
+			fatal("invalid pprof address", "error", err)
+		}
+		go servePprof(ctx, *pprofAddr, chanError)
+	}
+
+	var jsonl *jsonlSink
+	if config.Jsonlfile != "" {
+		jsonl, err = newJSONLSink(config.Jsonlfile)
+		if err != nil {
+			fatal("could not setup jsonl output", "error", err)
+		}
+		defer jsonl.close() // nolint: errcheck
+	}
+
+	var siemOut *siemSink
+	if config.SIEM.Format != "" {
+		siemOut, err = newSIEMSink(config.SIEM)
+		if err != nil {
+			fatal("could not setup siem output", "error", err)
+		}
+		defer siemOut.close() // nolint: errcheck
+	}
+
+	var stdout *pasteLineSink
+	if *output == "json" {
+		stdout = newPasteLineSink(os.Stdout)
+	}
+
+	var stixOut *stixSink
+	if config.STIX.Directory != "" || config.STIX.TAXII.URL != "" {
+		stixOut, err = newSTIXSink(config.STIX)
+		if err != nil {
+			fatal("could not setup stix output", "error", err)
+		}
+	}
 
-	go func(c configuration) {
-		for p := range chanOutput {
-			debugOutput("found paste:\n%+v", p)
-			err = p.sendPasteMessage(c)
+	shutdownTimeout, err := time.ParseDuration(config.Shutdowntimeout)
+	if err != nil {
+		fatal("invalid value for shutdowntimeout", "shutdowntimeout", config.Shutdowntimeout, "error", err)
+	}
+	// the notifications outlive the main context so the queued pastes are
+	// still sent on shutdown
+	notifyCtx, cancelNotify := context.WithCancel(context.Background())
+	defer cancelNotify()
+	notified := make(chan struct{})
+	hooks := newHookRunner(config.Exec.Concurrency)
+	defer drainOutput(chanOutput, alerts.out, notified, cancelNotify, shutdownTimeout)
+	go func() {
+		defer close(notified)
+		defer hooks.wait()
+		for p := range alerts.out {
+			// use the current notification settings after a reload
+			c, m := live.get()
+			_, span := tracer.Start(trace.ContextWithSpanContext(notifyCtx, p.spanContext), "notify",
+				trace.WithAttributes(attribute.String("paste.key", p.Key), attribute.StringSlice("paste.keywords", getKeysFromMap(p.Matches))))
+			slog.Info("found paste", "paste_key", p.Key, "keywords", getKeysFromMap(p.Matches), "groups", p.Groups)
+			stats.addGroups(p.Groups)
+			activity.addMatch(p, time.Now())
+			iocFeed.add(p, time.Now())
+			period.addMatch(p)
+			reports.addMatch(p, m.score(p), time.Now())
+			if broker != nil {
+				broker.publish(p, time.Now())
+			}
+			if events != nil {
+				if err := events.publish(p, time.Now()); err != nil {
+					chanError <- fmt.Errorf("stream: %v", err)
+				}
+			}
+			for k := range p.Matches {
+				metricMatches.inc(k)
+			}
+			if !config.Archive.All {
+				// archived first so the location is part of all outputs
+				var err error
+				if p.Archived, err = archivePaste(notifyCtx, archivers, p); err != nil {
+					chanError <- fmt.Errorf("archivePaste: %v", err)
+				}
+			}
+			// identifies the rows of this alert in the database
+			found := time.Now()
+			if db != nil {
+				if err := db.SaveMatches(notifyCtx, p.alert(), found); err != nil {
+					chanError <- fmt.Errorf("saveMatches: %v", err)
+				}
+			}
+			if stdout != nil {
+				if err := stdout.write(p, time.Now()); err != nil {
+					chanError <- fmt.Errorf("stdout: %v", err)
+				}
+			}
+			if jsonl != nil {
+				if err := jsonl.write(p); err != nil {
+					chanError <- fmt.Errorf("jsonl: %v", err)
+				}
+			}
+			if siemOut != nil {
+				if err := siemOut.write(p); err != nil {
+					chanError <- fmt.Errorf("siem: %v", err)
+				}
+			}
+			if stixOut != nil {
+				if err := stixOut.write(notifyCtx, p); err != nil {
+					chanError <- fmt.Errorf("stix: %v", err)
+				}
+			}
+			if es != nil {
+				if err := es.indexPaste(notifyCtx, p); err != nil {
+					chanError <- fmt.Errorf("indexPaste: %v", err)
+				}
+			}
+			for _, pl := range plugins {
+				if pl.is(pluginNotifier) {
+					if err := pl.notify(p, m.score(p), time.Now()); err != nil {
+						chanError <- fmt.Errorf("notify: %v", err)
+					}
+				}
+			}
+			if len(c.Exec.Hooks) > 0 {
+				hooks.run(notifyCtx, c.Exec.Hooks, p, m.score(p), time.Now())
+			}
+			send, hold := p.recipients(c), []string(nil)
+			// alerts are not held back any more once shutting down
+			if ctx.Err() == nil {
+				send, hold = splitQuiet(send, m.score(p), time.Now())
+			}
+			for _, to := range hold {
+				metricSuppressed.inc("")
+				digests.add(to, p)
+			}
+			var err error
+			if len(send) > 0 {
+				err = p.sendPasteMessageTo(notifyCtx, c, send)
+			}
+			endSpan(span, err)
 			if err != nil {
+				metricNotifications.inc("failure")
 				chanError <- fmt.Errorf("sendPasteMessage: %v", err)
+			} else {
+				metricNotifications.inc("success")
+			}
+			if err := st.setNotified(p.Key, err); err != nil {
+				chanError <- fmt.Errorf("setNotified: %v", err)
+			}
+			if db != nil {
+				if err := db.SetNotified(notifyCtx, p.Key, found, err); err != nil {
+					chanError <- fmt.Errorf("setNotified: %v", err)
+				}
 			}
 		}
-	}(*config)
+	}()
 
-	go func(c configuration) {
-		for err := range chanError {
-			log.Printf("%v", err)
+	go func() {
+		for err := range errorQueue.out {
+			slog.Error("error", "error", err)
+			activity.addError(time.Now())
+			period.addError()
+			c, _ := live.get()
 			if c.Mailonerror {
-				err2 := sendErrorMessage(c, err)
+				err2 := sendErrorMessage(notifyCtx, c, err)
 				if err2 != nil {
-					log.Printf("ERROR on sending error mail: %v", err2)
+					slog.Error("could not send error mail", "error", err2)
 				}
 			}
 		}
-	}(*config)
+	}()
 
-	for {
-		// Only fetch the main list once a minute
-		sleepTime := time.Until(lastCheck.Add(1 * time.Minute))
-		if sleepTime > 0 {
-			debugOutput("sleeping for %s", sleepTime)
-			time.Sleep(sleepTime)
+	_, startMatcher := live.get()
+	if err := checkAddedKeywords(ctx, &background, *config, startMatcher, st, chanOutput, chanError); err != nil {
+		fatal("could not check for added keywords", "error", err)
+	}
+
+	// request sends a request to the api with retries unless the circuit
+	// breaker paused fetching
+	request := func(what string, fn func() error) error {
+		if !breaker.wait(ctx) {
+			return ctx.Err()
+		}
+		err := retries.do(ctx, what, fn)
+		switch {
+		case ctx.Err() != nil:
+		case err == nil || (!isTransient(err) && what != "list"):
+			// a permanent error of a single paste like a removed paste
+			// still means the source is up
+			if breaker.success() {
+				slog.Info("source is up again, resuming fetching")
+			}
+		case breaker.failure():
+			chanError <- fmt.Errorf("source down after %d failed requests, pausing fetching until a probe succeeds: %v", breaker.threshold, err)
 		}
+		return err
+	}
 
-		lastCheck = time.Now()
-		pastes, err := fetchPasteList(ctx)
+	// failed handles a request which still failed after all retries
+	failed := func(what string, err error) {
+		if breaker.isOpen() {
+			// the source down error was already sent
+			logger(componentFetcher).Debug("request failed while the source is down", "request", what, "error", err)
+			return
+		}
+		if isTransient(err) && retries.giveUp != giveUpError {
+			logger(componentFetcher).Warn("giving up on request", "request", what, "error", err)
+			return
+		}
+		chanError <- fmt.Errorf("%s: %v", what, err)
+	}
+
+	// scanFetched scans a fetched paste and queues its alerts. It returns
+	// true if the paste matched.
+	scanFetched := func(span trace.Span, c configuration, m *matcher, p2 *paste) bool {
+		seen, err := st.seenContent(p2.Hash, time.Now())
 		if err != nil {
-			chanError <- fmt.Errorf("fetchPasteList: %v", err)
-			continue
+			chanError <- fmt.Errorf("seenContent: %v", err)
+		}
+		if seen {
+			// reposts and mirrors are already handled
+			logger(componentFetcher).Debug("skipping paste with already seen content", "paste_key", p2.Key, "hash", p2.Hash)
+			stats.addDuplicate()
+			return false
+		}
+		if !sizeAllowed(c, p2.length()) {
+			logger(componentFetcher).Debug("skipping paste by size", "paste_key", p2.Key, "size", p2.length())
+			metricPastesSkipped.inc("size")
+			return false
+		}
+		p2.spanContext = span.SpanContext()
+		p2.scan(m)
+		tenantMatches := p2.scanTenants(m)
+		matched := len(p2.Matches) > 0 || len(tenantMatches) > 0
+		period.addScanned()
+		reports.addScanned(time.Now())
+		// archives and alerts need the whole content
+		if config.Archive.All || matched {
+			if err := p2.loadContent(); err != nil {
+				chanError <- fmt.Errorf("loadContent: %v", err)
+			}
+		}
+		if config.Archive.All {
+			var err error
+			if p2.Archived, err = archivePaste(ctx, archivers, *p2); err != nil {
+				chanError <- fmt.Errorf("archivePaste: %v", err)
+			}
 		}
+		if matched && c.Extractiocs {
+			p2.IOCs = extractIOCs(p2.Content)
+			logger(componentMatcher).Debug("extracted indicators", "paste_key", p2.Key, "iocs", p2.IOCs.count())
+			enrichPaste(ctx, c.Enrichment, p2)
+		}
+		for _, x := range p2.withTenants(tenantMatches, m) {
+			if script != nil && !script.apply(&x, m.score(x)) {
+				continue
+			}
+			if duplicates != nil && !duplicates.filter(config.Nearduplicates.Mode, &x, time.Now()) {
+				continue
+			}
+			chanOutput <- x
+		}
+		return matched
+	}
 
-		for _, p := range pastes {
-			if _, ok := alredyChecked[p.Key]; ok {
-				debugOutput("skipping key %s as it was already checked", p.Key)
-			} else {
-				alredyChecked[p.Key] = time.Now()
-				p2, err := p.fetch(ctx, keywords, cidrs)
+	// followLinks fetches and scans the pastes linked from a matched paste,
+	// links of the linked pastes are not followed
+	followLinks := func(pctx context.Context, span trace.Span, c configuration, m *matcher, parent *paste) {
+		for _, u := range followableLinks(c.Follow, parent.Key, parent.Content) {
+			log := logger(componentFetcher).With("paste_key", parent.Key, "url", u)
+			l, ok, err := c.Follow.resolve(pctx, u)
+			if err != nil {
+				metricFollowedLinks.inc("error")
+				log.Warn("could not follow link", "error", err)
+				continue
+			}
+			if !ok || (l.key != "" && l.key == parent.Key) {
+				continue
+			}
+			linked := l.paste(*parent)
+			var p2 *paste
+			if l.key != "" {
+				checked, err := st.checked(l.key)
 				if err != nil {
-					chanError <- fmt.Errorf("fetch: %v", err)
-				} else if p2 != nil {
-					chanOutput <- *p2
+					chanError <- fmt.Errorf("checked: %v", err)
+					continue
+				}
+				if checked {
+					log.Debug("skipping already checked linked paste")
+					continue
+				}
+				if err := st.setChecked(l.key, time.Now()); err != nil {
+					chanError <- fmt.Errorf("setChecked: %v", err)
 				}
-				// do not hammer the API
-				time.Sleep(1 * time.Second)
+				err = request(l.key, func() error {
+					var err error
+					p2, err = linked.fetch(pctx)
+					return err
+				})
+			} else {
+				p2, err = linked.fetchRaw(pctx)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				metricFollowedLinks.inc("error")
+				log.Warn("could not fetch linked paste", "error", err)
+				continue
 			}
+			if p2 == nil {
+				continue
+			}
+			metricFollowedLinks.inc("fetched")
+			log.Debug("scanning linked paste", "linked_key", p2.Key)
+			scanFetched(span, c, m, p2)
+			p2.releaseContent()
+		}
+	}
+
+	// process fetches and scans a single paste. It returns false if the
+	// scraper is shutting down.
+	process := func(p paste) bool {
+		pctx, span := tracer.Start(ctx, "process paste", trace.WithAttributes(attribute.String("paste.key", p.Key)))
+		defer span.End()
+		// the paste stays pending if it was interrupted by a shutdown
+		done := true
+		defer func() {
+			if !done {
+				return
+			}
+			if err := st.removePending(p.Key); err != nil {
+				chanError <- fmt.Errorf("removePending: %v", err)
+			}
+		}()
+		c, m := live.get()
+		if size, err := strconv.ParseInt(p.Size, 10, 64); err == nil && !sizeAllowed(c, size) {
+			logger(componentFetcher).Debug("skipping paste by size from the list", "paste_key", p.Key, "size", size)
+			metricPastesSkipped.inc("size")
+			return true
+		}
+		var p2 *paste
+		err := request(p.Key, func() error {
+			var err error
+			p2, err = p.fetch(pctx)
+			return err
+		})
+		if ctx.Err() != nil {
+			// check the paste again on the next start
+			done = false
+			if err := st.unsetChecked(p.Key); err != nil {
+				slog.Error("could not reset state", "paste_key", p.Key, "error", err)
+			}
+			return false
+		}
+		if err != nil {
+			failed("fetch", err)
+			if isTransient(err) && retries.giveUp == giveUpRequeue {
+				// try again with the next paste list
+				if err := st.unsetChecked(p.Key); err != nil {
+					chanError <- fmt.Errorf("unsetChecked: %v", err)
+				}
+			}
+		} else if p2 != nil {
+			defer p2.releaseContent()
+			if scanFetched(span, c, m, p2) && c.Follow.Enabled {
+				followLinks(pctx, span, c, m, p2)
+			}
+		}
+		return true
+	}
+
+	// pastes of source plugins are matched like pastes from Pastebin
+	scanSourcePaste := func(p *paste) {
+		c, m := live.get()
+		p.scan(m)
+		tenantMatches := p.scanTenants(m)
+		period.addScanned()
+		reports.addScanned(time.Now())
+		if len(p.Matches) == 0 && len(tenantMatches) == 0 {
+			return
+		}
+		if c.Extractiocs {
+			p.IOCs = extractIOCs(p.Content)
+			enrichPaste(ctx, c.Enrichment, p)
+		}
+		for _, x := range p.withTenants(tenantMatches, m) {
+			if script != nil && !script.apply(&x, m.score(x)) {
+				continue
+			}
+			if duplicates != nil && !duplicates.filter(config.Nearduplicates.Mode, &x, time.Now()) {
+				continue
+			}
+			chanOutput <- x
+		}
+	}
+	var sources sync.WaitGroup
+	// the sources and the retro-scans must be done before the output is
+	// closed
+	defer func() {
+		cancel()
+		sources.Wait()
+		background.Wait()
+	}()
+	for _, pl := range plugins {
+		if !pl.is(pluginSource) {
+			continue
+		}
+		interval := defaultPluginInterval
+		if config.Plugins.Interval != "" {
+			if interval, err = time.ParseDuration(config.Plugins.Interval); err != nil {
+				fatal("invalid value for plugins.interval", "interval", config.Plugins.Interval, "error", err)
+			}
+		}
+		sources.Add(1)
+		go func(pl *plugin) {
+			defer sources.Done()
+			runPluginSource(ctx, pl, interval, scanSourcePaste, chanError)
+		}(pl)
+	}
+
+	// fetchList returns the pastes of the list which were not checked yet
+	fetchList := func() []paste {
+		lastCheck = time.Now()
+		var pastes []paste
+		err := request("list", func() error {
+			var err error
+			pastes, err = fetchPasteList(ctx)
+			return err
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				failed("fetchPasteList", err)
+			}
+			return nil
+		}
+		activity.checked(lastCheck, pastes)
+		period.checked(lastCheck, pastes)
+		if err := st.setLastCheck(lastCheck); err != nil {
+			chanError <- fmt.Errorf("setLastCheck: %v", err)
+		}
+		var ret []paste
+		for _, p := range pastes {
+			alreadyChecked, err := st.checked(p.Key)
+			if err != nil {
+				chanError <- fmt.Errorf("checked: %v", err)
+				continue
+			}
+			if alreadyChecked {
+				logger(componentFetcher).Debug("skipping already checked paste", "paste_key", p.Key)
+				continue
+			}
+			if err := st.setChecked(p.Key, time.Now()); err != nil {
+				chanError <- fmt.Errorf("setChecked: %v", err)
+			}
+			ret = append(ret, p)
 		}
-		// clean up old items in alreadyChecked map
+		return ret
+	}
+
+	// queuePending remembers the pastes until they are scanned
+	queuePending := func(pastes []paste) {
+		if err := st.addPending(pastes); err != nil {
+			chanError <- fmt.Errorf("addPending: %v", err)
+		}
+	}
+
+	cleanup := func() {
+		// clean up old items in the state
 		// delete everything older than 10 minutes
-		threshold := time.Now().Add(-10 * time.Minute)
-		for k, v := range alredyChecked {
-			if v.Before(threshold) {
-				debugOutput("deleting expired entry %s", k)
-				delete(alredyChecked, k)
+		n, err := st.expireChecked(time.Now().Add(-10 * time.Minute))
+		if err != nil {
+			chanError <- fmt.Errorf("expireChecked: %v", err)
+		}
+		slog.Debug("deleted expired entries", "count", n)
+		n, err = st.expireContent(time.Now().Add(-dedupWindow))
+		if err != nil {
+			chanError <- fmt.Errorf("expireContent: %v", err)
+		}
+		slog.Debug("deleted expired content hashes", "count", n)
+		slog.Debug("statistics", "stats", stats.String())
+		if err := st.flush(); err != nil {
+			chanError <- fmt.Errorf("could not save state: %v", err)
+		}
+	}
+
+	// resume the pastes which were not scanned before the last shutdown
+	resumed, err := st.pending()
+	if err != nil {
+		chanError <- fmt.Errorf("pending: %v", err)
+	}
+	if len(resumed) > 0 {
+		slog.Info("resuming pending pastes", "count", len(resumed))
+		for _, p := range resumed {
+			// do not check them again if they are still in the list
+			if err := st.setChecked(p.Key, time.Now()); err != nil {
+				chanError <- fmt.Errorf("setChecked: %v", err)
+			}
+		}
+		fetchPool(config.Workers, resumed, func() bool { return ctx.Err() != nil }, func(p paste) {
+			process(p)
+		}, func(p paste) {})
+	}
+
+	if queue != nil {
+		metricQueueDepth.set("redis", func() float64 {
+			n, _ := queue.length(context.Background())
+			return float64(n)
+		})
+		var wg sync.WaitGroup
+		for i := 0; i < config.Workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for ctx.Err() == nil {
+					p, err := queue.pop(ctx)
+					if err != nil && ctx.Err() == nil {
+						chanError <- fmt.Errorf("pop: %v", err)
+						sleep(ctx, 10*time.Second)
+					}
+					if p == nil {
+						continue
+					}
+					queuePending([]paste{*p})
+					if !process(*p) {
+						// hand the paste to another instance
+						if err := queue.push(context.Background(), []paste{*p}); err != nil {
+							slog.Error("could not requeue paste", "paste_key", p.Key, "error", err)
+							continue
+						}
+						if err := st.removePending(p.Key); err != nil {
+							slog.Error("could not remove pending paste", "paste_key", p.Key, "error", err)
+						}
+					}
+				}
+			}()
+		}
+		lastCleanup := time.Now()
+		for ctx.Err() == nil {
+			// only one instance fetches the list per interval and queues
+			// the new pastes for all instances
+			locked, err := queue.lockList(ctx, jitter(interval, config.Jitter))
+			if err != nil && ctx.Err() == nil {
+				chanError <- fmt.Errorf("lockList: %v", err)
+				sleep(ctx, 10*time.Second)
+				continue
+			}
+			if locked {
+				if err := queue.push(ctx, fetchList()); err != nil {
+					chanError <- fmt.Errorf("push: %v", err)
+				}
+			}
+			if time.Since(lastCleanup) >= 1*time.Minute {
+				lastCleanup = time.Now()
+				cleanup()
+			}
+			sleep(ctx, redisPopTimeout)
+		}
+		wg.Wait()
+		return nil
+	}
+
+	schedule := newScheduler(lastCheck.Add(interval), interval, config.Jitter, time.Now())
+	for ctx.Err() == nil {
+		if election != nil && !election.isLeader() {
+			// standby until the leader fails
+			sleep(ctx, 1*time.Second)
+			continue
+		}
+		// Only fetch the main list once per interval
+		sleepTime := schedule.until(time.Now())
+		if sleepTime > 0 {
+			logger(componentFetcher).Debug("sleeping", "duration", sleepTime)
+			if !sleep(ctx, sleepTime) {
+				break
 			}
+			continue
 		}
+
+		schedule.advance(time.Now())
+		pastes := fetchList()
+		queuePending(pastes)
+		fetchPool(config.Workers, pastes, func() bool {
+			return ctx.Err() != nil || (election != nil && !election.isLeader())
+		}, func(p paste) {
+			process(p)
+		}, func(p paste) {
+			// check the paste again on the next start or leave it to the
+			// new leader
+			if err := st.unsetChecked(p.Key); err != nil {
+				chanError <- fmt.Errorf("unsetChecked: %v", err)
+			}
+		})
+		cleanup()
 	}
+	return nil
 }
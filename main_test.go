@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/FireFart/pastebin_scraper/scraper"
+)
+
+func intPointer(i int) *int {
+	return &i
+}
+
+// mustMatcher compiles the rules into a matcher without tenants
+func mustMatcher(t *testing.T, r scraper.Rules) *matcher {
+	t.Helper()
+	m, err := scraper.Compile(r)
+	if err != nil {
+		t.Fatalf("could not compile rules: %v", err)
+	}
+	return &matcher{Matcher: m}
+}
@@ -0,0 +1,338 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v5.29.3
+// source: matchpb/matches.proto
+
+package matchpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SubscribeMatchesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// only send matches of these keywords, all matches if empty
+	Keywords []string `protobuf:"bytes,1,rep,name=keywords,proto3" json:"keywords,omitempty"`
+	// only send matches of these groups, all matches if empty
+	Groups        []string `protobuf:"bytes,2,rep,name=groups,proto3" json:"groups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeMatchesRequest) Reset() {
+	*x = SubscribeMatchesRequest{}
+	mi := &file_matchpb_matches_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeMatchesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeMatchesRequest) ProtoMessage() {}
+
+func (x *SubscribeMatchesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_matchpb_matches_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeMatchesRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeMatchesRequest) Descriptor() ([]byte, []int) {
+	return file_matchpb_matches_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubscribeMatchesRequest) GetKeywords() []string {
+	if x != nil {
+		return x.Keywords
+	}
+	return nil
+}
+
+func (x *SubscribeMatchesRequest) GetGroups() []string {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+type Match struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Key    string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Url    string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Title  string                 `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	User   string                 `protobuf:"bytes,4,opt,name=user,proto3" json:"user,omitempty"`
+	Syntax string                 `protobuf:"bytes,5,opt,name=syntax,proto3" json:"syntax,omitempty"`
+	Date   *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=date,proto3" json:"date,omitempty"`
+	// SHA-256 of the paste content
+	Hash          string                 `protobuf:"bytes,7,opt,name=hash,proto3" json:"hash,omitempty"`
+	Groups        []string               `protobuf:"bytes,8,rep,name=groups,proto3" json:"groups,omitempty"`
+	Matches       []*KeywordMatch        `protobuf:"bytes,9,rep,name=matches,proto3" json:"matches,omitempty"`
+	FoundAt       *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=found_at,json=foundAt,proto3" json:"found_at,omitempty"`
+	Hostname      string                 `protobuf:"bytes,11,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Match) Reset() {
+	*x = Match{}
+	mi := &file_matchpb_matches_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Match) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Match) ProtoMessage() {}
+
+func (x *Match) ProtoReflect() protoreflect.Message {
+	mi := &file_matchpb_matches_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Match.ProtoReflect.Descriptor instead.
+func (*Match) Descriptor() ([]byte, []int) {
+	return file_matchpb_matches_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Match) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Match) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Match) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Match) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *Match) GetSyntax() string {
+	if x != nil {
+		return x.Syntax
+	}
+	return ""
+}
+
+func (x *Match) GetDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Date
+	}
+	return nil
+}
+
+func (x *Match) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *Match) GetGroups() []string {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+func (x *Match) GetMatches() []*KeywordMatch {
+	if x != nil {
+		return x.Matches
+	}
+	return nil
+}
+
+func (x *Match) GetFoundAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FoundAt
+	}
+	return nil
+}
+
+func (x *Match) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+type KeywordMatch struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Keyword string                 `protobuf:"bytes,1,opt,name=keyword,proto3" json:"keyword,omitempty"`
+	// the matched lines
+	Lines         []string `protobuf:"bytes,2,rep,name=lines,proto3" json:"lines,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KeywordMatch) Reset() {
+	*x = KeywordMatch{}
+	mi := &file_matchpb_matches_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeywordMatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeywordMatch) ProtoMessage() {}
+
+func (x *KeywordMatch) ProtoReflect() protoreflect.Message {
+	mi := &file_matchpb_matches_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeywordMatch.ProtoReflect.Descriptor instead.
+func (*KeywordMatch) Descriptor() ([]byte, []int) {
+	return file_matchpb_matches_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *KeywordMatch) GetKeyword() string {
+	if x != nil {
+		return x.Keyword
+	}
+	return ""
+}
+
+func (x *KeywordMatch) GetLines() []string {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+var File_matchpb_matches_proto protoreflect.FileDescriptor
+
+const file_matchpb_matches_proto_rawDesc = "" +
+	"\n" +
+	"\x15matchpb/matches.proto\x12\x12pastebinscraper.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"M\n" +
+	"\x17SubscribeMatchesRequest\x12\x1a\n" +
+	"\bkeywords\x18\x01 \x03(\tR\bkeywords\x12\x16\n" +
+	"\x06groups\x18\x02 \x03(\tR\x06groups\"\xd8\x02\n" +
+	"\x05Match\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x14\n" +
+	"\x05title\x18\x03 \x01(\tR\x05title\x12\x12\n" +
+	"\x04user\x18\x04 \x01(\tR\x04user\x12\x16\n" +
+	"\x06syntax\x18\x05 \x01(\tR\x06syntax\x12.\n" +
+	"\x04date\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\x04date\x12\x12\n" +
+	"\x04hash\x18\a \x01(\tR\x04hash\x12\x16\n" +
+	"\x06groups\x18\b \x03(\tR\x06groups\x12:\n" +
+	"\amatches\x18\t \x03(\v2 .pastebinscraper.v1.KeywordMatchR\amatches\x125\n" +
+	"\bfound_at\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\afoundAt\x12\x1a\n" +
+	"\bhostname\x18\v \x01(\tR\bhostname\">\n" +
+	"\fKeywordMatch\x12\x18\n" +
+	"\akeyword\x18\x01 \x01(\tR\akeyword\x12\x14\n" +
+	"\x05lines\x18\x02 \x03(\tR\x05lines2g\n" +
+	"\aMatches\x12\\\n" +
+	"\x10SubscribeMatches\x12+.pastebinscraper.v1.SubscribeMatchesRequest\x1a\x19.pastebinscraper.v1.Match0\x01B.Z,github.com/FireFart/pastebin_scraper/matchpbb\x06proto3"
+
+var (
+	file_matchpb_matches_proto_rawDescOnce sync.Once
+	file_matchpb_matches_proto_rawDescData []byte
+)
+
+func file_matchpb_matches_proto_rawDescGZIP() []byte {
+	file_matchpb_matches_proto_rawDescOnce.Do(func() {
+		file_matchpb_matches_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_matchpb_matches_proto_rawDesc), len(file_matchpb_matches_proto_rawDesc)))
+	})
+	return file_matchpb_matches_proto_rawDescData
+}
+
+var file_matchpb_matches_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_matchpb_matches_proto_goTypes = []any{
+	(*SubscribeMatchesRequest)(nil), // 0: pastebinscraper.v1.SubscribeMatchesRequest
+	(*Match)(nil),                   // 1: pastebinscraper.v1.Match
+	(*KeywordMatch)(nil),            // 2: pastebinscraper.v1.KeywordMatch
+	(*timestamppb.Timestamp)(nil),   // 3: google.protobuf.Timestamp
+}
+var file_matchpb_matches_proto_depIdxs = []int32{
+	3, // 0: pastebinscraper.v1.Match.date:type_name -> google.protobuf.Timestamp
+	2, // 1: pastebinscraper.v1.Match.matches:type_name -> pastebinscraper.v1.KeywordMatch
+	3, // 2: pastebinscraper.v1.Match.found_at:type_name -> google.protobuf.Timestamp
+	0, // 3: pastebinscraper.v1.Matches.SubscribeMatches:input_type -> pastebinscraper.v1.SubscribeMatchesRequest
+	1, // 4: pastebinscraper.v1.Matches.SubscribeMatches:output_type -> pastebinscraper.v1.Match
+	4, // [4:5] is the sub-list for method output_type
+	3, // [3:4] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_matchpb_matches_proto_init() }
+func file_matchpb_matches_proto_init() {
+	if File_matchpb_matches_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_matchpb_matches_proto_rawDesc), len(file_matchpb_matches_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_matchpb_matches_proto_goTypes,
+		DependencyIndexes: file_matchpb_matches_proto_depIdxs,
+		MessageInfos:      file_matchpb_matches_proto_msgTypes,
+	}.Build()
+	File_matchpb_matches_proto = out.File
+	file_matchpb_matches_proto_goTypes = nil
+	file_matchpb_matches_proto_depIdxs = nil
+}
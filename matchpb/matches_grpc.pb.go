@@ -0,0 +1,132 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.0
+// - protoc             v5.29.3
+// source: matchpb/matches.proto
+
+package matchpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Matches_SubscribeMatches_FullMethodName = "/pastebinscraper.v1.Matches/SubscribeMatches"
+)
+
+// MatchesClient is the client API for Matches service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Matches streams the pastes matching the rules of the scraper
+type MatchesClient interface {
+	// SubscribeMatches sends every match found after the subscription until
+	// the client disconnects
+	SubscribeMatches(ctx context.Context, in *SubscribeMatchesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Match], error)
+}
+
+type matchesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMatchesClient(cc grpc.ClientConnInterface) MatchesClient {
+	return &matchesClient{cc}
+}
+
+func (c *matchesClient) SubscribeMatches(ctx context.Context, in *SubscribeMatchesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Match], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Matches_ServiceDesc.Streams[0], Matches_SubscribeMatches_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeMatchesRequest, Match]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Matches_SubscribeMatchesClient = grpc.ServerStreamingClient[Match]
+
+// MatchesServer is the server API for Matches service.
+// All implementations must embed UnimplementedMatchesServer
+// for forward compatibility.
+//
+// Matches streams the pastes matching the rules of the scraper
+type MatchesServer interface {
+	// SubscribeMatches sends every match found after the subscription until
+	// the client disconnects
+	SubscribeMatches(*SubscribeMatchesRequest, grpc.ServerStreamingServer[Match]) error
+	mustEmbedUnimplementedMatchesServer()
+}
+
+// UnimplementedMatchesServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMatchesServer struct{}
+
+func (UnimplementedMatchesServer) SubscribeMatches(*SubscribeMatchesRequest, grpc.ServerStreamingServer[Match]) error {
+	return status.Error(codes.Unimplemented, "method SubscribeMatches not implemented")
+}
+func (UnimplementedMatchesServer) mustEmbedUnimplementedMatchesServer() {}
+func (UnimplementedMatchesServer) testEmbeddedByValue()                 {}
+
+// UnsafeMatchesServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MatchesServer will
+// result in compilation errors.
+type UnsafeMatchesServer interface {
+	mustEmbedUnimplementedMatchesServer()
+}
+
+func RegisterMatchesServer(s grpc.ServiceRegistrar, srv MatchesServer) {
+	// If the following call panics, it indicates UnimplementedMatchesServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Matches_ServiceDesc, srv)
+}
+
+func _Matches_SubscribeMatches_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeMatchesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MatchesServer).SubscribeMatches(m, &grpc.GenericServerStream[SubscribeMatchesRequest, Match]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Matches_SubscribeMatchesServer = grpc.ServerStreamingServer[Match]
+
+// Matches_ServiceDesc is the grpc.ServiceDesc for Matches service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Matches_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pastebinscraper.v1.Matches",
+	HandlerType: (*MatchesServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeMatches",
+			Handler:       _Matches_SubscribeMatches_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "matchpb/matches.proto",
+}
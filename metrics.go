@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const metricsNamespace = "pastebin_scraper"
+
+var (
+	metrics = newMetricsRegistry()
+
+	metricPastesListed  = metrics.counter("pastes_listed_total", "Number of pastes returned by the scraping api.", "")
+	metricPastesFetched = metrics.counter("pastes_fetched_total", "Number of downloaded pastes.", "")
+	metricPastesSkipped = metrics.counter("pastes_skipped_total", "Number of pastes skipped without scanning by reason.", "reason")
+	metricFetchErrors   = metrics.counter("fetch_errors_total", "Number of failed api requests by type.", "type")
+	metricMatches       = metrics.counter("matches_total", "Number of matched pastes per keyword.", "keyword")
+	metricNotifications = metrics.counter("notifications_total", "Number of sent notifications by result.", "result")
+	metricFetchDuration = metrics.histogram("fetch_duration_seconds", "Latency of paste downloads.",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10})
+	metricQueueDepth = metrics.gauge("queue_depth", "Number of items waiting in the internal queues.", "queue")
+)
+
+type collector interface {
+	write(w io.Writer) error
+}
+
+// metricsRegistry holds all metrics exposed in the prometheus text format
+type metricsRegistry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{}
+}
+
+func (r *metricsRegistry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+func (r *metricsRegistry) counter(name, help, label string) *counterVec {
+	c := &counterVec{name: metricsNamespace + "_" + name, help: help, label: label, values: make(map[string]float64)}
+	r.register(c)
+	return c
+}
+
+func (r *metricsRegistry) histogram(name, help string, buckets []float64) *histogram {
+	h := &histogram{name: metricsNamespace + "_" + name, help: help, buckets: buckets, counts: make([]uint64, len(buckets))}
+	r.register(h)
+	return h
+}
+
+func (r *metricsRegistry) gauge(name, help, label string) *gaugeFunc {
+	g := &gaugeFunc{name: metricsNamespace + "_" + name, help: help, label: label, funcs: make(map[string]func() float64)}
+	r.register(g)
+	return g
+}
+
+func (r *metricsRegistry) write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.collectors {
+		if err := c.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *metricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := r.write(w); err != nil {
+		slog.Debug("could not write metrics", "error", err)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// series returns the name with the label if both are set
+func series(name, label, value string) string {
+	if label == "" {
+		return name
+	}
+	return fmt.Sprintf(`%s{%s="%s"}`, name, label, labelEscaper.Replace(value))
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// counterVec is a counter with an optional label
+type counterVec struct {
+	name   string
+	help   string
+	label  string
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func (c *counterVec) add(v float64, label string) {
+	c.mu.Lock()
+	c.values[label] += v
+	c.mu.Unlock()
+	if s := statsd.Load(); s != nil {
+		s.count(c.name, c.label, label, v)
+	}
+}
+
+func (c *counterVec) inc(label string) {
+	c.add(1, label)
+}
+
+func (c *counterVec) value(label string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[label]
+}
+
+func (c *counterVec) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name); err != nil {
+		return err
+	}
+	// counters without label are always exposed
+	if c.label == "" && len(c.values) == 0 {
+		_, err := fmt.Fprintf(w, "%s 0\n", c.name)
+		return err
+	}
+	for _, k := range sortedKeys(c.values) {
+		if _, err := fmt.Fprintf(w, "%s %s\n", series(c.name, c.label, k), formatFloat(c.values[k])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type histogram struct {
+	name    string
+	help    string
+	buckets []float64
+	mu      sync.Mutex
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+	if s := statsd.Load(); s != nil {
+		s.timing(h.name, v)
+	}
+}
+
+func (h *histogram) since(start time.Time) {
+	h.observe(time.Since(start).Seconds())
+}
+
+func (h *histogram) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name); err != nil {
+		return err
+	}
+	for i, b := range h.buckets {
+		if _, err := fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatFloat(b), h.counts[i]); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %s\n%s_count %d\n",
+		h.name, h.count, h.name, formatFloat(h.sum), h.name, h.count)
+	return err
+}
+
+// gaugeFunc reads the current values from the registered functions
+type gaugeFunc struct {
+	name  string
+	help  string
+	label string
+	mu    sync.Mutex
+	funcs map[string]func() float64
+}
+
+func (g *gaugeFunc) set(label string, fn func() float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.funcs[label] = fn
+}
+
+func (g *gaugeFunc) write(w io.Writer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(g.funcs))
+	for k := range g.funcs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "%s %s\n", series(g.name, g.label, k), formatFloat(g.funcs[k]())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serveMetrics exposes the metrics on /metrics until the context is
+// canceled. They only need authentication if admin.metricsauth is set.
+func serveMetrics(ctx context.Context, addr string, admin adminConfig, errs chan<- error) {
+	mux := http.NewServeMux()
+	var h http.Handler = metrics
+	if admin.Metricsauth {
+		h = apiAuth(admin.Token, metrics)
+	}
+	mux.Handle("/metrics", h)
+	serveAdmin(ctx, "metrics", addr, mux, admin, errs)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsRegistry(t *testing.T) {
+	r := newMetricsRegistry()
+	c := r.counter("test_total", "A test counter.", "keyword")
+	c.inc("b")
+	c.inc("a")
+	c.add(2, "a")
+	plain := r.counter("plain_total", "A counter without label.", "")
+	h := r.histogram("duration_seconds", "A test histogram.", []float64{0.1, 1})
+	h.observe(0.05)
+	h.observe(0.5)
+	h.observe(5)
+	g := r.gauge("depth", "A test gauge.", "queue")
+	g.set("output", func() float64 { return 3 })
+
+	var buf bytes.Buffer
+	if err := r.write(&buf); err != nil {
+		t.Fatalf("could not write metrics: %v", err)
+	}
+	expected := `# HELP pastebin_scraper_test_total A test counter.
+# TYPE pastebin_scraper_test_total counter
+pastebin_scraper_test_total{keyword="a"} 3
+pastebin_scraper_test_total{keyword="b"} 1
+# HELP pastebin_scraper_plain_total A counter without label.
+# TYPE pastebin_scraper_plain_total counter
+pastebin_scraper_plain_total 0
+# HELP pastebin_scraper_duration_seconds A test histogram.
+# TYPE pastebin_scraper_duration_seconds histogram
+pastebin_scraper_duration_seconds_bucket{le="0.1"} 1
+pastebin_scraper_duration_seconds_bucket{le="1"} 2
+pastebin_scraper_duration_seconds_bucket{le="+Inf"} 3
+pastebin_scraper_duration_seconds_sum 5.55
+pastebin_scraper_duration_seconds_count 3
+# HELP pastebin_scraper_depth A test gauge.
+# TYPE pastebin_scraper_depth gauge
+pastebin_scraper_depth{queue="output"} 3
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected output\n%s\nexpected\n%s", buf.String(), expected)
+	}
+	if plain.value("") != 0 {
+		t.Fatal("expected plain counter to be zero")
+	}
+}
+
+func TestMetricsLabelEscaping(t *testing.T) {
+	if x := series("name", "keyword", "a\"b\\c\n"); x != `name{keyword="a\"b\\c\n"}` {
+		t.Fatalf("unexpected series %q", x)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	metrics.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	b, err := ioutil.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatalf("could not read body: %v", err)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected content type %q", w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(string(b), "# TYPE pastebin_scraper_fetch_duration_seconds histogram") {
+		t.Fatalf("missing default metrics: %s", b)
+	}
+}
@@ -0,0 +1,20 @@
+// Package notify delivers the alert mails of the scraper. Other programs
+// can send their own mails with the same cancelable smtp client:
+//
+//	m := gomail.NewMessage()
+//	m.SetHeader("From", "scraper@example.com")
+//	m.SetHeader("To", "soc@example.com")
+//	m.SetBody("text/plain", "password found")
+//	err := notify.SMTP{Host: "localhost", Port: 25, Timeout: 10 * time.Second}.Send(ctx, m)
+package notify
+
+import (
+	"context"
+
+	gomail "gopkg.in/gomail.v2"
+)
+
+// Notifier delivers a mail. Canceling the context aborts the delivery.
+type Notifier interface {
+	Send(ctx context.Context, m *gomail.Message) error
+}
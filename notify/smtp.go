@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	gomail "gopkg.in/gomail.v2"
+)
+
+// SMTP delivers the mails to a mail server. STARTTLS is used if the server
+// offers it, port 465 uses implicit tls. The certificate is not verified.
+type SMTP struct {
+	Host string
+	Port int
+	// the whole delivery is aborted after it, 0 for no timeout
+	Timeout time.Duration
+}
+
+// Send delivers the mail within the timeout
+func (s SMTP) Send(ctx context.Context, m *gomail.Message) error {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	c, err := dialSMTP(ctx, s.Host, s.Port)
+	if err != nil {
+		return err
+	}
+	err = gomail.Send(c, m)
+	if err2 := c.Close(); err == nil {
+		err = err2
+	}
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("could not send mail: %v", ctx.Err())
+	}
+	return err
+}
+
+// smtpSender sends mails over a connection which is closed as soon as the
+// context is done. gomail.Dialer has no way to cancel a hanging server.
+type smtpSender struct {
+	c    *smtp.Client
+	stop func() bool
+}
+
+func dialSMTP(ctx context.Context, host string, port int) (*smtpSender, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: host, InsecureSkipVerify: true} // nolint: gosec
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to mail server %s: %v", addr, err)
+	}
+	// implicit tls as gomail does
+	if port == 465 {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	// only the context closes the connection, a deadline on the connection
+	// could fire before the context reports why
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close() // nolint: errcheck,gosec
+	})
+	fail := func(err error) (*smtpSender, error) {
+		stop()
+		conn.Close() // nolint: errcheck,gosec
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("could not connect to mail server %s: %v", addr, err)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fail(err)
+	}
+	if err := c.Hello("localhost"); err != nil {
+		return fail(err)
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fail(err)
+		}
+	}
+	return &smtpSender{c: c, stop: stop}, nil
+}
+
+func (s *smtpSender) Send(from string, to []string, msg io.WriterTo) error {
+	if err := s.c.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := s.c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := s.c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := msg.WriteTo(w); err != nil {
+		w.Close() // nolint: errcheck,gosec
+		return err
+	}
+	return w.Close()
+}
+
+func (s *smtpSender) Close() error {
+	defer s.stop()
+	if err := s.c.Quit(); err != nil {
+		s.c.Close() // nolint: errcheck,gosec
+		return err
+	}
+	return nil
+}
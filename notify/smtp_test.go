@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// fakeSMTP accepts a single mail and returns its data. With hang set it
+// greets but never answers.
+func fakeSMTP(t *testing.T, hang bool) (string, int, <-chan string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() }) // nolint: errcheck,gosec
+	data := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close() // nolint: errcheck
+		r := bufio.NewReader(conn)
+		if hang {
+			r.ReadString('\n') // nolint: errcheck,gosec
+			return
+		}
+		conn.Write([]byte("220 localhost\r\n")) // nolint: errcheck,gosec
+		var body strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					data <- body.String()
+					conn.Write([]byte("250 ok\r\n")) // nolint: errcheck,gosec
+					continue
+				}
+				body.WriteString(line)
+				continue
+			}
+			switch strings.ToUpper(strings.Fields(line)[0]) {
+			case "DATA":
+				inData = true
+				conn.Write([]byte("354 go ahead\r\n")) // nolint: errcheck,gosec
+			case "QUIT":
+				conn.Write([]byte("221 bye\r\n")) // nolint: errcheck,gosec
+				return
+			default:
+				conn.Write([]byte("250 ok\r\n")) // nolint: errcheck,gosec
+			}
+		}
+	}()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return host, p, data
+}
+
+func TestSMTP(t *testing.T) {
+	host, port, data := fakeSMTP(t, false)
+	m := gomail.NewMessage()
+	m.SetHeader("From", "from@mail.com")
+	m.SetHeader("To", "to@mail.com")
+	m.SetBody("text/plain", "hello")
+	if err := (SMTP{Host: host, Port: port, Timeout: 5 * time.Second}).Send(context.Background(), m); err != nil {
+		t.Fatalf("error returned: %v", err)
+	}
+	if body := <-data; !strings.Contains(body, "hello") {
+		t.Fatalf("unexpected mail: %s", body)
+	}
+}
+
+func TestSMTPCanceled(t *testing.T) {
+	host, port, _ := fakeSMTP(t, true)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := SMTP{Host: host, Port: port, Timeout: time.Minute}.Send(ctx, gomail.NewMessage())
+	if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("expected the mail to be aborted, took %v", d)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// upper bound of the delay after repeated rate limit responses
+	defaultMaxDelay = 5 * time.Minute
+)
+
+var (
+	// paces all requests to the scraping api
+	pace = newPacer(defaultDelay, defaultMaxDelay, 0)
+
+	metricRateLimited = metrics.counter("rate_limited_total", "Number of api responses signaling a rate limit.", "")
+	metricAPIDelay    = metrics.gauge("api_delay_seconds", "Current delay between two api requests.", "")
+)
+
+// pacer spaces the requests to the api. The delay doubles on every rate
+// limit response and shrinks by a tenth on every successful response back
+// to the configured minimum. The time spent on a request counts against
+// the delay instead of sleeping after every request.
+type pacer struct {
+	mu      sync.Mutex
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+	jitter  float64
+	// earliest time of the next request
+	next time.Time
+}
+
+func newPacer(min, max time.Duration, jitterFraction float64) *pacer {
+	if max < min {
+		max = min
+	}
+	return &pacer{min: min, max: max, current: min, jitter: jitterFraction}
+}
+
+// configure changes the limits keeping the current pace within them
+func (p *pacer) configure(min, max time.Duration, jitterFraction float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if max < min {
+		max = min
+	}
+	p.min, p.max, p.jitter = min, max, jitterFraction
+	p.current = clampDuration(p.current, min, max)
+}
+
+// configurePace applies the delay settings of the config to the api pace
+func configurePace(c configuration) error {
+	min, err := time.ParseDuration(c.Delay)
+	if err != nil {
+		return fmt.Errorf("invalid value for delay %q: %v", c.Delay, err)
+	}
+	max, err := time.ParseDuration(c.Maxdelay)
+	if err != nil {
+		return fmt.Errorf("invalid value for maxdelay %q: %v", c.Maxdelay, err)
+	}
+	pace.configure(min, max, c.Jitter)
+	return nil
+}
+
+// delay returns the current delay between two requests
+func (p *pacer) delay() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.current
+}
+
+// wait blocks until the next request is allowed. It returns false if the
+// context was canceled.
+func (p *pacer) wait(ctx context.Context) bool {
+	p.mu.Lock()
+	now := time.Now()
+	t := p.next
+	if t.Before(now) {
+		t = now
+	}
+	// reserve the slot so concurrent callers queue up behind each other
+	p.next = t.Add(jitter(p.current, p.jitter))
+	p.mu.Unlock()
+	return sleep(ctx, time.Until(t))
+}
+
+// observe adapts the pace to the response of a request
+func (p *pacer) observe(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusForbidden:
+		metricRateLimited.inc("")
+		p.current = clampDuration(p.current*2, p.min, p.max)
+		next := time.Now().Add(p.current)
+		if ra := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ra > 0 {
+			next = time.Now().Add(clampDuration(ra, p.current, p.max))
+		}
+		if next.After(p.next) {
+			p.next = next
+		}
+		logger(componentFetcher).Warn("rate limited by the api, slowing down", "status", resp.StatusCode, "delay", p.current)
+	case resp.StatusCode < http.StatusBadRequest:
+		p.current = clampDuration(p.current-p.current/10, p.min, p.max)
+	}
+}
+
+// retryAfter parses the seconds or http date of a Retry-After header
+func retryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+func clampDuration(d, min, max time.Duration) time.Duration {
+	if d < min {
+		return min
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
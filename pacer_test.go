@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPacerAdapts(t *testing.T) {
+	p := newPacer(time.Second, 10*time.Second, 0)
+	limited := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	ok := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+
+	p.observe(limited)
+	if d := p.delay(); d != 2*time.Second {
+		t.Fatalf("expected the delay to double, got %s", d)
+	}
+	for i := 0; i < 5; i++ {
+		p.observe(&http.Response{StatusCode: http.StatusForbidden, Header: http.Header{}})
+	}
+	if d := p.delay(); d != 10*time.Second {
+		t.Fatalf("expected the delay to be capped, got %s", d)
+	}
+	p.observe(ok)
+	if d := p.delay(); d != 9*time.Second {
+		t.Fatalf("expected the delay to shrink by a tenth, got %s", d)
+	}
+	for i := 0; i < 100; i++ {
+		p.observe(ok)
+	}
+	if d := p.delay(); d != time.Second {
+		t.Fatalf("expected the delay to return to the minimum, got %s", d)
+	}
+	p.observe(&http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}})
+	if d := p.delay(); d != time.Second {
+		t.Fatalf("expected other errors to keep the delay, got %s", d)
+	}
+}
+
+func TestPacerRetryAfter(t *testing.T) {
+	p := newPacer(time.Millisecond, time.Minute, 0)
+	p.observe(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"30"}}})
+	if wait := time.Until(p.next); wait < 29*time.Second || wait > 30*time.Second {
+		t.Fatalf("expected the next request in 30s, got %s", wait)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if p.wait(ctx) {
+		t.Fatal("expected wait to be canceled")
+	}
+}
+
+func TestPacerWait(t *testing.T) {
+	p := newPacer(20*time.Millisecond, time.Second, 0)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if !p.wait(context.Background()) {
+			t.Fatal("unexpected cancel")
+		}
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Fatalf("expected the requests to be spaced, took %s", d)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		"Wed, 01 Jan 2020 00:01:00 GMT": time.Minute,
+		"invalid":                       0,
+	}
+	for v, expected := range tests {
+		if d := retryAfter(v, now); d != expected {
+			t.Errorf("retryAfter(%q) = %s, expected %s", v, d, expected)
+		}
+	}
+}
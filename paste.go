@@ -5,35 +5,86 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"os"
-	"path"
 	"strings"
+	"sync/atomic"
 	"text/tabwriter"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	gomail "gopkg.in/gomail.v2"
 )
 
 const (
 	apiEndpoint = "https://scrape.pastebin.com/api_scraping.php"
+	// pastebin allows up to 10 MB for pro accounts
+	defaultMaxPasteSize = 10 << 20
+	// plain text attachments above this size are gzipped
+	defaultAttachmentGzipSize = 1 << 20
+)
+
+var (
+	// larger pastes are skipped without reading them to the end, a
+	// negative size disables the limit
+	maxPasteSize int64 = defaultMaxPasteSize
 )
 
 type paste struct {
-	FullURL   string `json:"full_url"`
-	ScrapeURL string `json:"scrape_url"`
-	Date      string `json:"date"`
-	Key       string `json:"key"`
-	Size      string `json:"size"`
-	Expire    string `json:"expire"`
-	Title     string `json:"title"`
-	Syntax    string `json:"syntax"`
-	User      string `json:"user"`
-	Content   string
-	Matches   map[string][]string
+	FullURL   string              `json:"full_url"`
+	ScrapeURL string              `json:"scrape_url"`
+	Date      string              `json:"date"`
+	Key       string              `json:"key"`
+	Size      string              `json:"size"`
+	Expire    string              `json:"expire"`
+	Title     string              `json:"title"`
+	Syntax    string              `json:"syntax"`
+	User      string              `json:"user"`
+	Content   string              `json:"-"`
+	Hash      string              `json:"hash,omitempty"`
+	Matches   map[string][]string `json:"matches,omitempty"`
+	Groups    []string            `json:"groups,omitempty"`
+	// set if matched by the rules of a tenant
+	Tenant string `json:"tenant,omitempty"`
+	// key of the recent alert this paste nearly duplicates
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// url of the matched paste linking to this one
+	LinkedFrom string `json:"linked_from,omitempty"`
+	// how likely the alert is relevant, 0 to 100
+	Confidence int `json:"confidence,omitempty"`
+	// set by the script
+	Severity string   `json:"severity,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	// locations of the archived copies
+	Archived   []string    `json:"archived,omitempty"`
+	IOCs       *iocSummary `json:"iocs,omitempty"`
+	Enrichment *enrichment `json:"enrichment,omitempty"`
+
+	// span of the processing to trace the notification
+	spanContext trace.SpanContext
+	// set if the content was spilled to disk to stay within the memory
+	// budget, Content is empty then
+	contentFile string
+	contentSize int64
+	// bytes of the memory budget held by Content
+	reserved int64
 }
 
 func (p *paste) String() string {
+	return p.alertText(false)
+}
+
+// alertText formats the alert. With defanged the links, IPs and emails
+// taken from the paste can not be clicked anymore, the paste URL itself
+// is kept.
+func (p *paste) alertText(defanged bool) string {
+	clean := func(s string) string { return s }
+	if defanged {
+		clean = defang
+	}
 	var buffer bytes.Buffer
 	bw := bufio.NewWriter(&buffer)
 	tw := tabwriter.NewWriter(bw, 0, 5, 3, ' ', 0)
@@ -42,24 +93,9 @@ func (p *paste) String() string {
 		return fmt.Sprintf("error on tostring: %v", err)
 	}
 
-	fields := []struct {
-		prefix  string
-		content string
-	}{
-		{"Title", p.Title},
-		{"URL", p.FullURL},
-		{"User", p.User},
-		{"Date", dateToString(p.Date)},
-		{"Size", p.Size},
-		{"Expire", dateToString(p.Expire)},
-		{"Syntax", p.Syntax},
-	}
-
-	for _, x := range fields {
-		if x.content != "" {
-			if _, err := fmt.Fprintf(tw, "%s:\t%s\n", x.prefix, x.content); err != nil {
-				return fmt.Sprintf("error on tostring: %v", err)
-			}
+	for _, x := range p.alertFields(clean) {
+		if _, err := fmt.Fprintf(tw, "%s:\t%s\n", x.Name, x.Value); err != nil {
+			return fmt.Sprintf("error on tostring: %v", err)
 		}
 	}
 
@@ -67,122 +103,317 @@ func (p *paste) String() string {
 		return fmt.Sprintf("error on tostring: %v", err)
 	}
 
+	// everything below is taken from the paste
+	var details bytes.Buffer
 	for k, v := range p.Matches {
-		if _, err := fmt.Fprintf(bw, "\nMatches for %s:\n", k); err != nil {
+		if _, err := fmt.Fprintf(&details, "\nMatches for %s:\n", k); err != nil {
 			return fmt.Sprintf("error on tostring: %v", err)
 		}
 		for _, m := range v {
-			if _, err := fmt.Fprintf(bw, "%s\n", m); err != nil {
+			if _, err := fmt.Fprintf(&details, "%s\n", m); err != nil {
 				return fmt.Sprintf("error on tostring: %v", err)
 			}
 		}
 	}
 
+	if err := p.IOCs.write(&details); err != nil {
+		return fmt.Sprintf("error on tostring: %v", err)
+	}
+	if err := p.Enrichment.write(&details); err != nil {
+		return fmt.Sprintf("error on tostring: %v", err)
+	}
+	if _, err := bw.WriteString(clean(details.String())); err != nil {
+		return fmt.Sprintf("error on tostring: %v", err)
+	}
+
 	if err := bw.Flush(); err != nil {
 		return fmt.Sprintf("error on tostring: %v", err)
 	}
 	return buffer.String()
 }
 
-func (p *paste) sendPasteMessage(config configuration) (err error) {
-	m := gomail.NewMessage()
-	m.SetHeader("From", config.Mailfrom)
-	m.SetHeader("To", config.Mailto)
-	keywords := strings.Join(getKeysFromMap(p.Matches), ", ")
-	m.SetHeader("Subject", fmt.Sprintf("Pastebin Alert for %s", keywords))
+type alertField struct {
+	Name  string
+	Value string
+	Link  string
+}
 
-	filename := fmt.Sprintf("%s.zip", randomString(10))
-	fullPath := path.Join(os.TempDir(), filename)
-	zipFile, err := createZip("content.txt", p.Content)
-	if err != nil {
-		return err
+// alertFields returns the metadata shown in the alerts without the empty
+// fields
+func (p *paste) alertFields(clean func(string) string) []alertField {
+	var ret []alertField
+	for _, f := range []alertField{
+		{Name: "Title", Value: clean(p.Title)},
+		{Name: "URL", Value: p.FullURL, Link: p.FullURL},
+		{Name: "Author", Value: authorToString(p.User)},
+		{Name: "Created", Value: dateToString(p.Date)},
+		{Name: "Size", Value: sizeToString(p.Size)},
+		{Name: "Expires", Value: expireToString(p.Expire)},
+		{Name: "Syntax", Value: p.Syntax},
+		{Name: "Tenant", Value: p.Tenant},
+		{Name: "Confidence", Value: confidenceToString(p.Confidence)},
+		{Name: "Duplicate of", Value: p.DuplicateOf},
+		{Name: "Linked from", Value: p.LinkedFrom, Link: p.LinkedFrom},
+		{Name: "Groups", Value: strings.Join(p.Groups, ", ")},
+		{Name: "Severity", Value: p.Severity},
+		{Name: "Tags", Value: strings.Join(p.Tags, ", ")},
+		{Name: "Archived", Value: strings.Join(p.Archived, ", ")},
+	} {
+		if f.Value != "" {
+			ret = append(ret, f)
+		}
 	}
+	return ret
+}
 
-	f, err := os.Create(fullPath)
-	if err != nil {
-		return err
+// recipients returns the default recipient and all recipients
+// configured for the groups of the matched keywords
+func (p *paste) recipients(config configuration) []string {
+	mailto, min, groups := config.Mailto, config.Minconfidence, config.Groups
+	if p.Tenant != "" {
+		// a tenant removed on reload gets no alerts anymore
+		t, ok := config.Tenants[p.Tenant]
+		if !ok {
+			return nil
+		}
+		mailto, min, groups = t.Mailto, t.Minconfidence, t.Groups
+	}
+	// nil if neither the default nor a group has a recipient
+	var to []string
+	if mailto != "" && p.Confidence >= min {
+		to = append(to, mailto)
 	}
-	defer func() {
-		rerr := os.Remove(fullPath)
-		if rerr != nil {
-			err = rerr
+	for _, g := range p.Groups {
+		if x, ok := groups[g]; ok && x.Mailto != "" && p.Confidence >= x.Minconfidence && !stringInSlice(x.Mailto, to) {
+			to = append(to, x.Mailto)
 		}
-	}()
+	}
+	return to
+}
 
-	_, err = f.Write(zipFile)
-	if err != nil {
+// alertMessageID returns the message id of the alert of the paste
+func alertMessageID(tenant, key, from string) string {
+	domain := "pastebin_scraper"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = strings.Trim(from[i+1:], "> ")
+	}
+	if tenant != "" {
+		key = tenant + "." + key
+	}
+	return fmt.Sprintf("<%s.alert@%s>", key, domain)
+}
+
+func (p *paste) sendPasteMessage(ctx context.Context, config configuration) error {
+	return p.sendPasteMessageTo(ctx, config, p.recipients(config))
+}
+
+// sendPasteMessageTo sends the alert to the given recipients only
+func (p *paste) sendPasteMessageTo(ctx context.Context, config configuration, to []string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", config.Mailfrom)
+	m.SetHeader("To", to...)
+	keywords := strings.Join(getKeysFromMap(p.Matches), ", ")
+	subject := fmt.Sprintf("Pastebin Alert for %s", keywords)
+	if p.Title != "" {
+		title := p.Title
+		if config.Defang {
+			title = defang(title)
+		}
+		subject = fmt.Sprintf("%s: %s", subject, title)
+	}
+	if len(p.Groups) > 0 {
+		subject = fmt.Sprintf("[%s] %s", strings.Join(p.Groups, ", "), subject)
+	}
+	if p.Severity != "" {
+		subject = fmt.Sprintf("[%s] %s", strings.ToUpper(p.Severity), subject)
+	}
+	m.SetHeader("Subject", subject)
+	m.SetHeader("Message-ID", alertMessageID(p.Tenant, p.Key, config.Mailfrom))
+	if p.DuplicateOf != "" {
+		// mail clients show the near duplicates in the thread of the first alert
+		ref := alertMessageID(p.Tenant, p.DuplicateOf, config.Mailfrom)
+		m.SetHeader("In-Reply-To", ref)
+		m.SetHeader("References", ref)
+	}
+
+	if err := p.attach(m, config.Attachment); err != nil {
 		return err
 	}
 
-	defer func() {
-		rerr := f.Close()
-		if rerr != nil {
-			err = rerr
+	m.SetBody("text/plain", p.format(config.Mailformat, config.Defang))
+	// the minimal format is meant for mail to sms gateways
+	if config.Mailhtml && config.Mailformat != formatMinimal {
+		body, err := p.alertHTML(config.Defang, config.Mailstyle)
+		if err != nil {
+			return err
 		}
-	}()
+		m.AddAlternative("text/html", body)
+	}
+	return sendEmail(ctx, config, m)
+}
 
-	m.Attach(fullPath)
+// attach adds the paste content to the alert. By default it is zipped
+// to keep mail filters from scanning it, as plain text it is gzipped
+// when it is larger than the threshold.
+func (p *paste) attach(m *gomail.Message, c attachmentConfig) error {
+	var name string
+	var content []byte
+	switch c.Format {
+	case "none":
+		return nil
+	case "text":
+		threshold := c.Gzipsize
+		if threshold == 0 {
+			threshold = defaultAttachmentGzipSize
+		}
+		name, content = p.Key+".txt", []byte(p.Content)
+		if threshold > 0 && int64(len(content)) > threshold {
+			var err error
+			if content, err = gzipString(p.Content); err != nil {
+				return fmt.Errorf("could not compress attachment: %v", err)
+			}
+			name += ".gz"
+		}
+	default:
+		zipFile, err := createZip("content.txt", p.Content)
+		if err != nil {
+			return err
+		}
+		name, content = fmt.Sprintf("%s.zip", randomString(10)), zipFile
+	}
+	m.Attach(name, gomail.SetCopyFunc(func(w io.Writer) error {
+		_, err := w.Write(content)
+		return err
+	}))
+	return nil
+}
 
-	m.SetBody("text/plain", p.String())
-	err = sendEmail(config, m)
-	return err
+// fetch downloads the paste. The returned paste contains the content and
+// the SHA-256 hash of it.
+func (p paste) fetch(ctx context.Context) (*paste, error) {
+	logger(componentFetcher).Debug("checking paste", "paste_key", p.Key)
+	ctx, span := tracer.Start(ctx, "fetch paste", trace.WithAttributes(attribute.String("paste.key", p.Key)))
+	ret, err := p.fetchBody(ctx)
+	if ret != nil {
+		span.SetAttributes(attribute.Int("paste.size", len(ret.Content)))
+	}
+	endSpan(span, err)
+	return ret, err
 }
 
-func (p paste) fetch(ctx context.Context, keywords *map[string]keywordType, cidrs *[]cidrType) (*paste, error) {
-	debugOutput("checking paste %s", p.Key)
+func (p paste) fetchBody(ctx context.Context) (*paste, error) {
+	start := time.Now()
 	resp, err := httpRequest(ctx, p.ScrapeURL)
 	if err != nil {
-		// Ignore HTTP based errors like timeout and connection reset
-		return nil, nil
+		// HTTP based errors like timeout and connection reset
+		metricFetchErrors.inc("paste")
+		return nil, transientError{err}
 	}
 
-	if resp.StatusCode == http.StatusOK || resp.ContentLength > 0 {
+	// error pages are never content, even if rate limits or blocks come
+	// with a body
+	if resp.StatusCode != http.StatusOK {
+		metricFetchErrors.inc("paste")
 		b, err := httpRespBodyToString(resp)
-		if err != nil {
-			return nil, err
+		err = fmt.Errorf("Output: %s, Error: %v", b, err)
+		if transientStatus(resp.StatusCode) {
+			return nil, transientError{err}
 		}
-		found, key := checkKeywords(b, keywords)
-		found2, key2 := checkCIDRs(b, cidrs)
-		if found || found2 {
-			// merge key1 and key2
-			for k, v := range key2 {
-				key[k] = v
-			}
+		return nil, err
+	}
 
+	max := atomic.LoadInt64(&maxPasteSize)
+	if contentBudget != nil {
+		err = p.readResponse(resp, max)
+	} else {
+		var b string
+		if b, err = httpRespBodyToStringLimit(resp, max); err == nil {
 			p.Content = b
-			p.Matches = key
-			return &p, nil
+			p.Hash = sha256Hex([]byte(b))
 		}
-	} else {
-		b, err := httpRespBodyToString(resp)
-		return nil, fmt.Errorf("Output: %s, Error: %v", b, err)
 	}
-	// nothing found
-	return nil, nil
+	if err == errBodyTooLarge {
+		logger(componentFetcher).Warn("skipping paste larger than maxpastesize", "paste_key", p.Key, "size", p.Size, "maxpastesize", max)
+		metricPastesSkipped.inc("size")
+		return nil, nil
+	}
+	if err != nil {
+		metricFetchErrors.inc("paste")
+		return nil, transientError{err}
+	}
+	metricFetchDuration.since(start)
+	metricPastesFetched.inc("")
+	return &p, nil
+}
+
+// sizeAllowed checks the size of a paste against the configured minimum
+// and maximum size
+func sizeAllowed(c configuration, size int64) bool {
+	if c.Minsize > 0 && size < c.Minsize {
+		return false
+	}
+	if c.Maxsize > 0 && size > c.Maxsize {
+		return false
+	}
+	return true
+}
+
+// scan checks the content against all rules and sets the matches
+func (p *paste) scan(m *matcher) {
+	_, span := tracer.Start(trace.ContextWithSpanContext(context.Background(), p.spanContext), "match")
+	defer span.End()
+	if p.contentFile != "" {
+		if err := p.scanFile(m); err != nil {
+			logger(componentMatcher).Error("could not scan spilled paste", "paste_key", p.Key, "error", err)
+		}
+	} else if found, key := m.Scan(p.Content); found {
+		p.Matches = key
+		p.Groups = m.Groups(key)
+	}
+	span.SetAttributes(attribute.Int("paste.matches", len(p.Matches)))
+	if len(p.Matches) > 0 {
+		p.Confidence = m.confidence(p, time.Now())
+	}
 }
 
 func fetchPasteList(ctx context.Context) ([]paste, error) {
+	ctx, span := tracer.Start(ctx, "fetch list")
+	list, err := fetchPasteListBody(ctx)
+	span.SetAttributes(attribute.Int("pastes", len(list)))
+	endSpan(span, err)
+	return list, err
+}
+
+func fetchPasteListBody(ctx context.Context) ([]paste, error) {
 	var list []paste
-	debugOutput("fetching paste list")
+	logger(componentFetcher).Debug("fetching paste list")
 	url := fmt.Sprintf("%s?limit=100", apiEndpoint)
 	resp, err := httpRequest(ctx, url)
 	if err != nil {
-		// Ignore HTTP based errors like timeout and connection reset
-		return list, nil
+		// HTTP based errors like timeout and connection reset
+		metricFetchErrors.inc("list")
+		return list, transientError{err}
 	}
 
 	body, err := httpRespBodyToString(resp)
 	if err != nil {
-		return list, err
+		metricFetchErrors.inc("list")
+		return list, transientError{err}
+	}
+	if transientStatus(resp.StatusCode) {
+		metricFetchErrors.inc("list")
+		return list, transientError{fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)}
 	}
 	// ip does not have access. Do not panic so error mail will be sent
 	if strings.Contains(body, "DOES NOT HAVE ACCESS") {
-		return list, fmt.Errorf(body)
+		return list, errors.New(body)
 	}
 
 	jsonErr := json.Unmarshal([]byte(body), &list)
 	if jsonErr != nil {
+		metricFetchErrors.inc("list")
 		return list, fmt.Errorf("error on parsing json: %v. json: %s", jsonErr, body)
 	}
+	metricPastesListed.add(float64(len(list)), "")
 	return list, nil
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	gomail "gopkg.in/gomail.v2"
+)
+
+func TestSizeAllowed(t *testing.T) {
+	c := configuration{Minsize: 10, Maxsize: 100}
+	tests := map[int64]bool{0: false, 9: false, 10: true, 100: true, 101: false}
+	for size, expected := range tests {
+		if got := sizeAllowed(c, size); got != expected {
+			t.Errorf("sizeAllowed(%d) = %v, expected %v", size, got, expected)
+		}
+	}
+	if !sizeAllowed(configuration{}, 1<<30) {
+		t.Error("expected all sizes to be allowed without limits")
+	}
+}
+
+func TestPasteStringMetadata(t *testing.T) {
+	p := paste{
+		FullURL: "https://pastebin.com/abc",
+		Title:   "dump",
+		Syntax:  "sql",
+		Size:    "2048",
+		Date:    "1500000000",
+		Expire:  "0",
+		Matches: map[string][]string{"password": {"password=1"}},
+	}
+	s := p.String()
+	for _, expected := range []string{"Title:", "dump", "Author:", "Guest", "Syntax:", "sql", "2.0 KB (2048 bytes)", "Created:", dateToString("1500000000"), "Expires:", "Never"} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("expected %q in alert, got:\n%s", expected, s)
+		}
+	}
+	p.User = "someone"
+	if s := p.String(); !strings.Contains(s, "someone") || strings.Contains(s, "Guest") {
+		t.Errorf("expected the author in alert, got:\n%s", s)
+	}
+}
+
+func TestPasteAttach(t *testing.T) {
+	p := paste{Key: "abc", Content: strings.Repeat("secret\n", 100)}
+	tests := []struct {
+		c        attachmentConfig
+		expected string
+	}{
+		{attachmentConfig{}, ".zip\""},
+		{attachmentConfig{Format: "text"}, "filename=\"abc.txt\""},
+		{attachmentConfig{Format: "text", Gzipsize: 10}, "filename=\"abc.txt.gz\""},
+		{attachmentConfig{Format: "text", Gzipsize: -1}, "filename=\"abc.txt\""},
+	}
+	for _, x := range tests {
+		m := gomail.NewMessage()
+		m.SetBody("text/plain", "alert")
+		if err := p.attach(m, x.c); err != nil {
+			t.Fatalf("attach %+v: %v", x.c, err)
+		}
+		var b bytes.Buffer
+		if _, err := m.WriteTo(&b); err != nil {
+			t.Fatalf("could not write message: %v", err)
+		}
+		if !strings.Contains(b.String(), x.expected) {
+			t.Errorf("expected %s in the message for %+v, got:\n%s", x.expected, x.c, b.String())
+		}
+	}
+	m := gomail.NewMessage()
+	m.SetBody("text/plain", "alert")
+	if err := p.attach(m, attachmentConfig{Format: "none"}); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	m.WriteTo(&b) // nolint: errcheck,gosec
+	if strings.Contains(b.String(), "Content-Disposition: attachment") {
+		t.Errorf("expected no attachment, got:\n%s", b.String())
+	}
+}
+
+func TestPasteStringArchived(t *testing.T) {
+	p := paste{FullURL: "https://pastebin.com/abc", Matches: map[string][]string{"a": {"a"}}}
+	if strings.Contains(p.String(), "Archived:") {
+		t.Error("expected no archive location without archive")
+	}
+	p.Archived = []string{"s3://bucket/2024/01/02/abc.txt.gz", "/var/archive/2024/01/02/abc.txt.gz"}
+	if s := p.String(); !strings.Contains(s, "s3://bucket/2024/01/02/abc.txt.gz, /var/archive/2024/01/02/abc.txt.gz") {
+		t.Errorf("expected the archive locations in the alert, got:\n%s", s)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	// halts all requests to the scraping api while paused
+	scraping = &pauseSwitch{}
+
+	metricPaused = metrics.gauge("paused", "1 while fetching is paused.", "")
+)
+
+// pauseSwitch pauses fetching without stopping the scraper so the state,
+// the connections and the leadership are kept
+type pauseSwitch struct {
+	mu     sync.Mutex
+	paused bool
+	since  time.Time
+	// closed on resume to wake up the waiting requests
+	resumed chan struct{}
+}
+
+// pause returns false if fetching was already paused
+func (s *pauseSwitch) pause(reason string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused {
+		return false
+	}
+	s.paused = true
+	s.since = time.Now()
+	s.resumed = make(chan struct{})
+	slog.Warn("fetching paused", "by", reason)
+	return true
+}
+
+// resume returns false if fetching was not paused
+func (s *pauseSwitch) resume(reason string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.paused {
+		return false
+	}
+	s.paused = false
+	close(s.resumed)
+	slog.Info("fetching resumed", "by", reason, "paused", time.Since(s.since).Round(time.Second))
+	return true
+}
+
+// status returns if fetching is paused and since when
+func (s *pauseSwitch) status() (bool, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused, s.since
+}
+
+// wait blocks while fetching is paused. It returns false if the context
+// was canceled.
+func (s *pauseSwitch) wait(ctx context.Context) bool {
+	s.mu.Lock()
+	paused, resumed := s.paused, s.resumed
+	s.mu.Unlock()
+	if !paused {
+		return ctx.Err() == nil
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-resumed:
+		return true
+	}
+}
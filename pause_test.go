@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPauseSwitch(t *testing.T) {
+	s := &pauseSwitch{}
+	if !s.wait(context.Background()) {
+		t.Fatal("expected wait to return immediately while running")
+	}
+	if !s.pause("test") || s.pause("test") {
+		t.Fatal("expected only the first pause to change the state")
+	}
+	if paused, since := s.status(); !paused || since.IsZero() {
+		t.Fatalf("expected to be paused, got %v %v", paused, since)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if s.wait(ctx) {
+		t.Fatal("expected wait to return false on cancel while paused")
+	}
+
+	done := make(chan bool)
+	go func() { done <- s.wait(context.Background()) }()
+	select {
+	case <-done:
+		t.Fatal("expected wait to block while paused")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if !s.resume("test") || s.resume("test") {
+		t.Fatal("expected only the first resume to change the state")
+	}
+	if !<-done {
+		t.Fatal("expected wait to return true on resume")
+	}
+}
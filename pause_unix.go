@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// pauseSignals maps the signals to pause (true) or resume (false) fetching
+var pauseSignals = map[os.Signal]bool{
+	syscall.SIGUSR1: true,
+	syscall.SIGUSR2: false,
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// windows has no user signals, use the api to pause fetching
+var pauseSignals = map[os.Signal]bool{}
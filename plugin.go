@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPluginTimeout  = 30 * time.Second
+	defaultPluginInterval = time.Minute
+	// longest line accepted from a plugin, sources return whole pastes
+	maxPluginLine = 64 * 1024 * 1024
+
+	pluginSource   = "source"
+	pluginDetector = "detector"
+	pluginNotifier = "notifier"
+)
+
+var (
+	metricPluginCalls  = metrics.counter("plugin_calls_total", "Number of requests sent to plugins.", "plugin")
+	metricPluginErrors = metrics.counter("plugin_errors_total", "Number of failed plugin requests.", "plugin")
+
+	// detectors of the plugins, they are part of every matcher
+	pluginDetectors []detector
+)
+
+// pluginHello is the first line a plugin writes after the start
+type pluginHello struct {
+	Name  string   `json:"name"`
+	Kinds []string `json:"kinds"`
+	// formatting profile of the messages passed to notifiers
+	Format string `json:"format"`
+}
+
+type pluginRequest struct {
+	ID      int          `json:"id"`
+	Method  string       `json:"method"`
+	Content string       `json:"content,omitempty"`
+	Paste   *hookPayload `json:"paste,omitempty"`
+}
+
+// pluginPaste is a paste returned by a source including the content
+type pluginPaste struct {
+	paste
+	Content string `json:"content"`
+}
+
+type pluginResponse struct {
+	ID      int           `json:"id"`
+	Error   string        `json:"error"`
+	Matches []string      `json:"matches"`
+	Pastes  []pluginPaste `json:"pastes"`
+}
+
+// plugin is an external program speaking json lines on stdin and stdout.
+// Requests are sent one at a time, a plugin not answering within the
+// timeout is stopped.
+type plugin struct {
+	name    string
+	kinds   []string
+	format  string
+	path    string
+	timeout time.Duration
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan []byte
+	nextID int
+	broken error
+}
+
+func startPlugin(path string, timeout time.Duration) (*plugin, error) {
+	cmd := exec.Command(path) // nolint: gosec
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not start plugin %s: %v", path, err)
+	}
+	p := &plugin{path: path, timeout: timeout, cmd: cmd, stdin: stdin, lines: make(chan []byte)}
+	go func() {
+		defer close(p.lines)
+		s := bufio.NewScanner(stdout)
+		s.Buffer(make([]byte, 64*1024), maxPluginLine)
+		for s.Scan() {
+			p.lines <- append([]byte(nil), s.Bytes()...)
+		}
+	}()
+	var hello pluginHello
+	if err := p.read(&hello); err != nil {
+		p.close()
+		return nil, fmt.Errorf("no handshake from plugin %s: %v", path, err)
+	}
+	if hello.Name == "" {
+		p.close()
+		return nil, fmt.Errorf("plugin %s did not send a name", path)
+	}
+	for _, k := range hello.Kinds {
+		if k != pluginSource && k != pluginDetector && k != pluginNotifier {
+			p.close()
+			return nil, fmt.Errorf("plugin %s has the unknown kind %q", path, k)
+		}
+	}
+	if !validFormat(hello.Format) {
+		p.close()
+		return nil, fmt.Errorf("plugin %s has the unknown format %q", path, hello.Format)
+	}
+	p.name, p.kinds, p.format = hello.Name, hello.Kinds, hello.Format
+	return p, nil
+}
+
+// read decodes the next line, the lock must be held after the start
+func (p *plugin) read(v interface{}) error {
+	t := time.NewTimer(p.timeout)
+	defer t.Stop()
+	select {
+	case line, ok := <-p.lines:
+		if !ok {
+			return fmt.Errorf("plugin exited")
+		}
+		if err := json.Unmarshal(line, v); err != nil {
+			return fmt.Errorf("invalid response: %v", err)
+		}
+		return nil
+	case <-t.C:
+		return fmt.Errorf("no response within %v", p.timeout)
+	}
+}
+
+func (p *plugin) is(kind string) bool {
+	return stringInSlice(kind, p.kinds)
+}
+
+// call sends the request and waits for the response with the same id
+func (p *plugin) call(req pluginRequest) (pluginResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var resp pluginResponse
+	if p.broken != nil {
+		return resp, p.broken
+	}
+	metricPluginCalls.inc(p.name)
+	p.nextID++
+	req.ID = p.nextID
+	err := func() error {
+		b, err := json.Marshal(req)
+		if err != nil {
+			return fmt.Errorf("could not encode request: %v", err)
+		}
+		if _, err := p.stdin.Write(append(b, '\n')); err != nil {
+			return fmt.Errorf("could not send request: %v", err)
+		}
+		if err := p.read(&resp); err != nil {
+			return err
+		}
+		if resp.ID != req.ID {
+			return fmt.Errorf("response %d for request %d", resp.ID, req.ID)
+		}
+		return nil
+	}()
+	if err != nil {
+		// the protocol is out of sync, the plugin is not used anymore
+		metricPluginErrors.inc(p.name)
+		p.broken = fmt.Errorf("plugin %s stopped after: %v", p.name, err)
+		p.cmd.Process.Kill() // nolint: errcheck,gosec
+		return resp, p.broken
+	}
+	if resp.Error != "" {
+		metricPluginErrors.inc(p.name)
+		return resp, fmt.Errorf("plugin %s: %s", p.name, resp.Error)
+	}
+	return resp, nil
+}
+
+// detector returns the plugin as detector, errors count as no match
+func (p *plugin) detector() detector {
+	return detector{Name: p.name, Detect: func(body string) []string {
+		resp, err := p.call(pluginRequest{Method: "detect", Content: body})
+		if err != nil {
+			logger(componentMatcher).Error("plugin detector failed", "plugin", p.name, "error", err)
+			return nil
+		}
+		return resp.Matches
+	}}
+}
+
+// notify passes the match in the format of the exec hooks
+func (p *plugin) notify(m paste, score int, foundAt time.Time) error {
+	_, err := p.call(pluginRequest{Method: "notify", Paste: &hookPayload{newPasteEvent(m, foundAt), m.Content, score, m.format(p.format, false)}})
+	return err
+}
+
+// list returns the new pastes of a source
+func (p *plugin) list() ([]paste, error) {
+	resp, err := p.call(pluginRequest{Method: "list"})
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]paste, 0, len(resp.Pastes))
+	for _, x := range resp.Pastes {
+		x.paste.Content = x.Content
+		ret = append(ret, x.paste)
+	}
+	return ret, nil
+}
+
+// close ends the plugin by closing its stdin and kills it if it does not
+// exit in time
+func (p *plugin) close() {
+	p.stdin.Close() // nolint: errcheck,gosec
+	// late responses must not block the reader
+	go func() {
+		for range p.lines {
+		}
+	}()
+	done := make(chan struct{})
+	go func() {
+		p.cmd.Wait() // nolint: errcheck,gosec
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(p.timeout):
+		p.cmd.Process.Kill() // nolint: errcheck,gosec
+		<-done
+	}
+}
+
+// discoverPlugins starts every executable in the directory
+func discoverPlugins(c pluginConfig) ([]*plugin, error) {
+	timeout := defaultPluginTimeout
+	if c.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(c.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid plugin timeout %q: %v", c.Timeout, err)
+		}
+	}
+	files, err := ioutil.ReadDir(c.Directory)
+	if err != nil {
+		return nil, fmt.Errorf("could not read plugin directory: %v", err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+	var ret []*plugin
+	for _, f := range files {
+		if !f.Mode().IsRegular() || f.Mode()&0111 == 0 {
+			continue
+		}
+		p, err := startPlugin(filepath.Join(c.Directory, f.Name()), timeout)
+		if err != nil {
+			closePlugins(ret)
+			return nil, err
+		}
+		logger(componentFetcher).Info("started plugin", "plugin", p.name, "kinds", p.kinds)
+		ret = append(ret, p)
+	}
+	return ret, nil
+}
+
+func closePlugins(plugins []*plugin) {
+	for _, p := range plugins {
+		p.close()
+	}
+}
+
+// runPluginSource polls the source and passes every paste to fn until
+// the context is canceled
+func runPluginSource(ctx context.Context, p *plugin, interval time.Duration, fn func(p *paste), errs chan<- error) {
+	for {
+		pastes, err := p.list()
+		if err != nil {
+			errs <- fmt.Errorf("source %s: %v", p.name, err)
+		}
+		for i := range pastes {
+			if ctx.Err() != nil {
+				return
+			}
+			fn(&pastes[i])
+		}
+		if !sleep(ctx, interval) {
+			return
+		}
+	}
+}
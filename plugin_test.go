@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPlugin = `#!/bin/sh
+echo '{"name":"demo","kinds":["detector","notifier","source"]}'
+i=0
+while read -r line; do
+  i=$((i+1))
+  case "$line" in
+  *'"method":"detect"'*secret*) echo "{\"id\":$i,\"matches\":[\"secret line\"]}" ;;
+  *'"method":"detect"'*) echo "{\"id\":$i}" ;;
+  *'"method":"list"'*) echo "{\"id\":$i,\"pastes\":[{\"key\":\"abc\",\"title\":\"t\",\"content\":\"hello\"}]}" ;;
+  *'"method":"notify"'*) echo "{\"id\":$i,\"error\":\"mail down\"}" ;;
+  esac
+done
+`
+
+func TestPlugins(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell available")
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "demo"), []byte(testPlugin), 0700); err != nil {
+		t.Fatal(err)
+	}
+	// not executable
+	if err := ioutil.WriteFile(filepath.Join(dir, "README"), []byte("docs"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	plugins, err := discoverPlugins(pluginConfig{Directory: dir, Timeout: "5s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closePlugins(plugins)
+	if len(plugins) != 1 || plugins[0].name != "demo" || !plugins[0].is(pluginSource) {
+		t.Fatalf("unexpected plugins %+v", plugins)
+	}
+	p := plugins[0]
+
+	d := p.detector()
+	if m := d.Detect("a secret paste"); len(m) != 1 || m[0] != "secret line" {
+		t.Errorf("unexpected detection %v", m)
+	}
+	if m := d.Detect("nothing"); len(m) != 0 {
+		t.Errorf("expected no detection, got %v", m)
+	}
+	pastes, err := p.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pastes) != 1 || pastes[0].Key != "abc" || pastes[0].Content != "hello" {
+		t.Errorf("unexpected pastes %+v", pastes)
+	}
+	if err := p.notify(paste{Key: "abc"}, 1, time.Now()); err == nil || !strings.Contains(err.Error(), "mail down") {
+		t.Errorf("expected the plugin error, got %v", err)
+	}
+	// an error response keeps the plugin usable
+	if _, err := p.list(); err != nil {
+		t.Errorf("expected the plugin to still work, got %v", err)
+	}
+}
+
+func TestPluginHandshakeTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell available")
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "silent"), []byte("#!/bin/sh\nexec sleep 10\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := discoverPlugins(pluginConfig{Directory: dir, Timeout: "100ms"}); err == nil || !strings.Contains(err.Error(), "handshake") {
+		t.Errorf("expected a handshake error, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("the plugin was not killed")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckLoopback(t *testing.T) {
+	for addr, valid := range map[string]bool{
+		"localhost:6060": true,
+		"127.0.0.1:6060": true,
+		"[::1]:6060":     true,
+		":6060":          false,
+		"0.0.0.0:6060":   false,
+		"10.0.0.1:6060":  false,
+		"localhost":      false,
+	} {
+		err := checkLoopback(addr)
+		if valid && err != nil {
+			t.Errorf("expected %q to be valid: %v", addr, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("expected %q to be invalid", addr)
+		}
+	}
+}
+
+func TestPprofMux(t *testing.T) {
+	w := httptest.NewRecorder()
+	pprofMux().ServeHTTP(w, httptest.NewRequest("GET", "/debug/pprof/goroutine?debug=1", nil))
+	if w.Code != 200 {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+}
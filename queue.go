@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAlertQueue = 1000
+	defaultErrorQueue = 100
+	// how often spooled alerts are moved back into the queue
+	spoolInterval = 10 * time.Second
+)
+
+var (
+	metricQueueDropped = metrics.counter("queue_dropped_total", "Number of items dropped because a queue was full.", "queue")
+	metricQueueSpooled = metrics.counter("queue_spooled_total", "Number of alerts written to the spool because the queue was full.", "")
+)
+
+// boundedQueue decouples the senders from a slow consumer like a hanging
+// mail server. Items which do not fit into the queue are passed to the
+// overflow handler instead of blocking the fetch loop.
+type boundedQueue[T any] struct {
+	out      chan T
+	overflow func(T)
+}
+
+func newBoundedQueue[T any](size int, overflow func(T)) *boundedQueue[T] {
+	return &boundedQueue[T]{out: make(chan T, size), overflow: overflow}
+}
+
+// put adds the item if there is room left
+func (q *boundedQueue[T]) put(v T) bool {
+	select {
+	case q.out <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+// forward moves the items of in to the queue until in is closed. refill
+// is called on start and in an interval to put back overflowed items.
+func (q *boundedQueue[T]) forward(in <-chan T, refill func(put func(T) bool)) {
+	defer close(q.out)
+	var tick <-chan time.Time
+	if refill != nil {
+		t := time.NewTicker(spoolInterval)
+		defer t.Stop()
+		tick = t.C
+		refill(q.put)
+	}
+	for {
+		select {
+		case v, ok := <-in:
+			if !ok {
+				return
+			}
+			if !q.put(v) {
+				q.overflow(v)
+			}
+		case <-tick:
+			refill(q.put)
+		}
+	}
+}
+
+// spooledPaste keeps the content which is not part of the json of a paste
+type spooledPaste struct {
+	Paste   paste  `json:"paste"`
+	Content string `json:"content"`
+}
+
+// alertSpool stores the alerts which did not fit into the queue on disk so
+// they are sent once the notifier caught up, also after a restart
+type alertSpool struct {
+	dir string
+}
+
+func newAlertSpool(dir string) (*alertSpool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create spool directory %s: %v", dir, err)
+	}
+	return &alertSpool{dir: dir}, nil
+}
+
+func (s *alertSpool) write(p paste) error {
+	b, err := json.Marshal(spooledPaste{Paste: p, Content: p.Content})
+	if err != nil {
+		return err
+	}
+	// the timestamp keeps the order of the alerts
+	name := fmt.Sprintf("%020d-%s.json", time.Now().UnixNano(), filepath.Base(p.Key))
+	tmp := filepath.Join(s.dir, "."+name)
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, name))
+}
+
+// refill puts the spooled alerts back in order until the queue is full
+func (s *alertSpool) refill(put func(paste) bool) error {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("could not read spool directory %s: %v", s.dir, err)
+	}
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") && !strings.HasPrefix(f.Name(), ".") {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file := filepath.Join(s.dir, name)
+		b, err := ioutil.ReadFile(file) // nolint: gosec
+		if err != nil {
+			return fmt.Errorf("could not read spooled alert %s: %v", file, err)
+		}
+		var sp spooledPaste
+		if err := json.Unmarshal(b, &sp); err != nil {
+			// move it aside so it does not block the following alerts
+			os.Rename(file, file+".invalid") // nolint: errcheck,gosec
+			return fmt.Errorf("invalid spooled alert %s: %v", file, err)
+		}
+		sp.Paste.Content = sp.Content
+		if !put(sp.Paste) {
+			return nil
+		}
+		if err := os.Remove(file); err != nil {
+			return fmt.Errorf("could not remove spooled alert %s: %v", file, err)
+		}
+	}
+	return nil
+}
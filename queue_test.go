@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBoundedQueueOverflow(t *testing.T) {
+	var dropped []int
+	q := newBoundedQueue(2, func(v int) { dropped = append(dropped, v) })
+	in := make(chan int)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.forward(in, nil)
+	}()
+	for i := 1; i <= 4; i++ {
+		in <- i
+	}
+	close(in)
+	<-done
+	var got []int
+	for v := range q.out {
+		got = append(got, v)
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("expected the first two items to be queued, got %v", got)
+	}
+	if len(dropped) != 2 || dropped[0] != 3 || dropped[1] != 4 {
+		t.Fatalf("expected the last two items to overflow, got %v", dropped)
+	}
+}
+
+func TestAlertSpool(t *testing.T) {
+	s, err := newAlertSpool(filepath.Join(t.TempDir(), "spool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		if err := s.write(paste{Key: k, Content: "content " + k, Matches: map[string][]string{"x": {}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the queue only has room for two alerts
+	var got []paste
+	put := func(p paste) bool {
+		if len(got) == 2 {
+			return false
+		}
+		got = append(got, p)
+		return true
+	}
+	if err := s.refill(put); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Key != "a" || got[1].Key != "b" || got[1].Content != "content b" {
+		t.Fatalf("unexpected refilled alerts: %+v", got)
+	}
+	if _, ok := got[0].Matches["x"]; !ok {
+		t.Fatalf("expected the matches to be kept, got %v", got[0].Matches)
+	}
+
+	got = nil
+	if err := s.refill(put); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Key != "c" {
+		t.Fatalf("expected the remaining alert, got %+v", got)
+	}
+	files, _ := ioutil.ReadDir(s.dir)
+	if len(files) != 0 {
+		t.Fatalf("expected an empty spool, got %d files", len(files))
+	}
+}
+
+func TestAlertSpoolInvalid(t *testing.T) {
+	s, err := newAlertSpool(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(s.dir, "0-bad.json")
+	if err := ioutil.WriteFile(bad, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.refill(func(paste) bool { return true }); err == nil {
+		t.Fatal("expected an error for the invalid alert")
+	}
+	if _, err := os.Stat(bad + ".invalid"); err != nil {
+		t.Fatalf("expected the invalid alert to be moved aside: %v", err)
+	}
+	if err := s.refill(func(paste) bool { return true }); err != nil {
+		t.Fatalf("expected the invalid alert to be skipped, got %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gomail "gopkg.in/gomail.v2"
+)
+
+const (
+	// how often the digests of the ended quiet hours are sent
+	digestInterval = 1 * time.Minute
+)
+
+var (
+	// quiet hours per mail recipient, replaced on reload
+	quietSchedules atomic.Pointer[map[string]*quietSchedule]
+	// alerts held back during quiet hours
+	digests = newDigestQueue()
+
+	metricSuppressed = metrics.counter("alerts_suppressed_total", "Number of alerts held back for a digest during quiet hours.", "")
+
+	weekdays = map[string]time.Weekday{
+		"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+		"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	}
+)
+
+// quietSchedule is the compiled form of the quiet hours of a recipient
+type quietSchedule struct {
+	// minutes since midnight, from == to means no daily quiet hours
+	from, to int
+	loc      *time.Location
+	days     map[time.Weekday]bool
+	minScore int
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseQuietHours returns nil if no quiet hours are configured
+func parseQuietHours(q quietHours) (*quietSchedule, error) {
+	if q.From == "" && q.To == "" && len(q.Days) == 0 {
+		return nil, nil
+	}
+	s := &quietSchedule{loc: time.Local, days: make(map[time.Weekday]bool), minScore: q.Minscore}
+	if q.From != "" || q.To != "" {
+		var err error
+		if s.from, err = parseClock(q.From); err != nil {
+			return nil, fmt.Errorf("from: %v", err)
+		}
+		if s.to, err = parseClock(q.To); err != nil {
+			return nil, fmt.Errorf("to: %v", err)
+		}
+	}
+	if q.Timezone != "" {
+		loc, err := time.LoadLocation(q.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %v", q.Timezone, err)
+		}
+		s.loc = loc
+	}
+	for _, d := range q.Days {
+		name := strings.ToLower(d)
+		if len(name) > 3 {
+			name = name[:3]
+		}
+		w, ok := weekdays[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid day %q", d)
+		}
+		s.days[w] = true
+	}
+	return s, nil
+}
+
+// quiet returns true if alerts are held back at the time. The daily quiet
+// hours may span midnight like 22:00 to 07:00.
+func (s *quietSchedule) quiet(t time.Time) bool {
+	t = t.In(s.loc)
+	if s.days[t.Weekday()] {
+		return true
+	}
+	m := t.Hour()*60 + t.Minute()
+	switch {
+	case s.from == s.to:
+		return false
+	case s.from < s.to:
+		return m >= s.from && m < s.to
+	default:
+		return m >= s.from || m < s.to
+	}
+}
+
+// urgent alerts are sent during quiet hours as well
+func (s *quietSchedule) urgent(score int) bool {
+	return s.minScore > 0 && score >= s.minScore
+}
+
+// buildQuietSchedules maps the recipients to their quiet hours. The global
+// quiet hours apply to mailto, the ones of a group to the mailto of the
+// group.
+func buildQuietSchedules(c configuration) (map[string]*quietSchedule, error) {
+	ret := make(map[string]*quietSchedule)
+	s, err := parseQuietHours(c.Quiethours)
+	if err != nil {
+		return nil, fmt.Errorf("quiethours: %v", err)
+	}
+	if s != nil && c.Mailto != "" {
+		ret[c.Mailto] = s
+	}
+	for _, name := range sortedKeys(c.Groups) {
+		g := c.Groups[name]
+		s, err := parseQuietHours(g.Quiethours)
+		if err != nil {
+			return nil, fmt.Errorf("groups.%s.quiethours: %v", name, err)
+		}
+		if _, ok := ret[g.Mailto]; s != nil && g.Mailto != "" && !ok {
+			ret[g.Mailto] = s
+		}
+	}
+	for _, name := range sortedKeys(c.Tenants) {
+		t := c.Tenants[name]
+		recipients := map[string]quietHours{t.Mailto: t.Quiethours}
+		for _, g := range sortedKeys(t.Groups) {
+			if _, ok := recipients[t.Groups[g].Mailto]; !ok {
+				recipients[t.Groups[g].Mailto] = t.Groups[g].Quiethours
+			}
+		}
+		for _, to := range sortedKeys(recipients) {
+			s, err := parseQuietHours(recipients[to])
+			if err != nil {
+				return nil, fmt.Errorf("tenants.%s.quiethours: %v", name, err)
+			}
+			if _, ok := ret[to]; s != nil && to != "" && !ok {
+				ret[to] = s
+			}
+		}
+	}
+	return ret, nil
+}
+
+// configureQuietHours applies the quiet hours of the config
+func configureQuietHours(c configuration) error {
+	s, err := buildQuietSchedules(c)
+	if err != nil {
+		return err
+	}
+	quietSchedules.Store(&s)
+	return nil
+}
+
+func recipientSchedule(to string) *quietSchedule {
+	s := quietSchedules.Load()
+	if s == nil {
+		return nil
+	}
+	return (*s)[to]
+}
+
+// splitQuiet splits the recipients of an alert into the ones notified now
+// and the ones in their quiet hours
+func splitQuiet(to []string, score int, t time.Time) (send, hold []string) {
+	for _, r := range to {
+		if s := recipientSchedule(r); s != nil && s.quiet(t) && !s.urgent(score) {
+			hold = append(hold, r)
+		} else {
+			send = append(send, r)
+		}
+	}
+	return send, hold
+}
+
+// digestQueue collects the held back alerts per recipient
+type digestQueue struct {
+	mu      sync.Mutex
+	pending map[string][]paste
+}
+
+func newDigestQueue() *digestQueue {
+	return &digestQueue{pending: make(map[string][]paste)}
+}
+
+func (d *digestQueue) add(to string, p paste) {
+	// the digest only lists the alerts, do not keep the content in memory
+	p.Content = ""
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending[to] = append(d.pending[to], p)
+}
+
+// due removes and returns the alerts of all recipients for which the
+// quiet hours are over. With all set every pending alert is returned.
+func (d *digestQueue) due(t time.Time, all bool) map[string][]paste {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ret := make(map[string][]paste)
+	for to, pastes := range d.pending {
+		if s := recipientSchedule(to); all || s == nil || !s.quiet(t) {
+			ret[to] = pastes
+			delete(d.pending, to)
+		}
+	}
+	return ret
+}
+
+func sendDigest(ctx context.Context, config configuration, to string, pastes []paste) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", config.Mailfrom)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", fmt.Sprintf("Pastebin Digest: %d alerts during quiet hours", len(pastes)))
+	var b strings.Builder
+	for i, p := range pastes {
+		if i > 0 {
+			b.WriteString("\n----------------------------------------\n\n")
+		}
+		b.WriteString(p.alertText(config.Defang))
+	}
+	m.SetBody("text/plain", b.String())
+	return sendEmail(ctx, config, m)
+}
+
+// runDigests sends the held back alerts once the quiet hours are over. On
+// shutdown all pending digests are sent so no alert is lost.
+func runDigests(ctx context.Context, live *liveConfig, errs chan<- error) {
+	send := func(ctx context.Context, all bool) error {
+		c, _ := live.get()
+		var err error
+		for to, pastes := range digests.due(time.Now(), all) {
+			logger(componentNotifier).Info("sending digest", "to", to, "alerts", len(pastes))
+			if err2 := sendDigest(ctx, c, to, pastes); err2 != nil {
+				// try again on the next tick
+				for _, p := range pastes {
+					digests.add(to, p)
+				}
+				err = fmt.Errorf("could not send digest to %s: %v", to, err2)
+			}
+		}
+		return err
+	}
+	ticker := time.NewTicker(digestInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+			if err := send(flushCtx, true); err != nil {
+				slog.Error("could not send pending digests", "error", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := send(ctx, false); err != nil {
+				errs <- fmt.Errorf("digest: %v", err)
+			}
+		}
+	}
+}
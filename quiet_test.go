@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietSchedule(t *testing.T) {
+	s, err := parseQuietHours(quietHours{From: "22:00", To: "07:00", Timezone: "UTC", Days: []string{"Sunday"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		t        string
+		expected bool
+	}{
+		{"2026-10-14T21:59:00Z", false},
+		{"2026-10-14T22:00:00Z", true},
+		{"2026-10-15T03:00:00Z", true},
+		{"2026-10-15T07:00:00Z", false},
+		{"2026-10-15T12:00:00Z", false},
+		// sunday
+		{"2026-10-18T12:00:00Z", true},
+	}
+	for _, x := range tests {
+		d, _ := time.Parse(time.RFC3339, x.t)
+		if q := s.quiet(d); q != x.expected {
+			t.Errorf("%s: expected %v, got %v", x.t, x.expected, q)
+		}
+	}
+
+	day, err := parseQuietHours(quietHours{From: "08:00", To: "18:00", Timezone: "UTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := time.Parse(time.RFC3339, "2026-10-14T12:00:00Z")
+	if !day.quiet(d) {
+		t.Error("expected the daytime quiet hours to be quiet at noon")
+	}
+
+	for _, q := range []quietHours{{From: "25:00", To: "07:00"}, {From: "22:00"}, {Days: []string{"someday"}}, {Days: []string{"sat"}, Timezone: "Nowhere/Invalid"}} {
+		if _, err := parseQuietHours(q); err == nil {
+			t.Errorf("expected an error for %+v", q)
+		}
+	}
+	if s, err := parseQuietHours(quietHours{}); s != nil || err != nil {
+		t.Errorf("expected no schedule without quiet hours, got %v %v", s, err)
+	}
+}
+
+func TestSplitQuiet(t *testing.T) {
+	old := quietSchedules.Load()
+	defer quietSchedules.Store(old)
+	c := configuration{
+		Mailto:     "mail@example.com",
+		Quiethours: quietHours{Days: []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}, Minscore: 10},
+		Groups:     map[string]group{"soc": {Mailto: "soc@example.com"}},
+	}
+	if err := configureQuietHours(c); err != nil {
+		t.Fatal(err)
+	}
+	to := []string{"mail@example.com", "soc@example.com"}
+	send, hold := splitQuiet(to, 1, time.Now())
+	if len(send) != 1 || send[0] != "soc@example.com" || len(hold) != 1 || hold[0] != "mail@example.com" {
+		t.Fatalf("unexpected split %v %v", send, hold)
+	}
+	send, hold = splitQuiet(to, 10, time.Now())
+	if len(send) != 2 || len(hold) != 0 {
+		t.Fatalf("expected urgent alerts to be sent, got %v %v", send, hold)
+	}
+
+	d := newDigestQueue()
+	d.add("mail@example.com", paste{Key: "a", Content: "secret"})
+	d.add("other@example.com", paste{Key: "b"})
+	due := d.due(time.Now(), false)
+	if len(due) != 1 || len(due["other@example.com"]) != 1 {
+		t.Fatalf("expected only the recipient without quiet hours to be due, got %v", due)
+	}
+	due = d.due(time.Now(), true)
+	if p := due["mail@example.com"]; len(p) != 1 || p[0].Content != "" {
+		t.Fatalf("expected the held alert without content, got %v", p)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	// rdap.org redirects to the rdap server of the registry
+	defaultRDAPEndpoint = "https://rdap.org"
+	defaultRDAPRate     = 60
+	// registries throttle rdap queries, pastes listing hundreds of domains
+	// only get the first ones looked up
+	defaultRDAPLookups = 10
+	// domains registered within this time are flagged
+	defaultRDAPNewDomainAge = 30 * 24 * time.Hour
+)
+
+var (
+	rdap = &rdapClient{
+		pace:  newPacer(time.Minute/defaultRDAPRate, time.Minute/defaultRDAPRate, 0),
+		cache: newLookupCache[rdapResult](defaultEnrichCacheTTL),
+	}
+)
+
+// rdapResult is the registration of a domain
+type rdapResult struct {
+	Domain    string    `json:"domain"`
+	Registrar string    `json:"registrar,omitempty"`
+	Created   time.Time `json:"created,omitempty"`
+	// registered within the configured newdomainage
+	New bool `json:"new,omitempty"`
+}
+
+func (r rdapResult) String() string {
+	var parts []string
+	if r.Registrar != "" {
+		parts = append(parts, r.Registrar)
+	}
+	if !r.Created.IsZero() {
+		parts = append(parts, "created "+r.Created.Format("2006-01-02"))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "unknown registration")
+	}
+	s := r.Domain + ": " + strings.Join(parts, ", ")
+	if r.New {
+		s += " [newly registered]"
+	}
+	return s
+}
+
+// rdapClient looks up the registration of domains
+type rdapClient struct {
+	pace  *pacer
+	cache *lookupCache[rdapResult]
+}
+
+// registeredDomains returns the deduplicated registrable domains so
+// subdomains of the same domain are only looked up once
+func registeredDomains(domains []string) []string {
+	var ret []string
+	for _, d := range domains {
+		r, err := publicsuffix.EffectiveTLDPlusOne(d)
+		if err != nil || stringInSlice(r, ret) {
+			continue
+		}
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+// lookup returns the registrations of the domains. Cached results are
+// always returned, at most maxlookups are sent to the rdap servers.
+func (d *rdapClient) lookup(ctx context.Context, c rdapConfig, iocs *iocSummary) []rdapResult {
+	rate := c.Requestsperminute
+	if rate <= 0 {
+		rate = defaultRDAPRate
+	}
+	d.pace.configure(time.Minute/time.Duration(rate), time.Minute/time.Duration(rate), 0)
+	maxLookups := c.Maxlookups
+	if maxLookups <= 0 {
+		maxLookups = defaultRDAPLookups
+	}
+	newAge := defaultRDAPNewDomainAge
+	if c.Newdomainage != "" {
+		if a, err := time.ParseDuration(c.Newdomainage); err == nil {
+			newAge = a
+		}
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = defaultRDAPEndpoint
+	}
+	var ret []rdapResult
+	lookups := 0
+	for _, domain := range registeredDomains(iocs.Domains) {
+		r, ok := d.cache.get(domain, time.Now())
+		if !ok {
+			if lookups >= maxLookups || !d.pace.wait(ctx) {
+				continue
+			}
+			lookups++
+			metricEnrichLookups.inc("rdap")
+			var err error
+			if r, err = d.get(ctx, endpoint, domain); err != nil {
+				metricEnrichErrors.inc("rdap")
+				logger(componentNotifier).Warn("rdap lookup failed", "domain", domain, "error", err)
+				continue
+			}
+			d.cache.put(domain, r, time.Now())
+		}
+		// the age changes while the result is cached
+		r.New = !r.Created.IsZero() && time.Since(r.Created) < newAge
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+func (d *rdapClient) get(ctx context.Context, endpoint, domain string) (rdapResult, error) {
+	r := rdapResult{Domain: domain}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(endpoint, "/")+"/domain/"+url.PathEscape(domain), nil)
+	if err != nil {
+		return r, fmt.Errorf("could not create request: %v", err)
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+	resp, err := enrichClient.Do(req)
+	if err != nil {
+		return r, fmt.Errorf("could not query rdap: %v", err)
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body) // nolint: errcheck,gosec
+		return r, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var body struct {
+		Events []struct {
+			Action string    `json:"eventAction"`
+			Date   time.Time `json:"eventDate"`
+		} `json:"events"`
+		Entities []struct {
+			Roles []string          `json:"roles"`
+			Vcard []json.RawMessage `json:"vcardArray"`
+		} `json:"entities"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return r, fmt.Errorf("could not decode response: %v", err)
+	}
+	for _, e := range body.Events {
+		if e.Action == "registration" {
+			r.Created = e.Date
+		}
+	}
+	for _, e := range body.Entities {
+		if stringInSlice("registrar", e.Roles) {
+			r.Registrar = vcardName(e.Vcard)
+		}
+	}
+	return r, nil
+}
+
+// vcardName returns the formatted name of a jCard, ["vcard", [["fn", {},
+// "text", "name"], ...]]
+func vcardName(vcard []json.RawMessage) string {
+	if len(vcard) < 2 {
+		return ""
+	}
+	var props [][]interface{}
+	if err := json.Unmarshal(vcard[1], &props); err != nil {
+		return ""
+	}
+	for _, p := range props {
+		if len(p) < 4 || p[0] != "fn" {
+			continue
+		}
+		if name, ok := p[3].(string); ok {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegisteredDomains(t *testing.T) {
+	got := registeredDomains([]string{"www.example.com", "mail.example.com", "shop.example.co.uk", "localhost"})
+	if expected := []string{"example.com", "example.co.uk"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestRDAPLookup(t *testing.T) {
+	created := time.Now().Add(-48 * time.Hour).UTC().Truncate(time.Second)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/domain/example.com":
+			w.Write([]byte(`{"events":[{"eventAction":"registration","eventDate":"1995-08-14T04:00:00Z"}],` + // nolint: errcheck
+				`"entities":[{"roles":["registrar"],"vcardArray":["vcard",[["version",{},"text","4.0"],["fn",{},"text","Example Registrar, Inc."]]]}]}`))
+		case "/domain/fresh.org":
+			w.Write([]byte(`{"events":[{"eventAction":"registration","eventDate":"` + created.Format(time.RFC3339) + `"}]}`)) // nolint: errcheck
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	d := &rdapClient{pace: newPacer(0, 0, 0), cache: newLookupCache[rdapResult](time.Hour)}
+	iocs := &iocSummary{Domains: []string{"www.example.com", "fresh.org", "missing.net"}}
+	got := d.lookup(context.Background(), rdapConfig{Enabled: true, Endpoint: ts.URL, Requestsperminute: 6000}, iocs)
+	expected := []rdapResult{
+		{Domain: "example.com", Registrar: "Example Registrar, Inc.", Created: time.Date(1995, 8, 14, 4, 0, 0, 0, time.UTC)},
+		{Domain: "fresh.org", Created: created, New: true},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected results:\n%+v\nexpected\n%+v", got, expected)
+	}
+	if s := got[0].String(); s != "example.com: Example Registrar, Inc., created 1995-08-14" {
+		t.Errorf("unexpected formatting %q", s)
+	}
+	if s := got[1].String(); s != "fresh.org: created "+created.Format("2006-01-02")+" [newly registered]" {
+		t.Errorf("unexpected formatting %q", s)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRedisPrefix = "pastebin_scraper:"
+	// how long a paste stays claimed. Matches the expiry of the local state.
+	redisCheckedTTL = 10 * time.Minute
+	// how long a pop from the work queue blocks
+	redisPopTimeout = 5 * time.Second
+)
+
+// openRedis connects to the configured redis server
+func openRedis(c redisConfig) (*redis.Client, error) {
+	opts := &redis.Options{
+		Addr:     c.Address,
+		Username: c.Username,
+		Password: c.Password,
+		DB:       c.DB,
+	}
+	if c.TLS {
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close() // nolint: errcheck,gosec
+		return nil, fmt.Errorf("could not connect to redis %s: %v", c.Address, err)
+	}
+	return client, nil
+}
+
+// redisState shares the checked pastes and content hashes of all
+// instances. Checking a paste atomically claims it so only one instance
+// processes it. Entries expire in redis so expiring is a no-op.
+type redisState struct {
+	stateStore
+	client      *redis.Client
+	prefix      string
+	dedupWindow time.Duration
+}
+
+func newRedisState(inner stateStore, client *redis.Client, prefix string, dedupWindow time.Duration) *redisState {
+	if prefix == "" {
+		prefix = defaultRedisPrefix
+	}
+	return &redisState{stateStore: inner, client: client, prefix: prefix, dedupWindow: dedupWindow}
+}
+
+// checked claims the paste and returns true if another instance already
+// claimed it
+func (s *redisState) checked(key string) (bool, error) {
+	ok, err := s.client.SetNX(context.Background(), s.prefix+"checked:"+key, time.Now().Unix(), redisCheckedTTL).Result()
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+// setChecked is a no-op as the paste is claimed on checking
+func (s *redisState) setChecked(key string, t time.Time) error {
+	return nil
+}
+
+func (s *redisState) unsetChecked(key string) error {
+	return s.client.Del(context.Background(), s.prefix+"checked:"+key).Err()
+}
+
+func (s *redisState) expireChecked(before time.Time) (int, error) {
+	return 0, nil
+}
+
+func (s *redisState) seenContent(hash string, t time.Time) (bool, error) {
+	ok, err := s.client.SetNX(context.Background(), s.prefix+"content:"+hash, t.Unix(), s.dedupWindow).Result()
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+func (s *redisState) expireContent(before time.Time) (int, error) {
+	return 0, nil
+}
+
+func (s *redisState) close() error {
+	err := s.stateStore.close()
+	if err2 := s.client.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// redisQueue distributes the pastes to fetch between all instances. The
+// paste list is only fetched by the instance holding the list lock.
+type redisQueue struct {
+	client *redis.Client
+	prefix string
+	// identifies this instance as holder of the list lock
+	id string
+}
+
+func newRedisQueue(client *redis.Client, prefix, id string) *redisQueue {
+	if prefix == "" {
+		prefix = defaultRedisPrefix
+	}
+	return &redisQueue{client: client, prefix: prefix, id: id}
+}
+
+// lockList returns true if this instance should fetch the paste list
+// now. The lock expires after the interval so the next list is fetched
+// by whichever instance gets it first.
+func (q *redisQueue) lockList(ctx context.Context, interval time.Duration) (bool, error) {
+	return q.client.SetNX(ctx, q.prefix+"listlock", q.id, interval).Result()
+}
+
+func (q *redisQueue) push(ctx context.Context, pastes []paste) error {
+	if len(pastes) == 0 {
+		return nil
+	}
+	values := make([]interface{}, 0, len(pastes))
+	for _, p := range pastes {
+		b, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		values = append(values, b)
+	}
+	return q.client.RPush(ctx, q.prefix+"queue", values...).Err()
+}
+
+// pop waits for the next paste in the queue. It returns nil if the queue
+// stayed empty.
+func (q *redisQueue) pop(ctx context.Context) (*paste, error) {
+	res, err := q.client.BLPop(ctx, redisPopTimeout, q.prefix+"queue").Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p paste
+	if err := json.Unmarshal([]byte(res[1]), &p); err != nil {
+		return nil, fmt.Errorf("invalid paste in queue: %v", err)
+	}
+	return &p, nil
+}
+
+func (q *redisQueue) length(ctx context.Context) (int64, error) {
+	return q.client.LLen(ctx, q.prefix+"queue").Result()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	return miniredis.RunT(t)
+}
+
+func TestRedisState(t *testing.T) {
+	mr := newTestRedis(t)
+	var states []*redisState
+	for i := 0; i < 2; i++ {
+		client, err := openRedis(redisConfig{Address: mr.Addr()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		inner, err := loadFileState("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := newRedisState(inner, client, "", time.Hour)
+		defer s.close() // nolint: errcheck
+		states = append(states, s)
+	}
+
+	// the first instance claims the paste
+	if checked, err := states[0].checked("abc"); err != nil || checked {
+		t.Fatalf("expected unchecked paste, got %v %v", checked, err)
+	}
+	if checked, err := states[1].checked("abc"); err != nil || !checked {
+		t.Fatalf("expected paste to be claimed by the other instance, got %v %v", checked, err)
+	}
+	if err := states[0].unsetChecked("abc"); err != nil {
+		t.Fatal(err)
+	}
+	if checked, err := states[1].checked("abc"); err != nil || checked {
+		t.Fatalf("expected paste to be released, got %v %v", checked, err)
+	}
+
+	if seen, err := states[0].seenContent("hash", time.Now()); err != nil || seen {
+		t.Fatalf("expected unseen content, got %v %v", seen, err)
+	}
+	if seen, err := states[1].seenContent("hash", time.Now()); err != nil || !seen {
+		t.Fatalf("expected content to be seen by the other instance, got %v %v", seen, err)
+	}
+	mr.FastForward(2 * time.Hour)
+	if seen, err := states[1].seenContent("hash", time.Now()); err != nil || seen {
+		t.Fatalf("expected content to expire after the dedup window, got %v %v", seen, err)
+	}
+}
+
+func TestRedisQueue(t *testing.T) {
+	mr := newTestRedis(t)
+	client, err := openRedis(redisConfig{Address: mr.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close() // nolint: errcheck
+	ctx := context.Background()
+	q1 := newRedisQueue(client, "test:", "one")
+	q2 := newRedisQueue(client, "test:", "two")
+
+	if locked, err := q1.lockList(ctx, time.Minute); err != nil || !locked {
+		t.Fatalf("expected the first instance to get the lock, got %v %v", locked, err)
+	}
+	if locked, err := q2.lockList(ctx, time.Minute); err != nil || locked {
+		t.Fatalf("expected the lock to be held, got %v %v", locked, err)
+	}
+	mr.FastForward(time.Minute)
+	if locked, err := q2.lockList(ctx, time.Minute); err != nil || !locked {
+		t.Fatalf("expected the lock to expire, got %v %v", locked, err)
+	}
+
+	if err := q1.push(ctx, []paste{{Key: "a", ScrapeURL: "https://example.com/a"}, {Key: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := q2.length(ctx); err != nil || n != 2 {
+		t.Fatalf("expected 2 queued pastes, got %d %v", n, err)
+	}
+	p, err := q2.pop(ctx)
+	if err != nil || p == nil || p.Key != "a" || p.ScrapeURL != "https://example.com/a" {
+		t.Fatalf("unexpected paste %+v %v", p, err)
+	}
+	if p, err := q1.pop(ctx); err != nil || p == nil || p.Key != "b" {
+		t.Fatalf("unexpected paste %+v %v", p, err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FireFart/pastebin_scraper/scraper"
+)
+
+// newMatcher compiles all rules of the config
+func newMatcher(c configuration) (*matcher, error) {
+	detectors, err := parseDetectors(c.Detectors)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse detectors: %v", err)
+	}
+	detectors = append(detectors, pluginDetectors...)
+	m, err := compileRules(c, detectors)
+	if err != nil {
+		return nil, err
+	}
+	tenants, err := newTenantMatchers(c)
+	if err != nil {
+		return nil, err
+	}
+	return &matcher{Matcher: m, tenants: tenants}, nil
+}
+
+// compileRules compiles the keywords, cidrs and the blocklist of the
+// config. Snoozed keywords are dropped before the threshold is applied.
+func compileRules(c configuration, detectors []detector) (*scraper.Matcher, error) {
+	return scraper.Compile(scraper.Rules{
+		Keywords:       c.Keywords,
+		CIDRs:          c.CIDRs,
+		Blocklist:      c.Blocklist,
+		Threshold:      c.Threshold,
+		Normalize:      c.Normalize,
+		FoldHomoglyphs: c.FoldHomoglyphs,
+		Detectors:      detectors,
+		Filter: func(found map[string][]string) {
+			snoozes.filter(found, time.Now())
+		},
+	})
+}
+
+// liveConfig holds the current config and the compiled rules so they can
+// be replaced on reload while the scraper is running
+type liveConfig struct {
+	mu      sync.RWMutex
+	file    string
+	config  configuration
+	matcher *matcher
+}
+
+func newLiveConfig(file string, c configuration) (*liveConfig, error) {
+	m, err := newMatcher(c)
+	if err != nil {
+		return nil, err
+	}
+	return &liveConfig{file: file, config: c, matcher: m}, nil
+}
+
+func (l *liveConfig) get() (configuration, *matcher) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.config, l.matcher
+}
+
+// reload reads the config file again and replaces the rules and the
+// notification settings. The old config stays active on errors. Storage
+// settings like the database, archives and the state are only read on
+// startup.
+func (l *liveConfig) reload() (configuration, error) {
+	c, err := getConfig(l.file)
+	if err != nil {
+		return configuration{}, fmt.Errorf("could not read config file %s: %v", l.file, err)
+	}
+	m, err := newMatcher(*c)
+	if err != nil {
+		return configuration{}, err
+	}
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return configuration{}, fmt.Errorf("invalid value for timeout %q: %v", c.Timeout, err)
+	}
+	if err := configureQuietHours(*c); err != nil {
+		return configuration{}, err
+	}
+	if err := configurePace(*c); err != nil {
+		return configuration{}, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config = *c
+	l.matcher = m
+	client.Timeout = timeout
+	sourceHeaders.set(c.Headers, c.Useragents)
+	atomic.StoreInt64(&maxPasteSize, c.Maxpastesize)
+	return *c, nil
+}
+
+// checkAddedKeywords compares the keywords with the previous run or
+// config and retro-scans the archive if keywords were added
+func checkAddedKeywords(ctx context.Context, wg *sync.WaitGroup, c configuration, m *matcher, st stateStore, out chan<- paste, errs chan<- error) error {
+	current := keywordNames(c.Keywords)
+	previous, err := st.keywords()
+	if err != nil {
+		return fmt.Errorf("could not read keywords from state: %v", err)
+	}
+	added := addedKeywords(previous, current)
+	if previous == nil || len(added) == 0 || c.Retroscan.Days <= 0 || c.Archive.Local.Directory == "" {
+		return st.setKeywords(current)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		since := time.Now().AddDate(0, 0, -c.Retroscan.Days)
+		slog.Info("checking archive for added keywords", "since", since, "keywords", added)
+		n, err := retroScan(ctx, c.Archive.Local.Directory, since, m, added, func(p paste) {
+			select {
+			case out <- p:
+			case <-ctx.Done():
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			sendError(ctx, errs, fmt.Errorf("retroScan: %v", err))
+			return
+		}
+		slog.Info("finished checking archive for added keywords", "found", n)
+		if err := st.setKeywords(current); err != nil {
+			sendError(ctx, errs, fmt.Errorf("setKeywords: %v", err))
+		}
+	}()
+	return nil
+}
+
+// sendError hands the error to the notifier unless the scraper is shutting
+// down and nobody reads it anymore
+func sendError(ctx context.Context, errs chan<- error, err error) {
+	select {
+	case errs <- err:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLiveConfigReload(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "config.json")
+	write := func(content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(f, []byte(content), 0600); err != nil {
+			t.Fatalf("could not write config: %v", err)
+		}
+	}
+	write(`{"timeout": "10s", "mailto": "old@example.com", "keywords": [{"keyword": "old"}]}`)
+	c, err := getConfig(f)
+	if err != nil {
+		t.Fatalf("could not read config: %v", err)
+	}
+	live, err := newLiveConfig(f, *c)
+	if err != nil {
+		t.Fatalf("could not create live config: %v", err)
+	}
+
+	write(`{"timeout": "10s", "mailto": "new@example.com", "keywords": [{"keyword": "old"}, {"keyword": "new"}]}`)
+	if _, err := live.reload(); err != nil {
+		t.Fatalf("could not reload: %v", err)
+	}
+	c2, m := live.get()
+	if c2.Mailto != "new@example.com" {
+		t.Fatalf("notification settings were not reloaded: %q", c2.Mailto)
+	}
+	if found, _ := m.Scan("this is new"); !found {
+		t.Fatal("added keyword was not compiled")
+	}
+
+	// invalid configs keep the current one
+	write(`{"timeout": "10s", "keywords": [{"keyword": "(", "boundary": "invalid"}]}`)
+	if _, err := live.reload(); err == nil {
+		t.Fatal("expected error on invalid keywords")
+	}
+	if c3, _ := live.get(); c3.Mailto != "new@example.com" {
+		t.Fatal("config was replaced by an invalid one")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// replayStats holds the timings of a replay to benchmark the rules
+type replayStats struct {
+	pastes   int
+	bytes    int
+	matches  int
+	notified int
+	failed   int
+	// time spent matching all rules
+	total   time.Duration
+	slowest time.Duration
+	// time spent per detector
+	detectors map[string]time.Duration
+}
+
+// replayArchive feeds the pastes of the local archive since the given
+// time through the matcher. fn is called for all matching pastes.
+func replayArchive(ctx context.Context, dir string, since time.Time, m *matcher, fn func(p paste)) (replayStats, error) {
+	s := replayStats{detectors: make(map[string]time.Duration)}
+	err := walkLocalArchive(dir, since, func(p paste) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.pastes++
+		s.bytes += len(p.Content)
+		p.Matches = nil
+		p.Groups = nil
+		start := time.Now()
+		p.scan(m)
+		d := time.Since(start)
+		s.total += d
+		if d > s.slowest {
+			s.slowest = d
+		}
+		// detectors are timed on their own as they are the most expensive rules
+		for _, x := range m.Detectors() {
+			start := time.Now()
+			checkDetectors(p.Content, []detector{x})
+			s.detectors[x.Name] += time.Since(start)
+		}
+		if len(p.Matches) > 0 {
+			s.matches++
+			fn(p)
+		}
+		return nil
+	})
+	return s, err
+}
+
+func (s replayStats) print(w io.Writer) {
+	fmt.Fprintf(w, "replayed %d pastes (%d bytes), %d matched", s.pastes, s.bytes, s.matches)
+	if s.notified > 0 || s.failed > 0 {
+		fmt.Fprintf(w, ", %d notified, %d failed", s.notified, s.failed)
+	}
+	fmt.Fprintln(w)
+	if s.pastes == 0 {
+		return
+	}
+	fmt.Fprintf(w, "matching took %s, %s per paste on average, slowest %s\n",
+		s.total, s.total/time.Duration(s.pastes), s.slowest)
+	names := make([]string, 0, len(s.detectors))
+	for k := range s.detectors {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		fmt.Fprintf(w, "detector %s took %s, %s per paste on average\n", k, s.detectors[k], s.detectors[k]/time.Duration(s.pastes))
+	}
+}
+
+// runReplay implements the replay subcommand. Pastes from the local
+// archive are matched against the current config and only printed unless
+// notifications are enabled.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	configFile := fs.String("config", "", "Config File to use")
+	since := fs.String("since", "", "only replay pastes from this date on (YYYY-MM-DD)")
+	notify := fs.Bool("notify", false, "send notifications for matches instead of only printing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := getConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("could not read config file %s: %v", *configFile, err)
+	}
+	if config.Archive.Local.Directory == "" {
+		return fmt.Errorf("replay needs a local archive")
+	}
+	from, err := parseSearchDate(*since)
+	if err != nil {
+		return fmt.Errorf("invalid since date %q: %v", *since, err)
+	}
+	m, err := newMatcher(*config)
+	if err != nil {
+		return err
+	}
+	if config.Timeout != "" {
+		if client.Timeout, err = time.ParseDuration(config.Timeout); err != nil {
+			return fmt.Errorf("invalid value for timeout %q: %v", config.Timeout, err)
+		}
+	}
+
+	var notified, failed int
+	s, err := replayArchive(context.Background(), config.Archive.Local.Directory, from, m, func(p paste) {
+		fmt.Printf("%s\t%s\t%s\t%s\n", p.Key, dateToString(p.Date), strings.Join(sortedKeys(p.Matches), ", "), strings.Join(p.Groups, ", "))
+		if !*notify {
+			return
+		}
+		if err := p.sendPasteMessage(context.Background(), *config); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "could not send notification for %s: %v\n", p.Key, err)
+			return
+		}
+		notified++
+	})
+	if err != nil {
+		return fmt.Errorf("could not replay archive: %v", err)
+	}
+	s.notified, s.failed = notified, failed
+	s.print(os.Stderr)
+	return nil
+}
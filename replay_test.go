@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FireFart/pastebin_scraper/scraper"
+)
+
+func TestReplayArchive(t *testing.T) {
+	dir := t.TempDir()
+	l, err := newLocalArchiver(local{Directory: dir})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+	for _, p := range []paste{
+		{Key: "old", Date: strconv.FormatInt(now.Add(-72*time.Hour).Unix(), 10), Content: "password=old"},
+		{Key: "match", Date: strconv.FormatInt(now.Add(-1*time.Hour).Unix(), 10), Content: "password=hunter2"},
+		{Key: "nomatch", Date: strconv.FormatInt(now.Add(-1*time.Hour).Unix(), 10), Content: "nothing to see"},
+	} {
+		if _, err := l.archive(ctx, p); err != nil {
+			t.Fatalf("could not archive: %v", err)
+		}
+	}
+
+	detectors, err := parseDetectors([]string{"sqldump"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := mustMatcher(t, scraper.Rules{Keywords: []keyword{{Keyword: "password"}}, Detectors: detectors})
+	var found []string
+	s, err := replayArchive(ctx, dir, now.Add(-24*time.Hour), m, func(p paste) {
+		found = append(found, p.Key)
+	})
+	if err != nil {
+		t.Fatalf("could not replay: %v", err)
+	}
+	if s.pastes != 2 || s.matches != 1 || len(found) != 1 || found[0] != "match" {
+		t.Fatalf("unexpected results: %+v %v", s, found)
+	}
+	if _, ok := s.detectors["database dump"]; !ok {
+		t.Fatalf("expected timings of the detector, got %v", s.detectors)
+	}
+
+	var b bytes.Buffer
+	s.print(&b)
+	if !strings.Contains(b.String(), "replayed 2 pastes") || !strings.Contains(b.String(), "detector database dump took") {
+		t.Fatalf("unexpected summary: %s", b.String())
+	}
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/template"
+	"time"
+
+	gomail "gopkg.in/gomail.v2"
+)
+
+const (
+	// days kept in the report history
+	reportHistoryDays = 120
+	// pastes listed in the daily report
+	reportTopPastes = 10
+	reportDayFormat = "2006-01-02"
+	// how often the history file is written between two reports
+	reportSaveInterval = time.Hour
+)
+
+var (
+	// per day counters for the reports
+	reports = newReportHistory(time.Local)
+)
+
+type reportPaste struct {
+	Key      string   `json:"key"`
+	URL      string   `json:"url"`
+	Title    string   `json:"title,omitempty"`
+	Keywords []string `json:"keywords"`
+	Score    int      `json:"score"`
+}
+
+type reportDay struct {
+	Scanned int            `json:"scanned"`
+	Matches int            `json:"matches"`
+	Hits    map[string]int `json:"hits"`
+	// highest scored pastes of the day
+	Top []reportPaste `json:"top"`
+}
+
+// reportHistory keeps the counters of the last days in the timezone of
+// the reports. It is written to the history file so the trends survive
+// restarts.
+type reportHistory struct {
+	mu   sync.Mutex
+	loc  *time.Location
+	Days map[string]*reportDay `json:"days"`
+}
+
+func newReportHistory(loc *time.Location) *reportHistory {
+	return &reportHistory{loc: loc, Days: make(map[string]*reportDay)}
+}
+
+// day returns the counters of the day of t, the lock must be held
+func (h *reportHistory) day(t time.Time) *reportDay {
+	k := t.In(h.loc).Format(reportDayFormat)
+	d, ok := h.Days[k]
+	if !ok {
+		d = &reportDay{Hits: make(map[string]int)}
+		h.Days[k] = d
+		h.expire(t)
+	}
+	return d
+}
+
+func (h *reportHistory) expire(now time.Time) {
+	oldest := now.In(h.loc).AddDate(0, 0, -reportHistoryDays).Format(reportDayFormat)
+	for k := range h.Days {
+		if k < oldest {
+			delete(h.Days, k)
+		}
+	}
+}
+
+func (h *reportHistory) addScanned(t time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.day(t).Scanned++
+}
+
+func (h *reportHistory) addMatch(p paste, score int, t time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	d := h.day(t)
+	d.Matches++
+	keywords := getKeysFromMap(p.Matches)
+	sort.Strings(keywords)
+	for _, k := range keywords {
+		d.Hits[k]++
+	}
+	d.Top = append(d.Top, reportPaste{Key: p.Key, URL: p.FullURL, Title: p.Title, Keywords: keywords, Score: score})
+	sort.SliceStable(d.Top, func(i, j int) bool {
+		if d.Top[i].Score != d.Top[j].Score {
+			return d.Top[i].Score > d.Top[j].Score
+		}
+		return len(d.Top[i].Keywords) > len(d.Top[j].Keywords)
+	})
+	if len(d.Top) > reportTopPastes {
+		d.Top = d.Top[:reportTopPastes]
+	}
+}
+
+// get returns a copy of the counters of the day, empty if nothing was
+// recorded
+func (h *reportHistory) get(day time.Time) reportDay {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	d, ok := h.Days[day.In(h.loc).Format(reportDayFormat)]
+	if !ok {
+		return reportDay{Hits: map[string]int{}}
+	}
+	c := *d
+	c.Hits = make(map[string]int, len(d.Hits))
+	for k, v := range d.Hits {
+		c.Hits[k] = v
+	}
+	c.Top = append([]reportPaste(nil), d.Top...)
+	return c
+}
+
+func (h *reportHistory) load(f string) error {
+	b, err := ioutil.ReadFile(f)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read report history: %v", err)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := json.Unmarshal(b, h); err != nil {
+		return fmt.Errorf("could not parse report history %s: %v", f, err)
+	}
+	if h.Days == nil {
+		h.Days = make(map[string]*reportDay)
+	}
+	return nil
+}
+
+func (h *reportHistory) save(f string) error {
+	h.mu.Lock()
+	b, err := json.Marshal(h)
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := f + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return fmt.Errorf("could not write report history: %v", err)
+	}
+	return os.Rename(tmp, f)
+}
+
+type reportKeyword struct {
+	Keyword string
+	Hits    int
+	// change to the previous day
+	Change int
+	// average of the seven days before
+	Average float64
+}
+
+// dailyReport summarizes one day
+type dailyReport struct {
+	Day      time.Time
+	Scanned  int
+	Matches  int
+	Change   int
+	Keywords []reportKeyword
+	Top      []reportPaste
+}
+
+// buildDailyReport compares the day with the day before and the average
+// of the week before
+func buildDailyReport(h *reportHistory, day time.Time) dailyReport {
+	d := h.get(day)
+	prev := h.get(day.AddDate(0, 0, -1))
+	week := make([]reportDay, 0, 7)
+	for i := 1; i <= 7; i++ {
+		week = append(week, h.get(day.AddDate(0, 0, -i)))
+	}
+	r := dailyReport{Day: day, Scanned: d.Scanned, Matches: d.Matches, Change: d.Matches - prev.Matches, Top: d.Top}
+	keywords := map[string]bool{}
+	for k := range d.Hits {
+		keywords[k] = true
+	}
+	for k := range prev.Hits {
+		keywords[k] = true
+	}
+	for k := range keywords {
+		sum := 0
+		for _, w := range week {
+			sum += w.Hits[k]
+		}
+		r.Keywords = append(r.Keywords, reportKeyword{Keyword: k, Hits: d.Hits[k], Change: d.Hits[k] - prev.Hits[k], Average: float64(sum) / 7})
+	}
+	sort.Slice(r.Keywords, func(i, j int) bool {
+		if r.Keywords[i].Hits != r.Keywords[j].Hits {
+			return r.Keywords[i].Hits > r.Keywords[j].Hits
+		}
+		return r.Keywords[i].Keyword < r.Keywords[j].Keyword
+	})
+	return r
+}
+
+var reportFuncs = map[string]interface{}{
+	"signed": func(i int) string { return fmt.Sprintf("%+d", i) },
+	"date":   reportDate,
+	"spark":  sparkline,
+}
+
+var markdownReport = template.Must(template.New("report").Funcs(reportFuncs).Parse(`# Pastebin Scraper report for {{date .Day}}
+
+- Pastes scanned: {{.Scanned}}
+- Matches: {{.Matches}} ({{signed .Change}} to the day before)
+
+## Matches per keyword
+{{if .Keywords}}
+| Keyword | Matches | Change | 7 day average |
+|---|---:|---:|---:|
+{{range .Keywords}}| {{.Keyword}} | {{.Hits}} | {{signed .Change}} | {{printf "%.1f" .Average}} |
+{{end}}{{else}}
+No matches.
+{{end}}
+## Top pastes
+{{if .Top}}
+{{range .Top}}- [{{if .Title}}{{.Title}}{{else}}{{.Key}}{{end}}]({{.URL}}) score {{.Score}}: {{range $i, $k := .Keywords}}{{if $i}}, {{end}}{{$k}}{{end}}
+{{end}}{{else}}
+No matches.
+{{end}}`))
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("report").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Pastebin Scraper report for {{date .Day}}</title></head>
+<body style="font-family: sans-serif">
+<h1>Pastebin Scraper report for {{date .Day}}</h1>
+<p>Pastes scanned: {{.Scanned}}<br>Matches: {{.Matches}} ({{signed .Change}} to the day before)</p>
+<h2>Matches per keyword</h2>
+{{if .Keywords}}<table border="1" cellpadding="4" style="border-collapse: collapse">
+<tr><th>Keyword</th><th>Matches</th><th>Change</th><th>7 day average</th></tr>
+{{range .Keywords}}<tr><td>{{.Keyword}}</td><td align="right">{{.Hits}}</td><td align="right">{{signed .Change}}</td><td align="right">{{printf "%.1f" .Average}}</td></tr>
+{{end}}</table>{{else}}<p>No matches.</p>{{end}}
+<h2>Top pastes</h2>
+{{if .Top}}<ol>
+{{range .Top}}<li><a href="{{.URL}}">{{if .Title}}{{.Title}}{{else}}{{.Key}}{{end}}</a> score {{.Score}}: {{range $i, $k := .Keywords}}{{if $i}}, {{end}}{{$k}}{{end}}</li>
+{{end}}</ol>{{else}}<p>No matches.</p>{{end}}
+</body>
+</html>
+`))
+
+// render formats the report as markdown or html
+func (r dailyReport) render(format string) (string, error) {
+	return renderReport(format, markdownReport, htmlReport, r)
+}
+
+func renderReport(format string, md *template.Template, html *htmltemplate.Template, data interface{}) (string, error) {
+	var b bytes.Buffer
+	var err error
+	if format == "html" {
+		err = html.Execute(&b, data)
+	} else {
+		err = md.Execute(&b, data)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not render report: %v", err)
+	}
+	return b.String(), nil
+}
+
+// deliverReport writes the report to the directory and mails it
+func deliverReport(ctx context.Context, config configuration, c reportConfig, name, subject, body string) error {
+	ext := ".md"
+	if c.Format == "html" {
+		ext = ".html"
+	}
+	if c.Directory != "" {
+		if err := ioutil.WriteFile(filepath.Join(c.Directory, name+ext), []byte(body), 0600); err != nil {
+			return fmt.Errorf("could not write report: %v", err)
+		}
+	}
+	if !c.Mail {
+		return nil
+	}
+	to := c.Mailto
+	if to == "" {
+		to = config.Mailto
+	}
+	m := gomail.NewMessage()
+	m.SetHeader("From", config.Mailfrom)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	if c.Format == "html" {
+		m.SetBody("text/html", body)
+	} else {
+		m.SetBody("text/plain", body)
+	}
+	return sendEmail(ctx, config, m)
+}
+
+// reportLocation returns the timezone the days of the reports are in
+func reportLocation(c reportConfig) (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report.timezone %q: %v", c.Timezone, err)
+	}
+	return loc, nil
+}
+
+// nextMidnight returns the start of the next day in the location
+func nextMidnight(now time.Time, loc *time.Location) time.Time {
+	n := now.In(loc)
+	return time.Date(n.Year(), n.Month(), n.Day()+1, 0, 0, 0, 0, loc)
+}
+
+// runReports sends the report of the previous day after every midnight,
+// the weekly report on mondays and keeps the history file up to date
+func runReports(ctx context.Context, c reportConfig, live *liveConfig, errs chan<- error) {
+	save := func() {
+		if c.Historyfile == "" {
+			return
+		}
+		if err := reports.save(c.Historyfile); err != nil {
+			errs <- fmt.Errorf("report: %v", err)
+		}
+	}
+	// nobody reads the errors anymore on shutdown
+	defer func() {
+		if c.Historyfile == "" {
+			return
+		}
+		if err := reports.save(c.Historyfile); err != nil {
+			slog.Error("could not save report history", "error", err)
+		}
+	}()
+	for {
+		midnight := nextMidnight(time.Now(), reports.loc)
+		wake := midnight
+		if t := time.Now().Add(reportSaveInterval); t.Before(wake) {
+			wake = t
+		}
+		if !sleep(ctx, time.Until(wake)) {
+			return
+		}
+		save()
+		if time.Now().Before(midnight) {
+			continue
+		}
+		config, _ := live.get()
+		if c.Daily {
+			day := midnight.AddDate(0, 0, -1)
+			r := buildDailyReport(reports, day)
+			body, err := r.render(c.Format)
+			if err != nil {
+				errs <- fmt.Errorf("report: %v", err)
+			} else {
+				slog.Info("daily report", "day", reportDate(day), "matches", r.Matches, "scanned", r.Scanned)
+				subject := fmt.Sprintf("Pastebin Scraper report for %s: %d matches", reportDate(day), r.Matches)
+				if err := deliverReport(ctx, config, c, "report-"+reportDate(day), subject, body); err != nil {
+					errs <- fmt.Errorf("could not deliver daily report: %v", err)
+				}
+			}
+		}
+		if c.Weekly && midnight.Weekday() == time.Monday {
+			r := buildWeeklyReport(reports, midnight)
+			body, err := r.render(c.Format)
+			if err != nil {
+				errs <- fmt.Errorf("report: %v", err)
+				continue
+			}
+			slog.Info("weekly report", "week", reportDate(r.Start), "matches", r.Matches, "scanned", r.Scanned)
+			subject := fmt.Sprintf("Pastebin Scraper weekly trends %s to %s: %d matches", reportDate(r.Start), reportDate(r.End), r.Matches)
+			if err := deliverReport(ctx, config, c, "weekly-"+reportDate(r.Start), subject, body); err != nil {
+				errs <- fmt.Errorf("could not deliver weekly report: %v", err)
+			}
+		}
+	}
+}
+
+func reportDate(t time.Time) string {
+	return t.Format(reportDayFormat)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDailyReport(t *testing.T) {
+	day := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	h := newReportHistory(time.UTC)
+	for i := 0; i < 3; i++ {
+		h.addScanned(day)
+	}
+	h.addMatch(paste{Key: "low", FullURL: "https://pastebin.com/low", Matches: map[string][]string{"admin": {}}}, 1, day)
+	h.addMatch(paste{Key: "high", FullURL: "https://pastebin.com/high", Title: "dump", Matches: map[string][]string{"password": {}, "admin": {}}}, 5, day)
+	h.addMatch(paste{Key: "before", Matches: map[string][]string{"password": {}}}, 1, day.AddDate(0, 0, -1))
+	h.addMatch(paste{Key: "before2", Matches: map[string][]string{"password": {}}}, 1, day.AddDate(0, 0, -1))
+	h.addMatch(paste{Key: "old", Matches: map[string][]string{"token": {}}}, 1, day.AddDate(0, 0, -3))
+
+	r := buildDailyReport(h, day)
+	if r.Scanned != 3 || r.Matches != 2 || r.Change != 0 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	if len(r.Top) != 2 || r.Top[0].Key != "high" {
+		t.Fatalf("expected the highest scored paste first, got %+v", r.Top)
+	}
+	want := []reportKeyword{
+		{Keyword: "admin", Hits: 2, Change: 2, Average: 0},
+		{Keyword: "password", Hits: 1, Change: -1, Average: 2.0 / 7},
+	}
+	if len(r.Keywords) != len(want) {
+		t.Fatalf("unexpected keywords %+v", r.Keywords)
+	}
+	for i, k := range want {
+		if r.Keywords[i] != k {
+			t.Errorf("expected %+v, got %+v", k, r.Keywords[i])
+		}
+	}
+
+	md, err := r.render("markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"report for 2026-10-14", "| admin | 2 | +2 | 0.0 |", "[dump](https://pastebin.com/high) score 5: admin, password"} {
+		if !strings.Contains(md, s) {
+			t.Errorf("expected %q in %s", s, md)
+		}
+	}
+	html, err := r.render("html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html, `<a href="https://pastebin.com/high">dump</a>`) {
+		t.Errorf("unexpected html report %s", html)
+	}
+}
+
+func TestReportHistoryFile(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "reports.json")
+	now := time.Now()
+	h := newReportHistory(time.UTC)
+	h.addMatch(paste{Key: "b", Matches: map[string][]string{"admin": {}}}, 1, now.AddDate(0, 0, -reportHistoryDays-1))
+	h.addMatch(paste{Key: "a", Matches: map[string][]string{"admin": {}}}, 1, now)
+	if err := h.save(f); err != nil {
+		t.Fatal(err)
+	}
+	loaded := newReportHistory(time.UTC)
+	if err := loaded.load(f); err != nil {
+		t.Fatal(err)
+	}
+	if d := loaded.get(now); d.Matches != 1 || d.Hits["admin"] != 1 {
+		t.Fatalf("unexpected loaded day %+v", d)
+	}
+	if len(loaded.Days) != 1 {
+		t.Errorf("expected the old day to be expired, got %d days", len(loaded.Days))
+	}
+	if err := newReportHistory(time.UTC).load(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("expected a missing file to be ignored, got %v", err)
+	}
+}
+
+func TestNextMidnight(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	now := time.Date(2026, 10, 14, 23, 30, 0, 0, time.UTC)
+	if n := nextMidnight(now, loc); !n.Equal(time.Date(2026, 10, 16, 0, 0, 0, 0, loc)) {
+		t.Errorf("unexpected next midnight %v", n)
+	}
+}
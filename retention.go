@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/FireFart/pastebin_scraper/store"
+)
+
+const (
+	defaultRetentionInterval = 1 * time.Hour
+)
+
+// retentionPolicy deletes everything exceeding the configured retention
+// and returns the number of deleted items
+type retentionPolicy struct {
+	name  string
+	prune func(ctx context.Context, now time.Time) (int, error)
+}
+
+// setupRetention collects the policies of all configured stores
+func setupRetention(c retention, db store.Store, archivers []archiver) ([]retentionPolicy, error) {
+	var policies []retentionPolicy
+	if c.Matches != "" && db != nil {
+		maxAge, err := time.ParseDuration(c.Matches)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retention for matches %q: %v", c.Matches, err)
+		}
+		policies = append(policies, retentionPolicy{
+			name: "matches",
+			prune: func(ctx context.Context, now time.Time) (int, error) {
+				return db.PruneMatches(ctx, now.Add(-maxAge))
+			},
+		})
+	}
+	for _, a := range archivers {
+		// cloud storage should use the lifecycle rules of the provider
+		if l, ok := a.(*localArchiver); ok {
+			policies = append(policies, retentionPolicy{name: "archive", prune: l.prune})
+		}
+	}
+	return policies, nil
+}
+
+// runRetention applies all policies once and records the deleted items
+func runRetention(ctx context.Context, policies []retentionPolicy, errs chan<- error) {
+	for _, p := range policies {
+		n, err := p.prune(ctx, time.Now())
+		if err != nil {
+			errs <- fmt.Errorf("retention %s: %v", p.name, err)
+		}
+		if n > 0 {
+			slog.Info("retention deleted items", "policy", p.name, "count", n)
+			stats.addPruned(p.name, n)
+		}
+	}
+}
+
+// retentionJob runs the retention policies in the given interval until
+// the context is canceled
+func retentionJob(ctx context.Context, interval time.Duration, policies []retentionPolicy, errs chan<- error) {
+	if len(policies) == 0 {
+		return
+	}
+	runRetention(ctx, policies, errs)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runRetention(ctx, policies, errs)
+		}
+	}
+}
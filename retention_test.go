@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/FireFart/pastebin_scraper/store"
+)
+
+func TestRunRetention(t *testing.T) {
+	s := stats
+	stats = newStatistics()
+	defer func() { stats = s }()
+
+	errs := make(chan error, 10)
+	policies := []retentionPolicy{
+		{name: "good", prune: func(ctx context.Context, now time.Time) (int, error) { return 3, nil }},
+		{name: "bad", prune: func(ctx context.Context, now time.Time) (int, error) { return 0, errors.New("failed") }},
+	}
+	runRetention(context.Background(), policies, errs)
+	close(errs)
+
+	if c := stats.prunedCount("good"); c != 3 {
+		t.Fatalf("expected 3 pruned items, got %d", c)
+	}
+	var got []error
+	for err := range errs {
+		got = append(got, err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected one error, got %v", got)
+	}
+}
+
+func TestSetupRetention(t *testing.T) {
+	l, err := newLocalArchiver(local{Directory: t.TempDir(), MaxAge: "1h"})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	policies, err := setupRetention(retention{Matches: "24h"}, nil, []archiver{l})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	// no store is configured so only the archive is pruned
+	if len(policies) != 1 || policies[0].name != "archive" {
+		t.Fatalf("unexpected policies: %v", policies)
+	}
+	if _, err := setupRetention(retention{Matches: "invalid"}, &store.SQL{}, nil); err == nil {
+		t.Fatal("expected error on invalid duration")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// keywordNames returns the configured keywords in config order
+func keywordNames(k []keyword) []string {
+	ret := make([]string, 0, len(k))
+	for _, x := range k {
+		ret = append(ret, x.Keyword)
+	}
+	return ret
+}
+
+// addedKeywords returns all keywords not part of the previous run
+func addedKeywords(previous, current []string) []string {
+	var ret []string
+	for _, k := range current {
+		if !stringInSlice(k, previous) {
+			ret = append(ret, k)
+		}
+	}
+	return ret
+}
+
+// retroScan checks the pastes of the local archive since the given time
+// and calls fn for all pastes matching at least one of the added keywords.
+// It returns the number of found pastes.
+func retroScan(ctx context.Context, dir string, since time.Time, m *matcher, added []string, fn func(p paste)) (int, error) {
+	n := 0
+	err := walkLocalArchive(dir, since, func(p paste) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p.Matches = nil
+		p.Groups = nil
+		p.scan(m)
+		for k := range p.Matches {
+			if stringInSlice(k, added) {
+				n++
+				fn(p)
+				break
+			}
+		}
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FireFart/pastebin_scraper/scraper"
+)
+
+func TestAddedKeywords(t *testing.T) {
+	x := addedKeywords([]string{"a", "b"}, []string{"b", "c", "d"})
+	if !reflect.DeepEqual(x, []string{"c", "d"}) {
+		t.Fatalf("unexpected added keywords %v", x)
+	}
+	if x := addedKeywords([]string{"a"}, []string{"a"}); len(x) != 0 {
+		t.Fatalf("expected no added keywords, got %v", x)
+	}
+}
+
+func TestRetroScan(t *testing.T) {
+	dir := t.TempDir()
+	l, err := newLocalArchiver(local{Directory: dir})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+	for _, p := range []paste{
+		{Key: "old", Date: strconv.FormatInt(now.Add(-72*time.Hour).Unix(), 10), Content: "new keyword"},
+		{Key: "new", Date: strconv.FormatInt(now.Add(-1*time.Hour).Unix(), 10), Content: "this has the new keyword"},
+		{Key: "other", Date: strconv.FormatInt(now.Add(-1*time.Hour).Unix(), 10), Content: "only the old keyword"},
+	} {
+		if _, err := l.archive(ctx, p); err != nil {
+			t.Fatalf("could not archive: %v", err)
+		}
+	}
+
+	m := mustMatcher(t, scraper.Rules{Keywords: []keyword{{Keyword: "old"}, {Keyword: "new"}}})
+	var found []paste
+	n, err := retroScan(ctx, dir, now.Add(-24*time.Hour), m, []string{"new"}, func(p paste) {
+		found = append(found, p)
+	})
+	if err != nil {
+		t.Fatalf("could not scan: %v", err)
+	}
+	if n != 1 || len(found) != 1 || found[0].Key != "new" {
+		t.Fatalf("unexpected results: %d %+v", n, found)
+	}
+	if _, ok := found[0].Matches["new"]; !ok {
+		t.Fatalf("expected matches of the new keyword, got %v", found[0].Matches)
+	}
+}
+
+func TestCheckAddedKeywordsCanceled(t *testing.T) {
+	dir := t.TempDir()
+	l, err := newLocalArchiver(local{Directory: dir})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := l.archive(ctx, paste{Key: "new", Date: strconv.FormatInt(time.Now().Unix(), 10), Content: "new keyword"}); err != nil {
+		t.Fatalf("could not archive: %v", err)
+	}
+	st, err := loadFileState("")
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if err := st.setKeywords([]string{"old"}); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	c := configuration{Keywords: []keyword{{Keyword: "old"}, {Keyword: "new"}}}
+	c.Retroscan.Days = 1
+	c.Archive.Local.Directory = dir
+	m := mustMatcher(t, scraper.Rules{Keywords: c.Keywords})
+
+	// nobody reads the channels after a shutdown
+	var wg sync.WaitGroup
+	out, errs := make(chan paste), make(chan error)
+	if err := checkAddedKeywords(ctx, &wg, c, m, st, out, errs); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("retro-scan still running after the context was canceled")
+	}
+}
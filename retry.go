@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultRetryAttempts   = 3
+	defaultRetryBackoff    = 1 * time.Second
+	defaultRetryMaxBackoff = 30 * time.Second
+
+	// what happens to a paste after all attempts failed
+	giveUpDrop    = "drop"
+	giveUpRequeue = "requeue"
+	giveUpError   = "error"
+)
+
+// transientError marks failed requests which may succeed when retried
+type transientError struct {
+	err error
+}
+
+func (e transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e transientError) Unwrap() error {
+	return e.err
+}
+
+func isTransient(err error) bool {
+	var t transientError
+	return errors.As(err, &t)
+}
+
+// transientStatus returns if the status code of a response is worth a retry
+func transientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= http.StatusInternalServerError
+}
+
+type retryPolicy struct {
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	jitter     float64
+	giveUp     string
+}
+
+func newRetryPolicy(c retryConfig, jitterFraction float64) (retryPolicy, error) {
+	r := retryPolicy{
+		attempts:   c.Attempts,
+		backoff:    defaultRetryBackoff,
+		maxBackoff: defaultRetryMaxBackoff,
+		jitter:     jitterFraction,
+		giveUp:     c.GiveUp,
+	}
+	if r.attempts <= 0 {
+		r.attempts = defaultRetryAttempts
+	}
+	var err error
+	if c.Backoff != "" {
+		if r.backoff, err = time.ParseDuration(c.Backoff); err != nil {
+			return r, fmt.Errorf("invalid value for retry.backoff %q: %v", c.Backoff, err)
+		}
+	}
+	if c.MaxBackoff != "" {
+		if r.maxBackoff, err = time.ParseDuration(c.MaxBackoff); err != nil {
+			return r, fmt.Errorf("invalid value for retry.maxbackoff %q: %v", c.MaxBackoff, err)
+		}
+	}
+	switch r.giveUp {
+	case "":
+		r.giveUp = giveUpDrop
+	case giveUpDrop, giveUpRequeue, giveUpError:
+	default:
+		return r, fmt.Errorf("invalid value for retry.giveup %q", c.GiveUp)
+	}
+	return r, nil
+}
+
+// do calls fn until it succeeds, returns a permanent error or all attempts
+// are used up. The backoff doubles after every failed attempt.
+func (r retryPolicy) do(ctx context.Context, what string, fn func() error) error {
+	backoff := r.backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || !isTransient(err) || attempt >= r.attempts {
+			return err
+		}
+		logger(componentFetcher).Debug("retrying request", "request", what, "attempt", attempt, "backoff", backoff, "error", err)
+		if !sleep(ctx, jitter(backoff, r.jitter)) {
+			return err
+		}
+		backoff = clampDuration(backoff*2, 0, r.maxBackoff)
+	}
+}
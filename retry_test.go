@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	r, err := newRetryPolicy(retryConfig{Attempts: 3, Backoff: "1ms", MaxBackoff: "2ms"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.giveUp != giveUpDrop {
+		t.Errorf("expected default give up %q, got %q", giveUpDrop, r.giveUp)
+	}
+
+	calls := 0
+	err = r.do(context.Background(), "test", func() error {
+		calls++
+		if calls < 3 {
+			return transientError{errors.New("timeout")}
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("expected success on the third attempt, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = r.do(context.Background(), "test", func() error {
+		calls++
+		return transientError{errors.New("timeout")}
+	})
+	if !isTransient(err) || calls != 3 {
+		t.Fatalf("expected to give up after 3 attempts, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = r.do(context.Background(), "test", func() error {
+		calls++
+		return errors.New("permanent")
+	})
+	if err == nil || isTransient(err) || calls != 1 {
+		t.Fatalf("expected no retry of permanent errors, got %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryPolicyCanceled(t *testing.T) {
+	r, err := newRetryPolicy(retryConfig{Attempts: 5, Backoff: "1h"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	start := time.Now()
+	r.do(ctx, "test", func() error { // nolint: errcheck
+		calls++
+		return transientError{errors.New("timeout")}
+	})
+	if calls != 1 || time.Since(start) > time.Second {
+		t.Fatalf("expected the backoff to be canceled, got %d calls", calls)
+	}
+}
+
+func TestRetryPolicyInvalid(t *testing.T) {
+	for _, c := range []retryConfig{{Backoff: "1"}, {MaxBackoff: "x"}, {GiveUp: "ignore"}} {
+		if _, err := newRetryPolicy(c, 0); err == nil {
+			t.Errorf("expected error for %+v", c)
+		}
+	}
+}
+
+func TestFetchTransient(t *testing.T) {
+	unpaced(t)
+	code := http.StatusServiceUnavailable
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+		fmt.Fprint(w, "slow down")
+	}))
+	defer ts.Close()
+	p := paste{Key: "test", ScrapeURL: ts.URL}
+	for _, code = range []int{http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		if _, err := p.fetch(context.Background()); !isTransient(err) {
+			t.Fatalf("expected transient error for status %d, got %v", code, err)
+		}
+	}
+	for _, code = range []int{http.StatusNotFound, http.StatusForbidden} {
+		if _, err := p.fetch(context.Background()); err == nil || isTransient(err) {
+			t.Fatalf("expected permanent error for status %d, got %v", code, err)
+		}
+	}
+}
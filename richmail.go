@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+const (
+	defaultMailStyle = "github"
+)
+
+// highlightCode renders the code with inline styles as mail clients
+// drop style sheets. The lexer is taken from the syntax declared on
+// Pastebin and guessed from the code for plain text pastes.
+func highlightCode(code, syntax, style string) (template.HTML, error) {
+	lexer := lexers.Get(syntax)
+	if lexer == nil || syntax == "" || syntax == "text" {
+		lexer = lexers.Analyse(code)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	s := styles.Get(style)
+	if s == nil {
+		s = styles.Fallback
+	}
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return "", fmt.Errorf("could not tokenise: %v", err)
+	}
+	var b bytes.Buffer
+	if err := chromahtml.New(chromahtml.WithClasses(false)).Format(&b, s, it); err != nil {
+		return "", fmt.Errorf("could not format: %v", err)
+	}
+	return template.HTML(b.String()), nil // nolint: gosec
+}
+
+var alertTemplate = template.Must(template.New("alert").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"></head>
+<body style="font-family: sans-serif">
+<h2>Pastebin Alert for Keywords {{.Keywords}}</h2>
+<table>
+{{range .Fields}}<tr><td><b>{{.Name}}</b></td><td>{{if .Link}}<a href="{{.Link}}">{{.Value}}</a>{{else}}{{.Value}}{{end}}</td></tr>
+{{end}}</table>
+{{range .Matches}}<h3>Matches for {{.Keyword}}</h3>
+{{.Code}}
+{{end}}{{if .Details}}<pre>{{.Details}}</pre>{{end}}
+</body>
+</html>
+`))
+
+type alertMatch struct {
+	Keyword string
+	Code    template.HTML
+}
+
+// alertHTML formats the alert as html with the matched lines highlighted
+// according to the syntax of the paste
+func (p *paste) alertHTML(defanged bool, style string) (string, error) {
+	clean := func(s string) string { return s }
+	if defanged {
+		clean = defang
+	}
+	if style == "" {
+		style = defaultMailStyle
+	}
+	keywords := getKeysFromMap(p.Matches)
+	sort.Strings(keywords)
+	data := struct {
+		Keywords string
+		Fields   []alertField
+		Matches  []alertMatch
+		Details  string
+	}{Keywords: strings.Join(keywords, ", ")}
+	data.Fields = p.alertFields(clean)
+	for _, k := range keywords {
+		code, err := highlightCode(clean(strings.Join(p.Matches[k], "\n")), p.Syntax, style)
+		if err != nil {
+			return "", err
+		}
+		data.Matches = append(data.Matches, alertMatch{Keyword: k, Code: code})
+	}
+	var details bytes.Buffer
+	if err := p.IOCs.write(&details); err != nil {
+		return "", err
+	}
+	if err := p.Enrichment.write(&details); err != nil {
+		return "", err
+	}
+	data.Details = strings.TrimSpace(clean(details.String()))
+	var b bytes.Buffer
+	if err := alertTemplate.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("could not render alert: %v", err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlightCode(t *testing.T) {
+	code, err := highlightCode(`password = "secret"`, "python", "github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(code)
+	if !strings.Contains(s, "<pre") || !strings.Contains(s, "style=\"") || !strings.Contains(s, "secret") {
+		t.Errorf("expected inline styled code, got %s", s)
+	}
+	// unknown syntax and style fall back instead of failing
+	if _, err := highlightCode("<b>x</b>", "unknown", "unknown"); err != nil {
+		t.Errorf("expected a fallback, got %v", err)
+	}
+}
+
+func TestAlertHTML(t *testing.T) {
+	p := paste{
+		FullURL: "https://pastebin.com/abc",
+		Title:   "dump <1>",
+		Syntax:  "sql",
+		Matches: map[string][]string{"password": {"INSERT INTO users VALUES ('admin', 'http://evil.example.com')"}},
+		IOCs:    &iocSummary{URLs: []string{"http://evil.example.com"}},
+	}
+	s, err := p.alertHTML(true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"Matches for password", `<a href="https://pastebin.com/abc">`, "dump &lt;1&gt;", "hxxp://evil[.]example[.]com", "Indicators of compromise"} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("expected %q in the html alert, got:\n%s", expected, s)
+		}
+	}
+	if strings.Contains(s, "http://evil.example.com") {
+		t.Errorf("expected the links from the paste to be defanged, got:\n%s", s)
+	}
+}
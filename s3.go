@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	s3LockGovernance = "GOVERNANCE"
+	s3LockCompliance = "COMPLIANCE"
+)
+
+// s3Archiver uploads pastes to an S3 bucket or S3 compatible storage
+type s3Archiver struct {
+	config   s3
+	creds    awsCredentials
+	endpoint string
+	client   *http.Client
+}
+
+func newS3Archiver(c s3) (*s3Archiver, error) {
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+	creds, err := resolveAWSCredentials(c.AccessKey, c.SecretKey, c.SessionToken)
+	if err != nil {
+		return nil, err
+	}
+	switch c.ObjectLockMode {
+	case "", s3LockGovernance, s3LockCompliance:
+	default:
+		return nil, fmt.Errorf("invalid object lock mode %q", c.ObjectLockMode)
+	}
+	if (c.ObjectLockMode == "") != (c.ObjectLockDays <= 0) {
+		return nil, fmt.Errorf("objectlockmode and objectlockdays must be set together")
+	}
+	endpoint, err := s3Endpoint(c)
+	if err != nil {
+		return nil, err
+	}
+	a := &s3Archiver{config: c, creds: creds, endpoint: endpoint, client: client}
+	if c.CAFile != "" {
+		a.client, err = clientWithCA(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// s3Endpoint returns the base url of the bucket. Virtual hosted style
+// puts the bucket in the hostname, path style in the first path segment.
+func s3Endpoint(c s3) (string, error) {
+	base := c.Endpoint
+	if base == "" {
+		base = fmt.Sprintf("https://s3.%s.amazonaws.com", c.Region)
+	}
+	u, err := url.Parse(strings.TrimRight(base, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid s3 endpoint %q: %v", base, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid s3 endpoint %q", base)
+	}
+	if c.PathStyle {
+		u.Path += "/" + c.Bucket
+	} else {
+		u.Host = c.Bucket + "." + u.Host
+	}
+	return u.String(), nil
+}
+
+// setObjectLock adds the headers to write the object immutable. S3
+// requires a checksum of the content if object lock headers are set.
+func (s *s3Archiver) setObjectLock(req *http.Request, body []byte, now time.Time) {
+	if s.config.ObjectLockMode == "" && !s.config.LegalHold {
+		return
+	}
+	sum := md5.Sum(body) // nolint: gosec
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	if s.config.ObjectLockMode != "" {
+		until := now.UTC().AddDate(0, 0, s.config.ObjectLockDays)
+		req.Header.Set("X-Amz-Object-Lock-Mode", s.config.ObjectLockMode)
+		req.Header.Set("X-Amz-Object-Lock-Retain-Until-Date", until.Format(time.RFC3339))
+	}
+	if s.config.LegalHold {
+		req.Header.Set("X-Amz-Object-Lock-Legal-Hold", "ON")
+	}
+}
+
+// clientWithCA returns a copy of the default client which additionally
+// trusts the certificates in the given file, for example for self-signed
+// on-prem storage
+func clientWithCA(f string) (*http.Client, error) {
+	b, err := ioutil.ReadFile(f) // nolint: gosec
+	if err != nil {
+		return nil, fmt.Errorf("could not read ca file: %v", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", f)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	c := *client
+	c.Transport = transport
+	return &c, nil
+}
+
+func (s *s3Archiver) archive(ctx context.Context, p paste) (string, error) {
+	key := archiveKey(s.config.Prefix, p)
+	body, err := gzipString(p.Content)
+	if err != nil {
+		return "", err
+	}
+	if err := s.put(ctx, key, body); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("s3://%s/%s", s.config.Bucket, key), nil
+}
+
+func (s *s3Archiver) put(ctx context.Context, key string, body []byte) error {
+	u := fmt.Sprintf("%s/%s", strings.TrimRight(s.endpoint, "/"), key)
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", "application/gzip")
+	s.setObjectLock(req, body, time.Now())
+	signV4(req, sha256Hex(body), s.creds, s.config.Region, "s3", time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	b, err := httpRespBodyToString(resp)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3 returned %s: %s", resp.Status, b)
+	}
+	return nil
+}
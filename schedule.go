@@ -0,0 +1,50 @@
+package main
+
+import (
+	"time"
+)
+
+var (
+	metricMissedChecks = metrics.counter("checks_missed_total", "Number of scheduled list fetches skipped because the previous run took too long.", "")
+)
+
+// scheduler runs the list fetches on fixed slots of the interval. The
+// jitter moves a run within its slot but does not shift the following
+// slots, and a run taking longer than the interval skips the missed slots
+// instead of fetching several times in a row. So the cadence does not
+// drift with the time spent on fetching.
+type scheduler struct {
+	interval time.Duration
+	jitter   float64
+	// start of the next slot and the jittered time of its run
+	slot time.Time
+	run  time.Time
+}
+
+// newScheduler returns a scheduler with the first run at first, or now if
+// that is in the past
+func newScheduler(first time.Time, interval time.Duration, jitterFraction float64, now time.Time) *scheduler {
+	if first.Before(now) {
+		first = now
+	}
+	return &scheduler{interval: interval, jitter: jitterFraction, slot: first, run: first}
+}
+
+// until returns the time left until the next run
+func (s *scheduler) until(now time.Time) time.Duration {
+	return s.run.Sub(now)
+}
+
+// advance moves to the slot after now. It is called when a run starts.
+func (s *scheduler) advance(now time.Time) {
+	s.slot = s.slot.Add(s.interval)
+	if missed := int64(now.Sub(s.slot)/s.interval) + 1; !now.Before(s.slot) {
+		metricMissedChecks.add(float64(missed), "")
+		logger(componentFetcher).Warn("fetching took longer than the interval, skipping checks", "missed", missed, "interval", s.interval)
+		s.slot = s.slot.Add(time.Duration(missed) * s.interval)
+	}
+	s.run = s.slot.Add(jitter(s.interval, s.jitter) - s.interval)
+	if s.run.Before(now) {
+		s.run = now
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	start := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	s := newScheduler(start.Add(-time.Hour), time.Minute, 0, start)
+	if d := s.until(start); d != 0 {
+		t.Fatalf("expected a first check in the past to run now, got %v", d)
+	}
+
+	// a run taking 20 seconds does not shift the next run
+	s.advance(start)
+	if d := s.until(start.Add(20 * time.Second)); d != 40*time.Second {
+		t.Fatalf("expected the next run on the slot, got %v", d)
+	}
+
+	// a run starting late still keeps the slots
+	s.advance(start.Add(time.Minute + 10*time.Second))
+	if !s.run.Equal(start.Add(2 * time.Minute)) {
+		t.Fatalf("expected the next run at the slot, got %v", s.run)
+	}
+
+	// a run taking 2.5 intervals skips the missed slots
+	s.advance(start.Add(4*time.Minute + 30*time.Second))
+	if !s.run.Equal(start.Add(5 * time.Minute)) {
+		t.Fatalf("expected the missed slots to be skipped, got %v", s.run)
+	}
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	start := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	s := newScheduler(start, time.Minute, 0.2, start)
+	now := start
+	for i := 1; i <= 100; i++ {
+		s.advance(now)
+		slot := start.Add(time.Duration(i) * time.Minute)
+		if d := s.run.Sub(slot); d < -12*time.Second || d > 12*time.Second {
+			t.Fatalf("run %d is %v off its slot", i, d)
+		}
+		now = s.run
+	}
+}
@@ -0,0 +1,116 @@
+package scraper
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/FireFart/pastebin_scraper/detect"
+)
+
+// Explain prints which rules match the body and why, for example
+// which exception suppressed a line or that the score is below the
+// threshold
+// nolint: gocyclo
+func (m *Matcher) Explain(w io.Writer, body string) {
+	original := body
+	if m.normalize {
+		body = detect.Normalize(body)
+	}
+	if term := findBlocked(body, m.blocklist); term != "" {
+		fmt.Fprintf(w, "  blocked: paste contains blocked term %q\n", term)
+		fmt.Fprintln(w, "  result: no alert")
+		return
+	}
+
+	type variant struct {
+		name string
+		body string
+		// lines of the body the folded lines came from
+		original map[string]string
+	}
+	bodies := []variant{{body: body}}
+	if m.fold {
+		for _, folded := range detect.FoldHomoglyphs(body) {
+			bodies = append(bodies, variant{name: " (homoglyphs folded)", body: folded, original: foldedLines(body, folded)})
+		}
+	}
+
+	names := make([]string, 0, len(*m.keywords))
+	for k := range *m.keywords {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	matched := make(map[string][]string)
+	for _, name := range names {
+		k := (*m.keywords)[name]
+		var out []string
+		for _, b := range bodies {
+			if k.bodyExceptions {
+				if e, ok := findException(b.body, k.exceptions, k.exceptionRegexes); ok {
+					if len(keywordLines(b.body, k)) > 0 {
+						out = append(out, fmt.Sprintf("    skipped%s: paste contains exception %q", b.name, e))
+					}
+					continue
+				}
+			}
+			for _, line := range keywordLines(b.body, k) {
+				line = strings.TrimSpace(line)
+				if o, ok := b.original[line]; ok {
+					line = o
+				}
+				if !k.bodyExceptions {
+					if e, ok := findException(line, k.exceptions, k.exceptionRegexes); ok {
+						out = append(out, fmt.Sprintf("    exception %q%s: %s", e, b.name, line))
+						continue
+					}
+				}
+				matched[name] = append(matched[name], line)
+				out = append(out, fmt.Sprintf("    match%s: %s", b.name, line))
+			}
+		}
+		if len(out) == 0 {
+			continue
+		}
+		details := []string{fmt.Sprintf("score %d", k.score)}
+		if k.group != "" {
+			details = append(details, fmt.Sprintf("group %s", k.group))
+		}
+		if k.fuzzy > 0 {
+			details = append(details, fmt.Sprintf("fuzzy %d", k.fuzzy))
+		}
+		fmt.Fprintf(w, "  keyword %q (%s):\n", name, strings.Join(details, ", "))
+		for _, o := range out {
+			fmt.Fprintln(w, o)
+		}
+	}
+	if m.threshold > 0 && len(matched) > 0 {
+		score := keywordScore(matched, m.keywords)
+		status := "reached"
+		if score < m.threshold {
+			status = "not reached, keyword matches are ignored"
+		}
+		fmt.Fprintf(w, "  score %d of threshold %d %s\n", score, m.threshold, status)
+	}
+
+	_, cidrs := checkCIDRs(body, m.cidrs)
+	for _, k := range sortedKeys(cidrs) {
+		fmt.Fprintf(w, "  cidr %s: %s\n", k, strings.Join(cidrs[k], ", "))
+	}
+	detectors := detect.Run(body, m.detectors)
+	for _, k := range sortedKeys(detectors) {
+		fmt.Fprintf(w, "  detector %s: %s\n", k, strings.Join(detectors[k], ", "))
+	}
+
+	found, key := m.Scan(original)
+	if !found {
+		fmt.Fprintln(w, "  result: no alert")
+		return
+	}
+	result := fmt.Sprintf("  result: alert for %s", strings.Join(sortedKeys(key), ", "))
+	if groups := m.Groups(key); len(groups) > 0 {
+		result = fmt.Sprintf("%s, groups: %s", result, strings.Join(groups, ", "))
+	}
+	fmt.Fprintln(w, result)
+}
@@ -0,0 +1,65 @@
+package scraper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	m, err := Compile(Rules{
+		Threshold: 2,
+		Keywords: []Keyword{
+			{Keyword: "password", Exceptions: []string{"example"}, Group: "credentials"},
+			{Keyword: "admin"},
+			{Keyword: "unused"},
+		},
+		CIDRs:     []string{"10.0.0.0/8"},
+		Blocklist: []string{"minecraft"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		body     string
+		expected []string
+	}{
+		{
+			body: "user=admin password=hunter2\npassword=example",
+			expected: []string{
+				`keyword "admin" (score 1):`,
+				`keyword "password" (score 1, group credentials):`,
+				"match: user=admin password=hunter2",
+				`exception "example": password=example`,
+				"score 2 of threshold 2 reached",
+				"result: alert for admin, password, groups: credentials",
+			},
+		},
+		{
+			body: "password=hunter2\nserver 10.1.2.3",
+			expected: []string{
+				"score 1 of threshold 2 not reached",
+				"cidr 10.0.0.0/8: 10.1.2.3",
+				"result: alert for 10.0.0.0/8",
+			},
+		},
+		{
+			body:     "admin password minecraft",
+			expected: []string{`blocked: paste contains blocked term "minecraft"`, "result: no alert"},
+		},
+	}
+	for _, x := range tt {
+		var b bytes.Buffer
+		m.Explain(&b, x.body)
+		out := b.String()
+		for _, e := range x.expected {
+			if !strings.Contains(out, e) {
+				t.Errorf("expected output to contain %q, got:\n%s", e, out)
+			}
+		}
+		if strings.Contains(out, "unused") {
+			t.Errorf("expected keywords without matches to be omitted, got:\n%s", out)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package scraper
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxFuzzyDistance = 2
+)
+
+// findFuzzy returns all lines of the body containing the keyword after
+// applying the keywords substitutions or within the allowed edit distance
+func findFuzzy(body string, k keywordType) []string {
+	var ret []string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimRight(line, "\r")
+		normalized := strings.ToLower(line)
+		if k.substitutions != nil {
+			normalized = k.substitutions.Replace(normalized)
+		}
+		if k.regexp.MatchString(normalized) {
+			ret = append(ret, line)
+			continue
+		}
+		if k.fuzzy > 0 && containsFuzzy(normalized, k.keyword, k.fuzzy, k.boundary) {
+			logger().Log(context.Background(), levelTrace, "line fuzzy matches", "line", line, "keyword", k.keyword)
+			ret = append(ret, line)
+		}
+	}
+	return ret
+}
+
+// containsFuzzy checks if s contains a substring which is at most distance
+// edits away from keyword. The word boundaries are handled like in the
+// regex based matching.
+func containsFuzzy(s, keyword string, distance int, boundary string) bool {
+	line := []rune(s)
+	kw := []rune(keyword)
+	if len(kw) == 0 {
+		return false
+	}
+	start := boundary != boundaryNone && isWordRune(kw[0])
+	end := boundary == boundaryBoth && isWordRune(kw[len(kw)-1])
+	for i := range line {
+		if start && (!isWordRune(line[i]) || (i > 0 && isWordRune(line[i-1]))) {
+			continue
+		}
+		for l := len(kw) - distance; l <= len(kw)+distance; l++ {
+			if l <= 0 || i+l > len(line) {
+				continue
+			}
+			if end && i+l < len(line) && isWordRune(line[i+l]) {
+				continue
+			}
+			if levenshtein(line[i:i+l], kw) <= distance {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// levenshtein calculates the edit distance between a and b
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
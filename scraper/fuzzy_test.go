@@ -0,0 +1,81 @@
+package scraper
+
+import (
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tt := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"abc", "abc", 0},
+		{"abc", "abd", 1},
+		{"abc", "ab", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, x := range tt {
+		if d := levenshtein([]rune(x.a), []rune(x.b)); d != x.distance {
+			t.Errorf("levenshtein(%q, %q) = %d, expected %d", x.a, x.b, d, x.distance)
+		}
+	}
+}
+
+func TestContainsFuzzy(t *testing.T) {
+	tt := []struct {
+		s        string
+		distance int
+		boundary string
+		expected bool
+	}{
+		{"visit examplecorp today", 1, "", true},
+		{"visit exampelcorp today", 1, "", false},
+		{"visit exampelcorp today", 2, "", true},
+		{"visit examp1ecorp today", 1, "", true},
+		{"visit notexamplecorp today", 1, "", false},
+		{"visit notexamplecorp today", 1, boundaryNone, true},
+		{"visit examplecorp123 today", 1, boundaryBoth, false},
+		{"visit examplecorp today", 1, boundaryBoth, true},
+		{"nothing here", 2, "", false},
+	}
+	for _, x := range tt {
+		if got := containsFuzzy(x.s, "examplecorp", x.distance, x.boundary); got != x.expected {
+			t.Errorf("containsFuzzy(%q, %d, %q) = %t, expected %t", x.s, x.distance, x.boundary, got, x.expected)
+		}
+	}
+}
+
+func TestCheckKeywordsFuzzy(t *testing.T) {
+	keywords := mustParseKeywords(t, []Keyword{
+		{Keyword: "examplecorp", Fuzzy: 1},
+		{Keyword: "brand", Substitutions: map[string]string{"4": "a"}},
+	})
+	found, key := checkKeywords("login for Examp1eCorp\nBR4ND leak", keywords)
+	if !found {
+		t.Fatal("expected a match")
+	}
+	if len(key["examplecorp"]) != 1 || key["examplecorp"][0] != "login for Examp1eCorp" {
+		t.Fatalf("unexpected fuzzy matches: %v", key["examplecorp"])
+	}
+	if len(key["brand"]) != 1 || key["brand"][0] != "BR4ND leak" {
+		t.Fatalf("unexpected substitution matches: %v", key["brand"])
+	}
+}
+
+func TestSubstitutionReplacer(t *testing.T) {
+	subs := map[string]string{"1": "l", "13": "b", "3": "e", "4": "a"}
+	// the order of the map must not change the result
+	for i := 0; i < 20; i++ {
+		if x := substitutionReplacer(subs).Replace("13r4nd 1e4k"); x != "brand leak" {
+			t.Fatalf("got %q, expected %q", x, "brand leak")
+		}
+	}
+}
+
+func TestParseKeywordsInvalidFuzzy(t *testing.T) {
+	_, err := parseKeywords([]Keyword{{Keyword: "keyword", Fuzzy: 3}})
+	if err == nil {
+		t.Fatal("expected error on too large fuzzy distance")
+	}
+}
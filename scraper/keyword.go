@@ -0,0 +1,210 @@
+package scraper
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	boundaryStart = "start"
+	boundaryBoth  = "both"
+	boundaryNone  = "none"
+)
+
+// Keyword is a keyword of the config. Pastes containing it anywhere in a
+// line match unless the line contains one of the exceptions.
+type Keyword struct {
+	Keyword          string            `json:"keyword"`
+	Exceptions       []string          `json:"exceptions"`
+	ExceptionRegexes []string          `json:"exceptionregexes"`
+	ExceptionsInBody bool              `json:"exceptionsinbody"`
+	Score            *int              `json:"score"`
+	Boundary         string            `json:"boundary"`
+	Group            string            `json:"group"`
+	Fuzzy            int               `json:"fuzzy"`
+	Substitutions    map[string]string `json:"substitutions"`
+}
+
+type keywordType struct {
+	keyword          string
+	regexp           *regexp.Regexp
+	exceptions       []string
+	exceptionRegexes []*regexp.Regexp
+	bodyExceptions   bool
+	score            int
+	fuzzy            int
+	boundary         string
+	group            string
+	substitutions    *strings.Replacer
+}
+
+// CheckKeyword returns the error compiling the keyword would fail with
+func CheckKeyword(k Keyword) error {
+	_, err := parseKeywords([]Keyword{k})
+	return err
+}
+
+func checkKeywords(body string, keywords *map[string]keywordType) (bool, map[string][]string) {
+	found := make(map[string][]string)
+	status := false
+	for k, v := range *keywords {
+		var x []string
+		// exceptions apply to the whole paste so skip the keyword entirely
+		if v.bodyExceptions && checkExceptions(body, v.exceptions, v.exceptionRegexes) {
+			continue
+		}
+		s := keywordLines(body, v)
+		// we have a match
+		if len(s) > 0 {
+			// check for exceptions
+			for _, m := range s {
+				match := strings.TrimSpace(m)
+				if v.bodyExceptions || !checkExceptions(match, v.exceptions, v.exceptionRegexes) {
+					x = append(x, match)
+					status = true
+				}
+			}
+		}
+		// append result if not in exceptions
+		if len(x) > 0 {
+			found[k] = x
+		}
+	}
+	return status, found
+}
+
+// keywordLines returns all lines containing the keyword before checking
+// the exceptions
+func keywordLines(body string, k keywordType) []string {
+	if k.fuzzy > 0 || k.substitutions != nil {
+		return findFuzzy(body, k)
+	}
+	return k.regexp.FindAllString(body, -1)
+}
+
+// keywordScore sums up the scores of all matched keywords
+func keywordScore(found map[string][]string, keywords *map[string]keywordType) int {
+	score := 0
+	for k := range found {
+		if v, ok := (*keywords)[k]; ok {
+			score += v.score
+		}
+	}
+	return score
+}
+
+// matchedGroups returns the sorted groups of all matched keywords
+func matchedGroups(found map[string][]string, keywords *map[string]keywordType) []string {
+	var groups []string
+	for k := range found {
+		if v, ok := (*keywords)[k]; ok && v.group != "" && !stringInSlice(v.group, groups) {
+			groups = append(groups, v.group)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+func checkExceptions(s string, exceptions []string, regexes []*regexp.Regexp) bool {
+	_, found := findException(s, exceptions, regexes)
+	return found
+}
+
+// findException returns the first exception matching the string
+func findException(s string, exceptions []string, regexes []*regexp.Regexp) (string, bool) {
+	for _, x := range exceptions {
+		if strings.Contains(s, x) {
+			logger().Debug("string contains exception", "string", s, "exception", x)
+			return x, true
+		}
+	}
+	for _, x := range regexes {
+		if x.MatchString(s) {
+			logger().Debug("string matches exception", "string", s, "exception", x.String())
+			return x.String(), true
+		}
+	}
+	return "", false
+}
+
+// keywordRegex builds the regex matching a whole line containing the keyword.
+// By default a boundary is only used in front of the keyword.
+func keywordRegex(k Keyword) (string, error) {
+	q := regexp.QuoteMeta(k.Keyword)
+	switch k.Boundary {
+	case "", boundaryStart:
+		q = `\b` + q
+	case boundaryBoth:
+		q = `\b` + q + `\b`
+	case boundaryNone:
+	default:
+		return "", fmt.Errorf("invalid boundary %q for keyword %q", k.Boundary, k.Keyword)
+	}
+	return fmt.Sprintf(`(?im)^(.*%s.*)$`, q), nil
+}
+
+// substitutionReplacer builds the replacer in a fixed order as the
+// replacer prefers the earlier pair if several match at the same position.
+// Longer strings come first so "13" -> "b" wins over "1" -> "l".
+func substitutionReplacer(subs map[string]string) *strings.Replacer {
+	from := make([]string, 0, len(subs))
+	for f := range subs {
+		from = append(from, f)
+	}
+	sort.Slice(from, func(i, j int) bool {
+		if len(from[i]) != len(from[j]) {
+			return len(from[i]) > len(from[j])
+		}
+		return from[i] < from[j]
+	})
+	pairs := make([]string, 0, 2*len(from))
+	for _, f := range from {
+		pairs = append(pairs, strings.ToLower(f), strings.ToLower(subs[f]))
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+func parseKeywords(k []Keyword) (*map[string]keywordType, error) {
+	keywords := make(map[string]keywordType)
+	for _, k := range k {
+		var exceptionRegexes []*regexp.Regexp
+		for _, e := range k.ExceptionRegexes {
+			re, err := regexp.Compile(e)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse exception regex %q of keyword %q: %v", e, k.Keyword, err)
+			}
+			exceptionRegexes = append(exceptionRegexes, re)
+		}
+		if k.Fuzzy < 0 || k.Fuzzy > maxFuzzyDistance {
+			return nil, fmt.Errorf("fuzzy distance of keyword %q must be between 0 and %d", k.Keyword, maxFuzzyDistance)
+		}
+		var substitutions *strings.Replacer
+		if len(k.Substitutions) > 0 {
+			substitutions = substitutionReplacer(k.Substitutions)
+		}
+		r, err := keywordRegex(k)
+		if err != nil {
+			return nil, err
+		}
+		// keywords without a score count as a single point
+		score := 1
+		if k.Score != nil {
+			score = *k.Score
+		}
+		keywords[k.Keyword] = keywordType{
+			keyword:          strings.ToLower(k.Keyword),
+			regexp:           regexp.MustCompile(r),
+			exceptions:       k.Exceptions,
+			exceptionRegexes: exceptionRegexes,
+			bodyExceptions:   k.ExceptionsInBody,
+			score:            score,
+			fuzzy:            k.Fuzzy,
+			boundary:         k.Boundary,
+			group:            k.Group,
+			substitutions:    substitutions,
+		}
+	}
+	return &keywords, nil
+}
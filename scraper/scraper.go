@@ -0,0 +1,320 @@
+// Package scraper provides the keyword matcher of the scraper so other
+// programs can scan their own text with the rules of a config. The
+// heuristics matching text without keywords are in the detect package.
+//
+//	m, err := scraper.Compile(scraper.Rules{Keywords: []scraper.Keyword{{Keyword: "password"}}})
+//	if err != nil {
+//		return err
+//	}
+//	if found, matches := m.Scan(body); found {
+//		fmt.Println(matches)
+//	}
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/FireFart/pastebin_scraper/detect"
+)
+
+// levelTrace is the level of the messages logged for every checked line
+const levelTrace = slog.Level(-8)
+
+var (
+	// capture IPs (only v4)
+	// https://www.regular-expressions.info/ip.html
+	regexIP = regexp.MustCompile(`(\b(?:\d{1,3}\.){3}\d{1,3}\b)`)
+)
+
+// Rules are the rules of a config a Matcher is compiled from
+type Rules struct {
+	Keywords []Keyword
+	CIDRs    []string
+	// pastes containing one of the terms never match
+	Blocklist []string
+	// minimum keyword score for the keyword matches to count, 0 disables it
+	Threshold      int
+	Normalize      bool
+	FoldHomoglyphs bool
+	Detectors      []detect.Detector
+	// Filter may remove keyword matches before the threshold is applied,
+	// the scraper drops the snoozed keywords with it
+	Filter func(found map[string][]string)
+}
+
+// Matcher bundles all compiled rules a paste is checked against. It is
+// safe for concurrent use.
+type Matcher struct {
+	keywords  *map[string]keywordType
+	cidrs     *[]cidrType
+	threshold int
+	blocklist []string
+	normalize bool
+	fold      bool
+	detectors []detect.Detector
+	filter    func(found map[string][]string)
+}
+
+type cidrType struct {
+	ipNet *net.IPNet
+}
+
+// Compile compiles the rules into a Matcher
+func Compile(r Rules) (*Matcher, error) {
+	keywords, err := parseKeywords(r.Keywords)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse keywords: %v", err)
+	}
+	cidrs, err := parseCIDRs(r.CIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse cidrs: %v", err)
+	}
+	return &Matcher{
+		keywords:  keywords,
+		cidrs:     cidrs,
+		threshold: r.Threshold,
+		blocklist: parseBlocklist(r.Blocklist),
+		normalize: r.Normalize,
+		fold:      r.FoldHomoglyphs,
+		detectors: r.Detectors,
+		filter:    r.Filter,
+	}, nil
+}
+
+// CheckCIDRs returns the error compiling the cidrs would fail with
+func CheckCIDRs(cidrs []string) error {
+	_, err := parseCIDRs(cidrs)
+	return err
+}
+
+// Scan checks the body against all rules and returns the matched lines by
+// keyword, cidr or detector name. Keyword matches only count if their
+// score reaches the threshold.
+func (m *Matcher) Scan(body string) (bool, map[string][]string) {
+	blocked, keywords, other := m.ScanRules(body)
+	if blocked {
+		return false, make(map[string][]string)
+	}
+	return m.Combine(keywords, other)
+}
+
+// ScanRules returns the keyword matches apart from the cidr and detector
+// matches so the threshold can be applied after merging the matches of
+// several parts of a paste. It returns true if the body contains a blocked
+// term.
+func (m *Matcher) ScanRules(body string) (bool, map[string][]string, map[string][]string) {
+	if m.normalize {
+		body = detect.Normalize(body)
+	}
+	if checkBlocklist(body, m.blocklist) {
+		return true, nil, nil
+	}
+	_, key := checkKeywords(body, m.keywords)
+	if m.fold {
+		// the folded body only adds matches as it breaks keywords containing digits
+		for _, folded := range detect.FoldHomoglyphs(body) {
+			if foundFolded, keyFolded := checkKeywords(folded, m.keywords); foundFolded {
+				MergeMatches(key, originalMatches(keyFolded, foldedLines(body, folded)))
+			}
+		}
+	}
+	if m.filter != nil {
+		m.filter(key)
+	}
+	other := make(map[string][]string)
+	_, key2 := checkCIDRs(body, m.cidrs)
+	for k, v := range key2 {
+		other[k] = v
+	}
+	for k, v := range detect.Run(body, m.detectors) {
+		logger().Debug("detector matched", "detector", k)
+		other[k] = v
+	}
+	return false, key, other
+}
+
+// Combine applies the threshold to the keyword matches and adds the cidr
+// and detector matches
+func (m *Matcher) Combine(key, other map[string][]string) (bool, map[string][]string) {
+	if len(key) > 0 && m.threshold > 0 {
+		score := keywordScore(key, m.keywords)
+		if score < m.threshold {
+			logger().Debug("keyword score is below threshold", "score", score, "threshold", m.threshold)
+			key = make(map[string][]string)
+		}
+	}
+	for k, v := range other {
+		key[k] = v
+	}
+	return len(key) > 0, key
+}
+
+// Groups returns the groups of the matched keywords and detectors
+func (m *Matcher) Groups(found map[string][]string) []string {
+	groups := matchedGroups(found, m.keywords)
+	for _, d := range m.detectors {
+		if _, ok := found[d.Name]; ok && !stringInSlice(d.Name, groups) {
+			groups = append(groups, d.Name)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// Score sums up the scores of all matched keywords
+func (m *Matcher) Score(found map[string][]string) int {
+	return keywordScore(found, m.keywords)
+}
+
+// IsKeyword returns true if the match key is a keyword and not a cidr or
+// a detector
+func (m *Matcher) IsKeyword(name string) bool {
+	_, ok := (*m.keywords)[name]
+	return ok
+}
+
+// Detectors returns the detectors the matcher runs
+func (m *Matcher) Detectors() []detect.Detector {
+	return m.detectors
+}
+
+// foldedLines maps the trimmed lines of the folded body to the lines of
+// the body they were folded from. Folding never touches line breaks.
+func foldedLines(body, folded string) map[string]string {
+	lines := strings.Split(body, "\n")
+	ret := make(map[string]string, len(lines))
+	for i, l := range strings.Split(folded, "\n") {
+		if i < len(lines) {
+			ret[strings.TrimSpace(l)] = strings.TrimSpace(lines[i])
+		}
+	}
+	return ret
+}
+
+// originalMatches replaces the folded lines of the matches with the lines
+// of the paste so alerts show what was posted
+func originalMatches(found map[string][]string, lines map[string]string) map[string][]string {
+	for _, v := range found {
+		for i, x := range v {
+			if o, ok := lines[x]; ok {
+				v[i] = o
+			}
+		}
+	}
+	return found
+}
+
+// MergeMatches adds all matches from src to dst skipping duplicates
+func MergeMatches(dst, src map[string][]string) {
+	for k, v := range src {
+		for _, x := range v {
+			if !stringInSlice(x, dst[k]) {
+				dst[k] = append(dst[k], x)
+			}
+		}
+	}
+}
+
+func checkCIDRs(body string, cidrs *[]cidrType) (bool, map[string][]string) {
+	found := make(map[string][]string)
+	status := false
+	for _, cidr := range *cidrs {
+		var x []string
+		s := regexIP.FindAllString(body, -1)
+		// we have a match
+		if len(s) > 0 {
+			for _, m := range s {
+				match := strings.TrimSpace(m)
+				ip := net.ParseIP(match)
+				// invalid IP matched
+				if ip == nil {
+					logger().Log(context.Background(), levelTrace, "not a valid ip", "ip", match)
+					continue
+				}
+				if cidr.ipNet.Contains(ip) {
+					logger().Debug("cidr contains ip", "cidr", cidr.ipNet.String(), "ip", ip.String())
+					x = append(x, match)
+					status = true
+				}
+			}
+		}
+		if len(x) > 0 {
+			found[cidr.ipNet.String()] = x
+		}
+	}
+	return status, found
+}
+
+// checkBlocklist returns true if the body contains any of the globally
+// blocked terms. The terms need to be lowercased already.
+func checkBlocklist(body string, blocklist []string) bool {
+	return findBlocked(body, blocklist) != ""
+}
+
+// findBlocked returns the first blocked term contained in the body
+func findBlocked(body string, blocklist []string) string {
+	if len(blocklist) == 0 {
+		return ""
+	}
+	lower := strings.ToLower(body)
+	for _, b := range blocklist {
+		if strings.Contains(lower, b) {
+			logger().Debug("paste contains blocked term", "term", b)
+			return b
+		}
+	}
+	return ""
+}
+
+func parseBlocklist(in []string) []string {
+	var ret []string
+	for _, b := range in {
+		b = strings.ToLower(strings.TrimSpace(b))
+		if b != "" {
+			ret = append(ret, b)
+		}
+	}
+	return ret
+}
+
+func parseCIDRs(cidrs []string) (*[]cidrType, error) {
+	var ret []cidrType
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse cidr %s: %v", c, err)
+		}
+		ret = append(ret, cidrType{ipNet: n})
+	}
+	return &ret, nil
+}
+
+// logger returns the logger of the matcher, it uses the component of the
+// scraper so the scraper can filter the messages
+func logger() *slog.Logger {
+	return slog.Default().With("component", "matcher")
+}
+
+func stringInSlice(s string, list []string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,234 @@
+package scraper
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/FireFart/pastebin_scraper/detect"
+)
+
+func intPointer(i int) *int {
+	return &i
+}
+
+func mustParseKeywords(t *testing.T, k []Keyword) *map[string]keywordType {
+	t.Helper()
+	keywords, err := parseKeywords(k)
+	if err != nil {
+		t.Fatalf("could not parse keywords: %v", err)
+	}
+	return keywords
+}
+
+func TestMatcherThreshold(t *testing.T) {
+	m := &Matcher{
+		keywords: mustParseKeywords(t, []Keyword{
+			{Keyword: "weak1", Score: intPointer(2)},
+			{Keyword: "weak2", Score: intPointer(3)},
+		}),
+		cidrs:     &[]cidrType{},
+		threshold: 5,
+	}
+
+	found, _ := m.Scan("this contains weak1 only")
+	if found {
+		t.Fatal("expected no match below threshold")
+	}
+
+	found, key := m.Scan("this contains weak1\nand weak2")
+	if !found {
+		t.Fatal("expected a match when reaching the threshold")
+	}
+	if len(key) != 2 {
+		t.Fatalf("expected 2 matched keywords, got %d", len(key))
+	}
+}
+
+func TestParseKeywordsScore(t *testing.T) {
+	var keywords []Keyword
+	if err := json.Unmarshal([]byte(`[{"keyword": "default"}, {"keyword": "tag", "score": 0}, {"keyword": "strong", "score": 5}]`), &keywords); err != nil {
+		t.Fatalf("could not parse json: %v", err)
+	}
+	k := mustParseKeywords(t, keywords)
+	for name, want := range map[string]int{"default": 1, "tag": 0, "strong": 5} {
+		if x := (*k)[name].score; x != want {
+			t.Errorf("score of %s is %d, want %d", name, x, want)
+		}
+	}
+}
+
+func TestMatcherNoThreshold(t *testing.T) {
+	m := &Matcher{
+		keywords: mustParseKeywords(t, []Keyword{{Keyword: "keyword"}}),
+		cidrs:    &[]cidrType{},
+	}
+	found, _ := m.Scan("a keyword in a paste")
+	if !found {
+		t.Fatal("expected a match without threshold")
+	}
+}
+
+func TestMatcherCIDRIgnoresThreshold(t *testing.T) {
+	cidrs, err := parseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	m := &Matcher{
+		keywords:  mustParseKeywords(t, []Keyword{{Keyword: "keyword"}}),
+		cidrs:     cidrs,
+		threshold: 10,
+	}
+	found, key := m.Scan("keyword 10.1.2.3")
+	if !found {
+		t.Fatal("expected a cidr match")
+	}
+	if _, ok := key["keyword"]; ok {
+		t.Fatal("keyword below threshold should not be reported")
+	}
+}
+
+func TestMatcherBlocklist(t *testing.T) {
+	m := &Matcher{
+		keywords:  mustParseKeywords(t, []Keyword{{Keyword: "keyword"}}),
+		cidrs:     &[]cidrType{},
+		blocklist: parseBlocklist([]string{"Minecraft", " ", "lorem ipsum"}),
+	}
+	if len(m.blocklist) != 2 {
+		t.Fatalf("expected 2 blocklist entries, got %d", len(m.blocklist))
+	}
+	found, _ := m.Scan("keyword\nLorem Ipsum dolor sit amet")
+	if found {
+		t.Fatal("expected blocked paste to not match")
+	}
+	found, _ = m.Scan("keyword\nsomething else")
+	if !found {
+		t.Fatal("expected a match on a paste without blocked terms")
+	}
+}
+
+func TestCheckKeywordsExceptions(t *testing.T) {
+	keywords := mustParseKeywords(t, []Keyword{
+		{Keyword: "line", Exceptions: []string{"ignored"}, ExceptionRegexes: []string{`id=\d+`}},
+	})
+	found, key := checkKeywords("line ignored\nline id=123\nline id=abc", keywords)
+	if !found {
+		t.Fatal("expected a match")
+	}
+	if len(key["line"]) != 1 || key["line"][0] != "line id=abc" {
+		t.Fatalf("unexpected matches: %v", key["line"])
+	}
+}
+
+func TestCheckKeywordsBodyExceptions(t *testing.T) {
+	keywords := mustParseKeywords(t, []Keyword{
+		{Keyword: "keyword", ExceptionRegexes: []string{`(?im)^test paste$`}, ExceptionsInBody: true},
+	})
+	found, _ := checkKeywords("keyword\nTEST PASTE", keywords)
+	if found {
+		t.Fatal("expected no match when the body contains an exception")
+	}
+	found, _ = checkKeywords("keyword\nother paste", keywords)
+	if !found {
+		t.Fatal("expected a match")
+	}
+}
+
+func TestParseKeywordsInvalidException(t *testing.T) {
+	_, err := parseKeywords([]Keyword{{Keyword: "keyword", ExceptionRegexes: []string{"("}}})
+	if err == nil {
+		t.Fatal("expected error on invalid exception regex")
+	}
+}
+
+func TestCheckKeywordsBoundary(t *testing.T) {
+	keywords := mustParseKeywords(t, []Keyword{
+		{Keyword: "start"},
+		{Keyword: "both", Boundary: boundaryBoth},
+		{Keyword: "none", Boundary: boundaryNone},
+	})
+	_, key := checkKeywords("start123\nxstart\nboth123\nboth.\nxxnonexx", keywords)
+	expected := map[string][]string{
+		"start": {"start123"},
+		"both":  {"both."},
+		"none":  {"xxnonexx"},
+	}
+	for k, v := range expected {
+		if len(key[k]) != len(v) || key[k][0] != v[0] {
+			t.Errorf("unexpected matches for %s: %v", k, key[k])
+		}
+	}
+}
+
+func TestParseKeywordsInvalidBoundary(t *testing.T) {
+	_, err := parseKeywords([]Keyword{{Keyword: "keyword", Boundary: "invalid"}})
+	if err == nil {
+		t.Fatal("expected error on invalid boundary")
+	}
+}
+
+func TestMatchedGroups(t *testing.T) {
+	keywords := mustParseKeywords(t, []Keyword{
+		{Keyword: "password", Group: "credentials"},
+		{Keyword: "token", Group: "credentials"},
+		{Keyword: "brand", Group: "brand"},
+		{Keyword: "other"},
+	})
+	_, key := checkKeywords("password token\nbrand\nother", keywords)
+	groups := matchedGroups(key, keywords)
+	if len(groups) != 2 || groups[0] != "brand" || groups[1] != "credentials" {
+		t.Fatalf("unexpected groups: %v", groups)
+	}
+}
+
+func TestMatcherFold(t *testing.T) {
+	m := &Matcher{
+		keywords: mustParseKeywords(t, []Keyword{{Keyword: "password"}}),
+		cidrs:    &[]cidrType{},
+	}
+	body := "pаssw0rd: secret"
+	if found, _ := m.Scan(body); found {
+		t.Fatal("expected no match without folding")
+	}
+	m.normalize = true
+	m.fold = true
+	found, key := m.Scan(body)
+	if !found {
+		t.Fatal("expected a match with folding")
+	}
+	if len(key["password"]) != 1 || key["password"][0] != body {
+		t.Fatalf("expected the original line as single match, got %v", key["password"])
+	}
+	// 1 is read as l as well as i
+	if found, key := m.Scan("a1ice: p4ssw0rd"); !found || key["password"][0] != "a1ice: p4ssw0rd" {
+		t.Fatalf("expected the original line, got %v", key)
+	}
+	m.keywords = mustParseKeywords(t, []Keyword{{Keyword: "login"}, {Keyword: "leak"}})
+	if _, key := m.Scan("10gin\n1eak"); len(key["login"]) != 1 || len(key["leak"]) != 1 {
+		t.Fatalf("expected both readings of 1 to match, got %v", key)
+	}
+}
+
+const sqlDump = `-- MySQL dump 10.13
+CREATE TABLE users (id int, username varchar(255), password varchar(255));
+`
+
+func TestMatcherDetectorGroups(t *testing.T) {
+	d, ok := detect.Lookup("sqldump")
+	if !ok {
+		t.Fatal("missing sqldump detector")
+	}
+	m := &Matcher{
+		keywords:  mustParseKeywords(t, []Keyword{}),
+		cidrs:     &[]cidrType{},
+		threshold: 10,
+		detectors: []detect.Detector{d},
+	}
+	found, key := m.Scan(sqlDump)
+	if !found {
+		t.Fatal("expected a detector match")
+	}
+	groups := m.Groups(key)
+	if len(groups) != 1 || groups[0] != "database dump" {
+		t.Fatalf("unexpected groups: %v", groups)
+	}
+}
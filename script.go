@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+const (
+	// upper bound of the starlark steps per paste so a script can not
+	// stall the workers
+	defaultScriptMaxSteps = 1000000
+)
+
+var (
+	metricScriptDropped = metrics.counter("script_dropped_total", "Number of matches dropped by the script.", "")
+	metricScriptErrors  = metrics.counter("script_errors_total", "Number of failed script runs, the match is kept unchanged.", "")
+)
+
+// scriptResult is what the script changed
+type scriptResult struct {
+	Drop     bool
+	Severity string
+	Tags     []string
+	Groups   []string
+}
+
+// matchScript post-processes the matches with the process function of a
+// starlark script. The globals are frozen after loading so the function
+// is called concurrently by the workers.
+type matchScript struct {
+	file     string
+	maxSteps uint64
+	process  *starlark.Function
+	// compiled patterns of the search builtin
+	mu       sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+func loadMatchScript(c scriptConfig) (*matchScript, error) {
+	s := &matchScript{file: c.File, maxSteps: defaultScriptMaxSteps, patterns: make(map[string]*regexp.Regexp)}
+	if c.Maxsteps > 0 {
+		s.maxSteps = uint64(c.Maxsteps)
+	}
+	predeclared := starlark.StringDict{
+		"json":   json.Module,
+		"search": starlark.NewBuiltin("search", s.search),
+	}
+	thread := s.thread()
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, c.File, nil, predeclared)
+	if err != nil {
+		return nil, fmt.Errorf("could not load script %s: %v", c.File, err)
+	}
+	fn, ok := globals["process"].(*starlark.Function)
+	if !ok {
+		return nil, fmt.Errorf("script %s does not define a process function", c.File)
+	}
+	if fn.NumParams() != 1 {
+		return nil, fmt.Errorf("the process function of %s must take one argument", c.File)
+	}
+	s.process = fn
+	return s, nil
+}
+
+func (s *matchScript) thread() *starlark.Thread {
+	t := &starlark.Thread{
+		Name: "script",
+		Print: func(_ *starlark.Thread, msg string) {
+			logger(componentMatcher).Info("script", "file", s.file, "message", msg)
+		},
+	}
+	t.SetMaxExecutionSteps(s.maxSteps)
+	return t
+}
+
+// search reports if the regular expression matches the text
+func (s *matchScript) search(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, text string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &pattern, &text); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	re, ok := s.patterns[pattern]
+	if !ok {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("%s: %v", b.Name(), err)
+		}
+		s.patterns[pattern] = re
+	}
+	s.mu.Unlock()
+	return starlark.Bool(re.MatchString(text)), nil
+}
+
+func stringList(l []string) *starlark.List {
+	values := make([]starlark.Value, 0, len(l))
+	for _, s := range l {
+		values = append(values, starlark.String(s))
+	}
+	return starlark.NewList(values)
+}
+
+// scriptPaste converts the paste into the dict passed to the script
+func scriptPaste(p *paste, score int) *starlark.Dict {
+	keywords := getKeysFromMap(p.Matches)
+	sort.Strings(keywords)
+	matches := starlark.NewDict(len(keywords))
+	for _, k := range keywords {
+		matches.SetKey(starlark.String(k), stringList(p.Matches[k])) // nolint: errcheck,gosec
+	}
+	d := starlark.NewDict(16)
+	for k, v := range map[string]starlark.Value{
+		"key":        starlark.String(p.Key),
+		"url":        starlark.String(p.FullURL),
+		"title":      starlark.String(p.Title),
+		"user":       starlark.String(p.User),
+		"syntax":     starlark.String(p.Syntax),
+		"size":       starlark.MakeInt64(p.length()),
+		"content":    starlark.String(p.Content),
+		"keywords":   stringList(keywords),
+		"matches":    matches,
+		"groups":     stringList(p.Groups),
+		"score":      starlark.MakeInt(score),
+		"confidence": starlark.MakeInt(p.Confidence),
+		"severity":   starlark.String(p.Severity),
+		"tags":       stringList(p.Tags),
+	} {
+		d.SetKey(starlark.String(k), v) // nolint: errcheck,gosec
+	}
+	return d
+}
+
+// run calls process with the paste. It returns None to keep the match,
+// False to drop it or a dict with drop, severity, tags and groups.
+func (s *matchScript) run(p *paste, score int) (scriptResult, error) {
+	var r scriptResult
+	v, err := starlark.Call(s.thread(), s.process, starlark.Tuple{scriptPaste(p, score)}, nil)
+	if err != nil {
+		if e, ok := err.(*starlark.EvalError); ok {
+			return r, fmt.Errorf("%s", e.Backtrace())
+		}
+		return r, err
+	}
+	switch v := v.(type) {
+	case starlark.NoneType:
+	case starlark.Bool:
+		r.Drop = !bool(v)
+	case *starlark.Dict:
+		for _, item := range v.Items() {
+			k, ok := starlark.AsString(item[0])
+			if !ok {
+				return r, fmt.Errorf("process returned a non string key %s", item[0])
+			}
+			switch k {
+			case "drop":
+				r.Drop = bool(item[1].Truth())
+			case "severity":
+				if r.Severity, ok = starlark.AsString(item[1]); !ok {
+					return r, fmt.Errorf("severity must be a string, got %s", item[1].Type())
+				}
+			case "tags", "groups":
+				l, err := scriptStrings(item[1])
+				if err != nil {
+					return r, fmt.Errorf("%s: %v", k, err)
+				}
+				if k == "tags" {
+					r.Tags = l
+				} else {
+					r.Groups = l
+				}
+			default:
+				return r, fmt.Errorf("process returned the unknown key %q", k)
+			}
+		}
+	default:
+		return r, fmt.Errorf("process must return None, a bool or a dict, got %s", v.Type())
+	}
+	return r, nil
+}
+
+func scriptStrings(v starlark.Value) ([]string, error) {
+	it, ok := v.(starlark.Iterable)
+	if !ok {
+		return nil, fmt.Errorf("expected a list, got %s", v.Type())
+	}
+	iter := it.Iterate()
+	defer iter.Done()
+	ret := []string{}
+	var x starlark.Value
+	for iter.Next(&x) {
+		s, ok := starlark.AsString(x)
+		if !ok {
+			return nil, fmt.Errorf("expected strings, got %s", x.Type())
+		}
+		ret = append(ret, s)
+	}
+	return ret, nil
+}
+
+// apply runs the script and changes the paste accordingly. It returns
+// false if the match is dropped. A failing script keeps the match.
+func (s *matchScript) apply(p *paste, score int) bool {
+	r, err := s.run(p, score)
+	if err != nil {
+		metricScriptErrors.inc("")
+		logger(componentMatcher).Error("script failed", "file", s.file, "paste_key", p.Key, "error", err)
+		return true
+	}
+	if r.Drop {
+		metricScriptDropped.inc("")
+		logger(componentMatcher).Debug("match dropped by script", "paste_key", p.Key)
+		return false
+	}
+	if r.Severity != "" {
+		p.Severity = r.Severity
+	}
+	if r.Tags != nil {
+		p.Tags = r.Tags
+	}
+	if r.Groups != nil {
+		p.Groups = r.Groups
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeScript(t *testing.T, src string) scriptConfig {
+	f := filepath.Join(t.TempDir(), "process.star")
+	if err := ioutil.WriteFile(f, []byte(src), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return scriptConfig{File: f}
+}
+
+func TestMatchScript(t *testing.T) {
+	s, err := loadMatchScript(writeScript(t, `
+def process(paste):
+    if search(r"(?i)example\.(com|org)", paste["content"]):
+        return False
+    if "password" in paste["keywords"] and paste["score"] >= 5:
+        return {"severity": "high", "tags": ["credentials"], "groups": paste["groups"] + ["soc"]}
+    return None
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &paste{Content: "user@example.com:secret", Matches: map[string][]string{"password": {}}}
+	if s.apply(p, 5) {
+		t.Error("expected the example match to be dropped")
+	}
+	p = &paste{Content: "admin:secret", Matches: map[string][]string{"password": {}}, Groups: []string{"leaks"}}
+	if !s.apply(p, 5) {
+		t.Fatal("expected the match to be kept")
+	}
+	if p.Severity != "high" || !reflect.DeepEqual(p.Tags, []string{"credentials"}) || !reflect.DeepEqual(p.Groups, []string{"leaks", "soc"}) {
+		t.Errorf("unexpected paste after script %+v", p)
+	}
+	p = &paste{Content: "admin:secret", Matches: map[string][]string{"password": {}}, Groups: []string{"leaks"}}
+	if !s.apply(p, 1) || p.Severity != "" || !reflect.DeepEqual(p.Groups, []string{"leaks"}) {
+		t.Errorf("expected the paste to be unchanged, got %+v", p)
+	}
+}
+
+func TestMatchScriptErrors(t *testing.T) {
+	if _, err := loadMatchScript(writeScript(t, "x = 1\n")); err == nil || !strings.Contains(err.Error(), "process function") {
+		t.Errorf("expected a missing process function error, got %v", err)
+	}
+	if _, err := loadMatchScript(writeScript(t, "def process(:\n")); err == nil {
+		t.Error("expected a syntax error")
+	}
+
+	for src, msg := range map[string]string{
+		"def process(paste):\n    return 1\n":                                "must return",
+		"def process(paste):\n    return {\"score\": 1}\n":                   "unknown key",
+		"def process(paste):\n    return {\"tags\": [1]}\n":                  "expected strings",
+		"def process(paste):\n    return paste[\"missing\"]\n":               "missing",
+		"def process(paste):\n    for i in range(10000000):\n        pass\n": "too many steps",
+	} {
+		c := writeScript(t, src)
+		c.Maxsteps = 1000
+		s, err := loadMatchScript(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := &paste{Matches: map[string][]string{"password": {}}}
+		if _, err := s.run(p, 0); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected %q for %q, got %v", msg, src, err)
+		}
+		if !s.apply(p, 0) {
+			t.Error("expected a failing script to keep the match")
+		}
+	}
+}
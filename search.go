@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/FireFart/pastebin_scraper/store"
+)
+
+const searchDateFormat = "2006-01-02"
+
+// searchQuery filters the archived pastes and stored matches. Empty
+// fields match everything.
+type searchQuery struct {
+	keyword string
+	regexp  *regexp.Regexp
+	key     string
+	from    time.Time
+	to      time.Time
+	// at most this many results, 0 for all
+	limit int
+}
+
+// searchResult is a single line found in the archive or the database
+type searchResult struct {
+	source  string
+	key     string
+	url     string
+	date    time.Time
+	keyword string
+	line    string
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// matchLine checks a single line against the keyword and the regex
+func (q searchQuery) matchLine(line string) bool {
+	if q.keyword != "" && !containsFold(line, q.keyword) {
+		return false
+	}
+	return q.regexp == nil || q.regexp.MatchString(line)
+}
+
+// matchMeta checks the paste key and the date range
+func (q searchQuery) matchMeta(key string, date time.Time) bool {
+	if q.key != "" && q.key != key {
+		return false
+	}
+	if !q.from.IsZero() && date.Before(q.from) {
+		return false
+	}
+	if !q.to.IsZero() && !date.Before(q.to) {
+		return false
+	}
+	return true
+}
+
+// searchArchive returns all matching lines of the pastes in the local archive
+func searchArchive(dir string, q searchQuery) ([]searchResult, error) {
+	var ret []searchResult
+	err := walkLocalArchive(dir, q.from, func(p paste) error {
+		date := unixToTime(p.Date).Time
+		if !q.matchMeta(p.Key, date) {
+			return nil
+		}
+		for _, line := range strings.Split(p.Content, "\n") {
+			line = strings.TrimRight(line, "\r")
+			if q.full(ret) {
+				return filepath.SkipAll
+			}
+			if q.matchLine(line) {
+				ret = append(ret, searchResult{source: "archive", key: p.Key, url: p.FullURL, date: date, line: line})
+			}
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// full reports if the limit of results is reached
+func (q searchQuery) full(results []searchResult) bool {
+	return q.limit > 0 && len(results) >= q.limit
+}
+
+// searchMatches returns the matches stored by all scrapers sharing the
+// database
+func searchMatches(ctx context.Context, db *store.SQL, q searchQuery) ([]searchResult, error) {
+	matches, err := db.Search(ctx, store.Query{Keyword: q.keyword, Regexp: q.regexp, Key: q.key, From: q.from, To: q.to, Limit: q.limit})
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]searchResult, 0, len(matches))
+	for _, m := range matches {
+		r := searchResult{source: "database", key: m.Key, url: m.URL, date: m.Date, keyword: m.Keyword, line: m.Line}
+		if r.date.IsZero() {
+			r.date = m.FoundAt
+		}
+		ret = append(ret, r)
+	}
+	return ret, nil
+}
+
+func printSearchResults(w io.Writer, results []searchResult) error {
+	tw := tabwriter.NewWriter(w, 0, 5, 3, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "SOURCE\tKEY\tDATE\tKEYWORD\tLINE"); err != nil {
+		return err
+	}
+	for _, r := range results {
+		date := ""
+		if !r.date.IsZero() {
+			date = r.date.UTC().Format(time.RFC3339)
+		}
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.source, r.key, date, r.keyword, r.line); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func parseSearchDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(searchDateFormat, s, time.UTC)
+}
+
+// runSearch implements the search subcommand. It searches the local
+// archive and the database from the config file.
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	configFile := fs.String("config", "", "Config File to use")
+	keyword := fs.String("keyword", "", "only show lines containing this keyword (case insensitive)")
+	re := fs.String("regex", "", "only show lines matching this regular expression")
+	key := fs.String("key", "", "only show this paste key")
+	from := fs.String("from", "", "only show pastes from this date on (YYYY-MM-DD)")
+	to := fs.String("to", "", "only show pastes before this date (YYYY-MM-DD)")
+	limit := fs.Int("limit", 0, "show at most this many results per source, 0 for all")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := getConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("could not read config file %s: %v", *configFile, err)
+	}
+	q := searchQuery{keyword: *keyword, key: *key, limit: *limit}
+	if *re != "" {
+		if q.regexp, err = regexp.Compile(*re); err != nil {
+			return fmt.Errorf("invalid regex %q: %v", *re, err)
+		}
+	}
+	if q.from, err = parseSearchDate(*from); err != nil {
+		return fmt.Errorf("invalid from date %q: %v", *from, err)
+	}
+	if q.to, err = parseSearchDate(*to); err != nil {
+		return fmt.Errorf("invalid to date %q: %v", *to, err)
+	}
+
+	var results []searchResult
+	searched := false
+	if config.Archive.Local.Directory != "" {
+		searched = true
+		r, err := searchArchive(config.Archive.Local.Directory, q)
+		if err != nil {
+			return fmt.Errorf("could not search archive: %v", err)
+		}
+		results = append(results, r...)
+	}
+	if config.Database.Driver != "" {
+		searched = true
+		db, err := store.Open(config.Database.Driver, config.Database.DSN)
+		if err != nil {
+			return fmt.Errorf("could not open database: %v", err)
+		}
+		defer db.Close() // nolint: errcheck
+		r, err := searchMatches(context.Background(), db, q)
+		if err != nil {
+			return fmt.Errorf("could not search database: %v", err)
+		}
+		results = append(results, r...)
+	}
+	if !searched {
+		return fmt.Errorf("neither a local archive nor a database is configured")
+	}
+	return printSearchResults(os.Stdout, results)
+}
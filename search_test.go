@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FireFart/pastebin_scraper/store"
+)
+
+func TestSearchQuery(t *testing.T) {
+	q := searchQuery{
+		keyword: "Password",
+		regexp:  regexp.MustCompile(`=\s*\w+`),
+		from:    time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC),
+		to:      time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if !q.matchLine("password = secret") {
+		t.Error("expected line to match")
+	}
+	if q.matchLine("password") {
+		t.Error("expected line without regex match to be skipped")
+	}
+	if !q.matchMeta("abc", time.Date(2020, 5, 20, 0, 0, 0, 0, time.UTC)) {
+		t.Error("expected date in range to match")
+	}
+	if q.matchMeta("abc", time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("expected end date to be exclusive")
+	}
+	q.key = "xyz"
+	if q.matchMeta("abc", time.Date(2020, 5, 20, 0, 0, 0, 0, time.UTC)) {
+		t.Error("expected different key to be skipped")
+	}
+}
+
+func TestSearchArchive(t *testing.T) {
+	dir := t.TempDir()
+	l, err := newLocalArchiver(local{Directory: dir})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	ctx := context.Background()
+	for _, p := range []paste{
+		{Key: "abc", Date: "1590000000", Content: "first line\npassword=secret\n"},
+		{Key: "def", Date: "1580000000", Content: "password=old\n"},
+	} {
+		if _, err := l.archive(ctx, p); err != nil {
+			t.Fatalf("could not archive: %v", err)
+		}
+	}
+
+	results, err := searchArchive(dir, searchQuery{keyword: "password", from: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("could not search: %v", err)
+	}
+	if len(results) != 1 || results[0].key != "abc" || results[0].line != "password=secret" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	var buf bytes.Buffer
+	if err := printSearchResults(&buf, results); err != nil {
+		t.Fatalf("could not print results: %v", err)
+	}
+	if !strings.Contains(buf.String(), "2020-05-20T") {
+		t.Fatalf("date missing in output: %s", buf.String())
+	}
+}
+
+func TestSearchMatches(t *testing.T) {
+	s, err := store.Open(store.SQLite, filepath.Join(t.TempDir(), "matches.db"))
+	if err != nil {
+		t.Fatalf("could not open store: %v", err)
+	}
+	defer s.Close() // nolint: errcheck
+
+	ctx := context.Background()
+	p := paste{
+		Key:     "abc",
+		Date:    "1590000000",
+		Matches: map[string][]string{"password": {"password=secret"}, "other": {"other line"}},
+	}
+	if err := s.SaveMatches(ctx, p.alert(), time.Now()); err != nil {
+		t.Fatalf("could not save matches: %v", err)
+	}
+
+	results, err := searchMatches(ctx, s, searchQuery{keyword: "password", key: "abc"})
+	if err != nil {
+		t.Fatalf("could not search: %v", err)
+	}
+	if len(results) != 1 || results[0].keyword != "password" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	results, err = searchMatches(ctx, s, searchQuery{to: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("could not search: %v", err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("expected no results before the paste date, got %+v", results)
+	}
+	results, err = searchMatches(ctx, s, searchQuery{keyword: "LINE", limit: 5})
+	if err != nil {
+		t.Fatalf("could not search: %v", err)
+	}
+	if len(results) != 1 || results[0].line != "other line" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if results, err = searchMatches(ctx, s, searchQuery{limit: 1}); err != nil || len(results) != 1 {
+		t.Fatalf("expected a single result, got %+v, %v", results, err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// resolveSecrets replaces all config values referencing a secret in
+// vault (vault:secret/path#key), AWS Secrets Manager
+// (secretsmanager:name#key) or the SSM Parameter Store (ssm:/path). The
+// shortest lease of the vault secrets is recorded for refreshing.
+func resolveSecrets(ctx context.Context, c *configuration) error {
+	var v *vaultClient
+	var a *awsSecretsClient
+	var lease time.Duration
+	err := walkStrings(reflect.ValueOf(c).Elem(), func(s string) (string, error) {
+		var err error
+		switch {
+		case strings.HasPrefix(s, vaultPrefix):
+			if v == nil {
+				if v, err = newVaultClient(c.Vault); err != nil {
+					return "", err
+				}
+			}
+			value, d, err := v.get(ctx, strings.TrimPrefix(s, vaultPrefix))
+			if err != nil {
+				return "", fmt.Errorf("vault: %v", err)
+			}
+			if d > 0 && (lease == 0 || d < lease) {
+				lease = d
+			}
+			return value, nil
+		case strings.HasPrefix(s, secretsManagerPrefix), strings.HasPrefix(s, ssmPrefix):
+			if a == nil {
+				if a, err = newAWSSecretsClient(ctx, c.AWS); err != nil {
+					return "", err
+				}
+			}
+			if strings.HasPrefix(s, ssmPrefix) {
+				value, err := a.getParameter(ctx, strings.TrimPrefix(s, ssmPrefix))
+				if err != nil {
+					return "", fmt.Errorf("ssm: %v", err)
+				}
+				return value, nil
+			}
+			value, err := a.getSecret(ctx, strings.TrimPrefix(s, secretsManagerPrefix))
+			if err != nil {
+				return "", fmt.Errorf("secretsmanager: %v", err)
+			}
+			return value, nil
+		}
+		return s, nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not resolve secret: %v", err)
+	}
+	c.secretsLease = lease
+	return nil
+}
+
+// walkStrings calls fn for all strings in the struct and replaces them
+// with the returned value
+func walkStrings(v reflect.Value, fn func(string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := walkStrings(v.Field(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkStrings(v.Index(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		// map values are not addressable, so they are copied and set again
+		for _, k := range v.MapKeys() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			if err := walkStrings(e, fn); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, e)
+		}
+	case reflect.String:
+		s, err := fn(v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const (
+	// how long the queued notifications may take after an interrupt
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+var (
+	// replaced in tests
+	exit = os.Exit
+)
+
+// handleShutdown cancels the context on the first signal so sleeps and
+// running requests return immediately. A second signal exits without
+// waiting for the queued notifications.
+func handleShutdown(signals <-chan os.Signal, cancel context.CancelFunc) {
+	<-signals
+	slog.Info("shutting down, interrupt again to force quit")
+	cancel()
+	<-signals
+	slog.Warn("forcing shutdown")
+	exit(1)
+}
+
+// drainOutput closes the output channel and waits until the notifier sent
+// the pastes left in the queue. After the timeout the running notifications are
+// canceled and the remaining pastes are dropped.
+func drainOutput(out chan paste, queue <-chan paste, done <-chan struct{}, cancel context.CancelFunc, timeout time.Duration) {
+	close(out)
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-done:
+	case <-t.C:
+		slog.Warn("shutdown timeout reached, dropping queued notifications", "timeout", timeout, "queued", len(queue))
+		cancel()
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHandleShutdown(t *testing.T) {
+	exited := make(chan int, 1)
+	exit = func(code int) { exited <- code }
+	defer func() { exit = os.Exit }()
+
+	signals := make(chan os.Signal)
+	ctx, cancel := context.WithCancel(context.Background())
+	go handleShutdown(signals, cancel)
+
+	signals <- os.Interrupt
+	<-ctx.Done()
+	select {
+	case <-exited:
+		t.Fatal("expected the first signal not to exit")
+	default:
+	}
+	signals <- os.Interrupt
+	if code := <-exited; code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+}
+
+func TestDrainOutput(t *testing.T) {
+	out := make(chan paste, 1)
+	done := make(chan struct{})
+	out <- paste{Key: "a"}
+	go func() {
+		defer close(done)
+		for range out {
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	drainOutput(out, out, done, cancel, time.Minute)
+	if ctx.Err() != nil {
+		t.Fatal("expected the notifications not to be canceled")
+	}
+
+	// a notifier which never finishes is canceled after the timeout
+	ctx, cancel = context.WithCancel(context.Background())
+	start := time.Now()
+	drainOutput(make(chan paste), nil, make(chan struct{}), cancel, 10*time.Millisecond)
+	if ctx.Err() == nil {
+		t.Fatal("expected the notifications to be canceled")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("expected drain to return after the timeout, took %v", d)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	formatCEF  = "cef"
+	formatLEEF = "leef"
+
+	siemVendor  = "FireFart"
+	siemProduct = "pastebin_scraper"
+	siemVersion = "1.0"
+	siemEventID = "match"
+
+	defaultSIEMSeverity = 5
+	// facility local0 and severity warning
+	syslogPriority = 16*8 + 4
+)
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+	leefEscaper         = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+)
+
+type siemField struct {
+	cef   string
+	leef  string
+	value string
+}
+
+// siemFields returns the event attributes of the paste with their CEF
+// and LEEF names
+func siemFields(p paste, hostname string, t time.Time) []siemField {
+	keywords := getKeysFromMap(p.Matches)
+	sort.Strings(keywords)
+	fields := []siemField{
+		{"rt", "devTime", strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)},
+		{"dvchost", "identHostName", hostname},
+		{"request", "url", p.FullURL},
+		{"fname", "pasteKey", p.Key},
+		{"msg", "title", p.Title},
+		{"suser", "usrName", p.User},
+		{"cs1Label", "", "keywords"},
+		{"cs1", "keywords", strings.Join(keywords, ",")},
+		{"cs2Label", "", "groups"},
+		{"cs2", "groups", strings.Join(p.Groups, ",")},
+		{"cs3Label", "", "hash"},
+		{"cs3", "hash", p.Hash},
+	}
+	var ret []siemField
+	for _, f := range fields {
+		if f.value != "" {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+// formatCEFEvent serializes the paste in the ArcSight Common Event Format
+func formatCEFEvent(p paste, hostname string, severity int, t time.Time) string {
+	var ext []string
+	for _, f := range siemFields(p, hostname, t) {
+		ext = append(ext, fmt.Sprintf("%s=%s", f.cef, cefExtensionEscaper.Replace(f.value)))
+	}
+	keywords := getKeysFromMap(p.Matches)
+	sort.Strings(keywords)
+	name := fmt.Sprintf("Pastebin Alert for %s", strings.Join(keywords, ", "))
+	return fmt.Sprintf("CEF:0|%s|%s|%s|%s|%s|%d|%s", siemVendor, siemProduct, siemVersion, siemEventID,
+		cefHeaderEscaper.Replace(name), severity, strings.Join(ext, " "))
+}
+
+// formatLEEFEvent serializes the paste in the QRadar Log Event Extended Format
+func formatLEEFEvent(p paste, hostname string, severity int, t time.Time) string {
+	ext := []string{fmt.Sprintf("sev=%d", severity), "devTimeFormat=epoch"}
+	for _, f := range siemFields(p, hostname, t) {
+		if f.leef != "" {
+			ext = append(ext, fmt.Sprintf("%s=%s", f.leef, leefEscaper.Replace(f.value)))
+		}
+	}
+	return fmt.Sprintf("LEEF:1.0|%s|%s|%s|%s|%s", siemVendor, siemProduct, siemVersion, siemEventID, strings.Join(ext, "\t"))
+}
+
+// siemSink writes CEF or LEEF events to a file or a syslog server
+type siemSink struct {
+	config   siem
+	hostname string
+	severity int
+	format   func(p paste, hostname string, severity int, t time.Time) string
+
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+func newSIEMSink(c siem) (*siemSink, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("could not get hostname: %v", err)
+	}
+	s := &siemSink{config: c, hostname: hostname, severity: c.Severity}
+	if s.severity <= 0 {
+		s.severity = defaultSIEMSeverity
+	}
+	switch c.Format {
+	case formatCEF:
+		s.format = formatCEFEvent
+	case formatLEEF:
+		s.format = formatLEEFEvent
+	default:
+		return nil, fmt.Errorf("unsupported siem format %q", c.Format)
+	}
+	if (c.File == "") == (c.Syslog == "") {
+		return nil, fmt.Errorf("either a file or a syslog server must be configured")
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *siemSink) open() error {
+	if s.config.File != "" {
+		f, err := os.OpenFile(s.config.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640) // nolint: gosec
+		if err != nil {
+			return fmt.Errorf("could not open %s: %v", s.config.File, err)
+		}
+		s.w = f
+		return nil
+	}
+	u, err := url.Parse(s.config.Syslog)
+	if err != nil {
+		return fmt.Errorf("invalid syslog address %q: %v", s.config.Syslog, err)
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return fmt.Errorf("unsupported syslog protocol %q", u.Scheme)
+	}
+	conn, err := net.DialTimeout(u.Scheme, u.Host, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("could not connect to syslog server: %v", err)
+	}
+	s.w = conn
+	return nil
+}
+
+// line frames the event as a RFC 5424 syslog message if needed
+func (s *siemSink) line(event string, t time.Time) string {
+	if s.config.Syslog == "" {
+		return event + "\n"
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s - - - %s\n", syslogPriority, t.UTC().Format(time.RFC3339), s.hostname, siemProduct, event)
+}
+
+// write sends the event and reconnects once if the connection was lost
+func (s *siemSink) write(p paste) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	b := []byte(s.line(s.format(p, s.hostname, s.severity, now), now))
+	if _, err := s.w.Write(b); err == nil || s.config.Syslog == "" {
+		return err
+	}
+	s.w.Close() // nolint: errcheck,gosec
+	if err := s.open(); err != nil {
+		return err
+	}
+	_, err := s.w.Write(b)
+	return err
+}
+
+func (s *siemSink) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Close()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var siemTestPaste = paste{
+	Key:     "abc",
+	FullURL: "https://pastebin.com/abc",
+	Title:   "a=b|c\nd",
+	Hash:    "hash",
+	Groups:  []string{"team"},
+	Matches: map[string][]string{"b": {"line"}, "a": {"line"}},
+}
+
+func TestFormatCEFEvent(t *testing.T) {
+	x := formatCEFEvent(siemTestPaste, "host", 7, time.Unix(1590000000, 0))
+	expected := `CEF:0|FireFart|pastebin_scraper|1.0|match|Pastebin Alert for a, b|7|rt=1590000000000 dvchost=host request=https://pastebin.com/abc fname=abc msg=a\=b|c\nd cs1Label=keywords cs1=a,b cs2Label=groups cs2=team cs3Label=hash cs3=hash`
+	if x != expected {
+		t.Fatalf("unexpected event\n%s\nexpected\n%s", x, expected)
+	}
+}
+
+func TestFormatLEEFEvent(t *testing.T) {
+	x := formatLEEFEvent(siemTestPaste, "host", 7, time.Unix(1590000000, 0))
+	expected := "LEEF:1.0|FireFart|pastebin_scraper|1.0|match|sev=7\tdevTimeFormat=epoch\tdevTime=1590000000000\tidentHostName=host\turl=https://pastebin.com/abc\tpasteKey=abc\ttitle=a=b|c d\tkeywords=a,b\tgroups=team\thash=hash"
+	if x != expected {
+		t.Fatalf("unexpected event\n%q\nexpected\n%q", x, expected)
+	}
+}
+
+func TestSIEMSinkFile(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "events.log")
+	s, err := newSIEMSink(siem{Format: formatLEEF, File: f})
+	if err != nil {
+		t.Fatalf("could not create sink: %v", err)
+	}
+	if err := s.write(siemTestPaste); err != nil {
+		t.Fatalf("could not write: %v", err)
+	}
+	if err := s.close(); err != nil {
+		t.Fatalf("could not close: %v", err)
+	}
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		t.Fatalf("could not read file: %v", err)
+	}
+	if !strings.HasPrefix(string(b), "LEEF:1.0|") || strings.Count(string(b), "\n") != 1 {
+		t.Fatalf("unexpected file content %q", b)
+	}
+}
+
+func TestSIEMSinkSyslog(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	defer l.Close() // nolint: errcheck
+
+	lines := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close() // nolint: errcheck
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		lines <- line
+	}()
+
+	s, err := newSIEMSink(siem{Format: formatCEF, Syslog: "tcp://" + l.Addr().String()})
+	if err != nil {
+		t.Fatalf("could not create sink: %v", err)
+	}
+	defer s.close() // nolint: errcheck
+	if err := s.write(siemTestPaste); err != nil {
+		t.Fatalf("could not write: %v", err)
+	}
+	line := <-lines
+	if !strings.HasPrefix(line, "<132>1 ") || !strings.Contains(line, " pastebin_scraper - - - CEF:0|") {
+		t.Fatalf("unexpected syslog message %q", line)
+	}
+}
+
+func TestNewSIEMSinkInvalid(t *testing.T) {
+	for _, c := range []siem{
+		{Format: "xml", File: "x"},
+		{Format: formatCEF},
+		{Format: formatCEF, Syslog: "http://localhost"},
+	} {
+		if _, err := newSIEMSink(c); err == nil {
+			t.Errorf("expected error for %+v", c)
+		}
+	}
+}
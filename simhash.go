@@ -0,0 +1,132 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	nearDuplicateSuppress = "suppress"
+	nearDuplicateGroup    = "group"
+	// fingerprints differing in at most this many bits are near duplicates
+	defaultNearDuplicateDistance = 3
+	defaultNearDuplicateWindow   = 24 * time.Hour
+	// words per shingle
+	simhashShingle = 3
+	// upper bound of the remembered alerts, the oldest are dropped
+	maxNearDuplicateEntries = 10000
+)
+
+var (
+	metricNearDuplicates = metrics.counter("near_duplicates_total", "Number of alerts for near duplicates of recent alerts.", "mode")
+)
+
+// simhash returns the 64 bit fingerprint of the word shingles of the body.
+// Similar bodies have fingerprints differing in few bits, so a reposted
+// combolist with some lines added or changed is still recognized.
+func simhash(body string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(body), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return 0
+	}
+	var v [64]int
+	add := func(shingle []string) {
+		h := fnv.New64a()
+		for _, w := range shingle {
+			h.Write([]byte(w)) // nolint: errcheck,gosec
+			h.Write([]byte{0}) // nolint: errcheck,gosec
+		}
+		x := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if x&(1<<uint(i)) != 0 {
+				v[i]++
+			} else {
+				v[i]--
+			}
+		}
+	}
+	if len(words) < simhashShingle {
+		add(words)
+	}
+	for i := 0; i+simhashShingle <= len(words); i++ {
+		add(words[i : i+simhashShingle])
+	}
+	var ret uint64
+	for i := 0; i < 64; i++ {
+		if v[i] > 0 {
+			ret |= 1 << uint(i)
+		}
+	}
+	return ret
+}
+
+type simhashEntry struct {
+	hash   uint64
+	key    string
+	tenant string
+	at     time.Time
+}
+
+// nearDuplicates remembers the fingerprints of the recent alerts. Tenants
+// are separated so an alert is never related to one of another tenant.
+type nearDuplicates struct {
+	mu       sync.Mutex
+	distance int
+	window   time.Duration
+	entries  []simhashEntry
+}
+
+func newNearDuplicates(distance int, window time.Duration) *nearDuplicates {
+	if distance <= 0 {
+		distance = defaultNearDuplicateDistance
+	}
+	if window <= 0 {
+		window = defaultNearDuplicateWindow
+	}
+	return &nearDuplicates{distance: distance, window: window}
+}
+
+// check returns the key of a recent alert of the tenant with a similar
+// fingerprint. Otherwise the fingerprint is remembered and false returned.
+func (n *nearDuplicates) check(tenant, key string, hash uint64, now time.Time) (string, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	i := 0
+	for i < len(n.entries) && now.Sub(n.entries[i].at) > n.window {
+		i++
+	}
+	n.entries = n.entries[i:]
+	for _, e := range n.entries {
+		if e.tenant == tenant && e.key != key && bits.OnesCount64(e.hash^hash) <= n.distance {
+			return e.key, true
+		}
+	}
+	n.entries = append(n.entries, simhashEntry{hash: hash, key: key, tenant: tenant, at: now})
+	if len(n.entries) > maxNearDuplicateEntries {
+		n.entries = n.entries[len(n.entries)-maxNearDuplicateEntries:]
+	}
+	return "", false
+}
+
+// filter suppresses near duplicates or marks them as duplicate of the
+// first alert so their mails are threaded. It returns false if the alert
+// is not sent.
+func (n *nearDuplicates) filter(mode string, p *paste, now time.Time) bool {
+	orig, dup := n.check(p.Tenant, p.Key, simhash(p.Content), now)
+	if !dup {
+		return true
+	}
+	metricNearDuplicates.inc(mode)
+	if mode == nearDuplicateSuppress {
+		logger(componentMatcher).Info("suppressing near duplicate", "paste_key", p.Key, "duplicate_of", orig)
+		return false
+	}
+	p.DuplicateOf = orig
+	return true
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+	"testing"
+	"time"
+)
+
+func combolist(n, offset int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "user%d@mail.example:secret%d\n", i+offset, (i+offset)*7)
+	}
+	return b.String()
+}
+
+func TestSimhash(t *testing.T) {
+	orig := combolist(300, 0)
+	edited := "fresh combo, enjoy\n" + strings.Replace(orig, "user5@", "someone@", 1) + "user999@mail.example:x\n"
+	if d := bits.OnesCount64(simhash(orig) ^ simhash(edited)); d > defaultNearDuplicateDistance {
+		t.Errorf("expected a near duplicate, got a distance of %d", d)
+	}
+	other := combolist(300, 5000)
+	if d := bits.OnesCount64(simhash(orig) ^ simhash("lorem ipsum dolor sit amet "+other)); d <= defaultNearDuplicateDistance {
+		t.Errorf("expected different pastes to differ, got a distance of %d", d)
+	}
+	if simhash(orig) != simhash(strings.ToUpper(orig)) {
+		t.Error("expected the fingerprint to ignore the case")
+	}
+}
+
+func TestNearDuplicates(t *testing.T) {
+	now := time.Now()
+	n := newNearDuplicates(0, time.Hour)
+	if _, dup := n.check("", "a", 0xff, now); dup {
+		t.Fatal("expected the first paste to be new")
+	}
+	if orig, dup := n.check("", "b", 0xfe, now); !dup || orig != "a" {
+		t.Errorf("expected b to duplicate a, got %q %v", orig, dup)
+	}
+	if _, dup := n.check("acme", "c", 0xff, now); dup {
+		t.Error("expected tenants to be separated")
+	}
+	if _, dup := n.check("", "d", 0xff, now.Add(2*time.Hour)); dup {
+		t.Error("expected the old alert to be expired")
+	}
+
+	n = newNearDuplicates(0, time.Hour)
+	body := combolist(100, 0)
+	first := &paste{Key: "a", Content: body}
+	if !n.filter(nearDuplicateGroup, first, now) || first.DuplicateOf != "" {
+		t.Fatalf("unexpected first alert %+v", first)
+	}
+	second := &paste{Key: "b", Content: body + "one more:line\n"}
+	if !n.filter(nearDuplicateGroup, second, now) || second.DuplicateOf != "a" {
+		t.Errorf("expected the duplicate to be grouped, got %+v", second)
+	}
+	if n.filter(nearDuplicateSuppress, &paste{Key: "c", Content: body}, now) {
+		t.Error("expected the duplicate to be suppressed")
+	}
+}
+
+func TestAlertMessageID(t *testing.T) {
+	if id := alertMessageID("", "abc", "Scraper <scraper@example.com>"); id != "<abc.alert@example.com>" {
+		t.Errorf("unexpected message id %s", id)
+	}
+	if id := alertMessageID("acme", "abc", ""); id != "<acme.abc.alert@pastebin_scraper>" {
+		t.Errorf("unexpected message id %s", id)
+	}
+}
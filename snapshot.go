@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	snapshotPDF = "pdf"
+	snapshotPNG = "png"
+
+	defaultSnapshotTimeout = time.Minute
+	// characters per line of the built-in renderer
+	snapshotWidth = 95
+	// lines per A4 page in 9pt courier
+	snapshotPageLines = 70
+	// huge pastes are cut so a snapshot stays readable and small
+	maxSnapshotPages    = 200
+	maxSnapshotPNGLines = 2000
+)
+
+var (
+	metricSnapshotErrors = metrics.counter("snapshot_errors_total", "Number of matched pastes archived without a snapshot.", "")
+)
+
+// renderSnapshot returns a visual copy of the paste. The command renders
+// the paste page, e.g. with a headless browser, otherwise the metadata and
+// the content are rendered as text.
+func renderSnapshot(ctx context.Context, c snapshotConfig, p paste, at time.Time) ([]byte, error) {
+	if len(c.Command) > 0 {
+		return runSnapshotCommand(ctx, c, p)
+	}
+	lines := snapshotLines(p, at)
+	if snapshotFormat(c) == snapshotPNG {
+		return snapshotImage(lines)
+	}
+	return snapshotDocument(p, lines, at), nil
+}
+
+func (c snapshotConfig) enabled() bool {
+	return c.Enabled || len(c.Command) > 0
+}
+
+func snapshotFormat(c snapshotConfig) string {
+	if c.Format == "" {
+		return snapshotPDF
+	}
+	return c.Format
+}
+
+// snapshotFile returns the path of the snapshot next to the archived file
+func snapshotFile(f string, c snapshotConfig) string {
+	return strings.TrimSuffix(f, ".txt.gz") + "." + snapshotFormat(c)
+}
+
+// runSnapshotCommand runs the renderer with {url} and {output} replaced in
+// the arguments and returns the file it wrote
+func runSnapshotCommand(ctx context.Context, c snapshotConfig, p paste) ([]byte, error) {
+	timeout := defaultSnapshotTimeout
+	if c.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(c.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid timeout %q: %v", c.Timeout, err)
+		}
+	}
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck,gosec
+	output := filepath.Join(dir, p.Key+"."+snapshotFormat(c))
+	r := strings.NewReplacer("{url}", p.FullURL, "{output}", output)
+	args := make([]string, 0, len(c.Command))
+	for _, a := range c.Command {
+		args = append(args, r.Replace(a))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) // nolint: gosec
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("renderer killed after %v", timeout)
+		}
+		o := out.String()
+		if len(o) > maxHookOutput {
+			o = o[:maxHookOutput]
+		}
+		return nil, fmt.Errorf("renderer failed: %v: %s", err, strings.TrimSpace(o))
+	}
+	b, err := ioutil.ReadFile(output) // nolint: gosec
+	if err != nil {
+		return nil, fmt.Errorf("renderer did not write %s: %v", filepath.Base(output), err)
+	}
+	return b, nil
+}
+
+// snapshotLines returns the header with the metadata and the hash of the
+// content followed by the wrapped content
+func snapshotLines(p paste, at time.Time) []string {
+	date := p.Date
+	if i, err := strconv.ParseInt(p.Date, 10, 64); err == nil && i > 0 {
+		date = time.Unix(i, 0).UTC().Format(time.RFC3339)
+	}
+	keywords := getKeysFromMap(p.Matches)
+	sort.Strings(keywords)
+	var lines []string
+	for _, l := range []string{
+		"Paste:    " + p.Key,
+		"URL:      " + p.FullURL,
+		"Title:    " + p.Title,
+		"User:     " + p.User,
+		"Date:     " + date,
+		"Captured: " + at.UTC().Format(time.RFC3339),
+		"Keywords: " + strings.Join(keywords, ", "),
+		"SHA-256:  " + sha256Hex([]byte(p.Content)),
+	} {
+		lines = append(lines, wrapSnapshotLine(l)...)
+	}
+	lines = append(lines, strings.Repeat("-", snapshotWidth))
+	for _, l := range strings.Split(strings.TrimRight(p.Content, "\n"), "\n") {
+		lines = append(lines, wrapSnapshotLine(l)...)
+	}
+	return lines
+}
+
+// wrapSnapshotLine splits the line at the width of the page so nothing is
+// cut off
+func wrapSnapshotLine(l string) []string {
+	r := []rune(strings.ReplaceAll(strings.TrimRight(l, "\r"), "\t", "    "))
+	if len(r) <= snapshotWidth {
+		return []string{string(r)}
+	}
+	var ret []string
+	for len(r) > snapshotWidth {
+		ret = append(ret, string(r[:snapshotWidth]))
+		r = r[snapshotWidth:]
+	}
+	return append(ret, string(r))
+}
+
+// pdfString encodes the text as escaped latin-1 string, other characters
+// are not part of the standard fonts
+func pdfString(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r < 0x20 || (r >= 0x7f && r < 0xa0) || r > 0xff:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// snapshotDocument writes a pdf with the lines in courier on A4 pages
+func snapshotDocument(p paste, lines []string, at time.Time) []byte {
+	if max := maxSnapshotPages * snapshotPageLines; len(lines) > max {
+		lines = append(lines[:max-1:max-1], fmt.Sprintf("[truncated after %d pages]", maxSnapshotPages))
+	}
+	var pages [][]string
+	for len(lines) > 0 {
+		n := snapshotPageLines
+		if n > len(lines) {
+			n = len(lines)
+		}
+		pages = append(pages, lines[:n])
+		lines = lines[n:]
+	}
+
+	// 1 catalog, 2 pages, 3 font, then a page and its content per page
+	// and the document info last
+	var objects []string
+	kids := make([]string, 0, len(pages))
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 4+2*i))
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	for i, page := range pages {
+		var c strings.Builder
+		c.WriteString("BT /F1 9 Tf 11 TL 40 802 Td\n")
+		for _, l := range page {
+			c.WriteString(pdfString(l))
+			c.WriteString(" Tj T*\n")
+		}
+		c.WriteString("ET")
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", c.Len(), c.String()))
+	}
+	objects = append(objects, fmt.Sprintf("<< /Title %s /Producer (pastebin_scraper) /CreationDate (D:%s) >>",
+		pdfString("Paste "+p.Key), at.UTC().Format("20060102150405Z")))
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, 0, len(objects))
+	for i, o := range objects {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, len(objects), xref)
+	return b.Bytes()
+}
+
+// snapshotImage draws the lines in a fixed font on a white png
+func snapshotImage(lines []string) ([]byte, error) {
+	if len(lines) > maxSnapshotPNGLines {
+		lines = append(lines[:maxSnapshotPNGLines-1:maxSnapshotPNGLines-1], fmt.Sprintf("[truncated after %d lines]", maxSnapshotPNGLines))
+	}
+	face := basicfont.Face7x13
+	const margin = 10
+	img := image.NewRGBA(image.Rect(0, 0, 2*margin+snapshotWidth*face.Advance, 2*margin+len(lines)*face.Height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	d := font.Drawer{Dst: img, Src: image.NewUniform(color.Black), Face: face}
+	for i, l := range lines {
+		d.Dot = fixed.P(margin, margin+i*face.Height+face.Ascent)
+		d.DrawString(l)
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
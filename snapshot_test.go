@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotLines(t *testing.T) {
+	p := paste{Key: "abc", FullURL: "https://pastebin.com/abc", Date: "1580000000", Content: "a\tb\n" + strings.Repeat("x", snapshotWidth+5) + "\n",
+		Matches: map[string][]string{"password": {"x"}}}
+	lines := snapshotLines(p, time.Date(2020, 1, 26, 12, 0, 0, 0, time.UTC))
+	for _, want := range []string{"Paste:    abc", "Date:     2020-01-26T00:53:20Z", "Captured: 2020-01-26T12:00:00Z", "Keywords: password", "a    b"} {
+		if !stringInSlice(want, lines) {
+			t.Errorf("missing line %q in %q", want, lines)
+		}
+	}
+	if l := lines[len(lines)-1]; l != "xxxxx" {
+		t.Errorf("long line not wrapped, last line %q", l)
+	}
+}
+
+func TestSnapshotDocument(t *testing.T) {
+	p := paste{Key: "abc", Content: strings.Repeat("line (with) braces\n", snapshotPageLines)}
+	b := snapshotDocument(p, snapshotLines(p, time.Now()), time.Now())
+	s := string(b)
+	if !strings.HasPrefix(s, "%PDF-1.4\n") || !strings.HasSuffix(s, "%%EOF\n") {
+		t.Fatalf("not a pdf: %q", s[:20])
+	}
+	if !strings.Contains(s, "/Count 2") {
+		t.Errorf("expected two pages")
+	}
+	if !strings.Contains(s, `(line \(with\) braces) Tj`) {
+		t.Errorf("text not escaped")
+	}
+	// the xref offsets must point to the objects
+	i := strings.Index(s, "xref\n")
+	for n, l := range strings.Split(s[i:], "\n")[3:6] {
+		off, err := strconv.Atoi(strings.Fields(l)[0])
+		if err != nil {
+			t.Fatalf("invalid xref line %q", l)
+		}
+		if want := strings.TrimSpace(strings.Fields(s[off:])[0]); want != strconv.Itoa(n+1) {
+			t.Errorf("offset %d points to object %s", off, want)
+		}
+	}
+}
+
+func TestSnapshotImage(t *testing.T) {
+	b, err := renderSnapshot(context.Background(), snapshotConfig{Enabled: true, Format: snapshotPNG}, paste{Key: "abc", Content: "one\ntwo"}, time.Now())
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("invalid png: %v", err)
+	}
+	if w := img.Bounds().Dx(); w != 20+snapshotWidth*7 {
+		t.Errorf("unexpected width %d", w)
+	}
+}
+
+func TestSnapshotCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	c := snapshotConfig{Format: snapshotPNG, Command: []string{"sh", "-c", `printf '%s' "$0" > "$1"`, "{url}", "{output}"}}
+	b, err := renderSnapshot(context.Background(), c, paste{Key: "abc", FullURL: "https://pastebin.com/abc"}, time.Now())
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if string(b) != "https://pastebin.com/abc" {
+		t.Errorf("unexpected output %q", b)
+	}
+	c.Command = []string{"sh", "-c", "echo broken; exit 1"}
+	if _, err := renderSnapshot(context.Background(), c, paste{Key: "abc"}, time.Now()); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected the output in the error, got %v", err)
+	}
+}
+
+func TestLocalArchiverSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	a, err := newLocalArchiver(local{Directory: dir, Evidence: true, Snapshot: snapshotConfig{Enabled: true}})
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if _, err := a.archive(context.Background(), paste{Key: "nomatch", Date: "1580000000", Content: "content"}); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2020", "01", "26", "nomatch.pdf")); !os.IsNotExist(err) {
+		t.Errorf("snapshot written for a paste without matches")
+	}
+	p := paste{Key: "abc", Date: "1580000000", Content: "content", Matches: map[string][]string{"content": {"content"}}}
+	if _, err := a.archive(context.Background(), p); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(dir, "2020", "01", "26", "abc.pdf")); err != nil {
+		t.Fatalf("snapshot not written: %v", err)
+	}
+	if _, n, err := verifyChain(dir, true); err != nil || n != 5 {
+		t.Errorf("expected 5 chained files, got %d, %v", n, err)
+	}
+}
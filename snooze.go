@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// keywords snoozed at runtime, kept over reloads but not restarts
+	snoozes = newSnoozeList()
+)
+
+// snoozeList ignores keywords until a point in time, for example while a
+// known benign campaign floods a term
+type snoozeList struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+type snooze struct {
+	Keyword string    `json:"keyword"`
+	Until   time.Time `json:"until"`
+}
+
+func newSnoozeList() *snoozeList {
+	return &snoozeList{until: make(map[string]time.Time)}
+}
+
+func (s *snoozeList) snooze(keyword string, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.until[keyword] = until
+}
+
+// unsnooze returns false if the keyword was not snoozed
+func (s *snoozeList) unsnooze(keyword string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.until[keyword]
+	delete(s.until, keyword)
+	return ok
+}
+
+// active returns the snoozes which did not expire yet sorted by keyword
+func (s *snoozeList) active(t time.Time) []snooze {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]snooze, 0, len(s.until))
+	for k, until := range s.until {
+		if !until.After(t) {
+			delete(s.until, k)
+			continue
+		}
+		ret = append(ret, snooze{Keyword: k, Until: until})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Keyword < ret[j].Keyword })
+	return ret
+}
+
+// filter removes the matches of the snoozed keywords
+func (s *snoozeList) filter(found map[string][]string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, until := range s.until {
+		if _, ok := found[k]; ok && until.After(t) {
+			logger(componentMatcher).Debug("keyword is snoozed", "keyword", k, "until", until)
+			delete(found, k)
+		}
+	}
+}
+
+// runSnooze implements the snooze subcommand. It snoozes a keyword of the
+// running scraper through the api.
+func runSnooze(args []string) error {
+	fs := flag.NewFlagSet("snooze", flag.ExitOnError)
+	configFile := fs.String("config", "", "Config File to use")
+	duration := fs.Duration("duration", 4*time.Hour, "how long to snooze the keyword, 0 ends the snooze")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snooze [flags] <keyword>\n") // nolint: errcheck,gosec
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one keyword")
+	}
+	keyword := fs.Arg(0)
+	c, err := getConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("could not read config file %s: %v", *configFile, err)
+	}
+	if c.API.Listen == "" {
+		return fmt.Errorf("snooze needs the api, set api.listen")
+	}
+	host, port, err := net.SplitHostPort(c.API.Listen)
+	if err != nil {
+		return fmt.Errorf("invalid api.listen %q: %v", c.API.Listen, err)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	u := fmt.Sprintf("http://%s/api/v1/keywords/%s/snooze", net.JoinHostPort(host, port), url.PathEscape(keyword))
+	method := http.MethodDelete
+	var body []byte
+	if *duration > 0 {
+		method = http.MethodPost
+		if body, err = json.Marshal(map[string]string{"duration": duration.String()}); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.API.Token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not reach the api: %v", err)
+	}
+	b, err := httpRespBodyToString(resp)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("api returned %d: %s", resp.StatusCode, b)
+	}
+	if *duration > 0 {
+		fmt.Printf("snoozed %q for %s\n", keyword, *duration)
+	} else {
+		fmt.Printf("ended the snooze of %q\n", keyword)
+	}
+	return nil
+}
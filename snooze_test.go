@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnoozeList(t *testing.T) {
+	s := newSnoozeList()
+	now := time.Now()
+	s.snooze("password", now.Add(time.Hour))
+	s.snooze("expired", now.Add(-time.Minute))
+
+	found := map[string][]string{"password": {"password=1"}, "expired": {"expired"}, "admin": {"admin"}}
+	s.filter(found, now)
+	if _, ok := found["password"]; ok || len(found) != 2 {
+		t.Fatalf("expected only the snoozed keyword to be removed, got %v", found)
+	}
+	if a := s.active(now); len(a) != 1 || a[0].Keyword != "password" {
+		t.Fatalf("expected the expired snooze to be dropped, got %v", a)
+	}
+	if !s.unsnooze("password") || s.unsnooze("password") {
+		t.Fatal("expected only the first unsnooze to succeed")
+	}
+}
+
+func TestMatchSnoozed(t *testing.T) {
+	old := snoozes
+	defer func() { snoozes = old }()
+	snoozes = newSnoozeList()
+	m, err := newMatcher(configuration{Keywords: []keyword{{Keyword: "password"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := m.Scan("the password is 123"); !found {
+		t.Fatal("expected a match")
+	}
+	snoozes.snooze("password", time.Now().Add(time.Hour))
+	if found, key := m.Scan("the password is 123"); found || len(key) != 0 {
+		t.Fatalf("expected no match while snoozed, got %v", key)
+	}
+}
+
+func TestRunSnooze(t *testing.T) {
+	old := snoozes
+	defer func() { snoozes = old }()
+	snoozes = newSnoozeList()
+	ts := httptest.NewServer(apiAuth("secret", apiMux()))
+	defer ts.Close()
+
+	config := filepath.Join(t.TempDir(), "config.json")
+	b, _ := json.Marshal(map[string]interface{}{"api": map[string]string{"listen": strings.TrimPrefix(ts.URL, "http://"), "token": "secret"}})
+	if err := ioutil.WriteFile(config, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := runSnooze([]string{"-config", config, "-duration", "2h", "password"}); err != nil {
+		t.Fatal(err)
+	}
+	a := snoozes.active(time.Now())
+	if len(a) != 1 || a[0].Keyword != "password" || time.Until(a[0].Until) < time.Hour {
+		t.Fatalf("unexpected snoozes %v", a)
+	}
+
+	resp, err := http.DefaultClient.Do(func() *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/snoozes", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		return req
+	}())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed []snooze
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint: errcheck,gosec
+	if len(listed) != 1 || listed[0].Keyword != "password" {
+		t.Fatalf("unexpected listed snoozes %v", listed)
+	}
+
+	if err := runSnooze([]string{"-config", config, "-duration", "0", "password"}); err != nil {
+		t.Fatal(err)
+	}
+	if a := snoozes.active(time.Now()); len(a) != 0 {
+		t.Fatalf("expected the snooze to end, got %v", a)
+	}
+	if err := runSnooze([]string{"-config", config, "-duration", "0", "password"}); err == nil {
+		t.Fatal("expected an error for a keyword which is not snoozed")
+	}
+}
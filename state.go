@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// stateStore keeps the runtime state like the already checked pastes and
+// the notification status so it survives a restart
+type stateStore interface {
+	checked(key string) (bool, error)
+	setChecked(key string, t time.Time) error
+	unsetChecked(key string) error
+	// seenContent records the hash of a paste body and returns if it
+	// was already seen before
+	seenContent(hash string, t time.Time) (bool, error)
+	// expireChecked removes all entries checked before the given time
+	expireChecked(before time.Time) (int, error)
+	// expireContent removes all hashes first seen before the given time
+	expireContent(before time.Time) (int, error)
+	lastCheck() (time.Time, error)
+	setLastCheck(t time.Time) error
+	setNotified(key string, err error) error
+	// keywords returns the keywords of the last run, nil if unknown
+	keywords() ([]string, error)
+	setKeywords(k []string) error
+	// pending pastes are queued but not scanned yet and are resumed
+	// after a crash or restart
+	addPending(pastes []paste) error
+	removePending(key string) error
+	pending() ([]paste, error)
+	flush() error
+	close() error
+}
+
+// openStateStore uses the embedded database if configured, otherwise the
+// state is kept in memory and written to the state file if set
+func openStateStore(c configuration) (stateStore, error) {
+	var s stateStore
+	var err error
+	if c.Statedb != "" {
+		s, err = openBoltState(c.Statedb)
+	} else {
+		s, err = loadFileState(c.Statefile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !c.Bloom.Enabled {
+		return s, nil
+	}
+	b, err := newBloomState(s, c.Bloom)
+	if err != nil {
+		s.close() // nolint: errcheck,gosec
+		return nil, err
+	}
+	return b, nil
+}
+
+type notification struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error,omitempty"`
+}
+
+// state is the content of the json state file
+type state struct {
+	LastCheck time.Time               `json:"lastcheck"`
+	Checked   map[string]time.Time    `json:"checked"`
+	Notified  map[string]notification `json:"notified"`
+	Content   map[string]time.Time    `json:"content"`
+	Keywords  []string                `json:"keywords"`
+	Pending   map[string]paste        `json:"pending,omitempty"`
+}
+
+// fileState keeps the state in memory and writes it to a json file on
+// flush. Without a file the state is lost on restart.
+type fileState struct {
+	mu   sync.Mutex
+	file string
+	data state
+}
+
+// loadFileState reads the state file. A missing file returns an empty state.
+func loadFileState(f string) (*fileState, error) {
+	s := &fileState{file: f}
+	if f != "" {
+		b, err := ioutil.ReadFile(f) // nolint: gosec
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(b, &s.data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if s.data.Checked == nil {
+		s.data.Checked = make(map[string]time.Time)
+	}
+	if s.data.Notified == nil {
+		s.data.Notified = make(map[string]notification)
+	}
+	if s.data.Content == nil {
+		s.data.Content = make(map[string]time.Time)
+	}
+	if s.data.Pending == nil {
+		s.data.Pending = make(map[string]paste)
+	}
+	return s, nil
+}
+
+func (s *fileState) checked(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.data.Checked[key]
+	return ok, nil
+}
+
+func (s *fileState) setChecked(key string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Checked[key] = t
+	return nil
+}
+
+func (s *fileState) unsetChecked(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data.Checked, key)
+	return nil
+}
+
+func (s *fileState) expireChecked(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for k, v := range s.data.Checked {
+		if v.Before(before) {
+			delete(s.data.Checked, k)
+			delete(s.data.Notified, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *fileState) seenContent(hash string, t time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data.Content[hash]; ok {
+		return true, nil
+	}
+	s.data.Content[hash] = t
+	return false, nil
+}
+
+func (s *fileState) expireContent(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for k, v := range s.data.Content {
+		if v.Before(before) {
+			delete(s.data.Content, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *fileState) lastCheck() (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.LastCheck, nil
+}
+
+func (s *fileState) setLastCheck(t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.LastCheck = t
+	return nil
+}
+
+func (s *fileState) setNotified(key string, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := notification{Time: time.Now()}
+	if err != nil {
+		n.Error = err.Error()
+	}
+	s.data.Notified[key] = n
+	return nil
+}
+
+func (s *fileState) keywords() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.Keywords, nil
+}
+
+func (s *fileState) setKeywords(k []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Keywords = k
+	return nil
+}
+
+// addPending writes the state file right away so the pastes survive a
+// crash. Removing them is only written on the next flush to not rewrite
+// the whole file for every paste.
+func (s *fileState) addPending(pastes []paste) error {
+	if len(pastes) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	for _, p := range pastes {
+		s.data.Pending[p.Key] = p
+	}
+	s.mu.Unlock()
+	return s.flush()
+}
+
+func (s *fileState) removePending(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data.Pending, key)
+	return nil
+}
+
+func (s *fileState) pending() ([]paste, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]paste, 0, len(s.data.Pending))
+	for _, k := range sortedKeys(s.data.Pending) {
+		ret = append(ret, s.data.Pending[k])
+	}
+	return ret, nil
+}
+
+// flush writes the state to a temporary file first and renames it
+// afterwards so a crash does not leave a corrupt state file
+func (s *fileState) flush() error {
+	if s.file == "" {
+		return nil
+	}
+	s.mu.Lock()
+	b, err := json.Marshal(s.data)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.file, b, 0600)
+}
+
+func (s *fileState) close() error {
+	return s.flush()
+}
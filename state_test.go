@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testStateStore(t *testing.T, s stateStore) {
+	t.Helper()
+	now := time.Now().Truncate(time.Second)
+
+	if err := s.setLastCheck(now); err != nil {
+		t.Fatalf("could not set last check: %v", err)
+	}
+	last, err := s.lastCheck()
+	if err != nil {
+		t.Fatalf("could not get last check: %v", err)
+	}
+	if !last.Equal(now) {
+		t.Fatalf("last check does not match. Got %v, expected %v", last, now)
+	}
+
+	if err := s.setChecked("old", now.Add(-1*time.Hour)); err != nil {
+		t.Fatalf("could not set checked: %v", err)
+	}
+	if err := s.setChecked("new", now); err != nil {
+		t.Fatalf("could not set checked: %v", err)
+	}
+	if err := s.setChecked("removed", now); err != nil {
+		t.Fatalf("could not set checked: %v", err)
+	}
+	if err := s.unsetChecked("removed"); err != nil {
+		t.Fatalf("could not unset checked: %v", err)
+	}
+	if err := s.setNotified("new", errors.New("mail error")); err != nil {
+		t.Fatalf("could not set notified: %v", err)
+	}
+	n, err := s.expireChecked(now.Add(-10 * time.Minute))
+	if err != nil {
+		t.Fatalf("could not expire entries: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 expired entry, got %d", n)
+	}
+
+	for hash, expected := range map[string]bool{"a": false, "b": false} {
+		seen, err := s.seenContent(hash, now.Add(-2*time.Hour))
+		if err != nil {
+			t.Fatalf("could not check content: %v", err)
+		}
+		if seen != expected {
+			t.Errorf("expected seen of %q to be %t", hash, expected)
+		}
+	}
+	if seen, _ := s.seenContent("a", now); !seen {
+		t.Error("expected content to be seen on repost")
+	}
+	n, err = s.expireContent(now.Add(-1 * time.Hour))
+	if err != nil {
+		t.Fatalf("could not expire content: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 expired hashes, got %d", n)
+	}
+	if seen, _ := s.seenContent("a", now); seen {
+		t.Error("expected expired content not to be seen")
+	}
+
+	if k, err := s.keywords(); err != nil || k != nil {
+		t.Fatalf("expected no keywords, got %v (%v)", k, err)
+	}
+	if err := s.setKeywords([]string{"a", "b"}); err != nil {
+		t.Fatalf("could not set keywords: %v", err)
+	}
+	if k, _ := s.keywords(); len(k) != 2 || k[1] != "b" {
+		t.Fatalf("unexpected keywords %v", k)
+	}
+
+	if err := s.addPending([]paste{{Key: "b", ScrapeURL: "https://example.com/b"}, {Key: "a"}, {Key: "done"}}); err != nil {
+		t.Fatalf("could not add pending pastes: %v", err)
+	}
+	if err := s.removePending("done"); err != nil {
+		t.Fatalf("could not remove pending paste: %v", err)
+	}
+	if p, err := s.pending(); err != nil || len(p) != 2 || p[0].Key != "a" || p[1].ScrapeURL != "https://example.com/b" {
+		t.Fatalf("unexpected pending pastes %+v (%v)", p, err)
+	}
+
+	for key, expected := range map[string]bool{"old": false, "new": true, "removed": false, "unknown": false} {
+		found, err := s.checked(key)
+		if err != nil {
+			t.Fatalf("could not get checked: %v", err)
+		}
+		if found != expected {
+			t.Errorf("expected checked of %q to be %t", key, expected)
+		}
+	}
+}
+
+func TestFileState(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "state.json")
+	s, err := loadFileState(f)
+	if err != nil {
+		t.Fatalf("got error on missing state file: %v", err)
+	}
+	testStateStore(t, s)
+	if err := s.close(); err != nil {
+		t.Fatalf("could not save state: %v", err)
+	}
+
+	// reload from disk
+	s2, err := loadFileState(f)
+	if err != nil {
+		t.Fatalf("could not load state: %v", err)
+	}
+	if found, _ := s2.checked("new"); !found {
+		t.Fatal("checked key was not restored")
+	}
+	if _, ok := s2.data.Notified["new"]; !ok {
+		t.Fatal("notification was not restored")
+	}
+}
+
+func TestFileStatePendingCrash(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "state.json")
+	s, err := loadFileState(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.addPending([]paste{{Key: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	// no flush or close as after a crash
+	s2, err := loadFileState(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := s2.pending(); len(p) != 1 || p[0].Key != "a" {
+		t.Fatalf("expected the pending paste to survive a crash, got %+v", p)
+	}
+}
+
+func TestLoadFileStateInvalid(t *testing.T) {
+	_, err := loadFileState(filepath.Join("testdata", "invalid.json"))
+	if err == nil {
+		t.Fatal("expected error on invalid state file")
+	}
+}
+
+func TestBoltState(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "state.db")
+	s, err := openBoltState(f)
+	if err != nil {
+		t.Fatalf("could not open state database: %v", err)
+	}
+	testStateStore(t, s)
+	if err := s.close(); err != nil {
+		t.Fatalf("could not close state database: %v", err)
+	}
+
+	s2, err := openBoltState(f)
+	if err != nil {
+		t.Fatalf("could not open state database: %v", err)
+	}
+	defer s2.close() // nolint: errcheck
+	if found, _ := s2.checked("new"); !found {
+		t.Fatal("checked key was not persisted")
+	}
+	if p, _ := s2.pending(); len(p) != 2 {
+		t.Fatalf("expected pending pastes to be persisted, got %+v", p)
+	}
+}
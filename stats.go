@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	stats = newStatistics()
+)
+
+// statistics holds counters collected during the runtime
+type statistics struct {
+	mu     sync.Mutex
+	groups map[string]int
+	// deleted items per retention policy
+	pruned map[string]int
+	// pastes skipped because the content was already seen
+	duplicates int
+}
+
+func newStatistics() *statistics {
+	return &statistics{
+		groups: make(map[string]int),
+		pruned: make(map[string]int),
+	}
+}
+
+func (s *statistics) addPruned(name string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruned[name] += n
+}
+
+func (s *statistics) prunedCount(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pruned[name]
+}
+
+func (s *statistics) addDuplicate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.duplicates++
+}
+
+func (s *statistics) duplicateCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.duplicates
+}
+
+func (s *statistics) addGroups(groups []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, g := range groups {
+		s.groups[g]++
+	}
+}
+
+func (s *statistics) groupCount(group string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.groups[group]
+}
+
+func (s *statistics) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := fmt.Sprintf("matches per group: %s", formatCounters(s.groups))
+	if s.duplicates > 0 {
+		ret = fmt.Sprintf("%s; duplicates: %d", ret, s.duplicates)
+	}
+	if len(s.pruned) > 0 {
+		ret = fmt.Sprintf("%s; pruned: %s", ret, formatCounters(s.pruned))
+	}
+	return ret
+}
+
+// formatCounters returns the counters sorted by name as key=value pairs
+func formatCounters(in map[string]int) string {
+	keys := make([]string, 0, len(in))
+	for k := range in {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, in[k]))
+	}
+	return strings.Join(parts, ", ")
+}
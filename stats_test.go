@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestStatisticsGroups(t *testing.T) {
+	s := newStatistics()
+	s.addGroups([]string{"a", "b"})
+	s.addGroups([]string{"a"})
+	if c := s.groupCount("a"); c != 2 {
+		t.Fatalf("expected 2 matches for group a, got %d", c)
+	}
+	if x := s.String(); x != "matches per group: a=2, b=1" {
+		t.Fatalf("unexpected output %q", x)
+	}
+}
+
+func TestStatisticsDuplicates(t *testing.T) {
+	s := newStatistics()
+	s.addDuplicate()
+	s.addDuplicate()
+	if c := s.duplicateCount(); c != 2 {
+		t.Fatalf("expected 2 duplicates, got %d", c)
+	}
+	if x := s.String(); x != "matches per group: ; duplicates: 2" {
+		t.Fatalf("unexpected output %q", x)
+	}
+}
+
+func TestStatisticsPruned(t *testing.T) {
+	s := newStatistics()
+	s.addPruned("matches", 2)
+	s.addPruned("matches", 3)
+	if c := s.prunedCount("matches"); c != 5 {
+		t.Fatalf("expected 5 pruned matches, got %d", c)
+	}
+	if x := s.String(); x != "matches per group: ; pruned: matches=5" {
+		t.Fatalf("unexpected output %q", x)
+	}
+}
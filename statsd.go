@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultStatsdPrefix   = "pastebin_scraper."
+	defaultStatsdInterval = 10 * time.Second
+)
+
+var (
+	// set if the metrics are also pushed to statsd
+	statsd atomic.Pointer[statsdClient]
+)
+
+// statsdClient pushes the metrics to a statsd or dogstatsd agent over udp.
+// Counters and timings are sent on every change, gauges in an interval.
+type statsdClient struct {
+	conn   net.Conn
+	prefix string
+	// dogstatsd sends labels as tags, plain statsd appends the label value
+	// to the metric name
+	dogstatsd bool
+	tags      []string
+}
+
+func newStatsdClient(c statsdConfig) (*statsdClient, error) {
+	conn, err := net.Dial("udp", c.Address)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to statsd %s: %v", c.Address, err)
+	}
+	prefix := c.Prefix
+	if prefix == "" {
+		prefix = defaultStatsdPrefix
+	}
+	return &statsdClient{conn: conn, prefix: prefix, dogstatsd: c.Dogstatsd, tags: c.Tags}, nil
+}
+
+// line formats a single metric. name is the prometheus name which is
+// shortened to the statsd conventions.
+func (s *statsdClient) line(name, label, value, v, kind string) string {
+	name = strings.TrimPrefix(name, metricsNamespace+"_")
+	name = strings.TrimSuffix(name, "_total")
+	tags := s.tags
+	if label != "" && value != "" {
+		if s.dogstatsd {
+			tags = append(append([]string(nil), tags...), label+":"+value)
+		} else {
+			name += "." + strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", " ", "_").Replace(value)
+		}
+	}
+	l := fmt.Sprintf("%s%s:%s|%s", s.prefix, name, v, kind)
+	if s.dogstatsd && len(tags) > 0 {
+		l += "|#" + strings.Join(tags, ",")
+	}
+	return l
+}
+
+func (s *statsdClient) send(l string) {
+	// metrics are best effort, a missing agent must not break the scraper
+	s.conn.Write([]byte(l)) // nolint: errcheck,gosec
+}
+
+func (s *statsdClient) count(name, label, value string, v float64) {
+	s.send(s.line(name, label, value, formatFloat(v), "c"))
+}
+
+// timing sends a histogram observation, durations in seconds are sent as
+// milliseconds
+func (s *statsdClient) timing(name string, v float64) {
+	if strings.HasSuffix(name, "_seconds") {
+		s.send(s.line(strings.TrimSuffix(name, "_seconds"), "", "", formatFloat(v*1000), "ms"))
+		return
+	}
+	s.send(s.line(name, "", "", formatFloat(v), "h"))
+}
+
+func (s *statsdClient) gauges(r *metricsRegistry) {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+	for _, c := range collectors {
+		g, ok := c.(*gaugeFunc)
+		if !ok {
+			continue
+		}
+		g.mu.Lock()
+		for _, k := range sortedKeys(g.funcs) {
+			s.send(s.line(g.name, g.label, k, formatFloat(g.funcs[k]()), "g"))
+		}
+		g.mu.Unlock()
+	}
+}
+
+// pushStatsd sends all metrics to statsd until the context is canceled
+func pushStatsd(ctx context.Context, c statsdConfig, errs chan<- error) {
+	s, err := newStatsdClient(c)
+	if err != nil {
+		errs <- fmt.Errorf("statsd: %v", err)
+		return
+	}
+	defer s.conn.Close() // nolint: errcheck
+	interval := defaultStatsdInterval
+	if c.Interval != "" {
+		if interval, err = time.ParseDuration(c.Interval); err != nil {
+			errs <- fmt.Errorf("statsd: invalid interval %q: %v", c.Interval, err)
+			return
+		}
+	}
+	statsd.Store(s)
+	defer statsd.Store(nil)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.gauges(metrics)
+		}
+	}
+}
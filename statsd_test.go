@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func statsdListener(t *testing.T) (*net.UDPConn, func() string) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() }) // nolint: errcheck
+	return conn, func() string {
+		t.Helper()
+		buf := make([]byte, 1024)
+		conn.SetReadDeadline(time.Now().Add(time.Second)) // nolint: errcheck,gosec
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf[:n])
+	}
+}
+
+func TestStatsd(t *testing.T) {
+	conn, read := statsdListener(t)
+	s, err := newStatsdClient(statsdConfig{Address: conn.LocalAddr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.conn.Close() // nolint: errcheck
+
+	s.count("pastebin_scraper_matches_total", "keyword", "pass.word", 1)
+	if l := read(); l != "pastebin_scraper.matches.pass_word:1|c" {
+		t.Errorf("unexpected counter %q", l)
+	}
+	s.timing("pastebin_scraper_fetch_duration_seconds", 0.25)
+	if l := read(); l != "pastebin_scraper.fetch_duration:250|ms" {
+		t.Errorf("unexpected timing %q", l)
+	}
+
+	r := newMetricsRegistry()
+	g := r.gauge("queue_depth", "test", "queue")
+	g.set("output", func() float64 { return 3 })
+	r.counter("ignored_total", "test", "")
+	s.gauges(r)
+	if l := read(); l != "pastebin_scraper.queue_depth.output:3|g" {
+		t.Errorf("unexpected gauge %q", l)
+	}
+}
+
+func TestDogstatsd(t *testing.T) {
+	conn, read := statsdListener(t)
+	s, err := newStatsdClient(statsdConfig{Address: conn.LocalAddr().String(), Prefix: "scraper.", Dogstatsd: true, Tags: []string{"env:prod"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.conn.Close() // nolint: errcheck
+
+	s.count("pastebin_scraper_notifications_total", "result", "success", 2)
+	if l := read(); l != "scraper.notifications:2|c|#env:prod,result:success" {
+		t.Errorf("unexpected counter %q", l)
+	}
+	s.count("pastebin_scraper_pastes_fetched_total", "", "", 1)
+	if l := read(); l != "scraper.pastes_fetched:1|c|#env:prod" {
+		t.Errorf("unexpected counter %q", l)
+	}
+}
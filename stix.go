@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	stixSpecVersion  = "2.1"
+	taxiiContentType = "application/taxii+json;version=2.1"
+	stixTimeFormat   = "2006-01-02T15:04:05.000Z"
+	// creation time of the identity
+	stixIdentityCreated = "2020-01-01T00:00:00.000Z"
+)
+
+var (
+	taxiiClient = &http.Client{Timeout: 30 * time.Second}
+
+	// namespace of the deterministic ids of cyber observables defined by
+	// the STIX 2.1 specification
+	stixNamespace = uuid.MustParse("00abedb4-aa42-466c-9c01-fed23315a9b7")
+)
+
+// stixObject holds the properties of all object types used in the
+// bundles, unused properties are left out
+type stixObject struct {
+	Type           string            `json:"type"`
+	SpecVersion    string            `json:"spec_version"`
+	ID             string            `json:"id"`
+	CreatedByRef   string            `json:"created_by_ref,omitempty"`
+	Created        string            `json:"created,omitempty"`
+	Modified       string            `json:"modified,omitempty"`
+	Name           string            `json:"name,omitempty"`
+	Description    string            `json:"description,omitempty"`
+	IdentityClass  string            `json:"identity_class,omitempty"`
+	Labels         []string          `json:"labels,omitempty"`
+	Value          string            `json:"value,omitempty"`
+	Hashes         map[string]string `json:"hashes,omitempty"`
+	FirstObserved  string            `json:"first_observed,omitempty"`
+	LastObserved   string            `json:"last_observed,omitempty"`
+	NumberObserved int               `json:"number_observed,omitempty"`
+	ObjectRefs     []string          `json:"object_refs,omitempty"`
+	IndicatorTypes []string          `json:"indicator_types,omitempty"`
+	Pattern        string            `json:"pattern,omitempty"`
+	PatternType    string            `json:"pattern_type,omitempty"`
+	ValidFrom      string            `json:"valid_from,omitempty"`
+}
+
+type stixBundle struct {
+	Type    string       `json:"type"`
+	ID      string       `json:"id"`
+	Objects []stixObject `json:"objects"`
+}
+
+// stixObservable returns a cyber observable with the deterministic id of
+// its value
+func stixObservable(kind, value string, hashes map[string]string) stixObject {
+	var contributing interface{} = map[string]string{"value": value}
+	if hashes != nil {
+		contributing = map[string]interface{}{"hashes": hashes}
+	}
+	b, _ := json.Marshal(contributing) // nolint: errcheck
+	return stixObject{
+		Type:        kind,
+		SpecVersion: stixSpecVersion,
+		ID:          kind + "--" + uuid.NewSHA1(stixNamespace, b).String(),
+		Value:       value,
+		Hashes:      hashes,
+	}
+}
+
+// stixHashName returns the STIX name of the hash algorithm by length
+func stixHashName(h string) string {
+	switch len(h) {
+	case 32:
+		return "MD5"
+	case 40:
+		return "SHA-1"
+	default:
+		return "SHA-256"
+	}
+}
+
+// stixPattern returns the pattern matching the observable
+func stixPattern(o stixObject) string {
+	escape := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace
+	if o.Hashes != nil {
+		for name, h := range o.Hashes {
+			return fmt.Sprintf("[file:hashes.'%s' = '%s']", name, escape(h))
+		}
+	}
+	return fmt.Sprintf("[%s:value = '%s']", o.Type, escape(o.Value))
+}
+
+// stixIdentity is the producer of all objects. Its id is derived from
+// the hostname and it has a fixed creation time so it is the same object
+// across restarts.
+func stixIdentity(hostname string) stixObject {
+	t := stixIdentityCreated
+	return stixObject{
+		Type:          "identity",
+		SpecVersion:   stixSpecVersion,
+		ID:            "identity--" + uuid.NewSHA1(stixNamespace, []byte("pastebin_scraper "+hostname)).String(),
+		Created:       t,
+		Modified:      t,
+		Name:          "pastebin_scraper on " + hostname,
+		IdentityClass: "system",
+	}
+}
+
+// pasteBundle converts a matched paste into an observed-data object
+// referencing the paste url and all extracted indicators and an
+// indicator for each of them
+func pasteBundle(p paste, identity stixObject, now time.Time) stixBundle {
+	t := now.UTC().Format(stixTimeFormat)
+	keywords := getKeysFromMap(p.Matches)
+	sort.Strings(keywords)
+	observables := []stixObject{stixObservable("url", p.FullURL, nil)}
+	if p.IOCs != nil {
+		for _, ip := range p.IOCs.IPs {
+			kind := "ipv4-addr"
+			if strings.Contains(ip, ":") {
+				kind = "ipv6-addr"
+			}
+			observables = append(observables, stixObservable(kind, ip, nil))
+		}
+		for _, d := range p.IOCs.Domains {
+			observables = append(observables, stixObservable("domain-name", d, nil))
+		}
+		for _, u := range p.IOCs.URLs {
+			observables = append(observables, stixObservable("url", u, nil))
+		}
+		for _, e := range p.IOCs.Emails {
+			observables = append(observables, stixObservable("email-addr", e, nil))
+		}
+		for _, h := range p.IOCs.Hashes {
+			o := stixObservable("file", "", map[string]string{stixHashName(h): h})
+			observables = append(observables, o)
+		}
+	}
+	refs := make([]string, 0, len(observables))
+	for _, o := range observables {
+		refs = append(refs, o.ID)
+	}
+	objects := []stixObject{identity, {
+		Type:           "observed-data",
+		SpecVersion:    stixSpecVersion,
+		ID:             "observed-data--" + uuid.NewString(),
+		CreatedByRef:   identity.ID,
+		Created:        t,
+		Modified:       t,
+		FirstObserved:  t,
+		LastObserved:   t,
+		NumberObserved: 1,
+		ObjectRefs:     refs,
+		Labels:         keywords,
+	}}
+	objects = append(objects, observables...)
+	// the paste itself is no indicator
+	for _, o := range observables[1:] {
+		objects = append(objects, stixObject{
+			Type:           "indicator",
+			SpecVersion:    stixSpecVersion,
+			ID:             "indicator--" + uuid.NewString(),
+			CreatedByRef:   identity.ID,
+			Created:        t,
+			Modified:       t,
+			Name:           strings.TrimSuffix(stixPattern(o)[1:], "]"),
+			Description:    fmt.Sprintf("Found in %s matching %s", p.FullURL, strings.Join(keywords, ", ")),
+			IndicatorTypes: []string{"unknown"},
+			Pattern:        stixPattern(o),
+			PatternType:    "stix",
+			ValidFrom:      t,
+			Labels:         keywords,
+		})
+	}
+	return stixBundle{Type: "bundle", ID: "bundle--" + uuid.NewString(), Objects: objects}
+}
+
+// stixSink writes a bundle for every match to a directory and pushes the
+// objects to a TAXII collection
+type stixSink struct {
+	config   stixConfig
+	identity stixObject
+}
+
+func newSTIXSink(c stixConfig) (*stixSink, error) {
+	if c.Directory == "" && c.TAXII.URL == "" {
+		return nil, fmt.Errorf("either a directory or a taxii url must be configured")
+	}
+	if c.Directory != "" {
+		if err := os.MkdirAll(c.Directory, 0700); err != nil {
+			return nil, fmt.Errorf("could not create %s: %v", c.Directory, err)
+		}
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("could not get hostname: %v", err)
+	}
+	return &stixSink{config: c, identity: stixIdentity(hostname)}, nil
+}
+
+func (s *stixSink) write(ctx context.Context, p paste) error {
+	b := pasteBundle(p, s.identity, time.Now())
+	if s.config.Directory != "" {
+		data, err := json.MarshalIndent(b, "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not encode bundle: %v", err)
+		}
+		f := filepath.Join(s.config.Directory, fmt.Sprintf("%s-%s.json", p.Key, strings.TrimPrefix(b.ID, "bundle--")))
+		if err := ioutil.WriteFile(f, data, 0600); err != nil {
+			return fmt.Errorf("could not write bundle: %v", err)
+		}
+	}
+	if s.config.TAXII.URL != "" {
+		return s.push(ctx, b.Objects)
+	}
+	return nil
+}
+
+// push adds the objects to the TAXII collection, the url is the objects
+// endpoint of the collection
+func (s *stixSink) push(ctx context.Context, objects []stixObject) error {
+	body, err := json.Marshal(struct {
+		Objects []stixObject `json:"objects"`
+	}{objects})
+	if err != nil {
+		return fmt.Errorf("could not encode envelope: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.TAXII.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not create request: %v", err)
+	}
+	req.Header.Set("Content-Type", taxiiContentType)
+	req.Header.Set("Accept", taxiiContentType)
+	switch {
+	case s.config.TAXII.Token != "":
+		req.Header.Set("Authorization", "Bearer "+s.config.TAXII.Token)
+	case s.config.TAXII.Username != "":
+		req.SetBasicAuth(s.config.TAXII.Username, s.config.TAXII.Password)
+	}
+	resp, err := taxiiClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not push to taxii: %v", err)
+	}
+	defer resp.Body.Close()            // nolint: errcheck
+	io.Copy(ioutil.Discard, resp.Body) // nolint: errcheck,gosec
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected taxii status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStixObservableID(t *testing.T) {
+	// uuid v5 of the canonical json {"value":"198.51.100.3"}
+	o := stixObservable("ipv4-addr", "198.51.100.3", nil)
+	if o.ID != "ipv4-addr--28bb3599-77cd-5a82-a950-b5bc3caf07c4" {
+		t.Errorf("unexpected id %s", o.ID)
+	}
+	if a, b := stixObservable("url", "http://a", nil), stixObservable("url", "http://b", nil); a.ID == b.ID {
+		t.Error("expected different ids for different values")
+	}
+}
+
+func TestPasteBundle(t *testing.T) {
+	p := paste{
+		Key:     "abc",
+		FullURL: "https://pastebin.com/abc",
+		Matches: map[string][]string{"password": {"x"}},
+		IOCs: &iocSummary{
+			IPs:    []string{"203.0.113.7", "2001:db8::1"},
+			Emails: []string{"o'neil@example.com"},
+			Hashes: []string{"d41d8cd98f00b204e9800998ecf8427e"},
+		},
+	}
+	b := pasteBundle(p, stixIdentity("host"), time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	counts := map[string]int{}
+	patterns := map[string]bool{}
+	for _, o := range b.Objects {
+		counts[o.Type]++
+		if o.Type == "indicator" {
+			patterns[o.Pattern] = true
+			if o.ValidFrom != "2024-01-02T03:04:05.000Z" || o.CreatedByRef != b.Objects[0].ID {
+				t.Errorf("unexpected indicator %+v", o)
+			}
+		}
+		if o.Type == "observed-data" && len(o.ObjectRefs) != 5 {
+			t.Errorf("expected the paste and all indicators to be referenced, got %v", o.ObjectRefs)
+		}
+	}
+	expected := map[string]int{"identity": 1, "observed-data": 1, "url": 1, "ipv4-addr": 1, "ipv6-addr": 1, "email-addr": 1, "file": 1, "indicator": 4}
+	if len(counts) != len(expected) {
+		t.Errorf("expected objects %v, got %v", expected, counts)
+	}
+	for k, v := range expected {
+		if counts[k] != v {
+			t.Errorf("expected %d %s objects, got %d", v, k, counts[k])
+		}
+	}
+	for _, pattern := range []string{"[ipv4-addr:value = '203.0.113.7']", "[email-addr:value = 'o\\'neil@example.com']", "[file:hashes.'MD5' = 'd41d8cd98f00b204e9800998ecf8427e']"} {
+		if !patterns[pattern] {
+			t.Errorf("expected pattern %s, got %v", pattern, patterns)
+		}
+	}
+}
+
+func TestSTIXSink(t *testing.T) {
+	var pushed struct {
+		Objects []stixObject `json:"objects"`
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Content-Type") != taxiiContentType {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&pushed) // nolint: errcheck,gosec
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+	dir := t.TempDir()
+	s, err := newSTIXSink(stixConfig{Directory: dir, TAXII: taxiiConfig{URL: ts.URL, Token: "token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := paste{Key: "abc", FullURL: "https://pastebin.com/abc", Matches: map[string][]string{"a": {"a"}}}
+	if err := s.write(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+	if len(pushed.Objects) != 3 {
+		t.Errorf("expected the objects to be pushed, got %+v", pushed)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "abc-*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected one bundle file, got %v", files)
+	}
+	var b stixBundle
+	data, _ := ioutil.ReadFile(files[0])
+	if err := json.Unmarshal(data, &b); err != nil || b.Type != "bundle" || len(b.Objects) != 3 {
+		t.Errorf("unexpected bundle %s: %v", data, err)
+	}
+
+	s.config.TAXII.Token = "wrong"
+	if err := s.write(context.Background(), p); err == nil {
+		t.Error("expected an error on a rejected push")
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/FireFart/pastebin_scraper/store"
+)
+
+// alert returns the paste as it is saved in the database
+func (p paste) alert() store.Alert {
+	return store.Alert{
+		Key:     p.Key,
+		URL:     p.FullURL,
+		Date:    unixToTime(p.Date).Time,
+		Hash:    p.Hash,
+		Matches: p.Matches,
+	}
+}
+
+// unixToTime converts the unix timestamps from the scraping api
+func unixToTime(in string) sql.NullTime {
+	i, err := strconv.ParseInt(in, 10, 64)
+	if err != nil || i == 0 {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: time.Unix(i, 0), Valid: true}
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Query filters the stored matches. Empty fields match everything.
+type Query struct {
+	// matches if the keyword or the line contains it, case insensitive
+	Keyword string
+	Regexp  *regexp.Regexp
+	Key     string
+	// range of the paste date, the found date if it is unknown
+	From time.Time
+	To   time.Time
+	// at most this many results, 0 for all
+	Limit int
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns with ESCAPE '!'
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// match checks a stored match. The keyword filter applies to the matched
+// keyword and the line.
+func (q Query) match(m Match) bool {
+	if q.Key != "" && q.Key != m.Key {
+		return false
+	}
+	date := m.Date
+	if date.IsZero() {
+		date = m.FoundAt
+	}
+	if !q.From.IsZero() && date.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !date.Before(q.To) {
+		return false
+	}
+	if q.Regexp != nil && !q.Regexp.MatchString(m.Line) {
+		return false
+	}
+	return q.Keyword == "" || containsFold(m.Keyword, q.Keyword) || containsFold(m.Line, q.Keyword)
+}
+
+// Search returns the stored matches of all scrapers sharing the
+// database. The key, the keyword and on postgres and mysql the dates are
+// filtered in the query. On sqlite the dates are stored as strings and
+// filtered after the query like the regex.
+func (s *SQL) Search(ctx context.Context, q Query) ([]Match, error) {
+	var where []string
+	var args []interface{}
+	if q.Key != "" {
+		where = append(where, `paste_key = ?`)
+		args = append(args, q.Key)
+	}
+	if q.Keyword != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(q.Keyword)) + "%"
+		where = append(where, `(LOWER(keyword) LIKE ? ESCAPE '!' OR LOWER(line) LIKE ? ESCAPE '!')`)
+		args = append(args, pattern, pattern)
+	}
+	if s.dialect.timestamps {
+		if !q.From.IsZero() {
+			where = append(where, `COALESCE(paste_date, found_at) >= ?`)
+			args = append(args, q.From)
+		}
+		if !q.To.IsZero() {
+			where = append(where, `COALESCE(paste_date, found_at) < ?`)
+			args = append(args, q.To)
+		}
+	}
+	query := `SELECT hostname, paste_key, paste_url, paste_date, content_hash, keyword, line, found_at FROM matches`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY id`
+	// the limit only fits the query if nothing is filtered afterwards
+	if q.Limit > 0 && q.Regexp == nil && (s.dialect.timestamps || (q.From.IsZero() && q.To.IsZero())) {
+		query += fmt.Sprintf(` LIMIT %d`, q.Limit)
+	}
+
+	var ret []Match
+	err := s.query(ctx, s.rebind(query), args, func(m Match) bool {
+		if q.match(m) {
+			ret = append(ret, m)
+		}
+		return q.Limit == 0 || len(ret) < q.Limit
+	})
+	return ret, err
+}
+
+// Export calls fn for every stored match
+func (s *SQL) Export(ctx context.Context, fn func(m Match) error) error {
+	var fnErr error
+	err := s.query(ctx, `SELECT hostname, paste_key, paste_url, paste_date, content_hash, keyword, line, found_at FROM matches ORDER BY id`, nil, func(m Match) bool {
+		fnErr = fn(m)
+		return fnErr == nil
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+// query scans the matches selected by the query until fn returns false
+func (s *SQL) query(ctx context.Context, query string, args []interface{}, fn func(m Match) bool) error {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close() // nolint: errcheck
+
+	for rows.Next() {
+		var m Match
+		var date sql.NullTime
+		var hash sql.NullString
+		if err := rows.Scan(&m.Hostname, &m.Key, &m.URL, &date, &hash, &m.Keyword, &m.Line, &m.FoundAt); err != nil {
+			return err
+		}
+		m.Date = date.Time
+		m.Hash = hash.String
+		if !fn(m) {
+			break
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestQueryMatch(t *testing.T) {
+	q := Query{
+		Keyword: "Password",
+		Regexp:  regexp.MustCompile(`=\s*\w+`),
+		From:    time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC),
+		To:      time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+	m := Match{Key: "abc", Date: time.Date(2020, 5, 20, 0, 0, 0, 0, time.UTC), Keyword: "password", Line: "db = secret"}
+	if !q.match(m) {
+		t.Error("expected keyword of stored match to be used")
+	}
+	x := m
+	x.Line = "password"
+	if q.match(x) {
+		t.Error("expected line without regex match to be skipped")
+	}
+	x = m
+	x.Date = q.To
+	if q.match(x) {
+		t.Error("expected end date to be exclusive")
+	}
+	x = m
+	x.Date, x.FoundAt = time.Time{}, time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	if q.match(x) {
+		t.Error("expected the found date to be used without a paste date")
+	}
+	q.Key = "xyz"
+	if q.match(m) {
+		t.Error("expected different key to be skipped")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s, err := Open(SQLite, filepath.Join(t.TempDir(), "matches.db"))
+	if err != nil {
+		t.Fatalf("could not open store: %v", err)
+	}
+	defer s.Close() // nolint: errcheck
+
+	ctx := context.Background()
+	a := Alert{
+		Key:     "abc",
+		Date:    time.Unix(1590000000, 0),
+		Matches: map[string][]string{"password": {"password=secret"}, "other": {"other line", "100% match"}},
+	}
+	if err := s.SaveMatches(ctx, a, time.Now()); err != nil {
+		t.Fatalf("could not save matches: %v", err)
+	}
+
+	results, err := s.Search(ctx, Query{Keyword: "password", Key: "abc"})
+	if err != nil {
+		t.Fatalf("could not search: %v", err)
+	}
+	if len(results) != 1 || results[0].Keyword != "password" || !results[0].Date.Equal(a.Date) {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	results, err = s.Search(ctx, Query{To: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("could not search: %v", err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("expected no results before the paste date, got %+v", results)
+	}
+	// the wildcards of the keyword are escaped
+	results, err = s.Search(ctx, Query{Keyword: "0%"})
+	if err != nil {
+		t.Fatalf("could not search: %v", err)
+	}
+	if len(results) != 1 || results[0].Line != "100% match" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if x := likeEscaper.Replace("100%_!"); x != "100!%!_!!" {
+		t.Fatalf("wildcards not escaped: %q", x)
+	}
+	if results, err = s.Search(ctx, Query{Limit: 1}); err != nil || len(results) != 1 {
+		t.Fatalf("expected a single result, got %+v, %v", results, err)
+	}
+	if results, err = s.Search(ctx, Query{Regexp: regexp.MustCompile(`line$`), Limit: 1}); err != nil || len(results) != 1 || results[0].Line != "other line" {
+		t.Fatalf("expected the limit to count the regex matches, got %+v, %v", results, err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	s, err := Open(SQLite, filepath.Join(t.TempDir(), "matches.db"))
+	if err != nil {
+		t.Fatalf("could not open store: %v", err)
+	}
+	defer s.Close() // nolint: errcheck
+
+	ctx := context.Background()
+	a := Alert{Key: "abc", Hash: "hash", Matches: map[string][]string{"a": {"line 1", "line 2"}}}
+	if err := s.SaveMatches(ctx, a, time.Now()); err != nil {
+		t.Fatalf("could not save matches: %v", err)
+	}
+	var matches []Match
+	if err := s.Export(ctx, func(m Match) error {
+		matches = append(matches, m)
+		return nil
+	}); err != nil {
+		t.Fatalf("could not export: %v", err)
+	}
+	if len(matches) != 2 || matches[0].Hash != "hash" || !matches[0].Date.IsZero() || matches[0].Hostname != s.hostname {
+		t.Fatalf("unexpected matches: %+v", matches)
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	if err := s.Export(ctx, func(m Match) error {
+		n++
+		return stop
+	}); err != stop || n != 1 {
+		t.Fatalf("expected the error of fn after the first match, got %v after %d", err, n)
+	}
+}
@@ -0,0 +1,276 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	// database drivers
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	// sqlite driver without cgo
+	_ "modernc.org/sqlite"
+)
+
+// migration adds a column to a table created by an older version
+type migration struct {
+	column string
+	ddl    string
+}
+
+// dialect holds the database specific parts of the sql store
+type dialect struct {
+	// name of the registered database/sql driver
+	driver string
+	schema []string
+	// migrations of existing databases, run if the column is missing
+	migrations []migration
+	// numbered placeholders ($1, $2) instead of ?
+	numbered bool
+	// precision of the stored timestamps, found_at is truncated so the
+	// rows of an alert can be looked up by it again
+	precision time.Duration
+	// dates are stored as timestamps which compare correctly in queries,
+	// sqlite stores them as strings
+	timestamps bool
+}
+
+var dialects = map[string]dialect{
+	SQLite: {
+		driver: "sqlite",
+		schema: []string{
+			`CREATE TABLE IF NOT EXISTS matches (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				hostname TEXT NOT NULL,
+				paste_key TEXT NOT NULL,
+				paste_url TEXT NOT NULL,
+				paste_date TIMESTAMP NULL,
+				content_hash TEXT NULL,
+				keyword TEXT NOT NULL,
+				line TEXT NOT NULL,
+				found_at TIMESTAMP NOT NULL,
+				notified BOOLEAN NOT NULL DEFAULT FALSE,
+				notified_at TIMESTAMP NULL,
+				notify_error TEXT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_matches_paste_key ON matches (paste_key)`,
+			`CREATE INDEX IF NOT EXISTS idx_matches_keyword ON matches (keyword)`,
+		},
+		migrations: []migration{
+			{"hostname", `ALTER TABLE matches ADD COLUMN hostname TEXT NOT NULL DEFAULT ''`},
+			{"content_hash", `ALTER TABLE matches ADD COLUMN content_hash TEXT NULL`},
+		},
+	},
+	Postgres: {
+		driver: "pgx",
+		schema: []string{
+			`CREATE TABLE IF NOT EXISTS matches (
+				id BIGSERIAL PRIMARY KEY,
+				hostname TEXT NOT NULL,
+				paste_key TEXT NOT NULL,
+				paste_url TEXT NOT NULL,
+				paste_date TIMESTAMPTZ NULL,
+				content_hash TEXT NULL,
+				keyword TEXT NOT NULL,
+				line TEXT NOT NULL,
+				found_at TIMESTAMPTZ NOT NULL,
+				notified BOOLEAN NOT NULL DEFAULT FALSE,
+				notified_at TIMESTAMPTZ NULL,
+				notify_error TEXT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_matches_paste_key ON matches (paste_key)`,
+			`CREATE INDEX IF NOT EXISTS idx_matches_keyword ON matches (keyword)`,
+		},
+		migrations: []migration{
+			{"hostname", `ALTER TABLE matches ADD COLUMN IF NOT EXISTS hostname TEXT NOT NULL DEFAULT ''`},
+			{"content_hash", `ALTER TABLE matches ADD COLUMN IF NOT EXISTS content_hash TEXT NULL`},
+		},
+		numbered:   true,
+		timestamps: true,
+		precision:  time.Microsecond,
+	},
+	MySQL: {
+		driver: "mysql",
+		schema: []string{
+			// mysql does not support CREATE INDEX IF NOT EXISTS
+			`CREATE TABLE IF NOT EXISTS matches (
+				id BIGINT AUTO_INCREMENT PRIMARY KEY,
+				hostname VARCHAR(255) NOT NULL,
+				paste_key VARCHAR(64) NOT NULL,
+				paste_url VARCHAR(255) NOT NULL,
+				paste_date DATETIME NULL,
+				content_hash CHAR(64) NULL,
+				keyword VARCHAR(255) NOT NULL,
+				line TEXT NOT NULL,
+				found_at DATETIME NOT NULL,
+				notified BOOLEAN NOT NULL DEFAULT FALSE,
+				notified_at DATETIME NULL,
+				notify_error TEXT NULL,
+				INDEX idx_matches_paste_key (paste_key),
+				INDEX idx_matches_keyword (keyword)
+			) CHARACTER SET utf8mb4`,
+		},
+		migrations: []migration{
+			{"hostname", `ALTER TABLE matches ADD COLUMN hostname VARCHAR(255) NOT NULL DEFAULT ''`},
+			{"content_hash", `ALTER TABLE matches ADD COLUMN content_hash CHAR(64) NULL`},
+		},
+		timestamps: true,
+		precision:  time.Second,
+	},
+}
+
+// apply adds the column unless it exists. Not all databases support
+// ADD COLUMN IF NOT EXISTS so the column is looked up first.
+func (m migration) apply(db *sql.DB) error {
+	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM matches WHERE 1 = 0", m.column)) // nolint: gosec
+	if err == nil {
+		return rows.Close()
+	}
+	if _, err := db.Exec(m.ddl); err != nil {
+		return fmt.Errorf("could not add column %s: %v", m.column, err)
+	}
+	return nil
+}
+
+// SQL records all matches in a sqlite, postgres or mysql database.
+// Multiple scrapers can share a database, the hostname is recorded
+// on every match.
+type SQL struct {
+	db       *sql.DB
+	dialect  dialect
+	hostname string
+}
+
+// mysqlDSN enables parseTime so DATETIME columns scan into time.Time
+// instead of []byte
+func mysqlDSN(dsn string) (string, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	cfg.ParseTime = true
+	return cfg.FormatDSN(), nil
+}
+
+// Open opens the database and creates or migrates the schema
+func Open(driver, dsn string) (*SQL, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("could not get hostname: %v", err)
+	}
+
+	if driver == MySQL {
+		if dsn, err = mysqlDSN(dsn); err != nil {
+			return nil, fmt.Errorf("invalid mysql dsn: %v", err)
+		}
+	}
+	db, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if driver == SQLite {
+		// sqlite does not support concurrent writers
+		db.SetMaxOpenConns(1)
+	}
+	for _, s := range d.schema {
+		if _, err := db.Exec(s); err != nil {
+			db.Close() // nolint: errcheck,gosec
+			return nil, fmt.Errorf("could not create schema: %v", err)
+		}
+	}
+	for _, m := range d.migrations {
+		if err := m.apply(db); err != nil {
+			db.Close() // nolint: errcheck,gosec
+			return nil, fmt.Errorf("could not migrate schema: %v", err)
+		}
+	}
+	return &SQL{db: db, dialect: d, hostname: hostname}, nil
+}
+
+// rebind converts the ? placeholders to the format of the dialect
+func (s *SQL) rebind(query string) string {
+	if !s.dialect.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// SaveMatches inserts a row for every matched line of the alert
+func (s *SQL) SaveMatches(ctx context.Context, a Alert, found time.Time) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint: errcheck,gosec
+		}
+	}()
+
+	now := s.foundAt(found)
+	pasteDate := sql.NullTime{Time: a.Date, Valid: !a.Date.IsZero()}
+	for k, v := range a.Matches {
+		for _, line := range v {
+			_, err = tx.ExecContext(ctx,
+				s.rebind(`INSERT INTO matches (hostname, paste_key, paste_url, paste_date, content_hash, keyword, line, found_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+				s.hostname, a.Key, a.URL, pasteDate, a.Hash, k, line, now)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// foundAt returns the time as it is stored by the database
+func (s *SQL) foundAt(t time.Time) time.Time {
+	if s.dialect.precision > 0 {
+		t = t.Truncate(s.dialect.precision)
+	}
+	return t
+}
+
+// SetNotified records the outcome of the notification of an alert
+func (s *SQL) SetNotified(ctx context.Context, key string, found time.Time, notifyErr error) error {
+	var errString sql.NullString
+	if notifyErr != nil {
+		errString = sql.NullString{String: notifyErr.Error(), Valid: true}
+	}
+	_, err := s.db.ExecContext(ctx,
+		s.rebind(`UPDATE matches SET notified = ?, notified_at = ?, notify_error = ? WHERE paste_key = ? AND hostname = ? AND found_at = ?`),
+		notifyErr == nil, time.Now(), errString, key, s.hostname, s.foundAt(found))
+	return err
+}
+
+// PruneMatches deletes all matches found before the given time
+func (s *SQL) PruneMatches(ctx context.Context, before time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM matches WHERE found_at < ?`), before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Close closes the database
+func (s *SQL) Close() error {
+	return s.db.Close()
+}
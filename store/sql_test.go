@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQL(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "matches.db")
+	s, err := Open(SQLite, f)
+	if err != nil {
+		t.Fatalf("could not open store: %v", err)
+	}
+	defer s.Close() // nolint: errcheck
+
+	ctx := context.Background()
+	a := Alert{
+		Key:  "abc",
+		URL:  "https://pastebin.com/abc",
+		Date: time.Unix(1580000000, 0),
+		Hash: "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+		Matches: map[string][]string{
+			"keyword": {"line 1", "line 2"},
+			"other":   {"line 3"},
+		},
+	}
+	found := time.Now()
+	if err := s.SaveMatches(ctx, a, found); err != nil {
+		t.Fatalf("could not save matches: %v", err)
+	}
+	if err := s.SetNotified(ctx, a.Key, found, errors.New("mail error")); err != nil {
+		t.Fatalf("could not set notified: %v", err)
+	}
+	// a re-alert must not change the status of the first alert
+	realert := found.Add(time.Minute)
+	if err := s.SaveMatches(ctx, a, realert); err != nil {
+		t.Fatalf("could not save matches: %v", err)
+	}
+	if err := s.SetNotified(ctx, a.Key, realert, nil); err != nil {
+		t.Fatalf("could not set notified: %v", err)
+	}
+
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM matches WHERE paste_key = ? AND content_hash = ? AND notified = ? AND notify_error = ?`, "abc", a.Hash, false, "mail error").Scan(&count); err != nil {
+		t.Fatalf("could not query matches: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 matches, got %d", count)
+	}
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM matches WHERE paste_key = ? AND notified = ? AND notify_error IS NULL`, "abc", true).Scan(&count); err != nil {
+		t.Fatalf("could not query matches: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 notified matches of the re-alert, got %d", count)
+	}
+
+	// reopening runs the migrations on the existing schema
+	s2, err := Open(SQLite, f)
+	if err != nil {
+		t.Fatalf("could not reopen store: %v", err)
+	}
+	s2.Close() // nolint: errcheck,gosec
+
+	n, err := s.PruneMatches(ctx, time.Now().Add(-1*time.Hour))
+	if err != nil {
+		t.Fatalf("could not prune matches: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("expected no pruned matches, got %d", n)
+	}
+	n, err = s.PruneMatches(ctx, time.Now().Add(1*time.Hour))
+	if err != nil {
+		t.Fatalf("could not prune matches: %v", err)
+	}
+	if n != 6 {
+		t.Fatalf("expected 6 pruned matches, got %d", n)
+	}
+}
+
+func TestSQLMigration(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "matches.db")
+	// schema of the first version without hostname and content hash
+	db, err := sql.Open("sqlite", f)
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE matches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		paste_key TEXT NOT NULL,
+		paste_url TEXT NOT NULL,
+		paste_date TIMESTAMP NULL,
+		keyword TEXT NOT NULL,
+		line TEXT NOT NULL,
+		found_at TIMESTAMP NOT NULL,
+		notified BOOLEAN NOT NULL DEFAULT FALSE,
+		notified_at TIMESTAMP NULL,
+		notify_error TEXT NULL
+	)`); err != nil {
+		t.Fatalf("could not create old schema: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO matches (paste_key, paste_url, keyword, line, found_at) VALUES ('old', 'url', 'k', 'l', CURRENT_TIMESTAMP)`); err != nil {
+		t.Fatalf("could not insert old match: %v", err)
+	}
+	db.Close() // nolint: errcheck,gosec
+
+	s, err := Open(SQLite, f)
+	if err != nil {
+		t.Fatalf("could not migrate store: %v", err)
+	}
+	defer s.Close() // nolint: errcheck
+	a := Alert{Key: "abc", URL: "https://pastebin.com/abc", Hash: "hash", Matches: map[string][]string{"keyword": {"line"}}}
+	if err := s.SaveMatches(context.Background(), a, time.Now()); err != nil {
+		t.Fatalf("could not save matches: %v", err)
+	}
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM matches WHERE hostname = ? AND content_hash = ?`, s.hostname, "hash").Scan(&count); err != nil {
+		t.Fatalf("could not query matches: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 migrated match, got %d", count)
+	}
+}
+
+func TestSQLMigrationError(t *testing.T) {
+	m := migration{"missing", `ALTER TABLE nothing ADD COLUMN missing TEXT`}
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "matches.db"))
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	defer db.Close() // nolint: errcheck
+	if err := m.apply(db); err == nil {
+		t.Fatal("expected the migration error to be returned")
+	}
+}
+
+func TestMySQLDSN(t *testing.T) {
+	dsn, err := mysqlDSN("user:pass@tcp(host:3306)/db")
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if !strings.Contains(dsn, "parseTime=true") {
+		t.Fatalf("parseTime not set in %q", dsn)
+	}
+	if _, err := mysqlDSN("user:pass@tcp(host:3306"); err == nil {
+		t.Fatal("expected an error on an invalid dsn")
+	}
+}
+
+func TestOpenInvalidDriver(t *testing.T) {
+	if _, err := Open("invalid", ""); err == nil {
+		t.Fatal("expected error on invalid driver")
+	}
+}
+
+func TestSQLRebind(t *testing.T) {
+	s := &SQL{dialect: dialects[Postgres]}
+	if x := s.rebind("UPDATE x SET a = ? WHERE b = ?"); x != "UPDATE x SET a = $1 WHERE b = $2" {
+		t.Fatalf("unexpected query %q", x)
+	}
+	s = &SQL{dialect: dialects[MySQL]}
+	if x := s.rebind("UPDATE x SET a = ?"); x != "UPDATE x SET a = ?" {
+		t.Fatalf("unexpected query %q", x)
+	}
+}
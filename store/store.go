@@ -0,0 +1,64 @@
+// Package store records the matches of the scraper in a sqlite, postgres
+// or mysql database. Multiple scrapers can share a database, so other
+// programs can search and export the matches of all of them.
+//
+//	s, err := store.Open(store.SQLite, "matches.db")
+//	if err != nil {
+//		return err
+//	}
+//	defer s.Close()
+//	matches, err := s.Search(ctx, store.Query{Keyword: "password", Limit: 100})
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// supported database drivers
+const (
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+	MySQL    = "mysql"
+)
+
+// Store records all matches and their notification status
+type Store interface {
+	// SaveMatches records the matches of an alert found at the given time
+	SaveMatches(ctx context.Context, a Alert, found time.Time) error
+	// SetNotified updates the matches saved for the alert found at the
+	// given time, earlier alerts of the paste keep their status
+	SetNotified(ctx context.Context, key string, found time.Time, err error) error
+	// PruneMatches deletes all matches found before the given time
+	PruneMatches(ctx context.Context, before time.Time) (int, error)
+	Close() error
+}
+
+// Alert is a matched paste as it is saved
+type Alert struct {
+	Key string
+	URL string
+	// zero if the date of the paste is unknown
+	Date    time.Time
+	Hash    string
+	Matches map[string][]string
+}
+
+// Match is a single stored line
+type Match struct {
+	Hostname string
+	Key      string
+	URL      string
+	// zero if the date of the paste is unknown
+	Date    time.Time
+	Hash    string
+	Keyword string
+	Line    string
+	FoundAt time.Time
+}
+
+// Supported returns true if Open supports the driver
+func Supported(driver string) bool {
+	_, ok := dialects[driver]
+	return ok
+}
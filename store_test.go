@@ -0,0 +1,20 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/FireFart/pastebin_scraper/store"
+)
+
+func TestPasteAlert(t *testing.T) {
+	p := paste{Key: "abc", FullURL: "https://pastebin.com/abc", Date: "1580000000", Hash: "hash", Matches: map[string][]string{"keyword": {"line"}}}
+	expected := store.Alert{Key: "abc", URL: "https://pastebin.com/abc", Date: time.Unix(1580000000, 0), Hash: "hash", Matches: p.Matches}
+	if a := p.alert(); !reflect.DeepEqual(a, expected) {
+		t.Fatalf("unexpected alert %+v", a)
+	}
+	if a := (paste{Date: "invalid"}).alert(); !a.Date.IsZero() {
+		t.Fatalf("expected no date, got %v", a.Date)
+	}
+}
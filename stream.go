@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// events buffered per client before they are dropped
+	streamClientBuffer = 100
+	// a client not accepting an event within this time is disconnected
+	streamWriteTimeout = 10 * time.Second
+	streamUnixPrefix   = "unix:"
+)
+
+// eventStream sends every match as json line to the connected clients of
+// a unix socket or a local tcp port. Like the grpc subscribers slow
+// clients lose events instead of blocking the scraper.
+type eventStream struct {
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+}
+
+func newEventStream() *eventStream {
+	return &eventStream{clients: make(map[chan []byte]struct{})}
+}
+
+func (s *eventStream) subscribe() chan []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := make(chan []byte, streamClientBuffer)
+	s.clients[c] = struct{}{}
+	return c
+}
+
+func (s *eventStream) unsubscribe(c chan []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, c)
+}
+
+func (s *eventStream) publish(p paste, foundAt time.Time) error {
+	b, err := json.Marshal(newPasteEvent(p, foundAt))
+	if err != nil {
+		return fmt.Errorf("could not encode event: %v", err)
+	}
+	b = append(b, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		select {
+		case c <- b:
+		default:
+			slog.Warn("dropping match for slow stream client", "paste_key", p.Key)
+		}
+	}
+	return nil
+}
+
+// listenStream opens the unix socket for addresses like
+// unix:/run/pastebin_scraper.sock or the tcp port on a loopback address
+func listenStream(addr string) (net.Listener, error) {
+	if strings.HasPrefix(addr, streamUnixPrefix) {
+		path := strings.TrimPrefix(addr, streamUnixPrefix)
+		// a socket left over by a crash
+		if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(path) // nolint: errcheck,gosec
+		}
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(path, 0600); err != nil {
+			l.Close() // nolint: errcheck,gosec
+			return nil, fmt.Errorf("could not set permissions of %s: %v", path, err)
+		}
+		return l, nil
+	}
+	if err := checkLoopback(addr); err != nil {
+		return nil, err
+	}
+	return net.Listen("tcp", addr)
+}
+
+// serve accepts clients until the context is canceled
+func (s *eventStream) serve(ctx context.Context, l net.Listener, errs chan<- error) {
+	go func() {
+		<-ctx.Done()
+		l.Close() // nolint: errcheck,gosec
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				errs <- fmt.Errorf("stream: %v", err)
+			}
+			return
+		}
+		go s.handle(ctx, conn)
+	}
+}
+
+func (s *eventStream) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close() // nolint: errcheck
+	c := s.subscribe()
+	defer s.unsubscribe(c)
+	// clients only read, anything sent or the end of the connection
+	// disconnects them
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.Read(make([]byte, 1)) // nolint: errcheck,gosec
+	}()
+	logger(componentNotifier).Debug("stream client connected", "remote", conn.RemoteAddr().String())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-closed:
+			return
+		case b := <-c:
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout)) // nolint: errcheck,gosec
+			if _, err := conn.Write(b); err != nil {
+				logger(componentNotifier).Debug("stream client disconnected", "error", err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEventStream(t *testing.T) {
+	for _, addr := range []string{"unix:" + filepath.Join(t.TempDir(), "matches.sock"), "127.0.0.1:0"} {
+		l, err := listenStream(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		s := newEventStream()
+		errs := make(chan error, 1)
+		go s.serve(ctx, l, errs)
+
+		conn, err := net.Dial(l.Addr().Network(), l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// wait for the client to be subscribed
+		for i := 0; i < 100; i++ {
+			s.mu.Lock()
+			n := len(s.clients)
+			s.mu.Unlock()
+			if n == 1 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err := s.publish(paste{Key: "abc", Matches: map[string][]string{"password": {"password=1"}}}, time.Unix(1600000000, 0)); err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second)) // nolint: errcheck,gosec
+		line, err := bufio.NewReader(conn).ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("%s: %v", addr, err)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(line, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got["key"] != "abc" || got["found_at"] != "2020-09-13T12:26:40Z" {
+			t.Errorf("%s: unexpected event %s", addr, line)
+		}
+		conn.Close() // nolint: errcheck,gosec
+		cancel()
+		select {
+		case err := <-errs:
+			t.Errorf("unexpected error %v", err)
+		default:
+		}
+	}
+}
+
+func TestListenStreamLoopback(t *testing.T) {
+	if _, err := listenStream("0.0.0.0:0"); err == nil {
+		t.Error("expected a public address to be rejected")
+	}
+}
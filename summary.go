@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	gomail "gopkg.in/gomail.v2"
+)
+
+var (
+	// counters of the current summary period
+	period = newPeriodStats()
+)
+
+// periodStats collects the activity between two summaries
+type periodStats struct {
+	mu      sync.Mutex
+	start   time.Time
+	scanned int
+	matches int
+	hits    map[string]int
+	errors  int
+	// sum of the feed lag of all checks to average it
+	lag    time.Duration
+	checks int
+}
+
+// summary is a snapshot of one period
+type summary struct {
+	Start   time.Time
+	End     time.Time
+	Scanned int
+	Matches int
+	Hits    map[string]int
+	Errors  int
+	Checks  int
+	FeedLag time.Duration
+}
+
+func newPeriodStats() *periodStats {
+	return &periodStats{start: time.Now(), hits: make(map[string]int)}
+}
+
+func (s *periodStats) addScanned() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scanned++
+}
+
+func (s *periodStats) addMatch(p paste) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.matches++
+	for k := range p.Matches {
+		s.hits[k]++
+	}
+}
+
+func (s *periodStats) addError() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors++
+}
+
+// checked records the feed lag of a paste list, the age of its newest paste
+func (s *periodStats) checked(t time.Time, pastes []paste) {
+	var newest time.Time
+	for _, p := range pastes {
+		if d := unixToTime(p.Date); d.Valid && d.Time.After(newest) {
+			newest = d.Time
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks++
+	if !newest.IsZero() && t.After(newest) {
+		s.lag += t.Sub(newest)
+	}
+}
+
+// take returns the summary of the period and starts the next one
+func (s *periodStats) take(now time.Time) summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := summary{Start: s.start, End: now, Scanned: s.scanned, Matches: s.matches, Hits: s.hits, Errors: s.errors, Checks: s.checks}
+	if s.checks > 0 {
+		ret.FeedLag = (s.lag / time.Duration(s.checks)).Round(time.Second)
+	}
+	s.start, s.scanned, s.matches, s.errors, s.lag, s.checks = now, 0, 0, 0, 0, 0
+	s.hits = make(map[string]int)
+	return ret
+}
+
+func (s summary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Pastebin Scraper summary from %s to %s\n\n", s.Start.Format(time.RFC3339), s.End.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Checks:\t%d\nPastes scanned:\t%d\nMatches:\t%d\nErrors:\t%d\nAverage feed lag:\t%s\n", s.Checks, s.Scanned, s.Matches, s.Errors, s.FeedLag)
+	if len(s.Hits) > 0 {
+		b.WriteString("\nMatches per keyword:\n")
+		for _, k := range sortedKeys(s.Hits) {
+			fmt.Fprintf(&b, "%s:\t%d\n", k, s.Hits[k])
+		}
+	}
+	return b.String()
+}
+
+func sendSummary(ctx context.Context, config configuration, s summary) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", config.Mailfrom)
+	m.SetHeader("To", config.Mailtoerror)
+	m.SetHeader("Subject", fmt.Sprintf("Pastebin Scraper summary: %d scanned, %d matches, %d errors", s.Scanned, s.Matches, s.Errors))
+	m.SetBody("text/plain", s.String())
+	return sendEmail(ctx, config, m)
+}
+
+// nextSummary returns the end of the current period. Periods are aligned
+// to multiples of the interval so daily summaries cover whole days (UTC).
+func nextSummary(now time.Time, interval time.Duration) time.Time {
+	return now.Truncate(interval).Add(interval)
+}
+
+// runSummaries logs a summary of the activity every interval and mails it
+// to mailtoerror if enabled
+func runSummaries(ctx context.Context, c summaryConfig, live *liveConfig, errs chan<- error) {
+	interval, err := time.ParseDuration(c.Interval)
+	if err != nil || interval <= 0 {
+		errs <- fmt.Errorf("summary: invalid interval %q", c.Interval)
+		return
+	}
+	for {
+		if !sleep(ctx, time.Until(nextSummary(time.Now(), interval))) {
+			return
+		}
+		s := period.take(time.Now())
+		slog.Info("summary", "from", s.Start, "checks", s.Checks, "scanned", s.Scanned, "matches", s.Matches,
+			"keywords", formatCounters(s.Hits), "errors", s.Errors, "feed_lag", s.FeedLag)
+		if c.Mail {
+			config, _ := live.get()
+			if err := sendSummary(ctx, config, s); err != nil {
+				errs <- fmt.Errorf("could not send summary: %v", err)
+			}
+		}
+	}
+}
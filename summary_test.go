@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPeriodStats(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	s := newPeriodStats()
+	s.addScanned()
+	s.addScanned()
+	s.addMatch(paste{Matches: map[string][]string{"password": {}, "admin": {}}})
+	s.addError()
+	s.checked(now, []paste{{Date: strconv.FormatInt(now.Add(-10*time.Second).Unix(), 10)}})
+	s.checked(now, []paste{{Date: strconv.FormatInt(now.Add(-30*time.Second).Unix(), 10)}})
+
+	sum := s.take(now)
+	if sum.Scanned != 2 || sum.Matches != 1 || sum.Errors != 1 || sum.Checks != 2 || sum.Hits["password"] != 1 {
+		t.Fatalf("unexpected summary %+v", sum)
+	}
+	if sum.FeedLag != 20*time.Second {
+		t.Fatalf("expected an average feed lag of 20s, got %v", sum.FeedLag)
+	}
+	if out := sum.String(); !strings.Contains(out, "password:\t1") || !strings.Contains(out, "Pastes scanned:\t2") {
+		t.Fatalf("unexpected summary text %q", out)
+	}
+
+	next := s.take(now.Add(time.Hour))
+	if next.Scanned != 0 || len(next.Hits) != 0 || !next.Start.Equal(now) {
+		t.Fatalf("expected a fresh period, got %+v", next)
+	}
+}
+
+func TestNextSummary(t *testing.T) {
+	now := time.Date(2026, 10, 14, 13, 25, 0, 0, time.UTC)
+	if n := nextSummary(now, time.Hour); !n.Equal(time.Date(2026, 10, 14, 14, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected next hourly summary %v", n)
+	}
+	if n := nextSummary(now, 24*time.Hour); !n.Equal(time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected next daily summary %v", n)
+	}
+}
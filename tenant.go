@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// tenantConfiguration returns the config of the tenant matcher. Only the
+// keywords, groups and the threshold are taken from the tenant, the
+// normalization and the blocklist apply to all tenants.
+func tenantConfiguration(c configuration, t tenant) configuration {
+	c.Keywords = t.Keywords
+	c.Threshold = t.Threshold
+	c.Groups = t.Groups
+	c.CIDRs = nil
+	c.Detectors = nil
+	c.Tenants = nil
+	return c
+}
+
+// newTenantMatchers compiles the rules of every tenant
+func newTenantMatchers(c configuration) (map[string]*matcher, error) {
+	if len(c.Tenants) == 0 {
+		return nil, nil
+	}
+	ret := make(map[string]*matcher, len(c.Tenants))
+	for _, name := range sortedKeys(c.Tenants) {
+		// detectors and plugins belong to the operator
+		m, err := compileRules(tenantConfiguration(c, c.Tenants[name]), nil)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %v", name, err)
+		}
+		ret[name] = &matcher{Matcher: m}
+	}
+	return ret, nil
+}
+
+// forPaste returns the matcher the paste was matched with
+func (m *matcher) forPaste(p paste) *matcher {
+	if t, ok := m.tenants[p.Tenant]; ok && p.Tenant != "" {
+		return t
+	}
+	return m
+}
+
+// score returns the keyword score of the paste with the rules of its
+// tenant
+func (m *matcher) score(p paste) int {
+	return m.forPaste(p).Score(p.Matches)
+}
+
+// scanTenants matches the paste against the rules of every tenant and
+// returns the matches per tenant
+func (p *paste) scanTenants(m *matcher) map[string]map[string][]string {
+	var ret map[string]map[string][]string
+	for _, name := range sortedKeys(m.tenants) {
+		c := paste{Content: p.Content, contentFile: p.contentFile, contentSize: p.contentSize, spanContext: p.spanContext}
+		c.scan(m.tenants[name])
+		if len(c.Matches) == 0 {
+			continue
+		}
+		if ret == nil {
+			ret = make(map[string]map[string][]string)
+		}
+		ret[name] = c.Matches
+	}
+	return ret
+}
+
+// withTenants returns the paste if it matched the global rules and a copy
+// for every tenant holding only the matches of that tenant, so an alert
+// never contains the keywords of another tenant
+func (p *paste) withTenants(found map[string]map[string][]string, m *matcher) []paste {
+	var ret []paste
+	if len(p.Matches) > 0 {
+		ret = append(ret, *p)
+	}
+	for _, name := range sortedKeys(found) {
+		t := *p
+		t.Tenant = name
+		t.Matches = found[name]
+		t.Groups = m.tenants[name].Groups(t.Matches)
+		t.Confidence = m.tenants[name].confidence(&t, time.Now())
+		ret = append(ret, t)
+	}
+	return ret
+}
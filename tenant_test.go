@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTenants(t *testing.T) {
+	c := configuration{
+		Mailto:   "soc@example.com",
+		Keywords: []keyword{{Keyword: "password", Score: intPointer(1)}},
+		Tenants: map[string]tenant{
+			"acme": {
+				Mailto:   "security@acme.example",
+				Keywords: []keyword{{Keyword: "acme.example", Score: intPointer(5)}, {Keyword: "acme-vpn", Group: "network"}},
+				Groups:   map[string]group{"network": {Mailto: "noc@acme.example"}},
+			},
+			"globex": {
+				Mailto:   "security@globex.example",
+				Keywords: []keyword{{Keyword: "globex.example"}},
+			},
+		},
+	}
+	m, err := newMatcher(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &paste{Key: "abc", Content: "admin@acme.example:hunter2 password acme-vpn"}
+	p.scan(m)
+	found := p.scanTenants(m)
+	if len(found) != 1 || found["acme"] == nil {
+		t.Fatalf("expected only acme to match, got %v", found)
+	}
+	pastes := p.withTenants(found, m)
+	if len(pastes) != 2 {
+		t.Fatalf("expected the global and the acme alert, got %+v", pastes)
+	}
+	global, acme := pastes[0], pastes[1]
+	if global.Tenant != "" || !reflect.DeepEqual(getKeysFromMap(global.Matches), []string{"password"}) {
+		t.Errorf("unexpected global alert %+v", global)
+	}
+	if _, ok := acme.Matches["password"]; ok || acme.Tenant != "acme" || len(acme.Matches) != 2 {
+		t.Errorf("unexpected acme alert %+v", acme)
+	}
+	if !reflect.DeepEqual(acme.Groups, []string{"network"}) {
+		t.Errorf("unexpected acme groups %v", acme.Groups)
+	}
+	if s := m.score(acme); s != 6 {
+		t.Errorf("expected the score of the acme rules, got %d", s)
+	}
+	if s := m.score(global); s != 1 {
+		t.Errorf("expected the global score, got %d", s)
+	}
+
+	if to := acme.recipients(c); !reflect.DeepEqual(to, []string{"security@acme.example", "noc@acme.example"}) {
+		t.Errorf("unexpected acme recipients %v", to)
+	}
+	if to := global.recipients(c); !reflect.DeepEqual(to, []string{"soc@example.com"}) {
+		t.Errorf("unexpected global recipients %v", to)
+	}
+	delete(c.Tenants, "acme")
+	if to := acme.recipients(c); len(to) != 0 {
+		t.Errorf("expected no recipients for a removed tenant, got %v", to)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// runTest runs the rules of the config against local files so keywords
+// and exceptions can be tested without waiting for a live paste
+func runTest(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	configFile := fs.String("config", "", "Config File to use")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("please provide at least one file to test, - reads from stdin")
+	}
+
+	config, err := getConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("could not read config file %s: %v", *configFile, err)
+	}
+	m, err := newMatcher(*config)
+	if err != nil {
+		return err
+	}
+	for _, f := range fs.Args() {
+		var b []byte
+		if f == "-" {
+			b, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			b, err = ioutil.ReadFile(f) // nolint: gosec
+		}
+		if err != nil {
+			return fmt.Errorf("could not read %s: %v", f, err)
+		}
+		fmt.Printf("%s:\n", f)
+		m.Explain(os.Stdout, string(b))
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTorSocks   = "127.0.0.1:9050"
+	defaultTorControl = "127.0.0.1:9051"
+)
+
+// setupTor sends the requests to the source through the tor socks proxy.
+// The hostnames are resolved by tor.
+func setupTor(c torConfig) error {
+	socks := c.Socks
+	if socks == "" {
+		socks = defaultTorSocks
+	}
+	if _, _, err := net.SplitHostPort(socks); err != nil {
+		return fmt.Errorf("invalid tor socks address %q: %v", socks, err)
+	}
+	sourceTransport.Proxy = http.ProxyURL(&url.URL{Scheme: "socks5h", Host: socks})
+	return nil
+}
+
+// torNewCircuit signals tor on the control port to use new circuits for
+// new connections
+func torNewCircuit(ctx context.Context, c torConfig) error {
+	addr := c.Control
+	if addr == "" {
+		addr = defaultTorControl
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("could not connect to tor control port: %v", err)
+	}
+	defer conn.Close()                                 // nolint: errcheck
+	conn.SetDeadline(time.Now().Add(10 * time.Second)) // nolint: errcheck,gosec
+
+	auth := "AUTHENTICATE"
+	switch {
+	case c.Password != "":
+		auth += fmt.Sprintf(" %q", c.Password)
+	case c.CookieFile != "":
+		cookie, err := ioutil.ReadFile(c.CookieFile)
+		if err != nil {
+			return fmt.Errorf("could not read tor cookie: %v", err)
+		}
+		auth += " " + hex.EncodeToString(cookie)
+	}
+	r := bufio.NewReader(conn)
+	for _, cmd := range []string{auth, "SIGNAL NEWNYM"} {
+		if _, err := fmt.Fprintf(conn, "%s\r\n", cmd); err != nil {
+			return fmt.Errorf("could not send tor command: %v", err)
+		}
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("could not read tor reply: %v", err)
+		}
+		if !strings.HasPrefix(line, "250") {
+			return fmt.Errorf("tor command %s failed: %s", strings.Fields(cmd)[0], strings.TrimSpace(line))
+		}
+	}
+	fmt.Fprint(conn, "QUIT\r\n") // nolint: errcheck,gosec
+	return nil
+}
+
+// rotateTorCircuits requests new circuits every interval until the
+// context is canceled. Idle connections are closed so the next requests
+// use the new circuits.
+func rotateTorCircuits(ctx context.Context, c torConfig, interval time.Duration, errs chan<- error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := torNewCircuit(ctx, c); err != nil {
+				if ctx.Err() == nil {
+					errs <- fmt.Errorf("tor: %v", err)
+				}
+				continue
+			}
+			sourceTransport.CloseIdleConnections()
+			slog.Debug("rotated tor circuits")
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path"
+	"strings"
+	"testing"
+)
+
+// torControl is a fake tor control port accepting the given auth line
+func torControl(t *testing.T, auth string) (string, chan []string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() }) // nolint: errcheck
+	cmds := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close() // nolint: errcheck
+		var got []string
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimSpace(line)
+			got = append(got, line)
+			switch {
+			case strings.HasPrefix(line, "AUTHENTICATE") && line != auth:
+				fmt.Fprint(conn, "515 Authentication failed\r\n")
+			case line == "QUIT":
+				fmt.Fprint(conn, "250 closing connection\r\n")
+				cmds <- got
+				return
+			default:
+				fmt.Fprint(conn, "250 OK\r\n")
+			}
+		}
+		cmds <- got
+	}()
+	return l.Addr().String(), cmds
+}
+
+func TestTorNewCircuit(t *testing.T) {
+	addr, cmds := torControl(t, `AUTHENTICATE "secret"`)
+	if err := torNewCircuit(context.Background(), torConfig{Control: addr, Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	got := <-cmds
+	if len(got) != 3 || got[1] != "SIGNAL NEWNYM" {
+		t.Fatalf("unexpected commands %v", got)
+	}
+}
+
+func TestTorNewCircuitCookie(t *testing.T) {
+	cookie := path.Join(t.TempDir(), "control_auth_cookie")
+	if err := ioutil.WriteFile(cookie, []byte{0xde, 0xad, 0xbe, 0xef}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	addr, _ := torControl(t, "AUTHENTICATE deadbeef")
+	if err := torNewCircuit(context.Background(), torConfig{Control: addr, CookieFile: cookie}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTorNewCircuitAuthFailed(t *testing.T) {
+	addr, _ := torControl(t, `AUTHENTICATE "secret"`)
+	err := torNewCircuit(context.Background(), torConfig{Control: addr, Password: "wrong"})
+	if err == nil || !strings.Contains(err.Error(), "515") {
+		t.Fatalf("expected authentication error, got %v", err)
+	}
+}
+
+func TestSetupTor(t *testing.T) {
+	defer setupProxy("") // nolint: errcheck
+	if err := setupTor(torConfig{Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := setupTor(torConfig{Enabled: true, Socks: "invalid"}); err == nil {
+		t.Fatal("expected error for an invalid socks address")
+	}
+}
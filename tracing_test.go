@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingFetch(t *testing.T) {
+	unpaced(t)
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	h := httpServer(t, "password")
+	defer h.Close()
+	p := paste{Key: "abc", ScrapeURL: h.URL}
+	p2, err := p.fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	request, fetch := spans[0], spans[1]
+	if request.Name() != "http request" || fetch.Name() != "fetch paste" {
+		t.Fatalf("unexpected spans %q %q", request.Name(), fetch.Name())
+	}
+	if request.Parent().SpanID() != fetch.SpanContext().SpanID() {
+		t.Error("expected the request to be a child of the fetch")
+	}
+
+	m, err := newMatcher(configuration{Keywords: []keyword{{Keyword: "password"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2.spanContext = fetch.SpanContext()
+	p2.scan(m)
+	spans = recorder.Ended()
+	if len(spans) != 3 || spans[2].Name() != "match" || spans[2].Parent().TraceID() != fetch.SpanContext().TraceID() {
+		t.Fatalf("expected a match span in the same trace, got %d spans", len(spans))
+	}
+}
+
+func TestSetupTracingDisabled(t *testing.T) {
+	shutdown, err := setupTracing(context.Background(), tracingConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	vaultPrefix = "vault:"
+	// retry interval when refreshing the secrets failed
+	vaultRetryInterval = 1 * time.Minute
+)
+
+// vaultSecret is the response of a secret read. Version 2 of the KV
+// engine nests the values in another data object.
+type vaultSecret struct {
+	LeaseDuration int                    `json:"lease_duration"`
+	Data          map[string]interface{} `json:"data"`
+	Errors        []string               `json:"errors"`
+}
+
+// vaultClient reads secrets from the HTTP API of HashiCorp Vault
+type vaultClient struct {
+	address   string
+	token     string
+	namespace string
+	// secrets already read during this resolve, per path
+	cache map[string]vaultSecret
+}
+
+func newVaultClient(c vault) (*vaultClient, error) {
+	v := &vaultClient{
+		address:   c.Address,
+		token:     c.Token,
+		namespace: c.Namespace,
+		cache:     make(map[string]vaultSecret),
+	}
+	if v.address == "" {
+		v.address = os.Getenv("VAULT_ADDR")
+	}
+	if v.token == "" && c.TokenFile != "" {
+		b, err := ioutil.ReadFile(c.TokenFile) // nolint: gosec
+		if err != nil {
+			return nil, fmt.Errorf("could not read vault token file: %v", err)
+		}
+		v.token = strings.TrimSpace(string(b))
+	}
+	if v.token == "" {
+		v.token = os.Getenv("VAULT_TOKEN")
+	}
+	if v.address == "" {
+		return nil, fmt.Errorf("no vault address configured")
+	}
+	if v.token == "" {
+		return nil, fmt.Errorf("no vault token configured")
+	}
+	v.address = strings.TrimRight(v.address, "/")
+	return v, nil
+}
+
+func (v *vaultClient) read(ctx context.Context, p string) (vaultSecret, error) {
+	if s, ok := v.cache[p]; ok {
+		return s, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v1/%s", v.address, strings.TrimLeft(p, "/")), nil)
+	if err != nil {
+		return vaultSecret{}, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return vaultSecret{}, err
+	}
+	body, err := httpRespBodyToString(resp)
+	if err != nil {
+		return vaultSecret{}, err
+	}
+	var s vaultSecret
+	if err := json.Unmarshal([]byte(body), &s); err != nil {
+		return vaultSecret{}, fmt.Errorf("could not decode response for %s: %v", p, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return vaultSecret{}, fmt.Errorf("could not read %s: status %d: %s", p, resp.StatusCode, strings.Join(s.Errors, ", "))
+	}
+	// kv version 2
+	if data, ok := s.Data["data"].(map[string]interface{}); ok {
+		if _, ok := s.Data["metadata"]; ok {
+			s.Data = data
+		}
+	}
+	v.cache[p] = s
+	return s, nil
+}
+
+// get resolves a reference in the form secret/path#key
+func (v *vaultClient) get(ctx context.Context, ref string) (string, time.Duration, error) {
+	parts := strings.SplitN(ref, "#", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", 0, fmt.Errorf("invalid vault reference %q, expected path#key", ref)
+	}
+	s, err := v.read(ctx, parts[0])
+	if err != nil {
+		return "", 0, err
+	}
+	value, ok := s.Data[parts[1]]
+	if !ok {
+		return "", 0, fmt.Errorf("key %q not found in %s", parts[1], parts[0])
+	}
+	str, ok := value.(string)
+	if !ok {
+		return "", 0, fmt.Errorf("key %q in %s is not a string", parts[1], parts[0])
+	}
+	return str, time.Duration(s.LeaseDuration) * time.Second, nil
+}
+
+// refreshSecrets reloads the config before the leases of the vault
+// secrets expire
+func refreshSecrets(ctx context.Context, live *liveConfig, errs chan<- error) {
+	c, _ := live.get()
+	wait := c.secretsLease * 3 / 4
+	for wait > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		slog.Info("refreshing vault secrets")
+		c, err := live.reload()
+		if err != nil {
+			errs <- fmt.Errorf("refreshSecrets: %v", err)
+			wait = vaultRetryInterval
+			continue
+		}
+		wait = c.secretsLease * 3 / 4
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors": ["permission denied"]}`)) // nolint: errcheck,gosec
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/pastebin":
+			w.Write([]byte(`{"lease_duration": 0, "data": {"data": {"password": "kv2"}, "metadata": {"version": 1}}}`)) // nolint: errcheck,gosec
+		case "/v1/database/creds/scraper":
+			w.Write([]byte(`{"lease_duration": 3600, "data": {"username": "user", "password": "dynamic"}}`)) // nolint: errcheck,gosec
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": []}`)) // nolint: errcheck,gosec
+		}
+	}))
+	defer ts.Close()
+
+	c := configuration{
+		Mailserver: "localhost",
+		Vault:      vault{Address: ts.URL, Token: "token"},
+		Elasticsearch: elasticsearch{
+			Username: "vault:database/creds/scraper#username",
+			Password: "vault:secret/data/pastebin#password",
+		},
+		Database: database{DSN: "vault:database/creds/scraper#password"},
+		Groups:   map[string]group{"brand": {Mailto: "vault:secret/data/pastebin#password"}},
+	}
+	if err := resolveSecrets(context.Background(), &c); err != nil {
+		t.Fatalf("got error when resolving secrets: %v", err)
+	}
+	if c.Mailserver != "localhost" {
+		t.Errorf("expected plain values to be kept, got %q", c.Mailserver)
+	}
+	if c.Elasticsearch.Username != "user" || c.Elasticsearch.Password != "kv2" || c.Database.DSN != "dynamic" {
+		t.Errorf("secrets were not resolved: %+v %+v", c.Elasticsearch, c.Database)
+	}
+	if c.Groups["brand"].Mailto != "kv2" {
+		t.Errorf("expected secrets in maps to be resolved, got %q", c.Groups["brand"].Mailto)
+	}
+	if c.secretsLease != time.Hour {
+		t.Errorf("expected lease of 1h, got %v", c.secretsLease)
+	}
+
+	tt := []struct {
+		ref   string
+		token string
+		err   string
+	}{
+		{ref: "vault:secret/data/pastebin", token: "token", err: "invalid vault reference"},
+		{ref: "vault:secret/data/pastebin#missing", token: "token", err: "not found"},
+		{ref: "vault:secret/data/other#key", token: "token", err: "status 404"},
+		{ref: "vault:secret/data/pastebin#password", token: "invalid", err: "permission denied"},
+	}
+	for _, x := range tt {
+		c := configuration{Mailserver: x.ref, Vault: vault{Address: ts.URL, Token: x.token}}
+		err := resolveSecrets(context.Background(), &c)
+		if err == nil || !strings.Contains(err.Error(), x.err) {
+			t.Errorf("%s: expected error containing %q, got %v", x.ref, x.err, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// the public api allows 4 requests per minute
+	defaultVirusTotalRate = 4
+	// a paste full of hashes must not use up the daily quota of the public
+	// api on its own, other indicators are only shown if cached
+	defaultVirusTotalLookups = 4
+)
+
+var (
+	virusTotalEndpoint = "https://www.virustotal.com/api/v3"
+
+	virusTotal = &virusTotalClient{
+		pace:  newPacer(time.Minute/defaultVirusTotalRate, time.Minute/defaultVirusTotalRate, 0),
+		cache: newLookupCache[virusTotalResult](defaultEnrichCacheTTL),
+	}
+)
+
+// virusTotalResult is the detection ratio of a hash or url
+type virusTotalResult struct {
+	Indicator  string `json:"indicator"`
+	Type       string `json:"type"`
+	Found      bool   `json:"found"`
+	Malicious  int    `json:"malicious"`
+	Suspicious int    `json:"suspicious"`
+	Total      int    `json:"total"`
+}
+
+func (r virusTotalResult) String() string {
+	if !r.Found {
+		return fmt.Sprintf("%s: not found", r.Indicator)
+	}
+	s := fmt.Sprintf("%s: %d/%d malicious", r.Indicator, r.Malicious, r.Total)
+	if r.Suspicious > 0 {
+		s += fmt.Sprintf(", %d suspicious", r.Suspicious)
+	}
+	return s
+}
+
+// virusTotalClient looks up hashes and urls spacing the requests to the
+// rate limit of the api key
+type virusTotalClient struct {
+	pace  *pacer
+	cache *lookupCache[virusTotalResult]
+}
+
+// lookup returns the detection ratios of the hashes and urls. Cached
+// results are always returned, at most maxlookups are sent to the api.
+func (v *virusTotalClient) lookup(ctx context.Context, c virusTotalConfig, iocs *iocSummary) []virusTotalResult {
+	rate := c.Requestsperminute
+	if rate <= 0 {
+		rate = defaultVirusTotalRate
+	}
+	v.pace.configure(time.Minute/time.Duration(rate), time.Minute/time.Duration(rate), 0)
+	maxLookups := c.Maxlookups
+	if maxLookups <= 0 {
+		maxLookups = defaultVirusTotalLookups
+	}
+	type indicator struct{ kind, value string }
+	var indicators []indicator
+	for _, h := range iocs.Hashes {
+		indicators = append(indicators, indicator{"file", h})
+	}
+	for _, u := range iocs.URLs {
+		indicators = append(indicators, indicator{"url", u})
+	}
+	var ret []virusTotalResult
+	lookups := 0
+	for _, i := range indicators {
+		if r, ok := v.cache.get(i.kind+":"+i.value, time.Now()); ok {
+			ret = append(ret, r)
+			continue
+		}
+		if lookups >= maxLookups || !v.pace.wait(ctx) {
+			continue
+		}
+		lookups++
+		metricEnrichLookups.inc("virustotal")
+		r, err := v.get(ctx, c.APIKey, i.kind, i.value)
+		if err != nil {
+			metricEnrichErrors.inc("virustotal")
+			logger(componentNotifier).Warn("virustotal lookup failed", "indicator", i.value, "error", err)
+			continue
+		}
+		v.cache.put(i.kind+":"+i.value, r, time.Now())
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+func (v *virusTotalClient) get(ctx context.Context, apiKey, kind, value string) (virusTotalResult, error) {
+	r := virusTotalResult{Indicator: value, Type: kind}
+	path := "/files/" + url.PathEscape(value)
+	if kind == "url" {
+		path = "/urls/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, virusTotalEndpoint+path, nil)
+	if err != nil {
+		return r, fmt.Errorf("could not create request: %v", err)
+	}
+	req.Header.Set("x-apikey", apiKey)
+	resp, err := enrichClient.Do(req)
+	if err != nil {
+		return r, fmt.Errorf("could not query virustotal: %v", err)
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		io.Copy(ioutil.Discard, resp.Body) // nolint: errcheck,gosec
+		return r, nil
+	default:
+		return r, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var body struct {
+		Data struct {
+			Attributes struct {
+				Stats map[string]int `json:"last_analysis_stats"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return r, fmt.Errorf("could not decode response: %v", err)
+	}
+	r.Found = true
+	stats := body.Data.Attributes.Stats
+	r.Malicious, r.Suspicious = stats["malicious"], stats["suspicious"]
+	for _, n := range stats {
+		r.Total += n
+	}
+	return r, nil
+}
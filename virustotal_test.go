@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVirusTotalLookup(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-apikey") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requests = append(requests, r.URL.Path)
+		switch r.URL.Path {
+		case "/files/d41d8cd98f00b204e9800998ecf8427e":
+			w.Write([]byte(`{"data":{"attributes":{"last_analysis_stats":{"malicious":3,"suspicious":1,"undetected":6}}}}`)) // nolint: errcheck
+		case "/urls/" + base64.RawURLEncoding.EncodeToString([]byte("http://evil.example.com/")):
+			w.Write([]byte(`{"data":{"attributes":{"last_analysis_stats":{"malicious":0,"harmless":5}}}}`)) // nolint: errcheck
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	old := virusTotalEndpoint
+	virusTotalEndpoint = ts.URL
+	defer func() { virusTotalEndpoint = old }()
+
+	v := &virusTotalClient{pace: newPacer(0, 0, 0), cache: newLookupCache[virusTotalResult](time.Hour)}
+	iocs := &iocSummary{
+		Hashes: []string{"d41d8cd98f00b204e9800998ecf8427e", "da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+		URLs:   []string{"http://evil.example.com/", "http://other.example.com/"},
+	}
+	c := virusTotalConfig{APIKey: "key", Requestsperminute: 6000, Maxlookups: 3}
+	got := v.lookup(context.Background(), c, iocs)
+	expected := []virusTotalResult{
+		{Indicator: "d41d8cd98f00b204e9800998ecf8427e", Type: "file", Found: true, Malicious: 3, Suspicious: 1, Total: 10},
+		{Indicator: "da39a3ee5e6b4b0d3255bfef95601890afd80709", Type: "file"},
+		{Indicator: "http://evil.example.com/", Type: "url", Found: true, Total: 5},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected results:\n%+v\nexpected\n%+v", got, expected)
+	}
+	if got[0].String() != "d41d8cd98f00b204e9800998ecf8427e: 3/10 malicious, 1 suspicious" || !strings.HasSuffix(got[1].String(), "not found") {
+		t.Errorf("unexpected formatting %q, %q", got[0], got[1])
+	}
+
+	// the cached results do not count against the lookups
+	requests = nil
+	if got := v.lookup(context.Background(), c, iocs); len(got) != 4 {
+		t.Errorf("expected all indicators with the cache, got %+v", got)
+	}
+	if len(requests) != 1 {
+		t.Errorf("expected only the uncached indicator to be looked up, got %v", requests)
+	}
+
+	c.APIKey = "wrong"
+	v.cache = newLookupCache[virusTotalResult](time.Hour)
+	if got := v.lookup(context.Background(), c, iocs); len(got) != 0 {
+		t.Errorf("expected failed lookups to be left out, got %+v", got)
+	}
+}
+
+func TestLookupCache(t *testing.T) {
+	c := newLookupCache[int](time.Minute)
+	now := time.Now()
+	c.put("a", 1, now)
+	if v, ok := c.get("a", now.Add(time.Second)); !ok || v != 1 {
+		t.Errorf("expected cached value, got %d %v", v, ok)
+	}
+	if _, ok := c.get("a", now.Add(2*time.Minute)); ok {
+		t.Error("expected the entry to expire")
+	}
+}
+
+func TestEnrichmentString(t *testing.T) {
+	p := paste{
+		Matches:    map[string][]string{"a": {"a"}},
+		Enrichment: &enrichment{VirusTotal: []virusTotalResult{{Indicator: "http://x.example.com/", Found: true, Malicious: 2, Total: 4}}},
+	}
+	if s := p.String(); !strings.Contains(s, "\nVirusTotal:\n  http://x.example.com/: 2/4 malicious\n") {
+		t.Errorf("expected the detection ratio in the alert, got:\n%s", s)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	htmltemplate "html/template"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// weeks shown in the sparklines of the weekly report, the report
+	// history must cover them
+	reportWeeks = 8
+)
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws the values as a line of block characters scaled to the
+// largest value
+func sparkline(values []int) string {
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if max > 0 {
+			i = v * (len(sparkBlocks) - 1) / max
+		}
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
+
+type weeklyKeyword struct {
+	Keyword string
+	Hits    int
+	// change to the week before
+	Change int
+	// average of the weeks before
+	Average float64
+	// hits per week, oldest first
+	Weeks     []int
+	Sparkline string
+}
+
+// weeklyReport compares the last week with the weeks before
+type weeklyReport struct {
+	Start    time.Time
+	End      time.Time
+	Scanned  int
+	Matches  int
+	Change   int
+	Weeks    []int
+	Keywords []weeklyKeyword
+}
+
+// buildWeeklyReport sums up the seven days before end and the weeks
+// before them
+func buildWeeklyReport(h *reportHistory, end time.Time) weeklyReport {
+	r := weeklyReport{Start: end.AddDate(0, 0, -7), End: end.AddDate(0, 0, -1), Weeks: make([]int, reportWeeks)}
+	scanned := make([]int, reportWeeks)
+	hits := map[string][]int{}
+	for w := 0; w < reportWeeks; w++ {
+		start := end.AddDate(0, 0, -7*(reportWeeks-w))
+		for i := 0; i < 7; i++ {
+			d := h.get(start.AddDate(0, 0, i))
+			scanned[w] += d.Scanned
+			r.Weeks[w] += d.Matches
+			for k, v := range d.Hits {
+				if hits[k] == nil {
+					hits[k] = make([]int, reportWeeks)
+				}
+				hits[k][w] += v
+			}
+		}
+	}
+	last := reportWeeks - 1
+	r.Scanned = scanned[last]
+	r.Matches = r.Weeks[last]
+	r.Change = r.Weeks[last] - r.Weeks[last-1]
+	for k, weeks := range hits {
+		sum := 0
+		for _, v := range weeks[:last] {
+			sum += v
+		}
+		r.Keywords = append(r.Keywords, weeklyKeyword{
+			Keyword:   k,
+			Hits:      weeks[last],
+			Change:    weeks[last] - weeks[last-1],
+			Average:   float64(sum) / float64(last),
+			Weeks:     weeks,
+			Sparkline: sparkline(weeks),
+		})
+	}
+	sort.Slice(r.Keywords, func(i, j int) bool {
+		if r.Keywords[i].Hits != r.Keywords[j].Hits {
+			return r.Keywords[i].Hits > r.Keywords[j].Hits
+		}
+		return r.Keywords[i].Keyword < r.Keywords[j].Keyword
+	})
+	return r
+}
+
+var markdownWeekly = template.Must(template.New("weekly").Funcs(reportFuncs).Parse(`# Pastebin Scraper weekly trends {{date .Start}} to {{date .End}}
+
+- Pastes scanned: {{.Scanned}}
+- Matches: {{.Matches}} ({{signed .Change}} to the week before)
+- Last {{len .Weeks}} weeks: {{spark .Weeks}}
+
+## Matches per keyword
+{{if .Keywords}}
+| Keyword | Matches | Change | Average | Last {{len .Weeks}} weeks |
+|---|---:|---:|---:|---|
+{{range .Keywords}}| {{.Keyword}} | {{.Hits}} | {{signed .Change}} | {{printf "%.1f" .Average}} | {{.Sparkline}} |
+{{end}}{{else}}
+No matches.
+{{end}}`))
+
+var htmlWeekly = htmltemplate.Must(htmltemplate.New("weekly").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Pastebin Scraper weekly trends {{date .Start}} to {{date .End}}</title></head>
+<body style="font-family: sans-serif">
+<h1>Pastebin Scraper weekly trends {{date .Start}} to {{date .End}}</h1>
+<p>Pastes scanned: {{.Scanned}}<br>Matches: {{.Matches}} ({{signed .Change}} to the week before)<br>Last {{len .Weeks}} weeks: <span style="font-family: monospace">{{spark .Weeks}}</span></p>
+<h2>Matches per keyword</h2>
+{{if .Keywords}}<table border="1" cellpadding="4" style="border-collapse: collapse">
+<tr><th>Keyword</th><th>Matches</th><th>Change</th><th>Average</th><th>Last {{len .Weeks}} weeks</th></tr>
+{{range .Keywords}}<tr><td>{{.Keyword}}</td><td align="right">{{.Hits}}</td><td align="right">{{signed .Change}}</td><td align="right">{{printf "%.1f" .Average}}</td><td style="font-family: monospace" title="{{.Weeks}}">{{.Sparkline}}</td></tr>
+{{end}}</table>{{else}}<p>No matches.</p>{{end}}
+</body>
+</html>
+`))
+
+// render formats the report as markdown or html
+func (r weeklyReport) render(format string) (string, error) {
+	return renderReport(format, markdownWeekly, htmlWeekly, r)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSparkline(t *testing.T) {
+	if s := sparkline([]int{0, 1, 7, 14}); s != "▁▁▄█" {
+		t.Errorf("unexpected sparkline %q", s)
+	}
+	if s := sparkline([]int{0, 0}); s != "▁▁" {
+		t.Errorf("unexpected sparkline %q", s)
+	}
+}
+
+func TestWeeklyReport(t *testing.T) {
+	// a monday
+	end := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	h := newReportHistory(time.UTC)
+	h.addScanned(end.AddDate(0, 0, -1))
+	h.addMatch(paste{Key: "a", Matches: map[string][]string{"admin": {}}}, 1, end.AddDate(0, 0, -1))
+	h.addMatch(paste{Key: "b", Matches: map[string][]string{"admin": {}}}, 1, end.AddDate(0, 0, -7))
+	h.addMatch(paste{Key: "c", Matches: map[string][]string{"password": {}}}, 1, end.AddDate(0, 0, -8))
+	h.addMatch(paste{Key: "d", Matches: map[string][]string{"password": {}}}, 1, end.AddDate(0, 0, -8*7))
+	// the current week is not part of the report
+	h.addMatch(paste{Key: "e", Matches: map[string][]string{"admin": {}}}, 1, end)
+
+	r := buildWeeklyReport(h, end)
+	if r.Scanned != 1 || r.Matches != 2 || r.Change != 1 || reportDate(r.Start) != "2026-10-05" || reportDate(r.End) != "2026-10-11" {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	if !reflect.DeepEqual(r.Weeks, []int{1, 0, 0, 0, 0, 0, 1, 2}) {
+		t.Errorf("unexpected weeks %v", r.Weeks)
+	}
+	if len(r.Keywords) != 2 {
+		t.Fatalf("unexpected keywords %+v", r.Keywords)
+	}
+	admin := r.Keywords[0]
+	if admin.Keyword != "admin" || admin.Hits != 2 || admin.Change != 2 || admin.Average != 0 || admin.Sparkline != "▁▁▁▁▁▁▁█" {
+		t.Errorf("unexpected admin trend %+v", admin)
+	}
+	password := r.Keywords[1]
+	if password.Hits != 0 || password.Change != -1 || password.Average != 2.0/7 {
+		t.Errorf("unexpected password trend %+v", password)
+	}
+
+	md, err := r.render("markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(md, "| admin | 2 | +2 | 0.0 | ▁▁▁▁▁▁▁█ |") {
+		t.Errorf("unexpected markdown report %s", md)
+	}
+	html, err := r.render("html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html, "weekly trends 2026-10-05 to 2026-10-11") {
+		t.Errorf("unexpected html report %s", html)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"sync"
+)
+
+const (
+	defaultWorkers = 1
+)
+
+// fetchPool processes the pastes with n concurrent workers. The workers
+// share the api pace so more workers only help if fetching a paste takes
+// longer than the delay. Once stop returns true the pastes not started
+// yet are passed to skip instead.
+func fetchPool(n int, pastes []paste, stop func() bool, process func(p paste), skip func(p paste)) {
+	if n < 1 {
+		n = 1
+	}
+	work := make(chan paste)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range work {
+				process(p)
+			}
+		}()
+	}
+	for i, p := range pastes {
+		if stop() {
+			for _, p := range pastes[i:] {
+				skip(p)
+			}
+			break
+		}
+		work <- p
+	}
+	close(work)
+	wg.Wait()
+}
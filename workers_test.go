@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchPool(t *testing.T) {
+	var pastes []paste
+	for i := 0; i < 20; i++ {
+		pastes = append(pastes, paste{Key: fmt.Sprintf("p%d", i)})
+	}
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	var running, most int32
+	fetchPool(4, pastes, func() bool { return false }, func(p paste) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		mu.Lock()
+		seen[p.Key] = true
+		if n > most {
+			most = n
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}, func(p paste) {
+		t.Errorf("unexpected skip of %s", p.Key)
+	})
+	if len(seen) != len(pastes) {
+		t.Fatalf("expected all %d pastes to be processed, got %d", len(pastes), len(seen))
+	}
+	if most < 2 || most > 4 {
+		t.Fatalf("expected up to 4 concurrent workers, got %d", most)
+	}
+}
+
+func TestFetchPoolStop(t *testing.T) {
+	pastes := []paste{{Key: "a"}, {Key: "b"}, {Key: "c"}}
+	var processed, skipped []string
+	var mu sync.Mutex
+	fetchPool(1, pastes, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(processed) > 0
+	}, func(p paste) {
+		mu.Lock()
+		processed = append(processed, p.Key)
+		mu.Unlock()
+	}, func(p paste) {
+		skipped = append(skipped, p.Key)
+	})
+	// the first paste may still be handed out before the worker finished
+	if len(processed)+len(skipped) != 3 || len(skipped) == 0 {
+		t.Fatalf("expected the remaining pastes to be skipped, got %v %v", processed, skipped)
+	}
+}